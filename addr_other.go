@@ -0,0 +1,15 @@
+//go:build !windows
+
+package main
+
+import (
+	"syscall"
+
+	"github.com/pkg/errors"
+)
+
+// isAddrInUse reports whether err was caused by the address already being in
+// use.
+func isAddrInUse(err error) bool {
+	return errors.Is(err, syscall.EADDRINUSE)
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestListenAddrInUse(t *testing.T) {
+	first, err := listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer first.Close()
+
+	_, err = net.Listen("tcp", first.Addr().String())
+	if !isAddrInUse(err) {
+		t.Fatalf("isAddrInUse(%v) = false, want true", err)
+	}
+	if _, err := listen(first.Addr().String()); err == nil || !strings.Contains(err.Error(), "already in use") {
+		t.Errorf("listen() = %v, want a hint that the address is in use", err)
+	}
+}
@@ -0,0 +1,19 @@
+//go:build windows
+
+package main
+
+import (
+	"syscall"
+
+	"github.com/pkg/errors"
+)
+
+// _wsaeaddrinuse is the Winsock error returned when an address is already in
+// use. syscall.EADDRINUSE is a different, invented errno on Windows.
+const _wsaeaddrinuse syscall.Errno = 10048
+
+// isAddrInUse reports whether err was caused by the address already being in
+// use.
+func isAddrInUse(err error) bool {
+	return errors.Is(err, _wsaeaddrinuse) || errors.Is(err, syscall.EADDRINUSE)
+}
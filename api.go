@@ -0,0 +1,411 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/ajeetdsouza/clidle/pkg/game"
+	"github.com/ajeetdsouza/clidle/store"
+	"github.com/pkg/errors"
+)
+
+// _apiDescription describes the JSON API served with --api-addr. It is shown
+// in the usage, and served at the root of the API.
+const _apiDescription = `JSON API (--api-addr):
+  POST /games               Creates a game. Body (optional): {"ultra_hard": bool}.
+                            Returns the game (201).
+  GET  /games/{id}          Returns a game.
+  POST /games/{id}/guesses  Makes a guess. Body: {"guess": "crane"}.
+                            Returns the game, with the feedback for every guess.
+                            Invalid guesses are rejected (422), and so are
+                            guesses once the game is over (409).
+  GET  /stats               Returns the stats of every game played, as printed
+                            by clidle stats --json.
+
+  A game is {"id", "state" (in_progress, won, lost), "guesses": [{"guess",
+  "feedback": [absent, present, correct, ...], "pattern"}], "remaining_guesses",
+  "score"}, along with the "answer" once it is over. Errors are {"error"}.
+`
+
+// _apiGameIdle is how long a game is kept in memory without being played or
+// fetched, before it is forgotten.
+const _apiGameIdle = time.Hour
+
+// _apiFinishedGameLinger is how long a finished game is kept in memory, so
+// that its final state can still be fetched.
+const _apiFinishedGameLinger = 5 * time.Minute
+
+// _apiEvictInterval is how often forgotten games are evicted.
+const _apiEvictInterval = time.Minute
+
+// api serves the game engine over HTTP as JSON, for frontends other than the
+// terminal. Games in progress are kept in memory, and saved like any other
+// game, marked as played through the API.
+type api struct {
+	store      *store.Queries
+	dictionary Dictionary
+	options    options
+	clock      clock
+
+	mu    sync.Mutex
+	games map[string]*apiGame
+}
+
+func newAPI(store *store.Queries, dictionary Dictionary, options options, clock clock) *api {
+	return &api{
+		store:      store,
+		dictionary: dictionary,
+		options:    options,
+		clock:      clock,
+		games:      make(map[string]*apiGame),
+	}
+}
+
+// apiGame is a game created through the API. Its guesses are made one at a
+// time, under its lock, so that they are saved in order.
+type apiGame struct {
+	uuid   string
+	record int64
+	// expires is when the game is evicted, and finished is set once it is
+	// over. Both are guarded by the lock of the api.
+	expires  time.Time
+	finished bool
+
+	mu   sync.Mutex
+	game *game.Game
+}
+
+// apiGameState is a game, as returned by the API. The answer is left out until
+// the game is over.
+type apiGameState struct {
+	ID               string     `json:"id"`
+	State            string     `json:"state"`
+	Guesses          []apiGuess `json:"guesses"`
+	RemainingGuesses int        `json:"remaining_guesses"`
+	Score            int        `json:"score"`
+	Answer           string     `json:"answer,omitempty"`
+}
+
+// apiGuess is a guess made in a game, along with its feedback.
+type apiGuess struct {
+	Guess    string   `json:"guess"`
+	Feedback []string `json:"feedback"`
+	Pattern  string   `json:"pattern"`
+}
+
+// handler returns the handler serving every endpoint of the API.
+func (a *api) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte(_apiDescription))
+	})
+	mux.HandleFunc("POST /games", a.handleCreateGame)
+	mux.HandleFunc("GET /games/{id}", a.handleGetGame)
+	mux.HandleFunc("POST /games/{id}/guesses", a.handleGuess)
+	mux.HandleFunc("GET /stats", a.handleStats)
+	return mux
+}
+
+func (a *api) handleCreateGame(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		UltraHard *bool `json:"ultra_hard"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeAPIError(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+	}
+	ultraHard := a.options.ultraHard
+	if body.UltraHard != nil {
+		ultraHard = *body.UltraHard
+	}
+
+	uuid, err := newGameUUID()
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "could not create game")
+		return
+	}
+	var answer game.Word
+	copy(answer[:], a.dictionary.GetRandomCommonWord())
+	g := &apiGame{uuid: uuid, game: game.New(answer, a.dictionary)}
+	g.game.SetUltraHard(ultraHard)
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	params := store.CreateGameParams{
+		Answer:    sql.NullString{String: answer.String(), Valid: true},
+		StartedAt: sql.NullTime{Time: a.clock.Now(), Valid: true},
+		MaxRarity: int64(a.options.maxRarity),
+		Uuid:      sql.NullString{String: uuid, Valid: true},
+		Api:       true,
+	}
+	var row store.Game
+	err = store.Retry(ctx, func() (err error) {
+		row, err = a.store.CreateGame(ctx, params)
+		return err
+	})
+	if err != nil {
+		slog.Error("error creating game", slog.Any("error", err))
+		writeAPIError(w, http.StatusInternalServerError, "could not create game")
+		return
+	}
+
+	g.record = row.ID
+	g.expires = a.clock.Now().Add(_apiGameIdle)
+	state := g.state()
+
+	a.mu.Lock()
+	a.games[uuid] = g
+	a.mu.Unlock()
+	writeAPIJSON(w, http.StatusCreated, state)
+}
+
+// get returns a game kept in memory, and keeps it there for longer unless it
+// is over.
+func (a *api) get(id string) (*apiGame, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	g, ok := a.games[id]
+	if ok && !g.finished {
+		g.expires = a.clock.Now().Add(_apiGameIdle)
+	}
+	return g, ok
+}
+
+// linger shortens the time a finished game is kept in memory.
+func (a *api) linger(g *apiGame) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	g.expires = a.clock.Now().Add(_apiFinishedGameLinger)
+	g.finished = true
+}
+
+// evict forgets the games that have expired. They remain saved.
+func (a *api) evict(now time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for id, g := range a.games {
+		if !now.Before(g.expires) {
+			delete(a.games, id)
+		}
+	}
+}
+
+// evictEvery evicts expired games at every tick of the clock, until stop is
+// closed.
+func (a *api) evictEvery(d time.Duration, stop <-chan struct{}) {
+	ticker := a.clock.NewTicker(d)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case now := <-ticker.C():
+				a.evict(now)
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+func (a *api) handleGetGame(w http.ResponseWriter, r *http.Request) {
+	g, ok := a.get(r.PathValue("id"))
+	if !ok {
+		writeAPIError(w, http.StatusNotFound, "game not found")
+		return
+	}
+	g.mu.Lock()
+	state := g.state()
+	g.mu.Unlock()
+	writeAPIJSON(w, http.StatusOK, state)
+}
+
+func (a *api) handleGuess(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Guess string `json:"guess"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	g, ok := a.get(r.PathValue("id"))
+	if !ok {
+		writeAPIError(w, http.StatusNotFound, "game not found")
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	// The guess is made in a copy of the game, which only replaces it once
+	// the guess is saved.
+	next := g.game.Clone()
+	feedback, err := next.Guess(body.Guess)
+	var violation *game.Violation
+	switch {
+	case errors.Is(err, game.ErrGameOver):
+		writeAPIError(w, http.StatusConflict, "game is over")
+		return
+	case errors.As(err, &violation):
+		writeAPIError(w, http.StatusUnprocessableEntity, viewViolation(violation))
+		return
+	case errors.Is(err, game.ErrInvalidLength):
+		writeAPIError(w, http.StatusUnprocessableEntity, "Your guess must be a 5-letter word.")
+		return
+	case err != nil:
+		writeAPIError(w, http.StatusUnprocessableEntity, "That's not a valid word.")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	guesses := next.Guesses()
+	if err := a.saveGuess(ctx, g, guesses[len(guesses)-1], feedback); err != nil {
+		slog.Error("error saving guess", slog.Any("error", err))
+		writeAPIError(w, http.StatusInternalServerError, "could not save guess")
+		return
+	}
+	g.game = next
+
+	if g.game.State() != game.StateInProgress {
+		a.linger(g)
+		if err := a.finishGame(ctx, g); err != nil {
+			slog.Error("error finishing game", slog.Any("error", err))
+			writeAPIError(w, http.StatusInternalServerError, "could not finish game")
+			return
+		}
+	}
+	writeAPIJSON(w, http.StatusOK, g.state())
+}
+
+// saveGuess saves a guess of a game.
+func (a *api) saveGuess(ctx context.Context, g *apiGame, guess game.Word, feedback game.Feedback) error {
+	params := store.CreateGuessParams{
+		GameID:  sql.NullInt64{Int64: g.record, Valid: true},
+		Guess:   sql.NullString{String: guess.String(), Valid: true},
+		Pattern: sql.NullString{String: feedback.Pattern(), Valid: true},
+	}
+	err := store.Retry(ctx, func() error {
+		_, err := a.store.CreateGuess(ctx, params)
+		return err
+	})
+	return errors.Wrap(err, "could not save guess")
+}
+
+// finishGame records the end of a game.
+func (a *api) finishGame(ctx context.Context, g *apiGame) error {
+	finish := store.FinishGameParams{
+		FinishedAt: sql.NullTime{Time: a.clock.Now(), Valid: true},
+		ID:         g.record,
+	}
+	return errors.Wrap(store.Retry(ctx, func() error { return a.store.FinishGame(ctx, finish) }), "could not finish game")
+}
+
+func (a *api) handleStats(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	results, err := a.store.ListGameResults(ctx)
+	if err != nil {
+		slog.Error("error fetching game results", slog.Any("error", err))
+		writeAPIError(w, http.StatusInternalServerError, "could not read games")
+		return
+	}
+	writeAPIJSON(w, http.StatusOK, computeStats(results))
+}
+
+// state returns the game as returned by the API.
+func (g *apiGame) state() apiGameState {
+	state := apiGameState{
+		ID:               g.uuid,
+		State:            "in_progress",
+		Guesses:          make([]apiGuess, len(g.game.Guesses())),
+		RemainingGuesses: _numGuesses - len(g.game.Guesses()),
+		Score:            g.game.Score(),
+	}
+	for i, guess := range g.game.Guesses() {
+		feedback := g.game.Feedbacks()[i]
+		letters := make([]string, len(feedback))
+		for j, letterState := range feedback {
+			letters[j] = letterState.String()
+		}
+		state.Guesses[i] = apiGuess{Guess: guess.String(), Feedback: letters, Pattern: feedback.Pattern()}
+	}
+	switch g.game.State() {
+	case game.StateWon:
+		state.State = "won"
+	case game.StateLost:
+		state.State = "lost"
+	}
+	if state.State != "in_progress" {
+		state.Answer = g.game.Answer().String()
+	}
+	return state
+}
+
+// writeAPIJSON writes a response of the API.
+func writeAPIJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeAPIError writes an error response of the API.
+func writeAPIError(w http.ResponseWriter, status int, message string) {
+	writeAPIJSON(w, status, map[string]string{"error": message})
+}
+
+// runAPI serves the JSON API on the given address until clidle is
+// interrupted.
+func runAPI(addr string, options options) error {
+	openStore := getStore
+	if options.noPersist {
+		openStore = getMemoryStore
+	}
+	queries, err := openStore()
+	if err != nil {
+		return err
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return errors.Wrapf(err, "could not listen on %s", addr)
+	}
+
+	dictionary := EnglishDictionary.WithMaxRarity(options.maxRarity)
+	a := newAPI(queries, dictionary, options, realClock{})
+	stopEvicting := make(chan struct{})
+	defer close(stopEvicting)
+	a.evictEvery(_apiEvictInterval, stopEvicting)
+	server := &http.Server{
+		Handler:           a.handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	done := make(chan os.Signal, 1)
+	signal.Notify(done, os.Interrupt, syscall.SIGTERM)
+
+	slog.Info("starting API", slog.String("address", listener.Addr().String()))
+	go func() {
+		if err := server.Serve(listener); !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("API returned an error", slog.Any("error", err))
+			done <- os.Interrupt
+		}
+	}()
+
+	<-done
+	slog.Info("stopping API")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	return errors.Wrap(server.Shutdown(ctx), "could not shutdown API")
+}
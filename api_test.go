@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ajeetdsouza/clidle/store"
+)
+
+// newTestAPI creates an API backed by an in-memory database, whose games are
+// always TRACE.
+func newTestAPI(t *testing.T) (*api, *httptest.Server) {
+	t.Helper()
+
+	db, err := sql.Open("sqlite", "file::memory:?_time_format=sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	db.SetMaxOpenConns(1)
+	if err := migrate(db); err != nil {
+		t.Fatal(err)
+	}
+
+	dictionary := testDictionary
+	dictionary.commonWords = []string{"TRACE"}
+	options := options{maxRarity: _rarityUncommon}
+	a := newAPI(store.New(db), dictionary, options, newFakeClock(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)))
+	server := httptest.NewServer(a.handler())
+	t.Cleanup(server.Close)
+	return a, server
+}
+
+// doAPI makes a request to the API, and decodes the response into v. It
+// returns the status code.
+func doAPI(t *testing.T, method, url, body string, v any) int {
+	t.Helper()
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		t.Fatalf("%s %s: could not decode response: %v", method, url, err)
+	}
+	return resp.StatusCode
+}
+
+func TestAPIGame(t *testing.T) {
+	a, server := newTestAPI(t)
+
+	var state apiGameState
+	if status := doAPI(t, "POST", server.URL+"/games", "", &state); status != http.StatusCreated {
+		t.Fatalf("POST /games = %d, want %d", status, http.StatusCreated)
+	}
+	if state.State != "in_progress" || state.RemainingGuesses != _numGuesses || state.Answer != "" {
+		t.Fatalf("unexpected new game: %+v", state)
+	}
+	url := server.URL + "/games/" + state.ID
+
+	// Invalid guesses are rejected without using up a guess.
+	var apiErr struct{ Error string }
+	if status := doAPI(t, "POST", url+"/guesses", `{"guess": "zzzzz"}`, &apiErr); status != http.StatusUnprocessableEntity || apiErr.Error == "" {
+		t.Errorf("invalid guess = %d %q, want %d", status, apiErr.Error, http.StatusUnprocessableEntity)
+	}
+	if status := doAPI(t, "POST", url+"/guesses", `{"guess": `, &apiErr); status != http.StatusBadRequest {
+		t.Errorf("malformed guess = %d, want %d", status, http.StatusBadRequest)
+	}
+
+	if status := doAPI(t, "POST", url+"/guesses", `{"guess": "crane"}`, &state); status != http.StatusOK {
+		t.Fatalf("guess = %d, want %d", status, http.StatusOK)
+	}
+	want := []string{"present", "correct", "correct", "absent", "correct"}
+	if len(state.Guesses) != 1 || strings.Join(state.Guesses[0].Feedback, ",") != strings.Join(want, ",") || state.Answer != "" {
+		t.Fatalf("unexpected game after a guess: %+v", state)
+	}
+
+	doAPI(t, "POST", url+"/guesses", `{"guess": "TRACE"}`, &state)
+	if state.State != "won" || state.Answer != "TRACE" || state.Score != 90 {
+		t.Errorf("unexpected game after winning: %+v", state)
+	}
+	if status := doAPI(t, "POST", url+"/guesses", `{"guess": "crane"}`, &apiErr); status != http.StatusConflict {
+		t.Errorf("guess after the game = %d, want %d", status, http.StatusConflict)
+	}
+	if status := doAPI(t, "GET", url, "", &state); status != http.StatusOK || state.State != "won" {
+		t.Errorf("GET game = %d %+v", status, state)
+	}
+	if status := doAPI(t, "GET", server.URL+"/games/missing", "", &apiErr); status != http.StatusNotFound {
+		t.Errorf("GET missing game = %d, want %d", status, http.StatusNotFound)
+	}
+
+	// The game is saved, marked as played through the API, and counted in
+	// the stats.
+	var s stats
+	if status := doAPI(t, "GET", server.URL+"/stats", "", &s); status != http.StatusOK || s.Played != 1 || s.Won != 1 {
+		t.Errorf("GET /stats = %d %+v", status, s)
+	}
+	row, err := a.store.GetGame(context.Background(), 1)
+	if err != nil || !row.Api || !row.FinishedAt.Valid {
+		t.Errorf("expected a finished API game, got %+v, %v", row, err)
+	}
+}
+
+func TestAPIUltraHard(t *testing.T) {
+	_, server := newTestAPI(t)
+
+	var state apiGameState
+	doAPI(t, "POST", server.URL+"/games", `{"ultra_hard": true}`, &state)
+	url := server.URL + "/games/" + state.ID + "/guesses"
+	doAPI(t, "POST", url, `{"guess": "crane"}`, &state)
+
+	var apiErr struct{ Error string }
+	if status := doAPI(t, "POST", url, `{"guess": "crane"}`, &apiErr); status != http.StatusUnprocessableEntity || !strings.Contains(apiErr.Error, "already yellow") {
+		t.Errorf("guess breaking the rules = %d %q, want %d", status, apiErr.Error, http.StatusUnprocessableEntity)
+	}
+}
+
+func TestAPIEviction(t *testing.T) {
+	a, server := newTestAPI(t)
+	clock := a.clock.(*fakeClock)
+
+	var finished, idle, active apiGameState
+	doAPI(t, "POST", server.URL+"/games", "", &finished)
+	doAPI(t, "POST", server.URL+"/games/"+finished.ID+"/guesses", `{"guess": "trace"}`, &finished)
+	doAPI(t, "POST", server.URL+"/games", "", &idle)
+	doAPI(t, "POST", server.URL+"/games", "", &active)
+
+	// Finished games are only kept for a while, so that their final state
+	// can be fetched.
+	clock.Advance(_apiFinishedGameLinger)
+	a.evict(clock.Now())
+	var apiErr struct{ Error string }
+	if status := doAPI(t, "GET", server.URL+"/games/"+finished.ID, "", &apiErr); status != http.StatusNotFound {
+		t.Errorf("GET finished game = %d, want %d", status, http.StatusNotFound)
+	}
+
+	// Games in progress are kept as long as they are played.
+	doAPI(t, "POST", server.URL+"/games/"+active.ID+"/guesses", `{"guess": "crane"}`, &active)
+	clock.Advance(_apiGameIdle - _apiFinishedGameLinger)
+	a.evict(clock.Now())
+	if status := doAPI(t, "GET", server.URL+"/games/"+idle.ID, "", &apiErr); status != http.StatusNotFound {
+		t.Errorf("GET idle game = %d, want %d", status, http.StatusNotFound)
+	}
+	if status := doAPI(t, "GET", server.URL+"/games/"+active.ID, "", &active); status != http.StatusOK {
+		t.Errorf("GET active game = %d, want %d", status, http.StatusOK)
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"sort"
+
+	"github.com/ajeetdsouza/clidle/pkg/game"
+)
+
+// _numAssistKeys is the number of letters highlighted on the keyboard by the
+// key assist.
+const _numAssistKeys = 3
+
+// updateAssistKeys narrows down the possible answers with the feedback for the
+// latest guess, and picks the untried letters that would tell the most about
+// the answer if guessed next. It runs once per guess, so that the keyboard
+// doesn't have to work them out on every frame.
+func (m *model) updateAssistKeys() {
+	m.assistKeys = [26]bool{}
+	if !m.options.assistKeys {
+		return
+	}
+
+	guesses, feedbacks := m.game.Guesses(), m.game.Feedbacks()
+	switch n := len(guesses); {
+	case n == 0:
+		m.candidates = wordsToBytes(m.dictionary.commonWords)
+	default:
+		m.candidates = filterCandidates(m.candidates, guesses[n-1], feedbacks[n-1])
+	}
+	if m.isGameOver() {
+		return
+	}
+
+	type scored struct {
+		letter  byte
+		entropy float64
+	}
+	var letters []scored
+	for letter := byte('A'); letter <= 'Z'; letter++ {
+		if m.keyStates.get(letter) != _keyStateUnselected {
+			continue
+		}
+		if entropy := letterEntropy(m.candidates, letter); entropy > 0 {
+			letters = append(letters, scored{letter: letter, entropy: entropy})
+		}
+	}
+	sort.SliceStable(letters, func(i, j int) bool { return letters[i].entropy > letters[j].entropy })
+	for _, l := range letters[:min(len(letters), _numAssistKeys)] {
+		m.assistKeys[l.letter-'A'] = true
+	}
+}
+
+// keyStateOf returns the state in which a letter key is shown: its state in the
+// game, unless it is highlighted by the key assist.
+func (m *model) keyStateOf(key byte) keyState {
+	state := m.keyStates.get(key)
+	if state == _keyStateUnselected && isAsciiUpper(rune(key)) && m.assistKeys[key-'A'] {
+		return _keyStateSuggested
+	}
+	return state
+}
+
+// letterEntropy computes the entropy of whether the letter is in each of the
+// candidates, i.e. how much guessing it would narrow them down.
+func letterEntropy(candidates []game.Word, letter byte) float64 {
+	var counts [2]int
+	for _, candidate := range candidates {
+		found := 0
+		for _, ch := range candidate {
+			if ch == letter {
+				found = 1
+				break
+			}
+		}
+		counts[found]++
+	}
+	return countsEntropy(counts[:], len(candidates))
+}
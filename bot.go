@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/ajeetdsouza/clidle/pkg/game"
+	"github.com/pkg/errors"
+)
+
+// botFeedback is printed after every line read in bot mode.
+type botFeedback struct {
+	Guess     string   `json:"guess"`
+	Feedback  []string `json:"feedback,omitempty"`
+	Remaining int      `json:"remaining"`
+	Error     string   `json:"error,omitempty"`
+}
+
+// botResult is printed once the game in bot mode is over.
+type botResult struct {
+	Result  string `json:"result"`
+	Answer  string `json:"answer"`
+	Guesses int    `json:"guesses"`
+	Score   int    `json:"score"`
+}
+
+// runBot plays a single game over stdin/stdout using a line protocol. Guesses
+// are read as plain lines, and feedback is written as one JSON object per line.
+func runBot(args []string) error {
+	flags := flag.NewFlagSet("bot", flag.ExitOnError)
+	flagWord := flags.String("word", "", "Uses the given word as the answer instead of a random one")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	dictionary := EnglishDictionary
+	answer := dictionary.GetRandomCommonWord()
+	if word := strings.ToUpper(*flagWord); word != "" {
+		if !dictionary.IsWord(word) {
+			return errors.Errorf("invalid answer: %q", *flagWord)
+		}
+		answer = word
+	}
+	return playBot(os.Stdin, os.Stdout, dictionary, answer)
+}
+
+func playBot(r io.Reader, w io.Writer, dictionary Dictionary, answer string) error {
+	var answerWord game.Word
+	copy(answerWord[:], answer)
+	g := game.New(answerWord, dictionary)
+
+	encoder := json.NewEncoder(w)
+	scanner := bufio.NewScanner(r)
+
+	for g.State() == game.StateInProgress && scanner.Scan() {
+		guess := strings.ToUpper(strings.TrimSpace(scanner.Text()))
+		response := botFeedback{Guess: guess}
+
+		feedback, err := g.Guess(guess)
+		switch {
+		case errors.Is(err, game.ErrInvalidLength):
+			response.Error = "Your guess must be a 5-letter word."
+		case err != nil:
+			response.Error = "That's not a valid word."
+		default:
+			for _, letterState := range feedback {
+				response.Feedback = append(response.Feedback, letterState.String())
+			}
+		}
+		response.Remaining = _numGuesses - len(g.Guesses())
+
+		if err := encoder.Encode(response); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	result := botResult{
+		Answer:  answer,
+		Guesses: len(g.Guesses()),
+		Score:   g.Score(),
+	}
+	switch g.State() {
+	case game.StateWon:
+		result.Result = "win"
+	case game.StateLost:
+		result.Result = "loss"
+	default:
+		result.Result = "abandoned"
+	}
+	return encoder.Encode(result)
+}
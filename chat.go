@@ -0,0 +1,85 @@
+package main
+
+import (
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+const (
+	// _chatMaxLength is the longest chat message that can be sent.
+	_chatMaxLength = 40
+	// _chatInterval is the shortest time between two chat messages from the
+	// same player.
+	_chatInterval = 3 * time.Second
+	// _chatDuration is how long a chat message is shown.
+	_chatDuration = 4 * time.Second
+)
+
+// isChatChar checks if a character can be used in chat messages. Only
+// printable ASCII is allowed, so that messages can't break the opponent's
+// terminal.
+func isChatChar(r rune) bool {
+	return r >= ' ' && r <= '~'
+}
+
+// doStartChat starts typing a chat message to the opponent in a duel.
+func (m *model) doStartChat() tea.Cmd {
+	if m.match == nil || !m.matchView.started {
+		return nil
+	}
+	m.chatting = true
+	m.chatInput = ""
+	return nil
+}
+
+// updateChat handles key presses while a chat message is being typed, so that
+// they don't enter letters into the grid.
+func (m *model) updateChat(msg tea.KeyMsg) tea.Cmd {
+	switch msg.Type {
+	case tea.KeyCtrlC:
+		return m.doExit()
+	case tea.KeyEsc:
+		m.chatting = false
+	case tea.KeyEnter:
+		return m.doSendChat()
+	case tea.KeyBackspace:
+		if len(m.chatInput) > 0 {
+			m.chatInput = m.chatInput[:len(m.chatInput)-1]
+		}
+	case tea.KeySpace:
+		if len(m.chatInput) < _chatMaxLength {
+			m.chatInput += " "
+		}
+	case tea.KeyRunes:
+		for _, r := range msg.Runes {
+			if isChatChar(r) && len(m.chatInput) < _chatMaxLength {
+				m.chatInput += string(r)
+			}
+		}
+	}
+	return nil
+}
+
+// doSendChat sends the chat message that has been typed in to the opponent,
+// unless the player has sent one too recently.
+func (m *model) doSendChat() tea.Cmd {
+	text := strings.TrimSpace(m.chatInput)
+	m.chatting = false
+	if text == "" || m.match == nil {
+		return nil
+	}
+	now := m.clock.Now()
+	if now.Sub(m.lastChatAt) < _chatInterval {
+		return m.setStatus("Wait a moment before sending another message.", 2*time.Second)
+	}
+	m.lastChatAt = now
+	m.match.say(m.seat, text)
+	return m.setStatus("You: "+text, _chatDuration)
+}
+
+// viewChatInput renders the chat message being typed, in place of the status.
+func (m *model) viewChatInput() string {
+	return m.styles.status.Render(truncate("Say: "+m.chatInput+"_", m.windowWidth))
+}
@@ -0,0 +1,43 @@
+package main
+
+import "time"
+
+// clock tells the time, and waits for it to pass. It is injected into the
+// model so that time-based behavior can be tested deterministically.
+type clock interface {
+	Now() time.Time
+	NewTicker(d time.Duration) ticker
+	After(d time.Duration) <-chan time.Time
+}
+
+// ticker delivers ticks at intervals, like time.Ticker.
+type ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// realClock is a clock that follows the wall clock.
+type realClock struct{}
+
+var _ clock = realClock{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) NewTicker(d time.Duration) ticker {
+	return realTicker{time.NewTicker(d)}
+}
+
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// realTicker adapts a time.Ticker to the ticker interface.
+type realTicker struct {
+	*time.Ticker
+}
+
+func (t realTicker) C() <-chan time.Time {
+	return t.Ticker.C
+}
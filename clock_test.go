@@ -0,0 +1,732 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/muesli/termenv"
+)
+
+// fakeClock is a clock that only moves forward when advanced.
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []*fakeWaiter
+}
+
+// fakeWaiter is a pending After channel, or a ticker if interval is set.
+type fakeWaiter struct {
+	at       time.Time
+	interval time.Duration
+	ch       chan time.Time
+}
+
+var _ clock = (*fakeClock)(nil)
+
+func newFakeClock(now time.Time) *fakeClock {
+	return &fakeClock{now: now}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	return c.wait(d, 0).ch
+}
+
+func (c *fakeClock) NewTicker(d time.Duration) ticker {
+	return fakeTicker{clock: c, waiter: c.wait(d, d)}
+}
+
+func (c *fakeClock) wait(d, interval time.Duration) *fakeWaiter {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	w := &fakeWaiter{at: c.now.Add(d), interval: interval, ch: make(chan time.Time, 1)}
+	c.waiters = append(c.waiters, w)
+	return w
+}
+
+// Advance moves the clock forward, firing every waiter that is due in order.
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	target := c.now.Add(d)
+	for {
+		sort.SliceStable(c.waiters, func(i, j int) bool { return c.waiters[i].at.Before(c.waiters[j].at) })
+		if len(c.waiters) == 0 || c.waiters[0].at.After(target) {
+			break
+		}
+		w := c.waiters[0]
+		c.now = w.at
+		select {
+		case w.ch <- c.now:
+		default:
+		}
+		if w.interval > 0 {
+			w.at = w.at.Add(w.interval)
+		} else {
+			c.waiters = c.waiters[1:]
+		}
+	}
+	c.now = target
+}
+
+// fakeTicker is a ticker driven by a fakeClock.
+type fakeTicker struct {
+	clock  *fakeClock
+	waiter *fakeWaiter
+}
+
+func (t fakeTicker) C() <-chan time.Time {
+	return t.waiter.ch
+}
+
+func (t fakeTicker) Stop() {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	for i, w := range t.clock.waiters {
+		if w == t.waiter {
+			t.clock.waiters = append(t.clock.waiters[:i], t.clock.waiters[i+1:]...)
+			return
+		}
+	}
+}
+
+// runAsync runs each command in the background, and delivers its messages in
+// the order in which they are produced.
+func runAsync(cmds ...tea.Cmd) <-chan tea.Msg {
+	msgs := make(chan tea.Msg, len(cmds))
+	for _, cmd := range cmds {
+		go func() { msgs <- cmd() }()
+	}
+	return msgs
+}
+
+func TestNotify(t *testing.T) {
+	m := newTestModel(t, "TRACE", 80, 40)
+	clock := newFakeClock(time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC))
+	m.clock = clock
+
+	// A second flash started before the first ends keeps the status bar
+	// inverted for its full duration.
+	m.options.notify = "flash"
+	first := runAsync(m.notify())
+	clock.Advance(_flashDuration / 2)
+	second := runAsync(m.notify())
+	clock.Advance(_flashDuration / 2)
+	m.Update(<-first)
+	if !m.flashing {
+		t.Fatal("expected the status bar to still be flashing")
+	}
+	if status := m.viewStatus(); status != m.styles.flash.Render(m.status) {
+		t.Errorf("expected the status bar to be inverted, got %q", status)
+	}
+	clock.Advance(_flashDuration / 2)
+	m.Update(<-second)
+	if m.flashing {
+		t.Fatal("expected the flash to have ended")
+	}
+
+	// The bell rings instead through the view, so that it isn't written
+	// alongside the renderer.
+	m.options.notify = "bell"
+	ring := runAsync(m.notify())
+	if !strings.HasSuffix(m.View(), "\a") || m.flashing {
+		t.Fatal("expected the bell to ring without flashing")
+	}
+	clock.Advance(_flashDuration)
+	m.Update(<-ring)
+	if strings.Contains(m.View(), "\a") {
+		t.Fatal("expected the bell to have ended")
+	}
+	typeKeys(m, "cr\n")
+	if !m.ringing {
+		t.Error("expected a rejected guess to ring the bell")
+	}
+	m.ringing = false
+	m.options.notify = "none"
+	typeKeys(m, "\n")
+	if m.ringing || m.flashing {
+		t.Error("expected no notification")
+	}
+}
+
+func TestStatusExpiry(t *testing.T) {
+	m := newTestModel(t, "TRACE", 80, 40)
+	clock := newFakeClock(time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC))
+	m.clock = clock
+
+	msgs := runAsync(
+		m.setStatus("first", 1*time.Second),
+		m.setStatus("second", 2*time.Second),
+	)
+
+	// The first status expires while the second is still showing, so the
+	// second must stay.
+	clock.Advance(1 * time.Second)
+	m.Update(<-msgs)
+	if m.status != "second" {
+		t.Fatalf("expected the second status to be showing, got %q", m.status)
+	}
+	select {
+	case msg := <-msgs:
+		t.Fatalf("second status expired early: %#v", msg)
+	default:
+	}
+
+	clock.Advance(1 * time.Second)
+	m.Update(<-msgs)
+	if want := m.defaultStatus(); m.status != want {
+		t.Fatalf("expected the status to be reset to %q, got %q", want, m.status)
+	}
+}
+
+func TestFakeTicker(t *testing.T) {
+	clock := newFakeClock(time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC))
+	ticker := clock.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	clock.Advance(59 * time.Second)
+	select {
+	case <-ticker.C():
+		t.Fatal("ticked early")
+	default:
+	}
+	clock.Advance(1 * time.Second)
+	if tick := <-ticker.C(); !tick.Equal(clock.Now()) {
+		t.Fatalf("expected a tick at %v, got %v", clock.Now(), tick)
+	}
+}
+
+func TestDailyRollover(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("time zone database unavailable: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		now  time.Time
+		want string
+	}{
+		{name: "before midnight", now: time.Date(2026, 1, 14, 23, 59, 59, 0, newYork), want: "2026-01-14"},
+		{name: "midnight", now: time.Date(2026, 1, 15, 0, 0, 0, 0, newYork), want: "2026-01-15"},
+		{name: "after midnight UTC", now: time.Date(2026, 1, 15, 21, 0, 0, 0, newYork), want: "2026-01-15"},
+		// Clocks spring forward at 2am, so this day only lasts 23 hours.
+		{name: "spring forward start", now: time.Date(2026, 3, 8, 0, 0, 0, 0, newYork), want: "2026-03-08"},
+		{name: "spring forward end", now: time.Date(2026, 3, 9, 3, 59, 59, 0, time.UTC), want: "2026-03-08"},
+		{name: "spring forward next", now: time.Date(2026, 3, 9, 4, 0, 0, 0, time.UTC), want: "2026-03-09"},
+		// Clocks fall back at 2am, so this day lasts 25 hours.
+		{name: "fall back start", now: time.Date(2026, 11, 1, 4, 0, 0, 0, time.UTC), want: "2026-11-01"},
+		{name: "fall back repeated hour", now: time.Date(2026, 11, 1, 6, 30, 0, 0, time.UTC), want: "2026-11-01"},
+		{name: "fall back end", now: time.Date(2026, 11, 2, 4, 59, 59, 0, time.UTC), want: "2026-11-01"},
+		{name: "fall back next", now: time.Date(2026, 11, 2, 5, 0, 0, 0, time.UTC), want: "2026-11-02"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestModel(t, "TRACE", 80, 40)
+			m.clock = newFakeClock(tt.now)
+			m.options.daily = true
+			m.options.dailyLocation = newYork
+
+			m.doRestart()
+			if m.daily != tt.want {
+				t.Fatalf("expected the puzzle for %s, got %s", tt.want, m.daily)
+			}
+			date, _ := time.Parse(time.DateOnly, tt.want)
+			if want := testDictionary.GetDailyWord(date); m.game.Answer().String() != want {
+				t.Fatalf("expected the answer %s, got %s", want, m.game.Answer())
+			}
+		})
+	}
+}
+
+func TestDailyRolloverAdvance(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("time zone database unavailable: %v", err)
+	}
+
+	// The day of the spring forward transition is an hour short, so the
+	// puzzle changes 23 hours after the previous midnight.
+	m := newTestModel(t, "TRACE", 80, 40)
+	clock := newFakeClock(time.Date(2026, 3, 8, 0, 0, 0, 0, newYork))
+	m.clock = clock
+	m.options.daily = true
+	m.options.dailyLocation = newYork
+
+	m.doRestart()
+	clock.Advance(23*time.Hour - time.Second)
+	m.doRestart()
+	if m.daily != "2026-03-08" {
+		t.Fatalf("puzzle changed early, to %s", m.daily)
+	}
+	clock.Advance(time.Second)
+	m.doRestart()
+	if m.daily != "2026-03-09" {
+		t.Fatalf("puzzle didn't change at midnight, got %s", m.daily)
+	}
+}
+
+func TestAutoSubmit(t *testing.T) {
+	m := newTestModel(t, "TRACE", 80, 40)
+	clock := newFakeClock(time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC))
+	m.clock = clock
+	m.options.autoSubmit = true
+
+	// Deleting a letter during the grace period cancels the submission.
+	typeKeys(m, "cran")
+	_, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'e'}})
+	msgs := runAsync(cmd)
+	m.Update(tea.KeyMsg{Type: tea.KeyBackspace})
+	clock.Advance(_autoSubmitDelay)
+	m.Update(<-msgs)
+	if n := len(m.game.Guesses()); n != 0 {
+		t.Fatalf("canceled guess was submitted")
+	}
+
+	_, cmd = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'e'}})
+	msgs = runAsync(cmd)
+	clock.Advance(_autoSubmitDelay - time.Millisecond)
+	select {
+	case msg := <-msgs:
+		t.Fatalf("guess was submitted early: %#v", msg)
+	default:
+	}
+	clock.Advance(time.Millisecond)
+	m.Update(<-msgs)
+	if n := len(m.game.Guesses()); n != 1 {
+		t.Fatalf("expected the guess to be submitted, got %d guesses", n)
+	}
+}
+
+func TestScoreCountUp(t *testing.T) {
+	m := newTestModel(t, "TRACE", 80, 40)
+	clock := newFakeClock(time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC))
+	m.clock = clock
+	m.options.reducedMotion = false
+
+	// Winning starts a count-up, which is restarted to get hold of its frames.
+	typeKeys(m, "trace\n")
+	cmd := m.doCountUp(0)
+	if m.shownScore != 0 || !strings.Contains(m.status, "total 0") {
+		t.Fatalf("expected the count-up to start at 0, got %d (%q)", m.shownScore, m.status)
+	}
+
+	// Frames are delivered until the count-up ends, halfway through which the
+	// score is halfway up.
+	for elapsed := _countUpFrame; cmd != nil; elapsed += _countUpFrame {
+		msgs := runAsync(cmd)
+		clock.Advance(_countUpFrame)
+		_, cmd = m.Update(<-msgs)
+		if elapsed == _countUpDuration/2 && m.shownScore != m.score/2 {
+			t.Errorf("expected %d halfway through, got %d", m.score/2, m.shownScore)
+		}
+	}
+	if m.shownScore != m.score || !strings.Contains(m.status, fmt.Sprintf("total %d", m.score)) {
+		t.Fatalf("expected the count-up to end at %d, got %d (%q)", m.score, m.shownScore, m.status)
+	}
+
+	// Restarting cancels a count-up that is still running.
+	cmd = m.doCountUp(0)
+	msgs := runAsync(cmd)
+	m.doRestart()
+	clock.Advance(_countUpFrame)
+	if _, cmd := m.Update(<-msgs); cmd != nil || m.shownScore != m.score {
+		t.Fatalf("count-up continued after restarting")
+	}
+}
+
+func TestSolveTimes(t *testing.T) {
+	m := newTestModel(t, "TRACE", 80, 40)
+	m.renderer.SetColorProfile(termenv.Ascii)
+	clock := newFakeClock(time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC))
+	m.clock = clock
+
+	play := func(duration time.Duration) solveTimes {
+		t.Helper()
+		m.startGame(m.game.Answer())
+		clock.Advance(duration)
+		typeKeys(m, "trace\n")
+		msg, ok := m.checkSolveTimes()().(msgSolveTimes)
+		if !ok {
+			t.Fatal("solve times weren't computed")
+		}
+		m.Update(msg)
+		return *m.solveTimes
+	}
+
+	if got, want := play(2*time.Minute), (solveTimes{time: 2 * time.Minute, average: 2 * time.Minute, best: 2 * time.Minute}); got != want {
+		t.Fatalf("first game: got %+v, want %+v", got, want)
+	}
+	got := play(102 * time.Second)
+	if want := (solveTimes{time: 102 * time.Second, average: 111 * time.Second, best: 102 * time.Second, newRecord: true}); got != want {
+		t.Fatalf("second game: got %+v, want %+v", got, want)
+	}
+	if view := m.View(); !strings.Contains(view, "1m 42s  New record!") || !strings.Contains(view, "1m 51s") {
+		t.Errorf("view doesn't show the solve times:\n%s", view)
+	}
+
+	// The panel is cleared when a new game starts.
+	m.startGame(m.game.Answer())
+	if m.solveTimes != nil {
+		t.Error("solve times weren't cleared")
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	for d, want := range map[time.Duration]string{
+		42 * time.Second:              "42s",
+		102 * time.Second:             "1m 42s",
+		time.Hour + 3*time.Minute + 2: "1h 3m",
+	} {
+		if got := formatDuration(d); got != want {
+			t.Errorf("formatDuration(%s) = %q, want %q", d, got, want)
+		}
+	}
+}
+
+func TestNextDaily(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("time zone database unavailable: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		now  time.Time
+		want time.Duration
+	}{
+		{name: "regular day", now: time.Date(2026, 1, 15, 0, 0, 0, 0, newYork), want: 24 * time.Hour},
+		{name: "spring forward", now: time.Date(2026, 3, 8, 0, 0, 0, 0, newYork), want: 23 * time.Hour},
+		{name: "fall back", now: time.Date(2026, 11, 1, 0, 0, 0, 0, newYork), want: 25 * time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := nextDailyStart(tt.now, newYork)
+			if got := next.Sub(tt.now); got != tt.want {
+				t.Fatalf("expected the next puzzle in %s, got %s", tt.want, got)
+			}
+			// Exactly one puzzle starts in between.
+			today := dailyDate(tt.now, newYork)
+			if got := dailyDate(next.Add(-time.Nanosecond), newYork); got != today {
+				t.Errorf("expected the puzzle for %s until the next starts, got %s", today, got)
+			}
+			if got, want := dailyDate(next, newYork), today.AddDate(0, 0, 1); got != want {
+				t.Errorf("expected the puzzle for %s next, got %s", want, got)
+			}
+		})
+	}
+
+	// The countdown is shown in the player's time zone.
+	m := newTestModel(t, "TRACE", 80, 40)
+	m.clock = newFakeClock(time.Date(2026, 1, 15, 18, 47, 30, 0, time.UTC))
+	m.location = newYork
+	if got, want := m.viewNextDaily(), "Next puzzle in 5h 12m, at 19:00 EST"; got != want {
+		t.Errorf("viewNextDaily() = %q, want %q", got, want)
+	}
+}
+
+func TestDailyNumber(t *testing.T) {
+	m := newTestModel(t, "TRACE", 80, 40)
+	m.clock = newFakeClock(time.Date(2026, 1, 15, 12, 0, 0, 0, time.UTC))
+	m.options.daily = true
+	m.options.dailyEpoch = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	m.doRestart()
+	if m.dailyNumber != 15 || m.status != "Daily #15 · Score: 0" {
+		t.Fatalf("expected daily #15, got #%d (%q)", m.dailyNumber, m.status)
+	}
+
+	typeKeys(m, strings.ToLower(m.game.Answer().String())+"\n")
+	if !strings.HasPrefix(m.summary, "clidle daily #15 1/6") {
+		t.Errorf("summary doesn't include the puzzle number: %q", m.summary)
+	}
+	m.writes.flush()
+	results, err := m.store.ListGameResults(context.Background())
+	if err != nil || len(results) != 1 {
+		t.Fatalf("expected one game, got %d (%v)", len(results), err)
+	}
+	row, err := m.store.GetGame(context.Background(), results[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if row.DailyNumber != (sql.NullInt64{Int64: 15, Valid: true}) {
+		t.Errorf("stored daily number = %+v, want 15", row.DailyNumber)
+	}
+}
+
+func TestSessionsRefresh(t *testing.T) {
+	s := newSessions(2)
+	if !s.add(false) || !s.add(false) || s.add(false) {
+		t.Fatal("expected the third player to be turned away")
+	}
+	if got := s.String(); got != "2/2 playing now" {
+		t.Errorf("sessions = %q", got)
+	}
+	// Admins are let in even when the server is full.
+	if !s.add(true) {
+		t.Fatal("expected an admin to be let in")
+	}
+	s.remove()
+	s.remove()
+
+	clock := newFakeClock(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC))
+	m := newTestModel(t, "TRACE", 80, 40)
+	m.clock = clock
+	m.options.home = true
+	m.sessions = s
+	cmd := m.doShowHome()
+	if !strings.Contains(m.View(), "1/2 playing now") {
+		t.Fatal("home screen doesn't show the number of players")
+	}
+
+	// The count is refreshed while the home screen is shown, and only then.
+	s.add(false)
+	clock.Advance(_sessionsRefresh)
+	_, cmd = m.Update(cmd())
+	if cmd == nil || !strings.Contains(m.View(), "2/2 playing now") {
+		t.Fatal("expected the count to be refreshed")
+	}
+	m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	clock.Advance(_sessionsRefresh)
+	if _, cmd = m.Update(cmd()); cmd != nil || m.refreshingSessions {
+		t.Error("expected refreshing to stop once the home screen is closed")
+	}
+}
+
+func TestIdleNudge(t *testing.T) {
+	clock := newFakeClock(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC))
+	m := newTestModel(t, "TRACE", 80, 40)
+	m.clock = clock
+	m.options.idleNudge = time.Minute
+	cmd := m.recordInput()
+
+	// Pressing a key pushes the nudge back.
+	clock.Advance(30 * time.Second)
+	typeKeys(m, "c")
+	clock.Advance(30 * time.Second)
+	if _, cmd = m.Update(cmd()); cmd == nil || m.idleNudged {
+		t.Fatal("expected the nudge to be pushed back")
+	}
+	clock.Advance(30 * time.Second)
+	if _, cmd = m.Update(cmd()); cmd != nil || !strings.HasPrefix(m.status, "Still there?") {
+		t.Fatalf("expected a nudge, got %q", m.status)
+	}
+
+	// The nudge is cleared by the next key, which starts checking again.
+	if _, cmd = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'r'}}); cmd == nil || strings.HasPrefix(m.status, "Still there?") {
+		t.Fatalf("expected the nudge to be cleared, got %q", m.status)
+	}
+
+	// Finished games don't nudge.
+	typeKeys(m, "ane\ntrace\n")
+	status := m.status
+	clock.Advance(time.Minute)
+	if m.Update(cmd()); m.idleNudged || m.status != status {
+		t.Errorf("finished game was nudged: %q", m.status)
+	}
+}
+
+func TestIdleTimeout(t *testing.T) {
+	clock := newFakeClock(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC))
+	m := newTestModel(t, "TRACE", 80, 40)
+	m.clock = clock
+	m.options.idleNudge = 0
+	m.options.idleTimeout = 3 * time.Minute
+	cmd := m.recordInput()
+
+	// Players are warned a minute before they would be disconnected.
+	clock.Advance(2 * time.Minute)
+	if _, cmd = m.Update(cmd()); cmd == nil || !strings.HasPrefix(m.status, "Idle") {
+		t.Fatalf("expected a warning, got %q", m.status)
+	}
+
+	// Pressing a key clears the warning, and pushes the timeout back.
+	typeKeys(m, "c")
+	if strings.HasPrefix(m.status, "Idle") {
+		t.Fatalf("expected the warning to be cleared, got %q", m.status)
+	}
+	clock.Advance(time.Minute)
+	if _, cmd = m.Update(cmd()); cmd == nil || m.idleTimedOut || m.idleWarned {
+		t.Fatal("expected the timeout to be pushed back")
+	}
+	clock.Advance(time.Minute)
+	if _, cmd = m.Update(cmd()); cmd == nil || !m.idleWarned {
+		t.Fatal("expected a second warning")
+	}
+	clock.Advance(time.Minute)
+	if _, cmd = m.Update(cmd()); cmd == nil || !m.idleTimedOut {
+		t.Fatal("expected the player to be disconnected")
+	}
+	if _, ok := cmd().(tea.QuitMsg); !ok {
+		t.Error("expected the program to quit")
+	}
+}
+
+func TestExitAfter(t *testing.T) {
+	clock := newFakeClock(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC))
+	path := filepath.Join(t.TempDir(), "snapshot.json")
+	m := newTestModel(t, "TRACE", 80, 40)
+	m.clock = clock
+	m.options.snapshotFile = path
+	m.options.idleNudge = 0
+	m.options.idleTimeout = 3 * time.Minute
+	cmd := m.recordInput()
+	typeKeys(m, "crane\ntr")
+
+	clock.Advance(2 * time.Minute)
+	if _, cmd = m.Update(cmd()); cmd == nil || !strings.HasSuffix(m.status, "to keep playing") {
+		t.Fatalf("expected a warning, got %q", m.status)
+	}
+	clock.Advance(time.Minute)
+	if _, cmd = m.Update(cmd()); cmd == nil || !m.idleTimedOut {
+		t.Fatal("expected clidle to exit")
+	}
+	m.writes.flush()
+
+	// The board is saved as it was left, including the unfinished row.
+	restored := newTestModelStore(t, context.Background(), m.store, "SLATE", 80, 40)
+	restored.options.snapshotFile = path
+	restored.restoreSnapshot()
+	if restored.gridRow != 1 || string(restored.grid[1][:restored.gridLen]) != "TR" {
+		t.Errorf("expected the board to be restored, got row %d with %q", restored.gridRow, restored.grid[1][:restored.gridLen])
+	}
+}
+
+func TestDailyGuessPacing(t *testing.T) {
+	clock := newFakeClock(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC))
+	m := newTestModel(t, "TRACE", 80, 40)
+	m.clock = clock
+	m.daily = "2024-03-01"
+	m.pacer = newPacer(2 * time.Second)
+
+	typeKeys(m, "crane\n")
+	if m.gridRow != 1 {
+		t.Fatalf("expected the first guess to be accepted, got row %d", m.gridRow)
+	}
+
+	// A guess made too soon is turned away without using up a row.
+	clock.Advance(time.Second)
+	typeKeys(m, "crane\n")
+	if m.gridRow != 1 || !strings.HasPrefix(m.status, "Not so fast!") {
+		t.Fatalf("expected the guess to be turned away, got row %d and %q", m.gridRow, m.status)
+	}
+
+	// The same guess is accepted once the interval has passed.
+	clock.Advance(time.Second)
+	typeKeys(m, "\n")
+	if m.gridRow != 2 {
+		t.Fatalf("expected the guess to be accepted, got row %d", m.gridRow)
+	}
+
+	// The pace is kept per player, across sessions.
+	other := newTestModel(t, "TRACE", 80, 40)
+	other.clock = clock
+	other.daily = m.daily
+	other.pacer = m.pacer
+	other.player, other.remoteIP = m.player, m.remoteIP
+	other.playerName = m.playerName
+	typeKeys(other, "crane\n")
+	if other.gridRow != 0 {
+		t.Errorf("expected the pace to be shared, got row %d", other.gridRow)
+	}
+
+	// Players who could guess again are forgotten when the pacer is pruned.
+	clock.Advance(2 * time.Second)
+	m.pacer.prune(clock.Now())
+	if len(m.pacer.last) != 0 {
+		t.Errorf("expected the pacer to be pruned, got %v", m.pacer.last)
+	}
+}
+
+func TestPlaytime(t *testing.T) {
+	clock := newFakeClock(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC))
+	m := newTestModel(t, "TRACE", 80, 40)
+	m.clock = clock
+	m.connectedAt, m.playtimeAt = clock.Now(), clock.Now()
+	m.recordInput()
+
+	// Time on the game screen counts, up to a while after the last key.
+	clock.Advance(time.Minute)
+	typeKeys(m, "c")
+	clock.Advance(10 * time.Minute)
+	typeKeys(m, "r")
+	if want := time.Minute + _playtimeIdle; m.playtime != want {
+		t.Fatalf("playtime = %v, want %v", m.playtime, want)
+	}
+
+	// Time on other screens doesn't.
+	m.doToggleScoring()
+	clock.Advance(time.Minute)
+	if view := m.View(); !strings.Contains(view, "Connected 12m · 3m played") {
+		t.Fatalf("expected the time played in the corner, got:\n%s", view)
+	}
+	typeKeys(m, "x")
+	if m.screen != screenGame || m.playtime != 3*time.Minute {
+		t.Fatalf("expected time on the scoring screen not to count, got %v", m.playtime)
+	}
+
+	// The time played is saved, and added to on the next session.
+	m.doSavePlaytime()
+	m.writes.flush()
+	other := newTestModelStore(t, context.Background(), m.store, "TRACE", 80, 40)
+	other.loadPlaytime()
+	if other.lifetimePlaytime != 3*time.Minute {
+		t.Errorf("expected the time played to be saved, got %v", other.lifetimePlaytime)
+	}
+	if got := formatPlaytime(14*time.Hour + 20*time.Minute); got != "14h" {
+		t.Errorf("formatPlaytime() = %q", got)
+	}
+	if got := formatPlaytime(65 * time.Minute); got != "1h 5m" {
+		t.Errorf("formatPlaytime() = %q", got)
+	}
+}
+
+func TestAutosave(t *testing.T) {
+	clock := newFakeClock(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC))
+	path := filepath.Join(t.TempDir(), "snapshot.json")
+	m := newTestModel(t, "TRACE", 80, 40)
+	m.clock = clock
+	m.options.snapshotFile = path
+	m.options.autosave = 5 * time.Second
+	cmd := m.scheduleAutosave()
+	typeKeys(m, "crane\ntr")
+
+	// The row being typed is saved on the next tick.
+	clock.Advance(5 * time.Second)
+	if _, cmd = m.Update(cmd()); cmd == nil {
+		t.Fatal("expected another autosave to be scheduled")
+	}
+	m.writes.flush()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var s snapshot
+	if err := json.Unmarshal(data, &s); err != nil || s.Row != "TR" || len(s.Guesses) != 1 {
+		t.Fatalf("expected the typed row to be saved, got %+v, %v", s, err)
+	}
+
+	// An unchanged board isn't saved again.
+	if m.saveSnapshot() != nil {
+		t.Error("expected an unchanged board not to be saved")
+	}
+	typeKeys(m, "a")
+	if m.saveSnapshot() == nil {
+		t.Error("expected a changed board to be saved")
+	}
+}
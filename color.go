@@ -0,0 +1,36 @@
+package main
+
+import (
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+)
+
+// _colorModes contains the values accepted by --color.
+var _colorModes = map[string]struct{}{
+	"auto":      {},
+	"always":    {},
+	"never":     {},
+	"truecolor": {},
+	"256":       {},
+}
+
+// setColorProfile overrides the color profile detected by the renderer
+// according to the color mode. If noColor is set (usually because of the
+// NO_COLOR environment variable), colors are disabled regardless of the mode.
+func setColorProfile(renderer *lipgloss.Renderer, mode string, noColor bool) {
+	switch mode {
+	case "always":
+		if renderer.ColorProfile() == termenv.Ascii {
+			renderer.SetColorProfile(termenv.ANSI)
+		}
+	case "never":
+		renderer.SetColorProfile(termenv.Ascii)
+	case "truecolor":
+		renderer.SetColorProfile(termenv.TrueColor)
+	case "256":
+		renderer.SetColorProfile(termenv.ANSI256)
+	}
+	if noColor {
+		renderer.SetColorProfile(termenv.Ascii)
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+const (
+	// _countUpDuration is how long the shown score takes to count up to the
+	// total score.
+	_countUpDuration = 500 * time.Millisecond
+	// _countUpFrame is the time between updates of the shown score.
+	_countUpFrame = 50 * time.Millisecond
+)
+
+// countUp is a running animation of the shown score counting up to the total
+// score. It is identified by its address, so that frames of an animation that
+// was canceled are ignored.
+type countUp struct {
+	from  int
+	start time.Time
+}
+
+// doCountUp starts counting the shown score up from the given value to the
+// total score. With reduced motion, the total is shown right away.
+func (m *model) doCountUp(from int) tea.Cmd {
+	if m.options.reducedMotion || m.score <= from {
+		m.cancelCountUp()
+		return nil
+	}
+	m.countUp = &countUp{from: from, start: m.clock.Now()}
+	m.setShownScore(from)
+	return m.nextCountUpFrame()
+}
+
+// nextCountUpFrame returns a tea.Cmd that delivers the next frame of the
+// running count-up.
+func (m *model) nextCountUpFrame() tea.Cmd {
+	countUp := m.countUp
+	after := m.clock.After(_countUpFrame)
+	return func() tea.Msg {
+		<-after
+		return msgCountUp{countUp: countUp}
+	}
+}
+
+// updateCountUp advances the count-up to the current time.
+func (m *model) updateCountUp(msg msgCountUp) tea.Cmd {
+	if msg.countUp != m.countUp {
+		return nil
+	}
+	elapsed := m.clock.Now().Sub(m.countUp.start)
+	if elapsed >= _countUpDuration {
+		m.cancelCountUp()
+		return nil
+	}
+	from := m.countUp.from
+	m.setShownScore(from + int(int64(m.score-from)*int64(elapsed)/int64(_countUpDuration)))
+	return m.nextCountUpFrame()
+}
+
+// cancelCountUp stops the running count-up, if any, and shows the total score.
+func (m *model) cancelCountUp() {
+	m.countUp = nil
+	m.setShownScore(m.score)
+}
+
+// setShownScore changes the score that is shown, refreshing the status if it
+// is showing the score.
+func (m *model) setShownScore(score int) {
+	isDefault := m.statusPending == 0 && m.status == m.defaultStatus()
+	isResult := m.statusPending == 0 && m.game != nil && m.isGameOver() && m.status == m.viewResult()
+	m.shownScore = score
+	if isDefault {
+		m.resetStatus()
+	} else if isResult {
+		m.status = m.viewResult()
+	}
+}
+
+// msgCountUp is sent when the next frame of a count-up is due.
+type msgCountUp struct {
+	countUp *countUp
+}
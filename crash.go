@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// crashGuard wraps a model, recovering from panics in Init, Update and View so
+// that a bug in one game doesn't take down the process it runs in. Recovered
+// panics are passed to report, and the program quits; after a panic in View,
+// it quits on the next message.
+type crashGuard struct {
+	*model
+	report  func(value any, stack []byte)
+	crashed bool
+}
+
+var _ tea.Model = (*crashGuard)(nil)
+
+func newCrashGuard(m *model, report func(value any, stack []byte)) *crashGuard {
+	return &crashGuard{model: m, report: report}
+}
+
+func (g *crashGuard) Init() (cmd tea.Cmd) {
+	defer g.recover(&cmd)
+	return g.model.Init()
+}
+
+func (g *crashGuard) Update(msg tea.Msg) (_ tea.Model, cmd tea.Cmd) {
+	if g.crashed {
+		return g, tea.Quit
+	}
+	defer g.recover(&cmd)
+	_, cmd = g.model.Update(msg)
+	return g, cmd
+}
+
+func (g *crashGuard) View() (view string) {
+	if g.crashed {
+		return ""
+	}
+	defer g.recover(nil)
+	return g.model.View()
+}
+
+// recover reports a panic, if there is one, and replaces the returned command
+// with one that quits the program.
+func (g *crashGuard) recover(cmd *tea.Cmd) {
+	value := recover()
+	if value == nil {
+		return
+	}
+	g.crashed = true
+	g.report(value, debug.Stack())
+	if cmd != nil {
+		*cmd = tea.Quit
+	}
+}
+
+// writeCrashReport writes a panic and its stack trace to a new file in the
+// data directory, and returns its path.
+func writeCrashReport(value any, stack []byte) (string, error) {
+	if err := os.MkdirAll(pathClidle, 0700); err != nil {
+		return "", err
+	}
+	path := filepath.Join(pathClidle, fmt.Sprintf("crash-%s.log", time.Now().Format("20060102-150405")))
+	report := fmt.Sprintf("panic: %v\n\n%s", value, stack)
+	return path, os.WriteFile(path, []byte(report), 0600)
+}
@@ -1,22 +1,125 @@
 package main
 
 import (
+	"log/slog"
 	"math/rand"
+	"sort"
+	"time"
+)
+
+const (
+	// _rarityCommon is the rarity of words that can be answers.
+	_rarityCommon = 0
+	// _rarityUncommon is the rarity of all other accepted words.
+	_rarityUncommon = 1
 )
 
 type Dictionary struct {
+	// commonWords are the words that can be answers, in sorted order.
 	commonWords []string
 	allWords    map[string]struct{}
+	// acceptedWords, if set, restricts the words accepted by IsWord to a
+	// subset of allWords.
+	acceptedWords map[string]struct{}
 }
 
+// IsWord checks if a word is accepted as a guess.
 func (d Dictionary) IsWord(word string) bool {
+	words := d.allWords
+	if d.acceptedWords != nil {
+		words = d.acceptedWords
+	}
+	_, ok := words[word]
+	return ok
+}
+
+// IsKnownWord checks if a word is in the dictionary, even if it is not
+// accepted as a guess because of its rarity.
+func (d Dictionary) IsKnownWord(word string) bool {
 	_, ok := d.allWords[word]
 	return ok
 }
 
+// Rarity returns how rare a known word is. The dictionary only distinguishes
+// between words that can be answers (_rarityCommon), and all other words
+// (_rarityUncommon).
+func (d Dictionary) Rarity(word string) int {
+	idx := sort.SearchStrings(d.commonWords, word)
+	if idx < len(d.commonWords) && d.commonWords[idx] == word {
+		return _rarityCommon
+	}
+	return _rarityUncommon
+}
+
+// WithMaxRarity returns a copy of the dictionary that only accepts words up to
+// the given rarity as guesses. Answers are always common words, so they remain
+// unaffected.
+func (d Dictionary) WithMaxRarity(maxRarity int) Dictionary {
+	if maxRarity >= _rarityUncommon {
+		d.acceptedWords = nil
+		return d
+	}
+	d.acceptedWords = make(map[string]struct{}, len(d.commonWords))
+	for word := range d.allWords {
+		if d.Rarity(word) <= maxRarity {
+			d.acceptedWords[word] = struct{}{}
+		}
+	}
+	return d
+}
+
 func (d Dictionary) GetRandomCommonWord() string {
-	idx := rand.Intn(len(d.commonWords))
-	return d.commonWords[idx]
+	return d.pickAnswer(rand.Intn)
+}
+
+// _maxAnswerAttempts is the number of answers drawn before giving up on
+// finding one that is accepted as a guess.
+const _maxAnswerAttempts = 10
+
+// pickAnswer draws a common word using the given source of randomness. Every
+// answer must also be accepted as a guess, or the player could never win by
+// typing it; if one isn't, the mistake is logged and another is drawn.
+func (d Dictionary) pickAnswer(intn func(n int) int) string {
+	for attempt := 1; ; attempt++ {
+		word := d.commonWords[intn(len(d.commonWords))]
+		if d.IsWord(word) || attempt == _maxAnswerAttempts {
+			return word
+		}
+		slog.Error("answer is not accepted as a guess, choosing another", slog.String("answer", word))
+	}
+}
+
+// dailyDate returns the date of the daily puzzle at the given time, as
+// midnight UTC on the date in the given time zone. Puzzles change at midnight
+// in that time zone, which isn't always 24 hours after the previous midnight.
+func dailyDate(now time.Time, location *time.Location) time.Time {
+	year, month, day := now.In(location).Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+// _defaultDailyEpoch is the date of daily puzzle #1, unless another is chosen
+// with --daily-epoch.
+const _defaultDailyEpoch = "2024-01-01"
+
+// dailyNumber returns the number of the daily puzzle on the given date, counting
+// the puzzle on the epoch as #1. Both dates must be at midnight UTC, as returned
+// by dailyDate.
+func dailyNumber(date, epoch time.Time) int {
+	return int(date.Sub(epoch)/(24*time.Hour)) + 1
+}
+
+// nextDailyStart returns the time at which the daily puzzle after the one at
+// the given time starts, which is the next midnight in the given time zone.
+func nextDailyStart(now time.Time, location *time.Location) time.Time {
+	year, month, day := now.In(location).Date()
+	return time.Date(year, month, day+1, 0, 0, 0, 0, location)
+}
+
+// GetDailyWord returns the answer for the daily puzzle on the given date. Every
+// call with the same date returns the same word.
+func (d Dictionary) GetDailyWord(date time.Time) string {
+	days := date.Unix() / int64(24*time.Hour/time.Second)
+	return d.pickAnswer(rand.New(rand.NewSource(days)).Intn)
 }
 
 var EnglishDictionary = Dictionary{
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"database/sql"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"runtime"
+	"text/tabwriter"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/term"
+	"github.com/pkg/errors"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// errSkipped is returned by a diagnostic check that does not apply.
+var errSkipped = errors.New("skipped")
+
+// diagnostic is a single check run by the doctor command.
+type diagnostic struct {
+	name string
+	// run performs the check, and returns some details about the result.
+	run func() (string, error)
+	// fix is a suggestion shown if the check fails.
+	fix string
+}
+
+// runDoctor checks the environment that clidle runs in, and prints the result
+// of each check. It fails if any of the checks fail.
+func runDoctor(args []string, serveAddr, pprofAddr string) error {
+	flags := flag.NewFlagSet("doctor", flag.ExitOnError)
+	flagServe := flags.String("serve", serveAddr, "Also checks that an SSH server can listen on the given address")
+	flagPprof := flags.String("pprof-addr", pprofAddr, "Also checks the address on which profiles are served")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	diagnostics := []diagnostic{
+		{
+			name: "Data directory is writable",
+			run:  checkDataDir,
+			fix:  fmt.Sprintf("make sure that %s can be created and written to", pathClidle),
+		},
+		{
+			name: "Data directory is private",
+			run:  checkDataDirPrivate,
+			fix:  fmt.Sprintf("run chmod 700 %q, since it contains the host key", pathClidle),
+		},
+		{
+			name: "Database passes integrity check",
+			run:  checkDatabase,
+			fix:  fmt.Sprintf("restore %s from a backup, or move it away to start afresh", pathStore),
+		},
+		{
+			name: "Host key is valid",
+			run:  checkHostKey,
+			fix:  fmt.Sprintf("delete %s to generate a new host key on the next server start", pathHostKey),
+		},
+		{
+			name: "Dictionary is loaded",
+			run:  checkDictionary,
+			fix:  "reinstall clidle",
+		},
+		{
+			name: "Terminal is usable",
+			run:  checkTerminal,
+			fix:  "run clidle in an interactive terminal",
+		},
+		{
+			name: "Server address is bindable",
+			run:  func() (string, error) { return checkAddress(*flagServe) },
+			fix:  "choose another address with --serve, or stop the process using it",
+		},
+		{
+			name: "Profiling endpoint",
+			run:  func() (string, error) { return checkPprof(*flagPprof) },
+			fix:  "choose another address with --pprof-addr, or stop the process using it",
+		},
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, path := range [][2]string{
+		{"Data directory", pathClidle},
+		{"Database", pathStore},
+		{"Host key", pathHostKey},
+		{"Settings", pathSettings},
+		{"Snapshot", pathSnapshot},
+	} {
+		fmt.Fprintf(tw, "%s:\t%s\n", path[0], path[1])
+	}
+	tw.Flush()
+	fmt.Println()
+
+	failed := 0
+	for _, diagnostic := range diagnostics {
+		details, err := diagnostic.run()
+		switch {
+		case errors.Is(err, errSkipped):
+			fmt.Printf("[SKIP] %s: %s\n", diagnostic.name, details)
+		case err != nil:
+			failed++
+			fmt.Printf("[FAIL] %s: %v\n", diagnostic.name, err)
+			fmt.Printf("       fix: %s\n", diagnostic.fix)
+		default:
+			fmt.Printf("[ OK ] %s: %s\n", diagnostic.name, details)
+		}
+	}
+
+	if failed > 0 {
+		return errors.Errorf("%d check(s) failed", failed)
+	}
+	return nil
+}
+
+func checkDataDir() (string, error) {
+	if err := os.MkdirAll(pathClidle, 0700); err != nil {
+		return "", err
+	}
+	f, err := os.CreateTemp(pathClidle, ".doctor-*")
+	if err != nil {
+		return "", err
+	}
+	f.Close()
+	if err := os.Remove(f.Name()); err != nil {
+		return "", err
+	}
+	return pathClidle, nil
+}
+
+func checkDataDirPrivate() (string, error) {
+	// Windows ignores Unix permissions, and access is controlled by ACLs
+	// instead, which are private to the user in %LOCALAPPDATA%.
+	if runtime.GOOS == "windows" {
+		return "permissions are managed by Windows", errSkipped
+	}
+	info, err := os.Stat(pathClidle)
+	if err != nil {
+		return "", err
+	}
+	if perm := info.Mode().Perm(); perm&0077 != 0 {
+		return "", errors.Errorf("mode is %04o, which other users can access", perm)
+	}
+	return fmt.Sprintf("mode is %04o", info.Mode().Perm()), nil
+}
+
+func checkDatabase() (string, error) {
+	if _, err := os.Stat(pathStore); os.IsNotExist(err) {
+		return "not created yet", errSkipped
+	}
+
+	db, err := sql.Open("sqlite", sqliteDSN(pathStore, "mode=ro"))
+	if err != nil {
+		return "", err
+	}
+	defer db.Close()
+
+	var result string
+	if err := db.QueryRow("PRAGMA integrity_check").Scan(&result); err != nil {
+		return "", err
+	}
+	if result != "ok" {
+		return "", errors.Errorf("integrity check failed: %s", result)
+	}
+
+	var version int
+	if err := db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return "", err
+	}
+	if version > len(migrations) {
+		return "", errors.Errorf("schema version %d is newer than supported version %d", version, len(migrations))
+	}
+	return fmt.Sprintf("%s (schema version %d)", pathStore, version), nil
+}
+
+func checkHostKey() (string, error) {
+	pem, err := os.ReadFile(pathHostKey)
+	if os.IsNotExist(err) {
+		return "not created yet, it will be generated on the first server start", errSkipped
+	} else if err != nil {
+		return "", err
+	}
+
+	signer, err := gossh.ParsePrivateKey(pem)
+	var missing *gossh.PassphraseMissingError
+	if errors.As(err, &missing) {
+		// The public key of keys in the OpenSSH format isn't encrypted, so
+		// their fingerprint is still known.
+		if missing.PublicKey == nil {
+			return "encrypted", nil
+		}
+		return fmt.Sprintf("%s (encrypted)", gossh.FingerprintSHA256(missing.PublicKey)), nil
+	} else if err != nil {
+		return "", err
+	}
+	return gossh.FingerprintSHA256(signer.PublicKey()), nil
+}
+
+func checkDictionary() (string, error) {
+	dictionary := EnglishDictionary
+	if len(dictionary.commonWords) == 0 || len(dictionary.allWords) == 0 {
+		return "", errors.New("dictionary is empty")
+	}
+	for _, word := range dictionary.commonWords {
+		if !dictionary.IsWord(word) {
+			return "", errors.Errorf("answer %s is not accepted as a guess", word)
+		}
+	}
+	return fmt.Sprintf("%d answers, %d accepted words", len(dictionary.commonWords), len(dictionary.allWords)), nil
+}
+
+func checkTerminal() (string, error) {
+	fd := os.Stderr.Fd()
+	if !term.IsTerminal(fd) {
+		return "stderr is not a terminal", errSkipped
+	}
+	width, height, err := term.GetSize(fd)
+	if err != nil {
+		return "", err
+	}
+	profile := lipgloss.NewRenderer(os.Stderr).ColorProfile()
+	return fmt.Sprintf("%dx%d, %s colors", width, height, profile.Name()), nil
+}
+
+func checkAddress(addr string) (string, error) {
+	if addr == "" {
+		return "no address given with --serve", errSkipped
+	}
+	listener, err := listen(addr)
+	if err != nil {
+		return "", err
+	}
+	listener.Close()
+	return addr, nil
+}
+
+func checkPprof(addr string) (string, error) {
+	if addr == "" {
+		return "off, enable it with --pprof-addr", errSkipped
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return "", err
+	}
+	listener.Close()
+	if !isLoopbackAddr(addr) {
+		return fmt.Sprintf("http://%s/debug/pprof/ (reachable from other machines)", addr), nil
+	}
+	return fmt.Sprintf("http://%s/debug/pprof/ (loopback only)", addr), nil
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// _eliminatePenalty is the number of points deducted every time the player
+// asks whether any of their marked letters are in the answer.
+const _eliminatePenalty = 5
+
+// doStartEliminate enters the eliminate assist, in which the player marks
+// letters they believe are absent, and is told whether at least one of them is
+// actually in the answer.
+func (m *model) doStartEliminate() tea.Cmd {
+	if !m.options.assistEliminate || m.isGameOver() {
+		return nil
+	}
+	m.cancelCountUp()
+	m.eliminating = true
+	m.eliminateMarks = m.eliminateMarks[:0]
+	return m.setEliminateStatus()
+}
+
+// updateEliminate handles key presses while the eliminate assist is active.
+func (m *model) updateEliminate(msg tea.KeyMsg) tea.Cmd {
+	switch msg.Type {
+	case tea.KeyCtrlC:
+		return m.doExit()
+	case tea.KeyEsc, tea.KeyCtrlE:
+		m.eliminating = false
+		return nil
+	case tea.KeyBackspace:
+		if len(m.eliminateMarks) > 0 {
+			m.eliminateMarks = m.eliminateMarks[:len(m.eliminateMarks)-1]
+		}
+	case tea.KeyEnter:
+		return m.doEliminate()
+	case tea.KeyRunes:
+		if len(msg.Runes) == 1 {
+			m.doToggleMark(msg.Runes[0])
+		}
+	}
+	return m.setEliminateStatus()
+}
+
+// doToggleMark marks a letter, or unmarks it if it was already marked.
+func (m *model) doToggleMark(ch rune) {
+	ch = toAsciiUpper(ch)
+	if !isAsciiUpper(ch) {
+		return
+	}
+	letter := byte(ch)
+	if idx := bytes.IndexByte(m.eliminateMarks, letter); idx >= 0 {
+		m.eliminateMarks = append(m.eliminateMarks[:idx], m.eliminateMarks[idx+1:]...)
+		return
+	}
+	m.eliminateMarks = append(m.eliminateMarks, letter)
+}
+
+// doEliminate tells the player whether any of the marked letters are in the
+// answer, at the cost of a few points.
+func (m *model) doEliminate() tea.Cmd {
+	if len(m.eliminateMarks) == 0 {
+		return m.setStatus("Mark at least one letter first.", 1*time.Second)
+	}
+	m.eliminating = false
+	m.penalty += _eliminatePenalty
+
+	answer := m.game.Answer()
+	letters := m.viewMarks()
+	if bytes.ContainsAny(answer[:], string(m.eliminateMarks)) {
+		return m.setStatus(fmt.Sprintf("At least one of %s is in the word. (-%d)", letters, _eliminatePenalty), 3*time.Second)
+	}
+	return m.setStatus(fmt.Sprintf("None of %s are in the word. (-%d)", letters, _eliminatePenalty), 3*time.Second)
+}
+
+// setEliminateStatus shows the marked letters and how to proceed.
+func (m *model) setEliminateStatus() tea.Cmd {
+	letters := m.viewMarks()
+	if letters == "" {
+		letters = "none"
+	}
+	msg := fmt.Sprintf("Marked: %s (enter to ask for %d points, esc to cancel)", letters, _eliminatePenalty)
+	return m.setStatus(msg, 0)
+}
+
+// viewMarks renders the marked letters as a list.
+func (m *model) viewMarks() string {
+	letters := make([]string, len(m.eliminateMarks))
+	for i, letter := range m.eliminateMarks {
+		letters[i] = string(letter)
+	}
+	return strings.Join(letters, ", ")
+}
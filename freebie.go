@@ -0,0 +1,33 @@
+package main
+
+import (
+	"math/rand"
+	"strings"
+)
+
+// _freebiePenalty is the number of points deducted from a game that starts
+// with a letter given away.
+const _freebiePenalty = 20
+
+// giveFreebie gives away one random letter of the answer, along with its
+// position, by marking it as correct on the keyboard. The game is worth fewer
+// points in return.
+func (m *model) giveFreebie() {
+	m.freebie = rand.Intn(_numChars)
+	m.keyStates.set(m.game.Answer()[m.freebie], _keyStateCorrect)
+	m.penalty += _freebiePenalty
+}
+
+// viewFreebie renders the letter given away as a pattern, such as __R__, or
+// returns an empty string if none was.
+func (m *model) viewFreebie() string {
+	if m.freebie < 0 {
+		return ""
+	}
+	pattern := []byte(strings.Repeat("_", _numChars))
+	pattern[m.freebie] = m.game.Answer()[m.freebie]
+	if m.options.lowercase {
+		return strings.ToLower(string(pattern))
+	}
+	return string(pattern)
+}
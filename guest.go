@@ -0,0 +1,137 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"strings"
+	"unicode"
+
+	"github.com/charmbracelet/ssh"
+	"github.com/pkg/errors"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// _guestPrefix identifies guests, who connect without a key, as in
+// guest:0123456789abcdef. Their identity is derived from their guest token, so
+// that they can reclaim it on their next visit.
+const _guestPrefix = "guest:"
+
+// _guestNameLength is the longest nickname a guest can pick.
+const _guestNameLength = 16
+
+// _guestTokenEncoding encodes guest tokens, which are meant to be written down.
+var _guestTokenEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// guest is a player who connected without a key, and picked a nickname.
+type guest struct {
+	name  string
+	token string
+	// fresh is set if the token was made for this session, rather than
+	// entered by the guest, so that it is shown when they leave.
+	fresh bool
+}
+
+// ctxKeyGuest holds the guest of a connection authenticated by
+// keyboard-interactive authentication.
+type ctxKeyGuest struct{}
+
+// player returns the identity of the guest.
+func (g guest) player() string {
+	hash := sha256.Sum256([]byte(g.token))
+	return _guestPrefix + hex.EncodeToString(hash[:8])
+}
+
+// isGuest reports whether a player is a guest.
+func isGuest(player string) bool {
+	return strings.HasPrefix(player, _guestPrefix)
+}
+
+// newGuestToken returns a new random guest token, as in ABCD-EFGH-IJKL-MNOP.
+func newGuestToken() string {
+	b := make([]byte, 10)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	token := _guestTokenEncoding.EncodeToString(b)
+	return strings.Join([]string{token[0:4], token[4:8], token[8:12], token[12:16]}, "-")
+}
+
+// parseGuestToken normalizes a guest token as entered by a guest, who may have
+// left out the dashes or changed the case.
+func parseGuestToken(s string) (string, error) {
+	s = strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(s))
+	if b, err := _guestTokenEncoding.DecodeString(s); err != nil || len(b) != 10 {
+		return "", errors.New("invalid guest token")
+	}
+	return strings.Join([]string{s[0:4], s[4:8], s[8:12], s[12:16]}, "-"), nil
+}
+
+// guestName cleans up a nickname, keeping only printable characters, and
+// falling back to the user name they connect with.
+func guestName(nickname, user string) string {
+	name := strings.TrimSpace(strings.Map(func(r rune) rune {
+		if !unicode.IsPrint(r) {
+			return -1
+		}
+		return r
+	}, nickname))
+	if name == "" {
+		name = user
+	}
+	if runes := []rune(name); len(runes) > _guestNameLength {
+		name = string(runes[:_guestNameLength])
+	}
+	return name
+}
+
+// guestHandler lets in players without a key as guests, once they have picked a
+// nickname, and optionally entered the token of an earlier visit.
+func guestHandler(ctx ssh.Context, challenge gossh.KeyboardInteractiveChallenge) bool {
+	g, err := askGuest(ctx.User(), challenge)
+	if err != nil {
+		return false
+	}
+	ctx.SetValue(ctxKeyGuest{}, g)
+	return true
+}
+
+// askGuest asks a guest for their nickname and token. A new token is made if
+// they don't enter one.
+func askGuest(user string, challenge gossh.KeyboardInteractiveChallenge) (guest, error) {
+	answers, err := challenge(user,
+		"Playing as a guest. Connect with an SSH key to keep your scores for good.",
+		[]string{"Nickname: ", "Guest token from an earlier visit (leave empty if new): "},
+		[]bool{true, false},
+	)
+	if err != nil {
+		return guest{}, err
+	}
+	if len(answers) != 2 {
+		return guest{}, errors.New("expected a nickname and a token")
+	}
+	g := guest{name: guestName(answers[0], user)}
+	if strings.TrimSpace(answers[1]) == "" {
+		g.token, g.fresh = newGuestToken(), true
+	} else if g.token, err = parseGuestToken(answers[1]); err != nil {
+		return guest{}, err
+	}
+	return g, nil
+}
+
+// leaderboardName returns the name of a player on a leaderboard, labeling
+// guests, and fitting it in width runes unless width is zero.
+func leaderboardName(name, player string, width int) string {
+	if name == "" {
+		name = "anonymous"
+	}
+	suffix := ""
+	if isGuest(player) {
+		suffix = " (guest)"
+	}
+	if runes := []rune(name); width > 0 && len(runes)+len(suffix) > width {
+		name = string(runes[:max(0, width-len(suffix))])
+	}
+	return name + suffix
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// history appends the result of every completed game to a file, one JSON
+// object per line. It is safe for concurrent use by the sessions of a server.
+type history struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// historyEntry is the result of a completed game, as written to the history.
+type historyEntry struct {
+	Answer      string    `json:"answer"`
+	Guesses     []string  `json:"guesses"`
+	Patterns    []string  `json:"patterns"`
+	Result      string    `json:"result"`
+	Score       int       `json:"score"`
+	LossPenalty int       `json:"loss_penalty,omitempty"`
+	Mode        string    `json:"mode"`
+	Daily       string    `json:"daily,omitempty"`
+	DailyNumber int       `json:"daily_number,omitempty"`
+	Player      string    `json:"player,omitempty"`
+	FinishedAt  time.Time `json:"finished_at"`
+}
+
+// openHistory opens the history file at the given path for appending,
+// creating it if needed.
+func openHistory(path string) (*history, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not open history file")
+	}
+	return &history{file: f}, nil
+}
+
+// append writes an entry to the history. Each entry is written with a single
+// call and synced to disk before the next one, so that entries from different
+// sessions never interleave and are kept even if clidle crashes.
+func (h *history) append(entry historyEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, err := h.file.Write(line); err != nil {
+		return errors.Wrap(err, "could not write to history file")
+	}
+	return errors.Wrap(h.file.Sync(), "could not sync history file")
+}
+
+// Close closes the history file.
+func (h *history) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.file.Close()
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/ajeetdsouza/clidle/store"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/pkg/errors"
+)
+
+// homeItem is an entry of the home screen.
+type homeItem int
+
+const (
+	homeDaily homeItem = iota
+	homeQuickPlay
+	homeDuel
+	homeLeaderboard
+	homeStats
+)
+
+// homeSummary is what the home screen shows about the player, loaded when it
+// is opened.
+type homeSummary struct {
+	dailyNumber int
+	// daily is the player's completed game of today's puzzle, if they have a
+	// key and have completed it.
+	daily *store.ListGameResultsRow
+	// finished is set if the player has finished today's puzzle, and only
+	// then is the leaderboard loaded, so that it can't spoil the answer.
+	finished    bool
+	leaderboard []store.GetDailyLeaderboardRow
+	// stats summarizes the player's games, and is nil for players without a
+	// key, whose games can't be told apart from others'.
+	stats *stats
+}
+
+// loadHomeSummary loads what the home screen shows about the player.
+func (m *model) loadHomeSummary(ctx context.Context) (*homeSummary, error) {
+	today := dailyDate(m.clock.Now(), m.options.dailyLocation)
+	daily := sql.NullString{String: today.Format(time.DateOnly), Valid: true}
+	summary := &homeSummary{dailyNumber: dailyNumber(today, m.options.dailyEpoch)}
+
+	if m.player != "" {
+		results, err := listPlayerResults(ctx, m.store, m.player)
+		if err != nil {
+			return nil, err
+		}
+		s := computeStats(results)
+		summary.stats = &s
+		for i := range results {
+			if results[i].Daily == daily && results[i].FinishedAt.Valid {
+				summary.daily = &results[i]
+				break
+			}
+		}
+	}
+
+	var err error
+	if summary.finished, err = m.hasFinishedDaily(ctx, m.record, m.player, daily); err != nil || !summary.finished {
+		return summary, err
+	}
+	summary.leaderboard, err = m.store.GetDailyLeaderboard(ctx, store.GetDailyLeaderboardParams{
+		Daily: daily,
+		Limit: _leaderboardSize,
+	})
+	return summary, err
+}
+
+// homeItems returns the entries of the home screen, in order.
+func (m *model) homeItems() []homeItem {
+	items := []homeItem{homeDaily, homeQuickPlay}
+	if m.invites != nil {
+		items = append(items, homeDuel)
+	}
+	return append(items, homeLeaderboard, homeStats)
+}
+
+// loadSkipHome loads whether the player prefers to skip the home screen. The
+// preference is only kept for players with a key.
+func (m *model) loadSkipHome() {
+	if m.player == "" {
+		return
+	}
+	ctx, cancel := context.WithTimeout(m.ctx, 5*time.Second)
+	defer cancel()
+
+	skip, err := m.store.GetSkipHome(ctx, m.player)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		slog.Error("error fetching home screen preference", slog.Any("error", err))
+	}
+	m.skipHome = skip
+}
+
+// doSaveSkipHome queues saving whether the player prefers to skip the home
+// screen. For players without a key, it only lasts for the session.
+func (m *model) doSaveSkipHome() tea.Cmd {
+	if m.player == "" {
+		return nil
+	}
+	params := store.SetSkipHomeParams{Player: m.player, SkipHome: m.skipHome}
+	return m.writes.enqueue(func() tea.Msg {
+		ctx, cancel := context.WithTimeout(m.writeCtx, 5*time.Second)
+		defer cancel()
+		err := store.Retry(ctx, func() error { return m.store.SetSkipHome(ctx, params) })
+		return msgSaved{err: err}
+	})
+}
+
+// doShowHome opens the home screen, from which the player picks what to play.
+// It can't be opened during a duel.
+func (m *model) doShowHome() tea.Cmd {
+	if !m.options.home || m.match != nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(m.ctx, 5*time.Second)
+	defer cancel()
+
+	summary, err := m.loadHomeSummary(ctx)
+	if err != nil {
+		slog.Error("error loading home screen", slog.Any("error", err))
+		return m.setStatus("Couldn't load the home screen.", 2*time.Second)
+	}
+	m.home = summary
+	m.homeRow = 0
+	m.screen, m.back = screenHome, screenGame
+	return m.refreshSessions()
+}
+
+// updateHome handles key presses while the home screen is shown. Closing it
+// returns to the game that was being played.
+func (m *model) updateHome(msg tea.KeyMsg) tea.Cmd {
+	numItems := len(m.homeItems())
+	switch msg.Type {
+	case tea.KeyCtrlC:
+		return m.doExit()
+	case tea.KeyEsc, tea.KeyCtrlL:
+		m.closeScreen()
+	case tea.KeyUp:
+		m.homeRow = (m.homeRow + numItems - 1) % numItems
+	case tea.KeyDown, tea.KeyTab:
+		m.homeRow = (m.homeRow + 1) % numItems
+	case tea.KeyCtrlO:
+		return m.doToggleSettings()
+	case tea.KeyEnter:
+		return m.doChooseHome()
+	}
+	return nil
+}
+
+// doChooseHome acts on the selected entry of the home screen. The leaderboard
+// and stats are shown as soon as they are selected, so choosing them does
+// nothing.
+func (m *model) doChooseHome() tea.Cmd {
+	switch m.homeItems()[m.homeRow] {
+	case homeDaily:
+		m.options.daily = true
+		m.closeScreen()
+		return m.doRestart()
+	case homeQuickPlay:
+		m.options.daily = false
+		m.closeScreen()
+		return m.doRestart()
+	case homeDuel:
+		return m.doToggleDuel()
+	}
+	return nil
+}
+
+// viewHome renders the home screen, including a border. The details of the
+// selected entry are shown below the list.
+func (m *model) viewHome() string {
+	title := "Welcome to clidle"
+	if m.playerName != "" {
+		title = "Welcome, " + m.playerName
+	}
+	rows := []string{m.styles.text.Render(title)}
+	if m.sessions != nil {
+		rows = append(rows, m.styles.subtext.Render(m.sessions.String()))
+	}
+	rows = append(rows, "")
+
+	items := m.homeItems()
+	for i, item := range items {
+		label, detail := m.viewHomeItem(item)
+		row := fmt.Sprintf("%-14s%s", label, detail)
+		if i == m.homeRow {
+			rows = append(rows, m.styles.text.Render("> "+row))
+		} else {
+			rows = append(rows, m.styles.subtext.Render("  "+row))
+		}
+	}
+
+	home := m.home
+	switch items[m.homeRow] {
+	case homeLeaderboard:
+		rows = append(rows, "")
+		switch {
+		case !home.finished:
+			rows = append(rows, m.styles.subtext.Render("Finish today's puzzle to see it."))
+		case len(home.leaderboard) == 0:
+			rows = append(rows, m.styles.subtext.Render("No one is on it yet."))
+		default:
+			rows = append(rows, m.viewLeaderboardEntries(home.leaderboard)...)
+		}
+	case homeStats:
+		if s := home.stats; s != nil {
+			rows = append(rows,
+				"",
+				m.styles.subtext.Render(fmt.Sprintf("Streak %d, best %d", s.CurrentStreak, s.MaxStreak)),
+				m.styles.subtext.Render(fmt.Sprintf("%s points", formatThousands(s.Points-s.LossPenalties))),
+			)
+		}
+	}
+	return m.styles.box.Render(lipgloss.JoinVertical(lipgloss.Left, rows...))
+}
+
+// viewHomeItem returns the label of an entry of the home screen, and a short
+// description of it.
+func (m *model) viewHomeItem(item homeItem) (string, string) {
+	home := m.home
+	switch item {
+	case homeDaily:
+		label := fmt.Sprintf("Daily #%d", home.dailyNumber)
+		switch {
+		case home.daily != nil && home.daily.Won:
+			return label, fmt.Sprintf("solved in %d/%d", home.daily.NumGuesses, _numGuesses)
+		case home.finished:
+			return label, "not solved"
+		case home.stats != nil:
+			return label, "not played yet"
+		}
+		return label, "today's puzzle"
+	case homeQuickPlay:
+		return "Quick play", "a random word"
+	case homeDuel:
+		return "Duel", "invite or join a friend"
+	case homeLeaderboard:
+		return "Leaderboard", "today's puzzle"
+	case homeStats:
+		if home.stats == nil {
+			return "Stats", "connect with a key to keep them"
+		}
+		return "Stats", fmt.Sprintf("%d played, %.0f%% won", home.stats.Played, 100*home.stats.WinRate)
+	}
+	return "", ""
+}
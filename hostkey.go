@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/charmbracelet/x/term"
+	"github.com/pkg/errors"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// _envHostKeyPassphrase is the environment variable that holds the passphrase
+// of the host key, for unattended starts.
+const _envHostKeyPassphrase = "CLIDLE_HOST_KEY_PASSPHRASE"
+
+var (
+	// errWrongPassphrase is returned when the host key can't be decrypted
+	// with the passphrase given.
+	errWrongPassphrase = errors.New("wrong passphrase")
+	// errNoPassphrase is returned when the host key is encrypted, but no
+	// passphrase was given, and there's no terminal to ask for one.
+	errNoPassphrase = errors.Errorf("no passphrase given, use --host-key-passphrase-file or %s, or start clidle in a terminal", _envHostKeyPassphrase)
+)
+
+// passphraseFunc returns the passphrase of the host key. If confirm is set, as
+// for a new key, a passphrase typed in the terminal is asked for twice.
+type passphraseFunc func(confirm bool) ([]byte, error)
+
+// loadHostKey returns the server's host key, reading it from the given path, or
+// generating it there on the first start. Encrypted keys are decrypted with the
+// passphrase, and new keys are encrypted with it if encrypt is set. If a new
+// key can't be saved, such as in a read-only container, a temporary key is used
+// instead, which clients will see change on every restart.
+func loadHostKey(path string, passphrase passphraseFunc, encrypt bool) (gossh.Signer, error) {
+	b, err := os.ReadFile(path)
+	if err == nil {
+		return parseHostKey(path, b, passphrase)
+	} else if !errors.Is(err, fs.ErrNotExist) && !errors.Is(err, syscall.ENOTDIR) {
+		return nil, errors.Wrapf(err, "could not read host key %s, check its permissions or delete it to generate a new one", path)
+	}
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not generate host key")
+	}
+	var block *pem.Block
+	if encrypt {
+		pass, err := passphrase(true)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not encrypt host key")
+		}
+		block, err = gossh.MarshalPrivateKeyWithPassphrase(key, "", pass)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not encrypt host key")
+		}
+	} else {
+		block, err = gossh.MarshalPrivateKey(key, "")
+		if err != nil {
+			return nil, errors.Wrap(err, "could not encode host key")
+		}
+	}
+	signer, err := gossh.NewSignerFromKey(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not generate host key")
+	}
+
+	if err := writeHostKey(path, pem.EncodeToMemory(block)); err != nil {
+		slog.Warn("could not save host key, using a temporary one that clients will see change on every restart",
+			slog.String("path", path),
+			slog.Any("error", err),
+		)
+		return signer, nil
+	}
+	slog.Info("generated host key", slog.String("path", path), slog.Bool("encrypted", encrypt))
+	return signer, nil
+}
+
+// parseHostKey parses a host key in PEM format, asking for its passphrase if it
+// is encrypted. A wrong passphrase is told apart from a corrupt key.
+func parseHostKey(path string, b []byte, passphrase passphraseFunc) (gossh.Signer, error) {
+	signer, err := gossh.ParsePrivateKey(b)
+	var missing *gossh.PassphraseMissingError
+	if !errors.As(err, &missing) {
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid host key %s, delete it to generate a new one", path)
+		}
+		return signer, nil
+	}
+
+	pass, err := passphrase(false)
+	if err != nil {
+		return nil, errors.Wrapf(err, "host key %s is encrypted", path)
+	}
+	signer, err = gossh.ParsePrivateKeyWithPassphrase(b, pass)
+	if errors.Is(err, x509.IncorrectPasswordError) {
+		return nil, errors.Wrapf(errWrongPassphrase, "could not decrypt host key %s", path)
+	} else if err != nil {
+		return nil, errors.Wrapf(err, "invalid host key %s, delete it to generate a new one", path)
+	}
+	return signer, nil
+}
+
+// writeHostKey saves a new host key, readable only by the current user.
+func writeHostKey(path string, b []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		os.Remove(path)
+		return err
+	}
+	return f.Close()
+}
+
+// hostKeyPassphrase returns the passphrase of the host key from the given file,
+// or from the environment, or else by asking for it on the controlling
+// terminal. It is only read once it is needed, so that unencrypted keys don't
+// ask for one.
+func hostKeyPassphrase(file string) passphraseFunc {
+	return func(confirm bool) ([]byte, error) {
+		if file != "" {
+			b, err := os.ReadFile(file)
+			if err != nil {
+				return nil, errors.Wrap(err, "could not read passphrase")
+			}
+			if b = bytes.TrimRight(b, "\r\n"); len(b) == 0 {
+				return nil, errors.Errorf("passphrase file %s is empty", file)
+			}
+			return b, nil
+		}
+		if pass := os.Getenv(_envHostKeyPassphrase); pass != "" {
+			return []byte(pass), nil
+		}
+		return promptPassphrase(confirm)
+	}
+}
+
+// promptPassphrase asks for the passphrase of the host key on the controlling
+// terminal, without echoing it.
+func promptPassphrase(confirm bool) ([]byte, error) {
+	tty, err := os.Open(_ttyPath)
+	if err != nil || !term.IsTerminal(tty.Fd()) {
+		if tty != nil {
+			tty.Close()
+		}
+		return nil, errNoPassphrase
+	}
+	defer tty.Close()
+
+	read := func(prompt string) ([]byte, error) {
+		fmt.Fprint(os.Stderr, prompt)
+		defer fmt.Fprintln(os.Stderr)
+		return term.ReadPassword(tty.Fd())
+	}
+	pass, err := read("Host key passphrase: ")
+	if err != nil {
+		return nil, errors.Wrap(err, "could not read passphrase")
+	}
+	if len(pass) == 0 {
+		return nil, errors.New("passphrase is empty")
+	}
+	if confirm {
+		again, err := read("Confirm passphrase: ")
+		if err != nil {
+			return nil, errors.Wrap(err, "could not read passphrase")
+		}
+		if !bytes.Equal(pass, again) {
+			return nil, errors.New("passphrases don't match")
+		}
+	}
+	return pass, nil
+}
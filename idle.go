@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// _defaultIdleNudge is how long a game waits for a key press before nudging
+// the player, well within the server's idle timeout.
+const _defaultIdleNudge = 5 * time.Minute
+
+// _idleTimeout is how long players on the server can go without pressing a
+// key before they are disconnected.
+const _idleTimeout = 30 * time.Minute
+
+// _idleWarning is how long before the idle timeout players are warned that
+// they are about to be disconnected.
+const _idleWarning = time.Minute
+
+// msgIdleCheck is sent when the player may have been idle for long enough to
+// be nudged, warned or disconnected.
+type msgIdleCheck struct{}
+
+// checkIdle queues a check for whether the player has stopped pressing keys,
+// at the time they would next have been idle for long enough to be nudged,
+// warned or disconnected. Only one check is pending at a time.
+func (m *model) checkIdle() tea.Cmd {
+	if m.idleCheckPending {
+		return nil
+	}
+	var next time.Duration
+	consider := func(d time.Duration) {
+		if next == 0 || d < next {
+			next = d
+		}
+	}
+	if m.options.idleNudge > 0 && !m.idleNudged && m.canNudge() {
+		consider(m.options.idleNudge)
+	}
+	if timeout := m.options.idleTimeout; timeout > 0 {
+		if !m.idleWarned {
+			consider(timeout - _idleWarning)
+		}
+		consider(timeout)
+	}
+	if next == 0 {
+		return nil
+	}
+
+	m.idleCheckPending = true
+	after := m.clock.After(m.lastInputAt.Add(next).Sub(m.clock.Now()))
+	return func() tea.Msg {
+		select {
+		case <-after:
+			return msgIdleCheck{}
+		case <-m.ctx.Done():
+			return nil
+		}
+	}
+}
+
+// canNudge checks if the player would be nudged for being idle, which is only
+// during a game.
+func (m *model) canNudge() bool {
+	return !m.isGameOver() && !m.isWaitingForOpponent() && m.screen == screenGame
+}
+
+// updateIdleCheck nudges the player if no key has been pressed for a while
+// during a game. If there is an idle timeout, it also warns them shortly
+// before it, and exits once it is up, whatever they are doing. On the server,
+// the connection itself stays up as long as anything is drawn, so this is what
+// enforces it. In the terminal, the board is saved first, so that the game
+// picks up where it was left off. Keys pressed since the check was queued push
+// it back.
+func (m *model) updateIdleCheck() tea.Cmd {
+	m.idleCheckPending = false
+	idle := m.clock.Now().Sub(m.lastInputAt)
+	timeout := m.options.idleTimeout
+	switch {
+	case timeout > 0 && idle >= timeout:
+		m.idleTimedOut = true
+		// Pending writes are flushed once the program has exited, so the
+		// snapshot is written before clidle exits.
+		m.saveSnapshot()
+		return m.doExit()
+	case timeout > 0 && !m.idleWarned && idle >= timeout-_idleWarning:
+		m.idleWarned = true
+		outcome := "stay connected"
+		if m.remoteIP == "" {
+			outcome = "keep playing"
+		}
+		msg := fmt.Sprintf("Idle — press any key within %ds to %s", int(_idleWarning.Seconds()), outcome)
+		return tea.Batch(m.notify(), m.setStatus(msg, 0), m.checkIdle())
+	case m.options.idleNudge > 0 && !m.idleNudged && idle >= m.options.idleNudge && m.canNudge():
+		m.idleNudged = true
+		return tea.Batch(m.notify(), m.setStatus("Still there? Your game is waiting.", 0), m.checkIdle())
+	}
+	return m.checkIdle()
+}
+
+// recordInput notes that a key was pressed, so that the player isn't nudged
+// or warned for a while, and starts checking again if they were. The time
+// played is accrued up to the key press first.
+func (m *model) recordInput() tea.Cmd {
+	m.accruePlaytime(m.clock.Now())
+	m.lastInputAt = m.clock.Now()
+	m.idleNudged = false
+	m.idleWarned = false
+	return m.checkIdle()
+}
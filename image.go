@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ajeetdsouza/clidle/pkg/game"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/pkg/errors"
+)
+
+// Layout of exported images, in pixels.
+const (
+	_imageTileSize = 62
+	_imageTileGap  = 6
+	_imagePadding  = 12
+	// _imageGlyphScale is the size of a pixel of the tile font.
+	_imageGlyphScale = 6
+)
+
+// _imageBackground is the background color of exported images, matching the
+// dark terminals that the state colors are chosen for.
+const _imageBackground = "#121213"
+
+// _imageFormats contains the file extensions accepted by --export-image.
+var _imageFormats = map[string]struct{}{
+	".png": {},
+	".svg": {},
+}
+
+// _imageGlyphs is a 5x7 pixel font for the letters drawn on tiles in PNG
+// images, indexed by ch - 'A'. Each row is drawn from its highest bit.
+var _imageGlyphs = [26][7]uint8{
+	{0b01110, 0b10001, 0b10001, 0b11111, 0b10001, 0b10001, 0b10001}, // A
+	{0b11110, 0b10001, 0b10001, 0b11110, 0b10001, 0b10001, 0b11110}, // B
+	{0b01110, 0b10001, 0b10000, 0b10000, 0b10000, 0b10001, 0b01110}, // C
+	{0b11110, 0b10001, 0b10001, 0b10001, 0b10001, 0b10001, 0b11110}, // D
+	{0b11111, 0b10000, 0b10000, 0b11110, 0b10000, 0b10000, 0b11111}, // E
+	{0b11111, 0b10000, 0b10000, 0b11110, 0b10000, 0b10000, 0b10000}, // F
+	{0b01110, 0b10001, 0b10000, 0b10111, 0b10001, 0b10001, 0b01111}, // G
+	{0b10001, 0b10001, 0b10001, 0b11111, 0b10001, 0b10001, 0b10001}, // H
+	{0b01110, 0b00100, 0b00100, 0b00100, 0b00100, 0b00100, 0b01110}, // I
+	{0b00111, 0b00010, 0b00010, 0b00010, 0b00010, 0b10010, 0b01100}, // J
+	{0b10001, 0b10010, 0b10100, 0b11000, 0b10100, 0b10010, 0b10001}, // K
+	{0b10000, 0b10000, 0b10000, 0b10000, 0b10000, 0b10000, 0b11111}, // L
+	{0b10001, 0b11011, 0b10101, 0b10101, 0b10001, 0b10001, 0b10001}, // M
+	{0b10001, 0b10001, 0b11001, 0b10101, 0b10011, 0b10001, 0b10001}, // N
+	{0b01110, 0b10001, 0b10001, 0b10001, 0b10001, 0b10001, 0b01110}, // O
+	{0b11110, 0b10001, 0b10001, 0b11110, 0b10000, 0b10000, 0b10000}, // P
+	{0b01110, 0b10001, 0b10001, 0b10001, 0b10101, 0b10010, 0b01101}, // Q
+	{0b11110, 0b10001, 0b10001, 0b11110, 0b10100, 0b10010, 0b10001}, // R
+	{0b01111, 0b10000, 0b10000, 0b01110, 0b00001, 0b00001, 0b11110}, // S
+	{0b11111, 0b00100, 0b00100, 0b00100, 0b00100, 0b00100, 0b00100}, // T
+	{0b10001, 0b10001, 0b10001, 0b10001, 0b10001, 0b10001, 0b01110}, // U
+	{0b10001, 0b10001, 0b10001, 0b10001, 0b10001, 0b01010, 0b00100}, // V
+	{0b10001, 0b10001, 0b10001, 0b10101, 0b10101, 0b10101, 0b01010}, // W
+	{0b10001, 0b10001, 0b01010, 0b00100, 0b01010, 0b10001, 0b10001}, // X
+	{0b10001, 0b10001, 0b01010, 0b00100, 0b00100, 0b00100, 0b00100}, // Y
+	{0b11111, 0b00001, 0b00010, 0b00100, 0b01000, 0b10000, 0b11111}, // Z
+}
+
+// exportImage queues writing the board of the current game to the image file
+// given by --export-image, replacing the image of the previous game.
+func (m *model) exportImage() tea.Cmd {
+	path := m.options.exportImage
+	if path == "" {
+		return nil
+	}
+	answer, guesses := m.game.Answer(), m.game.Guesses()
+	return m.writes.enqueue(func() tea.Msg {
+		return msgSaved{err: writeBoardImage(path, answer, guesses)}
+	})
+}
+
+// writeBoardImage writes the colored rows of a game with the given answer and
+// guesses to an image file. The format is chosen by the file extension.
+func writeBoardImage(path string, answer game.Word, guesses []game.Word) error {
+	var buf bytes.Buffer
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".svg":
+		writeBoardSVG(&buf, answer, guesses)
+	case ".png":
+		if err := png.Encode(&buf, drawBoard(answer, guesses)); err != nil {
+			return errors.Wrap(err, "could not encode image")
+		}
+	default:
+		return errors.Errorf("unsupported image format: %q", ext)
+	}
+	return errors.Wrap(os.WriteFile(path, buf.Bytes(), 0644), "could not write image")
+}
+
+// boardImageSize returns the size of the image of a board with the given
+// number of rows.
+func boardImageSize(numRows int) (int, int) {
+	width := 2*_imagePadding + _numChars*_imageTileSize + (_numChars-1)*_imageTileGap
+	height := 2*_imagePadding + numRows*_imageTileSize + max(0, numRows-1)*_imageTileGap
+	return width, height
+}
+
+// tileOrigin returns the top left corner of a tile in the image of a board.
+func tileOrigin(row, col int) (int, int) {
+	return _imagePadding + col*(_imageTileSize+_imageTileGap), _imagePadding + row*(_imageTileSize+_imageTileGap)
+}
+
+// writeBoardSVG writes the image of a board as SVG, with the letters as text.
+func writeBoardSVG(buf *bytes.Buffer, answer game.Word, guesses []game.Word) {
+	width, height := boardImageSize(len(guesses))
+	fmt.Fprintf(buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`+"\n", width, height, width, height)
+	fmt.Fprintf(buf, `<rect width="100%%" height="100%%" fill="%s"/>`+"\n", _imageBackground)
+	for row, guess := range guesses {
+		feedback := game.Evaluate(guess, answer)
+		for col, ch := range guess {
+			x, y := tileOrigin(row, col)
+			fmt.Fprintf(buf, `<rect x="%d" y="%d" width="%d" height="%d" fill="%s"/>`+"\n",
+				x, y, _imageTileSize, _imageTileSize, keyState(feedback[col]).color().TrueColor)
+			fmt.Fprintf(buf, `<text x="%d" y="%d" fill="%s" font-family="sans-serif" font-size="32" font-weight="bold" text-anchor="middle" dominant-baseline="central">%c</text>`+"\n",
+				x+_imageTileSize/2, y+_imageTileSize/2, _colorPrimary.TrueColor, ch)
+		}
+	}
+	buf.WriteString("</svg>\n")
+}
+
+// drawBoard draws the image of a board, with the letters in a pixel font so
+// that no font has to be installed.
+func drawBoard(answer game.Word, guesses []game.Word) image.Image {
+	width, height := boardImageSize(len(guesses))
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), image.NewUniform(parseHexColor(_imageBackground)), image.Point{}, draw.Src)
+
+	letter := image.NewUniform(parseHexColor(_colorPrimary.TrueColor))
+	glyphWidth, glyphHeight := 5*_imageGlyphScale, 7*_imageGlyphScale
+	for row, guess := range guesses {
+		feedback := game.Evaluate(guess, answer)
+		for col, ch := range guess {
+			x, y := tileOrigin(row, col)
+			tile := image.Rect(x, y, x+_imageTileSize, y+_imageTileSize)
+			fill := parseHexColor(keyState(feedback[col]).color().TrueColor)
+			draw.Draw(img, tile, image.NewUniform(fill), image.Point{}, draw.Src)
+
+			left, top := x+(_imageTileSize-glyphWidth)/2, y+(_imageTileSize-glyphHeight)/2
+			for i, bits := range _imageGlyphs[ch-'A'] {
+				for j := 0; j < 5; j++ {
+					if bits&(1<<(4-j)) == 0 {
+						continue
+					}
+					px := left + j*_imageGlyphScale
+					py := top + i*_imageGlyphScale
+					draw.Draw(img, image.Rect(px, py, px+_imageGlyphScale, py+_imageGlyphScale), letter, image.Point{}, draw.Src)
+				}
+			}
+		}
+	}
+	return img
+}
+
+// parseHexColor parses a color in the #rrggbb format used by the true color
+// palette. Invalid colors are parsed as black.
+func parseHexColor(s string) color.RGBA {
+	c := color.RGBA{A: 0xff}
+	fmt.Sscanf(s, "#%02x%02x%02x", &c.R, &c.G, &c.B)
+	return c
+}
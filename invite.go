@@ -0,0 +1,236 @@
+package main
+
+import (
+	"crypto/rand"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ajeetdsouza/clidle/pkg/game"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/pkg/errors"
+)
+
+const (
+	// _inviteCodeLength is the number of characters in an invite code.
+	_inviteCodeLength = 6
+	// _inviteCodeChars are the characters of invite codes. Characters that
+	// are easily mistaken for each other (0/O, 1/I/L) are left out.
+	_inviteCodeChars = "ABCDEFGHJKMNPQRSTUVWXYZ23456789"
+	// _inviteTTL is how long an invite can be joined after it is created.
+	_inviteTTL = 10 * time.Minute
+)
+
+var (
+	errInviteNotFound = errors.New("no duel with that code")
+	errInviteExpired  = errors.New("that code has expired")
+)
+
+// invites pairs up players on the server who share an invite code, for duels
+// between friends. Each code can only be joined once.
+type invites struct {
+	dictionary Dictionary
+	clock      clock
+
+	mu      sync.Mutex
+	pending map[string]invite
+}
+
+// invite is a match waiting for the player with its code to join.
+type invite struct {
+	match   *match
+	expires time.Time
+}
+
+func newInvites(dictionary Dictionary, clock clock) *invites {
+	return &invites{dictionary: dictionary, clock: clock, pending: make(map[string]invite)}
+}
+
+// create starts a match for a player, and returns the code with which an
+// opponent can join it. Expired invites are cleaned up along the way.
+func (iv *invites) create(name string) (string, *match, error) {
+	iv.mu.Lock()
+	defer iv.mu.Unlock()
+
+	now := iv.clock.Now()
+	for code, inv := range iv.pending {
+		if now.After(inv.expires) {
+			delete(iv.pending, code)
+		}
+	}
+
+	var code string
+	for code == "" || iv.pending[code].match != nil {
+		var err error
+		if code, err = newInviteCode(); err != nil {
+			return "", nil, err
+		}
+	}
+
+	var answer game.Word
+	copy(answer[:], iv.dictionary.GetRandomCommonWord())
+	mt := newMatch(answer, name)
+	iv.pending[code] = invite{match: mt, expires: now.Add(_inviteTTL)}
+	return code, mt, nil
+}
+
+// join adds a player to the match with the given code, which can't be joined
+// again afterwards. Codes are case-insensitive.
+func (iv *invites) join(code, name string) (*match, error) {
+	code = strings.ToUpper(code)
+
+	iv.mu.Lock()
+	inv, ok := iv.pending[code]
+	delete(iv.pending, code)
+	iv.mu.Unlock()
+
+	switch {
+	case !ok:
+		return nil, errInviteNotFound
+	case iv.clock.Now().After(inv.expires):
+		return nil, errInviteExpired
+	}
+	inv.match.start(name)
+	return inv.match, nil
+}
+
+// cancel withdraws the invite to a match, if it hasn't been joined yet.
+func (iv *invites) cancel(code string, mt *match) {
+	iv.mu.Lock()
+	defer iv.mu.Unlock()
+	if iv.pending[code].match == mt {
+		delete(iv.pending, code)
+	}
+}
+
+// newInviteCode returns a random invite code.
+func newInviteCode() (string, error) {
+	var sb strings.Builder
+	numChars := big.NewInt(int64(len(_inviteCodeChars)))
+	for i := 0; i < _inviteCodeLength; i++ {
+		n, err := rand.Int(rand.Reader, numChars)
+		if err != nil {
+			return "", errors.Wrap(err, "could not generate invite code")
+		}
+		sb.WriteByte(_inviteCodeChars[n.Int64()])
+	}
+	return sb.String(), nil
+}
+
+// doToggleDuel shows or hides the duel screen, on which the player can invite
+// a friend or join their duel.
+func (m *model) doToggleDuel() tea.Cmd {
+	if m.invites == nil || m.match != nil {
+		return nil
+	}
+	if m.screen == screenDuel {
+		m.closeScreen()
+	} else {
+		m.openScreen(screenDuel)
+	}
+	m.duelCode = ""
+	return nil
+}
+
+// updateDuel handles key presses while the duel screen is shown.
+func (m *model) updateDuel(msg tea.KeyMsg) tea.Cmd {
+	switch msg.Type {
+	case tea.KeyCtrlC:
+		return m.doExit()
+	case tea.KeyEsc, tea.KeyCtrlT:
+		m.closeScreen()
+	case tea.KeyCtrlN:
+		return m.doCreateInvite()
+	case tea.KeyEnter:
+		return m.doJoinInvite()
+	case tea.KeyBackspace:
+		if len(m.duelCode) > 0 {
+			m.duelCode = m.duelCode[:len(m.duelCode)-1]
+		}
+	case tea.KeyRunes:
+		for _, r := range strings.ToUpper(string(msg.Runes)) {
+			if len(m.duelCode) < _inviteCodeLength && strings.ContainsRune(_inviteCodeChars, r) {
+				m.duelCode += string(r)
+			}
+		}
+	}
+	return nil
+}
+
+// doCreateInvite starts a duel that a friend can join with a code, and waits
+// for them until the code expires.
+func (m *model) doCreateInvite() tea.Cmd {
+	code, mt, err := m.invites.create(m.playerName)
+	if err != nil {
+		return m.setStatus("Couldn't create an invite.", 2*time.Second)
+	}
+	m.screen, m.back = screenGame, screenGame
+	m.inviteCode = code
+	cmd := m.startMatch(mt, 0)
+	m.setStatus("Share your code "+code+" with a friend. (esc to cancel)", 0)
+
+	after := m.clock.After(_inviteTTL)
+	expire := func() tea.Msg {
+		select {
+		case <-after:
+			return msgInviteExpired{match: mt}
+		case <-m.ctx.Done():
+			return nil
+		}
+	}
+	return tea.Batch(cmd, expire)
+}
+
+// doJoinInvite joins the duel with the code that has been typed in.
+func (m *model) doJoinInvite() tea.Cmd {
+	mt, err := m.invites.join(m.duelCode, m.playerName)
+	if err != nil {
+		m.duelCode = ""
+		msg := "No duel has that code."
+		if errors.Is(err, errInviteExpired) {
+			msg = "That code has expired."
+		}
+		return m.setStatus(msg, 2*time.Second)
+	}
+	m.screen, m.back = screenGame, screenGame
+	return m.startMatch(mt, 1)
+}
+
+// doCancelInvite withdraws the player's invite while it is waiting for a
+// friend, and starts a regular game instead.
+func (m *model) doCancelInvite() tea.Cmd {
+	m.leaveMatch()
+	return m.doRestart()
+}
+
+// msgInviteExpired is sent when the invite to a match has expired.
+type msgInviteExpired struct {
+	match *match
+}
+
+// updateInviteExpired ends the wait for a friend once the invite has expired,
+// unless they joined in time.
+func (m *model) updateInviteExpired(msg msgInviteExpired) tea.Cmd {
+	if msg.match != m.match || !m.isWaitingForOpponent() {
+		return nil
+	}
+	cmd := m.doCancelInvite()
+	return tea.Batch(cmd, m.setStatus("Your invite expired.", 2*time.Second))
+}
+
+// viewDuel renders the duel screen, including a border.
+func (m *model) viewDuel() string {
+	code := m.duelCode + strings.Repeat("_", _inviteCodeLength-len(m.duelCode))
+	rows := []string{
+		m.styles.text.Render("Duel a friend"),
+		"",
+		m.styles.subtext.Render("Enter their code to join:"),
+		m.styles.highlight.Render(code),
+		"",
+		m.styles.subtext.Render("Or press ctrl+n to get a"),
+		m.styles.subtext.Render("code to send them."),
+	}
+	return m.styles.box.Render(lipgloss.JoinVertical(lipgloss.Left, rows...))
+}
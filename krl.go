@@ -0,0 +1,278 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/binary"
+	"math/big"
+	"os"
+
+	"github.com/pkg/errors"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// _krlMagic starts a key revocation list in the binary format of OpenSSH,
+// described in PROTOCOL.krl.
+const _krlMagic = "SSHKRL\n\x00"
+
+// Sections of a key revocation list, and of its certificate sections.
+const (
+	_krlSectionCertificates      = 1
+	_krlSectionExplicitKey       = 2
+	_krlSectionFingerprintSHA1   = 3
+	_krlSectionSignature         = 4
+	_krlSectionFingerprintSHA256 = 5
+
+	_krlCertSerialList   = 0x20
+	_krlCertSerialRange  = 0x21
+	_krlCertSerialBitmap = 0x22
+	_krlCertKeyID        = 0x23
+)
+
+// revocationList holds revoked keys and certificates, as read from an OpenSSH
+// key revocation list (KRL), or from a list of public keys like sshd's
+// RevokedKeys.
+type revocationList struct {
+	// keys are revoked keys, by their wire format. Revoking the key of a
+	// certificate authority revokes every certificate that it signed.
+	keys map[string]bool
+	// sha1 and sha256 are the hashes of the wire format of revoked keys.
+	sha1   map[[sha1.Size]byte]bool
+	sha256 map[[sha256.Size]byte]bool
+	// certs are the revoked certificates of each certificate authority, by
+	// the wire format of its key, or "" for those of any authority.
+	certs map[string]*revokedCerts
+}
+
+// revokedCerts are the certificates revoked for a certificate authority.
+type revokedCerts struct {
+	serials [][2]uint64 // inclusive ranges
+	bitmaps []serialBitmap
+	keyIDs  map[string]bool
+}
+
+// serialBitmap revokes the serial offset+i for every bit i that is set.
+type serialBitmap struct {
+	offset uint64
+	bits   *big.Int
+}
+
+// loadRevocationList reads a key revocation list from the given file, which is
+// either a KRL, or public keys in the authorized_keys format, one per line.
+func loadRevocationList(path string) (*revocationList, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not read revoked keys")
+	}
+	if bytes.HasPrefix(b, []byte(_krlMagic)) {
+		krl, err := parseKRL(b)
+		return krl, errors.Wrapf(err, "invalid key revocation list %s", path)
+	}
+
+	krl := newRevocationList()
+	for rest := b; len(bytes.TrimSpace(rest)) > 0; {
+		var key gossh.PublicKey
+		key, _, _, rest, err = gossh.ParseAuthorizedKey(rest)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid revoked keys %s", path)
+		}
+		krl.keys[string(key.Marshal())] = true
+	}
+	return krl, nil
+}
+
+func newRevocationList() *revocationList {
+	return &revocationList{
+		keys:   map[string]bool{},
+		sha1:   map[[sha1.Size]byte]bool{},
+		sha256: map[[sha256.Size]byte]bool{},
+		certs:  map[string]*revokedCerts{},
+	}
+}
+
+// parseKRL parses a key revocation list in the binary format. Its signatures,
+// if any, aren't checked, as with sshd.
+func parseKRL(b []byte) (*revocationList, error) {
+	r := krlReader{b: b[len(_krlMagic):]}
+	if version := r.uint32(); version != 1 {
+		return nil, errors.Errorf("unsupported format version %d", version)
+	}
+	r.uint64() // krl_version
+	r.uint64() // generated_date
+	r.uint64() // flags
+	r.string() // reserved
+	r.string() // comment
+
+	krl := newRevocationList()
+	for r.err == nil && len(r.b) > 0 {
+		sectionType := r.byte()
+		section := krlReader{b: r.string()}
+		switch sectionType {
+		case _krlSectionCertificates:
+			caKey := string(section.string())
+			section.string() // reserved
+			certs := krl.certs[caKey]
+			if certs == nil {
+				certs = &revokedCerts{keyIDs: map[string]bool{}}
+				krl.certs[caKey] = certs
+			}
+			section.err = certs.parse(&section)
+		case _krlSectionExplicitKey:
+			for section.err == nil && len(section.b) > 0 {
+				krl.keys[string(section.string())] = true
+			}
+		case _krlSectionFingerprintSHA1:
+			for section.err == nil && len(section.b) > 0 {
+				var hash [sha1.Size]byte
+				if copy(hash[:], section.string()) != sha1.Size {
+					return nil, errors.New("invalid SHA1 fingerprint")
+				}
+				krl.sha1[hash] = true
+			}
+		case _krlSectionFingerprintSHA256:
+			for section.err == nil && len(section.b) > 0 {
+				var hash [sha256.Size]byte
+				if copy(hash[:], section.string()) != sha256.Size {
+					return nil, errors.New("invalid SHA256 fingerprint")
+				}
+				krl.sha256[hash] = true
+			}
+		case _krlSectionSignature:
+			// Signatures come last, and cover the sections before them.
+			return krl, r.err
+		default:
+			return nil, errors.Errorf("unknown section type %d", sectionType)
+		}
+		if section.err != nil {
+			return nil, section.err
+		}
+	}
+	return krl, r.err
+}
+
+// parse reads the revoked certificates in a certificate section.
+func (c *revokedCerts) parse(r *krlReader) error {
+	for r.err == nil && len(r.b) > 0 {
+		sectionType := r.byte()
+		section := krlReader{b: r.string()}
+		switch sectionType {
+		case _krlCertSerialList:
+			for section.err == nil && len(section.b) > 0 {
+				serial := section.uint64()
+				c.serials = append(c.serials, [2]uint64{serial, serial})
+			}
+		case _krlCertSerialRange:
+			lo, hi := section.uint64(), section.uint64()
+			if lo > hi {
+				return errors.Errorf("invalid serial range %d-%d", lo, hi)
+			}
+			c.serials = append(c.serials, [2]uint64{lo, hi})
+		case _krlCertSerialBitmap:
+			offset := section.uint64()
+			c.bitmaps = append(c.bitmaps, serialBitmap{offset: offset, bits: new(big.Int).SetBytes(section.string())})
+		case _krlCertKeyID:
+			for section.err == nil && len(section.b) > 0 {
+				c.keyIDs[string(section.string())] = true
+			}
+		default:
+			return errors.Errorf("unknown certificate section type %#x", sectionType)
+		}
+		if section.err != nil {
+			return section.err
+		}
+	}
+	return r.err
+}
+
+// revoked reports whether a certificate is revoked here.
+func (c *revokedCerts) revoked(cert *gossh.Certificate) bool {
+	if c == nil {
+		return false
+	}
+	if c.keyIDs[cert.KeyId] {
+		return true
+	}
+	for _, serials := range c.serials {
+		if cert.Serial >= serials[0] && cert.Serial <= serials[1] {
+			return true
+		}
+	}
+	for _, bitmap := range c.bitmaps {
+		if cert.Serial >= bitmap.offset && cert.Serial-bitmap.offset < uint64(bitmap.bits.BitLen()) &&
+			bitmap.bits.Bit(int(cert.Serial-bitmap.offset)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// revoked reports whether a key is revoked. For a certificate, that is if the
+// certificate, its key, or the key of the authority that signed it is.
+func (krl *revocationList) revoked(key gossh.PublicKey) bool {
+	if krl == nil {
+		return false
+	}
+	if cert, ok := key.(*gossh.Certificate); ok {
+		if krl.revokedKey(cert.Key) || krl.revokedKey(cert.SignatureKey) {
+			return true
+		}
+		return krl.certs[""].revoked(cert) || krl.certs[string(cert.SignatureKey.Marshal())].revoked(cert)
+	}
+	return krl.revokedKey(key)
+}
+
+func (krl *revocationList) revokedKey(key gossh.PublicKey) bool {
+	blob := key.Marshal()
+	return krl.keys[string(blob)] || krl.sha1[sha1.Sum(blob)] || krl.sha256[sha256.Sum256(blob)]
+}
+
+// krlReader reads the fields of a KRL in the SSH wire format. Once a field
+// can't be read, err is set, and later fields are zero.
+type krlReader struct {
+	b   []byte
+	err error
+}
+
+func (r *krlReader) next(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || len(r.b) < n {
+		r.err = errors.New("unexpected end of data")
+		return nil
+	}
+	b := r.b[:n]
+	r.b = r.b[n:]
+	return b
+}
+
+func (r *krlReader) byte() byte {
+	if b := r.next(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (r *krlReader) uint32() uint32 {
+	if b := r.next(4); b != nil {
+		return binary.BigEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (r *krlReader) uint64() uint64 {
+	if b := r.next(8); b != nil {
+		return binary.BigEndian.Uint64(b)
+	}
+	return 0
+}
+
+func (r *krlReader) string() []byte {
+	n := r.uint32()
+	if n > uint32(len(r.b)) {
+		r.err = errors.New("unexpected end of data")
+		return nil
+	}
+	return r.next(int(n))
+}
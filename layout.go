@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// gridLayout is how the tiles of the grid are drawn. Layouts are ordered from
+// widest to narrowest, and the widest one that fits the window is used.
+type gridLayout int
+
+const (
+	// _gridLayoutTiles draws bordered tiles with padding, separated by the
+	// tile gap.
+	_gridLayoutTiles gridLayout = iota
+	// _gridLayoutSlim draws bordered tiles without padding or gaps.
+	_gridLayoutSlim
+	// _gridLayoutLetters draws letters in the color of their tile, separated
+	// by spaces.
+	_gridLayoutLetters
+	// _gridLayoutCompact draws letters on a background of the color of their
+	// tile, next to each other.
+	_gridLayoutCompact
+	// _gridLayoutTooNarrow means that not even the compact layout fits, and
+	// the player is asked for a wider window.
+	_gridLayoutTooNarrow
+)
+
+// rowWidth returns the width of a grid row of numChars tiles in the layout.
+func (l gridLayout) rowWidth(numChars, tileGap int) int {
+	switch l {
+	case _gridLayoutTiles:
+		return 5*numChars + tileGap*(numChars-1)
+	case _gridLayoutSlim:
+		return 3 * numChars
+	case _gridLayoutLetters:
+		return 2*numChars - 1
+	default:
+		return numChars
+	}
+}
+
+// chooseGridLayout returns the widest layout in which a grid row of numChars
+// tiles fits in the given width. A width of zero means that it isn't known yet,
+// and fits everything.
+func chooseGridLayout(width, numChars, tileGap int) gridLayout {
+	if width <= 0 {
+		return _gridLayoutTiles
+	}
+	for l := _gridLayoutTiles; l < _gridLayoutTooNarrow; l++ {
+		if l.rowWidth(numChars, tileGap) <= width {
+			return l
+		}
+	}
+	return _gridLayoutTooNarrow
+}
+
+// gridLayout returns the layout of the grid in the current window, leaving room
+// for the opponent's progress during a duel.
+func (m *model) gridLayout() gridLayout {
+	width := m.windowWidth
+	if m.match != nil && width > 0 {
+		width = max(1, width-2-lipgloss.Width(m.viewOpponent()))
+	}
+	return chooseGridLayout(width, _numChars, m.options.tileGap)
+}
+
+// viewTooNarrow asks for a wider window when not even the compact grid fits.
+func (m *model) viewTooNarrow() string {
+	msg := fmt.Sprintf("Please widen your terminal to at least %d columns.", _gridLayoutCompact.rowWidth(_numChars, 0))
+	msg = m.styles.status.Width(m.windowWidth).Align(lipgloss.Center).Render(msg)
+	return m.renderer.Place(m.windowWidth, m.windowHeight, lipgloss.Center, lipgloss.Center, msg)
+}
+
+// viewTile renders a tile of the grid in the given layout, underlining the
+// letter if it is under the cursor.
+func (m *model) viewTile(key string, state keyState, layout gridLayout, cursor bool) string {
+	if m.options.lowercase {
+		key = strings.ToLower(key)
+	}
+	return m.styles.renderTile(key, state, layout, cursor)
+}
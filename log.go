@@ -0,0 +1,46 @@
+package main
+
+import (
+	"io"
+	"log/slog"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+// setupLogging configures the default logger to write to the given file, or to
+// stderr if none is given, in the given format (text or json). If quiet is set,
+// only errors are written to stderr.
+func setupLogging(path, format string, quiet bool) error {
+	var w io.Writer = os.Stderr
+	level := slog.LevelInfo
+	if quiet {
+		level = slog.LevelError
+	}
+	if path != "" {
+		level = slog.LevelInfo
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+		if err != nil {
+			return errors.Wrap(err, "could not open log file")
+		}
+		w = f
+	}
+
+	switch format {
+	case "text":
+		// Errors are logged by message, since the text handler would otherwise
+		// include their stack trace.
+		options := &slog.HandlerOptions{Level: level, ReplaceAttr: func(_ []string, attr slog.Attr) slog.Attr {
+			if err, ok := attr.Value.Any().(error); ok {
+				attr.Value = slog.StringValue(err.Error())
+			}
+			return attr
+		}}
+		slog.SetDefault(slog.New(slog.NewTextHandler(w, options)))
+	case "json":
+		slog.SetDefault(slog.New(slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level})))
+	default:
+		return errors.Errorf("invalid log format: %s", format)
+	}
+	return nil
+}
@@ -4,32 +4,43 @@ import (
 	"context"
 	_ "embed"
 	"flag"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strings"
+	"sync"
 	"syscall"
 	"time"
 
 	"database/sql"
 
-	"github.com/adrg/xdg"
 	"github.com/ajeetdsouza/clidle/store"
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
 	"github.com/charmbracelet/ssh"
 	"github.com/charmbracelet/wish"
 	wtea "github.com/charmbracelet/wish/bubbletea"
 	"github.com/pkg/errors"
+	gossh "golang.org/x/crypto/ssh"
+	"golang.org/x/term"
 
-	"golang.org/x/exp/slog"
 	_ "modernc.org/sqlite"
 )
 
 var (
-	// pathClidle is the path to the local data directory.
-	// This is usually set to ~/.local/share/clidle on most UNIX systems.
-	pathClidle  = filepath.Join(xdg.DataHome, "clidle")
-	pathStore   = filepath.Join(pathClidle, "clidle.db")
-	pathHostKey = filepath.Join(pathClidle, "hostkey")
+	// pathClidle is the path to the local data directory: ~/.local/share/clidle
+	// on most UNIX systems, ~/Library/Application Support/clidle on macOS, and
+	// %LOCALAPPDATA%\clidle on Windows.
+	pathClidle   = dataDir()
+	pathStore    = filepath.Join(pathClidle, "clidle.db")
+	pathHostKey  = filepath.Join(pathClidle, "hostkey")
+	pathSnapshot = filepath.Join(pathClidle, "snapshot.json")
+	pathSettings = filepath.Join(pathClidle, "settings.json")
 
 	//go:embed schema.sql
 	schemaSQL string
@@ -41,15 +52,327 @@ var (
 	}
 )
 
+// ctxKeyModel is the key under which an SSH session's model, wrapped in a
+// crashGuard, is stored in its context.
+type ctxKeyModel struct{}
+
 func main() {
 	flagServe := flag.String("serve", "", "Spawns an SSH server on the given address (format: 0.0.0.0:1337)")
+	flagAPIAddr := flag.String("api-addr", "", "Serves the game over a JSON API on the given address instead of playing in the terminal (format: 127.0.0.1:8080)")
+	flagMaxSessions := flag.Int("max-sessions", 0, "Maximum number of players connected to the server at once (0: no limit)")
+	flagMinSize := flag.String("min-size", "25x20", "Minimum terminal size (WxH) of players connecting to the server; smaller terminals are asked to grow")
+	flagHostKeyPassphraseFile := flag.String("host-key-passphrase-file", "", "Reads the passphrase of an encrypted host key from the given file, instead of asking for it in the terminal or reading "+_envHostKeyPassphrase)
+	flagHostKeyEncrypt := flag.Bool("host-key-encrypt", false, "Encrypts the host key with a passphrase when generating it on the first server start")
+	flagTrustedUserCA := flag.String("trusted-user-ca", "", "Lets in players with user certificates signed by the CA keys in the given file, identified by the certificate's key ID")
+	flagRevokedKeys := flag.String("revoked-keys", "", "Rejects the keys and certificates revoked in the given OpenSSH key revocation list, or list of public keys")
+	flagRoster := flag.String("roster", "", "Names players and gives them roles from the given JSON file, by key fingerprint or certificate principal; reloaded on SIGHUP")
+	flagNoGuests := flag.Bool("no-guests", false, "Only lets in players with an SSH key, instead of letting players without one in as guests")
+	flagDaily := flag.Bool("daily", false, "Plays the daily puzzle, which is the same for everyone on a given day")
+	flagDailyEpoch := flag.String("daily-epoch", _defaultDailyEpoch, "Date of daily puzzle #1 (format: YYYY-MM-DD)")
+	flagDailyGuessInterval := flag.Duration("daily-guess-interval", _defaultDailyGuessInterval, "Minimum time between a player's guesses on the daily puzzle on the server, to keep the leaderboard fair (0 for no minimum)")
+	flagDailyTimezone := flag.String("daily-timezone", "UTC", "Time zone in which the daily puzzle changes at midnight (e.g. America/New_York)")
+	flagExpertKeyboard := flag.Bool("expert-keyboard", false, "Marks letters whose every copy has been located with a distinct color")
+	flagUltraHard := flag.Bool("ultra-hard", false, "Rejects guesses that reuse absent letters, repeat present letters in the same position, or move correct letters")
+	flagFreePlay := flag.Bool("free-play", false, "Accepts any five letters as a guess, in unscored practice games")
+	flagRevealOnQuit := flag.Bool("reveal-on-quit", false, "Prints the answer of an unfinished practice game on exit")
+	flagAutoSubmit := flag.Bool("auto-submit", false, "Submits a guess shortly after its last letter is typed, without pressing enter")
+	flagBenchmark := flag.Bool("benchmark", false, "Shows how many guesses a solver would have taken from your first guess")
+	flagWalkthrough := flag.Bool("walkthrough", false, "Shows how a solver would have found the answer when you lose a game")
+	flagQuiet := flag.Bool("quiet", false, "Doesn't print the result of the last game on exit, or log anything but errors to stderr")
+	flagBorder := flag.String("border", "normal", "Border style for tiles and the keyboard (normal, rounded, thick, double)")
+	flagKeyboard := flag.String("keyboard", "auto", "Keyboard display (auto: letters only or hidden if it doesn't fit, compact: without a frame, full: always shown, off: never shown)")
+	flagCase := flag.String("case", "upper", "Letter case of tiles and keyboard labels (upper, lower)")
+	flagTileGap := flag.Int("tile-gap", 0, "Number of blank cells between tiles in the grid")
+	flagMaxRarity := flag.Int("max-rarity", _rarityUncommon, "Only accepts guesses up to the given rarity (0: possible answers only, 1: all words)")
+	flagNoPersist := flag.Bool("no-persist", false, "Doesn't save games or create a database; scores only last for the session")
+	flagAutosave := flag.Duration("autosave", 5*time.Second, "Saves the board of a game in progress this often while you type, if it has changed (0 to only save it after every guess)")
+	flagStartupStats := flag.Bool("startup-stats", true, "Briefly shows a summary of your stats on startup")
+	flagVersus := flag.Bool("versus", false, "Pairs up players connecting to the server to race on the same word")
+	flagLossPenalty := flag.Int("loss-penalty", 0, "Points deducted from your total score for every lost game")
+	flagFreebie := flag.Bool("freebie", false, "Gives away one letter of the answer in its position at the start of every game, for a few points")
+	flagReferee := flag.Bool("referee", false, "Keeps the answer of every game on the server outside of the session playing it, which only gets the feedback for its guesses until the game is over")
+	flagWebAddr := flag.String("web-addr", "", "Serves a read-only leaderboard page at /leaderboard on the given address alongside the server (format: 0.0.0.0:8080)")
+	flagMetricsAddr := flag.String("metrics-addr", "", "Serves Prometheus metrics at /metrics on the given address alongside the server (format: 127.0.0.1:9090)")
+	flagPprofAddr := flag.String("pprof-addr", "", "Serves net/http/pprof at /debug/pprof/ on the given loopback address alongside the server (format: 127.0.0.1:6060)")
+	flagPprofAllowRemote := flag.Bool("pprof-allow-remote", false, "Allows --pprof-addr to listen on an address that isn't loopback")
+	flagWebhookURL := flag.String("webhook-url", "", "Posts notable events on the server as JSON to the given URL")
+	flagWebhookSecret := flag.String("webhook-secret", "", "Signs webhook bodies with HMAC-SHA256 using the given secret, sent in the X-Clidle-Signature header")
+	flagWebhookFirstSolve := flag.Bool("webhook-first-solve", true, "Sends a webhook when a player is the first to solve the daily puzzle")
+	flagWebhookHighScore := flag.Bool("webhook-high-score", true, "Sends a webhook when a player takes the lead of the all-time top scores")
+	flagWebhookStreak := flag.Bool("webhook-streak", true, fmt.Sprintf("Sends a webhook when a player wins %d games in a row, and every %d wins after that", _webhookStreakLength, _webhookStreakLength))
+	flagHome := flag.Bool("home", false, "Shows a home screen to players connecting to the server, from which they pick what to play")
+	flagPuzzle := flag.String("puzzle", "", "Plays the puzzle with the given code, as shown at the end of a game, with the same rules")
+	flagAssistKeys := flag.Bool("assist-keys", false, "Highlights the untried letters on the keyboard that would narrow down the word the most")
+	flagAssistEliminate := flag.Bool("assist-eliminate", false, "Lets you ask whether any of a set of letters is in the word, for a few points (ctrl+e)")
+	flagIdleNudge := flag.Duration("idle-nudge", _defaultIdleNudge, "Asks whether you are still there when no key has been pressed for this long during a game (0 to never ask)")
+	flagExitAfter := flag.Duration("exit-after", 0, "Saves your game and exits when no key has been pressed for this long, when playing in the terminal (0 to never exit)")
+	flagReducedMotion := flag.Bool("reduced-motion", false, "Shows changes right away instead of animating them")
+	flagNotify := flag.String("notify", "flash", "How to notify you of rejected guesses, the end of a game and idle nudges (flash: invert the status bar, bell: ring the terminal bell, none)")
+	flagTitle := flag.Bool("title", true, "Sets the terminal title to the progress of the game")
+	flagColor := flag.String("color", "auto", "Color mode (auto, always, never, truecolor, 256); NO_COLOR always disables colors")
+	flagHistoryFile := flag.String("history-file", "", "Appends the result of every completed game to the given file, as a line of JSON")
+	flagExportImage := flag.String("export-image", "", "Writes the board of every completed game to the given image file (.png, .svg), replacing the previous one")
+	flagLogFile := flag.String("log-file", "", "Writes logs to the given file instead of stderr")
+	flagLogFormat := flag.String("log-format", "text", "Log format (text, json)")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage of %s:\n", os.Args[0])
+		flag.PrintDefaults()
+		fmt.Fprint(flag.CommandLine.Output(), "\n"+_apiDescription)
+	}
 	flag.Parse()
+	// Options can also be set in the environment and the settings file, but
+	// every option is read from its flag, so they are resolved up front.
+	settings, err := resolveConfig(flag.CommandLine, os.Environ(), pathSettings)
+	if err != nil {
+		slog.Error("invalid configuration", slog.String("error", err.Error()))
+		os.Exit(2)
+	}
 
-	var err error
-	if addr := *flagServe; addr != "" {
-		err = runServer(addr)
-	} else {
-		err = runCLI()
+	if err := setupLogging(*flagLogFile, *flagLogFormat, *flagQuiet); err != nil {
+		slog.Error("could not set up logging", slog.String("error", err.Error()))
+		os.Exit(2)
+	}
+
+	border, ok := _borders[*flagBorder]
+	if !ok {
+		slog.Error("invalid border style", slog.String("border", *flagBorder))
+		os.Exit(2)
+	}
+	dailyLocation, err := time.LoadLocation(*flagDailyTimezone)
+	if err != nil {
+		slog.Error("invalid time zone", slog.String("daily-timezone", *flagDailyTimezone))
+		os.Exit(2)
+	}
+	dailyEpoch, err := time.Parse(time.DateOnly, *flagDailyEpoch)
+	if err != nil {
+		slog.Error("invalid daily epoch", slog.String("daily-epoch", *flagDailyEpoch))
+		os.Exit(2)
+	}
+	if _, ok := _notifyModes[*flagNotify]; !ok {
+		slog.Error("invalid notification mode", slog.String("notify", *flagNotify))
+		os.Exit(2)
+	}
+	if _, ok := _keyboardModes[*flagKeyboard]; !ok {
+		slog.Error("invalid keyboard mode", slog.String("keyboard", *flagKeyboard))
+		os.Exit(2)
+	}
+	if *flagCase != "upper" && *flagCase != "lower" {
+		slog.Error("invalid letter case", slog.String("case", *flagCase))
+		os.Exit(2)
+	}
+	if *flagTileGap < 0 {
+		slog.Error("invalid tile gap", slog.Int("tile-gap", *flagTileGap))
+		os.Exit(2)
+	}
+	if *flagLossPenalty < 0 {
+		slog.Error("invalid loss penalty", slog.Int("loss-penalty", *flagLossPenalty))
+		os.Exit(2)
+	}
+	if *flagDailyGuessInterval < 0 {
+		slog.Error("invalid daily guess interval", slog.Duration("daily-guess-interval", *flagDailyGuessInterval))
+		os.Exit(2)
+	}
+	if *flagAutosave < 0 {
+		slog.Error("invalid autosave interval", slog.Duration("autosave", *flagAutosave))
+		os.Exit(2)
+	}
+	if *flagIdleNudge < 0 {
+		slog.Error("invalid idle nudge", slog.Duration("idle-nudge", *flagIdleNudge))
+		os.Exit(2)
+	}
+	// Players are warned a minute before exiting, so shorter delays would
+	// leave no time to play.
+	if *flagExitAfter < 0 || *flagExitAfter > 0 && *flagExitAfter <= _idleWarning {
+		slog.Error("invalid exit delay, expected 0 or more than a minute", slog.Duration("exit-after", *flagExitAfter))
+		os.Exit(2)
+	}
+	if *flagMaxSessions < 0 {
+		slog.Error("invalid maximum sessions", slog.Int("max-sessions", *flagMaxSessions))
+		os.Exit(2)
+	}
+	if *flagMaxRarity < _rarityCommon || *flagMaxRarity > _rarityUncommon {
+		slog.Error("invalid maximum rarity", slog.Int("max-rarity", *flagMaxRarity))
+		os.Exit(2)
+	}
+	var minWidth, minHeight int
+	if n, err := fmt.Sscanf(*flagMinSize, "%dx%d", &minWidth, &minHeight); err != nil || n != 2 || minWidth < 0 || minHeight < 0 {
+		slog.Error("invalid minimum terminal size", slog.String("min-size", *flagMinSize))
+		os.Exit(2)
+	}
+	if *flagFreebie && (*flagDaily || *flagVersus) {
+		slog.Error("freebie cannot be combined with --daily or --versus")
+		os.Exit(2)
+	}
+	if *flagFreePlay && (*flagDaily || *flagUltraHard || *flagVersus) {
+		slog.Error("free play cannot be combined with --daily, --ultra-hard or --versus")
+		os.Exit(2)
+	}
+	if *flagHome && (*flagServe == "" || *flagVersus) {
+		slog.Error("home screen can only be used with --serve, and cannot be combined with --versus")
+		os.Exit(2)
+	}
+	if *flagReferee && (*flagServe == "" || *flagVersus || *flagFreebie || *flagAssistEliminate || *flagExpertKeyboard) {
+		slog.Error("referee can only be used with --serve, and cannot be combined with --versus, --freebie, --assist-eliminate or --expert-keyboard")
+		os.Exit(2)
+	}
+	if addr := *flagWebAddr; addr != "" {
+		if _, port, err := net.SplitHostPort(addr); err != nil || port == "" || *flagServe == "" {
+			slog.Error("web address must be host:port, and can only be used with --serve", slog.String("web-addr", addr))
+			os.Exit(2)
+		}
+	}
+	if addr := *flagMetricsAddr; addr != "" {
+		if _, port, err := net.SplitHostPort(addr); err != nil || port == "" || *flagServe == "" {
+			slog.Error("metrics address must be host:port, and can only be used with --serve", slog.String("metrics-addr", addr))
+			os.Exit(2)
+		}
+	}
+	if addr := *flagPprofAddr; addr != "" {
+		if _, port, err := net.SplitHostPort(addr); err != nil || port == "" || *flagServe == "" {
+			slog.Error("pprof address must be host:port, and can only be used with --serve", slog.String("pprof-addr", addr))
+			os.Exit(2)
+		}
+		if !isLoopbackAddr(addr) && !*flagPprofAllowRemote {
+			slog.Error("pprof address must be loopback, unless --pprof-allow-remote is given", slog.String("pprof-addr", addr))
+			os.Exit(2)
+		}
+	}
+	if rawURL := *flagWebhookURL; rawURL != "" {
+		if u, err := url.Parse(rawURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || *flagServe == "" {
+			slog.Error("webhook URL must be an http or https URL, and can only be used with --serve", slog.String("webhook-url", rawURL))
+			os.Exit(2)
+		}
+	}
+	if (*flagHostKeyPassphraseFile != "" || *flagHostKeyEncrypt) && *flagServe == "" {
+		slog.Error("host key passphrase file and encryption can only be used with --serve")
+		os.Exit(2)
+	}
+	if (*flagTrustedUserCA != "" || *flagRevokedKeys != "" || *flagRoster != "" || *flagNoGuests) && *flagServe == "" {
+		slog.Error("trusted user CA, revoked keys, roster and --no-guests can only be used with --serve")
+		os.Exit(2)
+	}
+	if addr := *flagAPIAddr; addr != "" {
+		if _, port, err := net.SplitHostPort(addr); err != nil || port == "" || *flagServe != "" {
+			slog.Error("API address must be host:port, and cannot be combined with --serve", slog.String("api-addr", addr))
+			os.Exit(2)
+		}
+	}
+	var puzzle *puzzle
+	if *flagPuzzle != "" {
+		if *flagDaily || *flagVersus || *flagServe != "" {
+			slog.Error("puzzle cannot be combined with --daily, --versus or --serve")
+			os.Exit(2)
+		}
+		p, err := decodePuzzle(EnglishDictionary, *flagPuzzle)
+		if err != nil {
+			slog.Error("invalid puzzle", slog.String("puzzle", *flagPuzzle), slog.String("error", err.Error()))
+			os.Exit(2)
+		}
+		*flagUltraHard, *flagFreePlay, *flagMaxRarity = p.ultraHard, p.freePlay, p.maxRarity
+		puzzle = &p
+	}
+	if path := *flagExportImage; path != "" {
+		if _, ok := _imageFormats[strings.ToLower(filepath.Ext(path))]; !ok || *flagServe != "" {
+			slog.Error("export image must end in .png or .svg, and cannot be combined with --serve", slog.String("export-image", path))
+			os.Exit(2)
+		}
+	}
+	if _, ok := _colorModes[*flagColor]; !ok {
+		slog.Error("invalid color mode", slog.String("color", *flagColor))
+		os.Exit(2)
+	}
+
+	webhookEvents := map[webhookEvent]bool{
+		_webhookFirstSolve: *flagWebhookFirstSolve,
+		_webhookHighScore:  *flagWebhookHighScore,
+		_webhookStreak:     *flagWebhookStreak,
+	}
+	options := options{
+		daily:              *flagDaily,
+		dailyLocation:      dailyLocation,
+		dailyEpoch:         dailyEpoch,
+		expertKeyboard:     *flagExpertKeyboard,
+		ultraHard:          *flagUltraHard,
+		freePlay:           *flagFreePlay,
+		revealOnQuit:       *flagRevealOnQuit,
+		autoSubmit:         *flagAutoSubmit,
+		border:             border,
+		tileGap:            *flagTileGap,
+		lowercase:          *flagCase == "lower",
+		keyboard:           *flagKeyboard,
+		benchmark:          *flagBenchmark,
+		walkthrough:        *flagWalkthrough,
+		quiet:              *flagQuiet,
+		color:              *flagColor,
+		startupStats:       *flagStartupStats,
+		noPersist:          *flagNoPersist,
+		autosave:           *flagAutosave,
+		assistEliminate:    *flagAssistEliminate,
+		assistKeys:         *flagAssistKeys,
+		freebie:            *flagFreebie,
+		puzzle:             puzzle,
+		home:               *flagHome,
+		versus:             *flagVersus,
+		maxRarity:          *flagMaxRarity,
+		minWidth:           minWidth,
+		minHeight:          minHeight,
+		maxSessions:        *flagMaxSessions,
+		dailyGuessInterval: *flagDailyGuessInterval,
+		referee:            *flagReferee,
+		webAddr:            *flagWebAddr,
+		metricsAddr:        *flagMetricsAddr,
+		pprofAddr:          *flagPprofAddr,
+		webhookURL:         *flagWebhookURL,
+		webhookSecret:      *flagWebhookSecret,
+		webhookEvents:      webhookEvents,
+		passphraseFile:     *flagHostKeyPassphraseFile,
+		encryptHostKey:     *flagHostKeyEncrypt,
+		trustedUserCA:      *flagTrustedUserCA,
+		revokedKeys:        *flagRevokedKeys,
+		rosterFile:         *flagRoster,
+		noGuests:           *flagNoGuests,
+		streaks:            true,
+		reducedMotion:      *flagReducedMotion,
+		title:              *flagTitle,
+		notify:             *flagNotify,
+		idleNudge:          *flagIdleNudge,
+		idleTimeout:        *flagExitAfter,
+		historyFile:        *flagHistoryFile,
+		exportImage:        *flagExportImage,
+		lossPenalty:        *flagLossPenalty,
+		solveTimes:         true,
+		weeklyRecap:        true,
+	}
+
+	switch flag.Arg(0) {
+	case "bot":
+		err = runBot(flag.Args()[1:])
+	case "simulate":
+		err = runSimulate(flag.Args()[1:])
+	case "stats":
+		err = runStats(flag.Args()[1:], options)
+	case "render":
+		err = runRender(flag.Args()[1:], options)
+	case "sync":
+		err = runSync(flag.Args()[1:])
+	case "profile":
+		err = runProfile(flag.Args()[1:])
+	case "merge":
+		err = runMerge(flag.Args()[1:])
+	case "config":
+		err = runConfig(flag.Args()[1:], settings)
+	case "doctor":
+		err = runDoctor(flag.Args()[1:], *flagServe, *flagPprofAddr)
+	case "":
+		if addr := *flagServe; addr != "" {
+			err = runServer(addr, options)
+		} else if addr := *flagAPIAddr; addr != "" {
+			err = runAPI(addr, options)
+		} else {
+			err = runCLI(options)
+		}
+	default:
+		err = errors.Errorf("unknown command: %s", flag.Arg(0))
 	}
 	if err != nil {
 		slog.Error("error running application", "error", slog.Any("error", err))
@@ -57,23 +380,200 @@ func main() {
 	}
 }
 
-func runCLI() error {
+func runCLI(options options) error {
+	// The minimum size is only enforced for players connecting to the server.
+	options.minWidth, options.minHeight = 0, 0
+	if !options.noPersist {
+		options.snapshotFile = pathSnapshot
+	}
+
 	ctx := context.Background()
-	model, err := getModel(ctx)
+	renderer := lipgloss.NewRenderer(os.Stderr)
+	setColorProfile(renderer, options.color, os.Getenv("NO_COLOR") != "")
+	model, err := getModel(ctx, options, renderer)
 	if err != nil {
 		return err
 	}
-	program := tea.NewProgram(model, teaOptions...)
+	if !options.noPersist {
+		model.settingsFile = newSettingsFile(pathSettings)
+	}
+	model.canSetTitle = term.IsTerminal(int(os.Stderr.Fd()))
+	if options.historyFile != "" {
+		if model.history, err = openHistory(options.historyFile); err != nil {
+			return err
+		}
+		defer model.history.Close()
+	}
+	var crashReport string
+	guard := newCrashGuard(model, func(value any, stack []byte) {
+		path, err := writeCrashReport(value, stack)
+		if err != nil {
+			slog.Error("could not write crash report", slog.Any("error", err))
+			return
+		}
+		crashReport = path
+	})
+	program := tea.NewProgram(guard, teaOptions...)
 
+	model.saveTitle(os.Stderr)
 	_, err = program.Run()
-	return err
+	model.restoreTitle(os.Stderr)
+	model.doSavePlaytime()
+	model.writes.flush()
+	if err != nil {
+		return err
+	}
+	if guard.crashed {
+		if crashReport != "" {
+			return errors.Errorf("clidle crashed, a report was written to %s", crashReport)
+		}
+		return errors.New("clidle crashed")
+	}
+
+	// Print the result of the last game to stdout, since the UI is rendered on
+	// stderr.
+	if model.idleTimedOut {
+		if options.noPersist {
+			fmt.Printf("Exited after %s without a key press.\n", options.idleTimeout)
+		} else {
+			fmt.Printf("Exited after %s without a key press. Your game is saved, run clidle to pick it up.\n", options.idleTimeout)
+		}
+		return nil
+	}
+	if !options.quiet && model.summary != "" {
+		fmt.Print(model.summary)
+	}
+	if reveal := model.viewReveal(); !options.quiet && reveal != "" {
+		fmt.Print(reveal)
+	}
+	return nil
 }
 
-func runServer(addr string) error {
+func runServer(addr string, options options) error {
+	listener, err := listen(addr)
+	if err != nil {
+		return err
+	}
+
+	// Open the database up front, so that problems are reported on startup
+	// rather than when the first player connects.
+	if !options.noPersist {
+		if _, err := getStore(); err != nil {
+			listener.Close()
+			return err
+		}
+		slog.Info("opened database", slog.String("path", pathStore), slog.Int("schema_version", len(migrations)))
+	}
+
+	signer, err := loadHostKey(pathHostKey, hostKeyPassphrase(options.passphraseFile), options.encryptHostKey)
+	if err != nil {
+		listener.Close()
+		return err
+	}
+	slog.Info("using host key", slog.String("fingerprint", gossh.FingerprintSHA256(signer.PublicKey())))
+
+	trust, err := loadKeyTrust(options.trustedUserCA, options.revokedKeys)
+	if err != nil {
+		listener.Close()
+		return err
+	}
+	var roster *roster
+	if options.rosterFile != "" {
+		if roster, err = loadRoster(options.rosterFile); err != nil {
+			listener.Close()
+			return err
+		}
+		stopRoster := make(chan struct{})
+		defer close(stopRoster)
+		roster.reloadOnHangup(stopRoster)
+	}
+
+	// Writes outlive the session that made them, but are aborted once the
+	// server has shut down.
+	writeCtx, cancelWrites := context.WithCancel(context.Background())
+	defer cancelWrites()
+
+	var lobby *lobby
+	if options.versus {
+		lobby = newLobby(EnglishDictionary)
+	}
+	// Duels share their answer between both players, so they can't be
+	// refereed.
+	var invites *invites
+	var referee *referee
+	if options.referee {
+		referee = newReferee(EnglishDictionary)
+	} else {
+		invites = newInvites(EnglishDictionary, realClock{})
+	}
+	sessions := newSessions(options.maxSessions)
+	// The server is named in the players' terminal titles.
+	host, _ := os.Hostname()
+	var pacer *pacer
+	if options.dailyGuessInterval > 0 {
+		pacer = newPacer(options.dailyGuessInterval)
+		stopPacer := make(chan struct{})
+		defer close(stopPacer)
+		pacer.pruneEvery(_pacerPruneInterval, realClock{}, stopPacer)
+	}
+
+	var webhooks *webhooks
+	if options.webhookURL != "" {
+		webhooks = newWebhooks(options.webhookURL, options.webhookSecret, options.webhookEvents, realClock{})
+	}
+
+	var history *history
+	if options.historyFile != "" {
+		if history, err = openHistory(options.historyFile); err != nil {
+			listener.Close()
+			return err
+		}
+		defer history.Close()
+	}
+
+	// Players are warned before they are disconnected for being idle, whatever
+	// --exit-after is set to.
+	options.idleTimeout = _idleTimeout
+	// Stats aren't tracked per player, so they would include everyone's games.
+	options.startupStats = false
+	options.streaks = false
+	options.solveTimes = false
+	options.weeklyRecap = false
+	options.shareable = true
+
 	server, err := wish.NewServer(
 		wish.WithAddress(addr),
-		wish.WithIdleTimeout(30*time.Minute),
+		wish.WithIdleTimeout(_idleTimeout),
 		wish.WithMiddleware(
+			// Middlewares run in reverse order, so this runs once the program
+			// has exited.
+			func(next ssh.Handler) ssh.Handler {
+				return func(session ssh.Session) {
+					if guard, ok := session.Context().Value(ctxKeyModel{}).(*crashGuard); ok {
+						guard.leaveMatch()
+						guard.leaveReferee()
+						guard.doSavePlaytime()
+						guard.writes.flush()
+						guard.restoreTitle(session)
+						var saved string
+						if guard.isSaved() {
+							saved = " Your game is saved."
+						}
+						switch {
+						case guard.crashed:
+							wish.Printf(session, "Something went wrong, reconnect to continue.%s\r\n", saved)
+						case guard.idleTimedOut:
+							wish.Printf(session, "Disconnected after %d minutes without a key press, reconnect to continue.%s\r\n", int(_idleTimeout.Minutes()), saved)
+						case !options.quiet:
+							wish.Print(session, strings.ReplaceAll(guard.summary+guard.viewReveal(), "\n", "\r\n"))
+						}
+						if token := guard.guestToken; token != "" && !guard.crashed {
+							wish.Printf(session, "Your guest token is %s. Enter it when you next connect to keep your scores.\r\n", token)
+						}
+					}
+					next(session)
+				}
+			},
 			wtea.Middleware(func(session ssh.Session) (tea.Model, []tea.ProgramOption) {
 				pty, _, active := session.Pty()
 				if !active {
@@ -81,29 +581,115 @@ func runServer(addr string) error {
 				}
 
 				ctx := session.Context()
-				model, err := getModel(ctx)
+				renderer := wtea.MakeRenderer(session)
+				setColorProfile(renderer, options.color, hasEnv(session.Environ(), "NO_COLOR"))
+				model, err := getModel(ctx, options, renderer)
 				if err != nil {
 					slog.Error("could not create model", slog.Any("error", err))
 					wish.Fatalf(session, "could not create model: %v\n", err)
 				}
+				model.writeCtx = writeCtx
+				model.lobby = lobby
+				model.invites = invites
+				model.sessions = sessions
+				model.pacer = pacer
+				if referee != nil {
+					model.referee, model.refereeSession = referee, referee.join()
+				}
+				model.history = history
+				model.webhooks = webhooks
 				model.windowWidth = pty.Window.Width
 				model.windowHeight = pty.Window.Height
+				model.playerName = trust.displayName(session.PublicKey(), session.User())
+				if entry, ok := roster.lookup(session.PublicKey(), trust); ok && entry.Name != "" {
+					model.playerName = entry.Name
+				}
+				model.location = sessionLocation(session, options.dailyLocation)
+				model.remoteIP = remoteIP(session.RemoteAddr())
+				model.player = trust.player(session.PublicKey())
+				if g, ok := ctx.Value(ctxKeyGuest{}).(guest); ok && session.PublicKey() == nil {
+					model.player, model.playerName = g.player(), g.name
+					if g.fresh {
+						model.guestToken = g.token
+					}
+				}
+				guard := newCrashGuard(model, func(value any, stack []byte) {
+					slog.Error("recovered from panic in session",
+						slog.Any("panic", value),
+						slog.String("stack", string(stack)),
+						slog.String("user", session.User()),
+						slog.String("player", model.player),
+						slog.String("remote", session.RemoteAddr().String()),
+					)
+				})
+				ctx.SetValue(ctxKeyModel{}, guard)
+
+				// Players always have a terminal, since sessions without
+				// one are turned away above.
+				model.host, model.canSetTitle = host, true
+				model.saveTitle(session)
 
-				return model, teaOptions
+				return guard, teaOptions
 			}),
+			// Only interactive sessions are counted, since commands are
+			// handled by the middlewares below before they get here.
+			sessions.middleware(func(session ssh.Session) bool {
+				return roster.admin(session.PublicKey(), trust)
+			}),
+			shareMiddleware(options),
+			syncMiddleware(EnglishDictionary, trust),
+			profileMiddleware(trust),
+			pprofLabelMiddleware(trust),
 		),
-		wish.WithHostKeyPath(pathHostKey),
+		func(server *ssh.Server) error {
+			server.AddHostKey(signer)
+			return nil
+		},
+		wish.WithBannerHandler(sessions.banner),
+		// Accept all public keys that aren't revoked so that players can be
+		// identified by their fingerprint or certificate, while still letting
+		// in players who don't have one as guests.
+		wish.WithPublicKeyAuth(trust.publicKeyHandler),
+		wish.WithKeyboardInteractiveAuth(func(ctx ssh.Context, challenge gossh.KeyboardInteractiveChallenge) bool {
+			return !options.noGuests && trust.keyboardInteractiveHandler(ctx, challenge) && guestHandler(ctx, challenge)
+		}),
 	)
 	if err != nil {
+		listener.Close()
 		return errors.Wrapf(err, "could not create server")
 	}
 
 	done := make(chan os.Signal, 1)
 	signal.Notify(done, os.Interrupt, syscall.SIGTERM)
 
+	var web, metrics, profiling *http.Server
+	if options.webAddr != "" {
+		if web, err = newWebServer(options); err == nil {
+			err = serveHTTP(web, "web leaderboard", options.webAddr, done)
+		}
+		if err != nil {
+			listener.Close()
+			return err
+		}
+	}
+	if options.metricsAddr != "" {
+		metrics = &http.Server{Handler: _metrics.handler(), ReadHeaderTimeout: 10 * time.Second}
+		if err := serveHTTP(metrics, "metrics", options.metricsAddr, done); err != nil {
+			listener.Close()
+			return err
+		}
+	}
+	if options.pprofAddr != "" {
+		profiling = newPprofServer()
+		if err := serveHTTP(profiling, "pprof", options.pprofAddr, done); err != nil {
+			listener.Close()
+			return err
+		}
+	}
+
 	slog.Info("starting server", slog.String("address", server.Addr))
 	go func() {
-		if err := server.ListenAndServe(); err != nil {
+		if err := server.Serve(listener); err != nil {
 			slog.Error("server returned an error", slog.Any("error", err))
 			done <- os.Interrupt
 		}
@@ -115,31 +701,161 @@ func runServer(addr string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
+	for _, s := range []*http.Server{web, metrics, profiling} {
+		if s == nil {
+			continue
+		}
+		if err := s.Shutdown(ctx); err != nil {
+			slog.Error("could not shutdown HTTP server", slog.Any("error", err))
+		}
+	}
 	err = server.Shutdown(ctx)
+	// Events of the last games are sent once their sessions have ended.
+	if webhooks != nil {
+		webhooks.close(ctx)
+	}
 	return errors.Wrapf(err, "could not shutdown server")
 }
 
-func getModel(ctx context.Context) (*model, error) {
-	dictionary := EnglishDictionary
-	store, err := getStore()
+// serveHTTP serves an HTTP server alongside the SSH server. If it stops with an
+// error, the SSH server is stopped too.
+func serveHTTP(server *http.Server, name, addr string, done chan<- os.Signal) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return errors.Wrapf(err, "could not listen on %s", addr)
+	}
+	slog.Info("starting "+name, slog.String("address", listener.Addr().String()))
+	go func() {
+		if err := server.Serve(listener); !errors.Is(err, http.ErrServerClosed) {
+			slog.Error(name+" returned an error", slog.Any("error", err))
+			done <- os.Interrupt
+		}
+	}()
+	return nil
+}
+
+// listen validates the address given with --serve and starts listening on it,
+// returning a friendly error if either fails.
+func listen(addr string) (net.Listener, error) {
+	if _, port, err := net.SplitHostPort(addr); err != nil || port == "" {
+		return nil, errors.Errorf("invalid address %q: expected host:port (format: 0.0.0.0:1337)", addr)
+	}
+	listener, err := net.Listen("tcp", addr)
+	if isAddrInUse(err) {
+		return nil, errors.Errorf("address %s is already in use: stop the process using it, or choose another port with --serve", addr)
+	}
+	return listener, errors.Wrapf(err, "could not listen on %s", addr)
+}
+
+func getModel(ctx context.Context, options options, renderer *lipgloss.Renderer) (*model, error) {
+	dictionary := EnglishDictionary.WithMaxRarity(options.maxRarity)
+	store, err := openStore(options)
 	if err != nil {
 		return nil, err
 	}
-	return newModel(ctx, store, dictionary), nil
+	return newModel(ctx, store, dictionary, options, renderer), nil
+}
+
+// openStore returns the store that games are kept in, which is the in-memory
+// one with --no-persist.
+func openStore(options options) (*store.Queries, error) {
+	if options.noPersist {
+		return getMemoryStore()
+	}
+	return getStore()
 }
 
-func getStore() (*store.Queries, error) {
+// getStore returns the store, opening and migrating the database the first
+// time it is called. Later calls share the same database, so it is cheap to
+// call once per session.
+var getStore = sync.OnceValues(func() (*store.Queries, error) {
 	if err := os.MkdirAll(pathClidle, 0700); err != nil {
 		return nil, err
 	}
 
-	db, err := sql.Open("sqlite", pathStore)
+	db, err := sql.Open("sqlite", sqliteDSN(pathStore, "_time_format=sqlite"))
 	if err != nil {
 		return nil, err
 	}
 	db.SetMaxOpenConns(1) // SQLite does not support concurrent writes
-	if _, err := db.Exec(schemaSQL); err != nil {
+	if err := migrate(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return store.New(db), nil
+})
+
+// getMemoryStore returns a store backed by an in-memory database, which is used
+// instead of the database file when games shouldn't be persisted. It is shared
+// by every session, and is lost when clidle exits.
+var getMemoryStore = sync.OnceValues(func() (*store.Queries, error) {
+	db, err := sql.Open("sqlite", "file::memory:?_time_format=sqlite")
+	if err != nil {
+		return nil, err
+	}
+	// Every connection to :memory: gets a database of its own, so only one
+	// may ever be opened.
+	db.SetMaxOpenConns(1)
+	if err := migrate(db); err != nil {
+		db.Close()
 		return nil, err
 	}
 	return store.New(db), nil
+})
+
+// getStoreReadOnly opens the database without write access, so that it can
+// be used alongside a running server.
+func getStoreReadOnly() (*store.Queries, error) {
+	if _, err := os.Stat(pathStore); err != nil {
+		return nil, errors.Wrap(err, "could not open database")
+	}
+
+	db, err := sql.Open("sqlite", sqliteDSN(pathStore, "mode=ro&_time_format=sqlite"))
+	if err != nil {
+		return nil, err
+	}
+
+	var version int
+	if err := db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		db.Close()
+		return nil, errors.Wrap(err, "could not read schema version")
+	}
+	if version != len(migrations) {
+		db.Close()
+		return nil, errors.Errorf("database schema version %d is not supported, run clidle once to upgrade it", version)
+	}
+	return store.New(db), nil
+}
+
+// hasEnv checks if the given variable is set to a non-empty value in a list of
+// environment variables.
+func hasEnv(environ []string, key string) bool {
+	return getEnv(environ, key) != ""
+}
+
+// getEnv returns the value of an environment variable in the given
+// environment, or an empty string if it isn't set.
+func getEnv(environ []string, key string) string {
+	for _, env := range environ {
+		if value, ok := strings.CutPrefix(env, key+"="); ok {
+			return value
+		}
+	}
+	return ""
+}
+
+// sessionLocation returns the time zone set by the player with the TZ
+// environment variable, if any, or the given fallback. It only affects how
+// times are shown; puzzles always change in the server's time zone.
+func sessionLocation(session ssh.Session, fallback *time.Location) *time.Location {
+	tz := getEnv(session.Environ(), "TZ")
+	if tz == "" {
+		return fallback
+	}
+	location, err := time.LoadLocation(tz)
+	if err != nil {
+		slog.Info("ignoring unknown time zone", slog.String("tz", tz), slog.String("user", session.User()))
+		return fallback
+	}
+	return location
 }
@@ -0,0 +1,318 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ajeetdsouza/clidle/pkg/game"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// _noWinner is the winner of a match that hasn't been decided yet, or that
+// ended in a draw.
+const _noWinner = -1
+
+// lobby pairs up players on the server for head-to-head matches.
+type lobby struct {
+	dictionary Dictionary
+
+	mu      sync.Mutex
+	waiting *match
+}
+
+func newLobby(dictionary Dictionary) *lobby {
+	return &lobby{dictionary: dictionary}
+}
+
+// join adds a player to the match that is waiting for an opponent, or creates
+// a new one. It returns the match, and the player's seat in it.
+func (l *lobby) join(name string) (*match, int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if mt := l.waiting; mt != nil {
+		l.waiting = nil
+		mt.start(name)
+		return mt, 1
+	}
+
+	var answer game.Word
+	copy(answer[:], l.dictionary.GetRandomCommonWord())
+	mt := newMatch(answer, name)
+	l.waiting = mt
+	return mt, 0
+}
+
+// leave removes a player from a match, e.g. when they disconnect.
+func (l *lobby) leave(mt *match, seat int) {
+	l.mu.Lock()
+	if l.waiting == mt {
+		l.waiting = nil
+	}
+	l.mu.Unlock()
+	mt.leave(seat)
+}
+
+// match is a race between two players to solve the same answer. The first
+// player to solve it wins the round.
+type match struct {
+	answer game.Word
+
+	mu      sync.Mutex
+	players [2]matchPlayer
+	started bool
+	winner  int
+
+	// updates notifies each player that the match has changed.
+	updates [2]chan struct{}
+}
+
+// matchPlayer is the progress of a player in a match.
+type matchPlayer struct {
+	name      string
+	feedbacks []game.Feedback
+	// done is set once the player has solved the answer or run out of
+	// guesses.
+	done bool
+	left bool
+	// chat is the last chat message sent by the player, and chatSeq counts
+	// the messages sent, so that repeated messages can be told apart.
+	chat    string
+	chatSeq int
+}
+
+// matchView is a snapshot of a match from the point of view of one player.
+type matchView struct {
+	started  bool
+	opponent matchPlayer
+	// won and lost are set once the round has been decided.
+	won  bool
+	lost bool
+}
+
+func newMatch(answer game.Word, name string) *match {
+	mt := &match{answer: answer, winner: _noWinner}
+	mt.players[0].name = name
+	for i := range mt.updates {
+		mt.updates[i] = make(chan struct{}, 1)
+	}
+	return mt
+}
+
+func (mt *match) start(name string) {
+	mt.mu.Lock()
+	defer mt.mu.Unlock()
+	mt.players[1].name = name
+	mt.started = true
+	mt.notify(0)
+}
+
+// report records a guess made by a player.
+func (mt *match) report(seat int, feedback game.Feedback, state game.State) {
+	mt.mu.Lock()
+	defer mt.mu.Unlock()
+
+	player := &mt.players[seat]
+	player.feedbacks = append(player.feedbacks, feedback)
+	player.done = state != game.StateInProgress
+	if state == game.StateWon && mt.winner == _noWinner {
+		mt.winner = seat
+	}
+	mt.notify(1 - seat)
+}
+
+// say sends a chat message from a player to their opponent.
+func (mt *match) say(seat int, text string) {
+	mt.mu.Lock()
+	defer mt.mu.Unlock()
+
+	player := &mt.players[seat]
+	player.chat = text
+	player.chatSeq++
+	mt.notify(1 - seat)
+}
+
+// leave records that a player has left. If the round is still undecided, it is
+// awarded to their opponent.
+func (mt *match) leave(seat int) {
+	mt.mu.Lock()
+	defer mt.mu.Unlock()
+
+	mt.players[seat].left = true
+	if mt.started && mt.winner == _noWinner && !mt.players[1-seat].left {
+		mt.winner = 1 - seat
+	}
+	mt.notify(1 - seat)
+}
+
+// view returns a snapshot of the match for the player in the given seat.
+func (mt *match) view(seat int) matchView {
+	mt.mu.Lock()
+	defer mt.mu.Unlock()
+
+	opponent := mt.players[1-seat]
+	opponent.feedbacks = append([]game.Feedback(nil), opponent.feedbacks...)
+	return matchView{
+		started:  mt.started,
+		opponent: opponent,
+		won:      mt.winner == seat,
+		lost:     mt.winner == 1-seat,
+	}
+}
+
+// notify wakes up the player in the given seat, if they aren't already due to
+// be woken up. It must be called with mt.mu held.
+func (mt *match) notify(seat int) {
+	select {
+	case mt.updates[seat] <- struct{}{}:
+	default:
+	}
+}
+
+// wait returns a tea.Cmd that delivers a msgMatch once the match changes.
+func (mt *match) wait(ctx context.Context, seat int) tea.Cmd {
+	return func() tea.Msg {
+		select {
+		case <-mt.updates[seat]:
+			return msgMatch{match: mt}
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// msgMatch is sent when a match that the player is in has changed.
+type msgMatch struct {
+	match *match
+}
+
+// doJoinMatch leaves the current match, if any, and joins a new one.
+func (m *model) doJoinMatch() tea.Cmd {
+	m.leaveMatch()
+	mt, seat := m.lobby.join(m.playerName)
+	cmd := m.startMatch(mt, seat)
+	if !m.matchView.started {
+		m.setStatus("Waiting for an opponent...", 0)
+	}
+	return cmd
+}
+
+// startMatch starts the game of a match, in the given seat. Matches are never
+// daily puzzles, even if they are played on a daily server.
+func (m *model) startMatch(mt *match, seat int) tea.Cmd {
+	m.match, m.seat = mt, seat
+	m.matchView = mt.view(seat)
+	m.daily, m.dailyNumber = "", 0
+	cmd := m.startGame(mt.answer)
+	return tea.Batch(cmd, mt.wait(m.ctx, seat))
+}
+
+// leaveMatch leaves the current match, if any, withdrawing its invite if it
+// was created with one.
+func (m *model) leaveMatch() {
+	if m.match == nil {
+		return
+	}
+	if m.inviteCode != "" {
+		m.invites.cancel(m.inviteCode, m.match)
+		m.inviteCode = ""
+	}
+	if m.lobby != nil {
+		m.lobby.leave(m.match, m.seat)
+	} else {
+		m.match.leave(m.seat)
+	}
+	m.match = nil
+	m.matchView = matchView{}
+}
+
+// isWaitingForOpponent checks if the player is in a match that hasn't started.
+func (m *model) isWaitingForOpponent() bool {
+	return m.match != nil && !m.matchView.started
+}
+
+// updateMatch refreshes the match after it has changed, and tells the player
+// what happened.
+func (m *model) updateMatch(msg msgMatch) tea.Cmd {
+	if msg.match != m.match {
+		return nil
+	}
+	prev := m.matchView
+	m.matchView = m.match.view(m.seat)
+	wait := m.match.wait(m.ctx, m.seat)
+
+	opponent := m.matchView.opponent.name
+	if opponent == "" {
+		opponent = "Your opponent"
+	}
+	switch {
+	case !prev.started && m.matchView.started:
+		// The game is timed from the start of the match, rather than from
+		// when the player began waiting for an opponent.
+		m.startedAt = m.clock.Now()
+		m.resetStatus()
+		return tea.Batch(wait, m.setStatus(fmt.Sprintf("Matched against %s. Go!", opponent), 2*time.Second))
+	case !prev.won && m.matchView.won && m.matchView.opponent.left:
+		return tea.Batch(wait, m.setStatus(fmt.Sprintf("%s left. You win the round!", opponent), 0))
+	case !prev.lost && m.matchView.lost:
+		msg := fmt.Sprintf("%s solved it first. The word was %s.", opponent, m.game.Answer())
+		cmd := tea.Batch(wait, m.setStatus(msg, 0))
+		// The round is over for the player too, so their game is recorded as
+		// unsolved, unless they had already run out of guesses.
+		if m.game.State() == game.StateInProgress {
+			cmd = tea.Batch(m.doGameOver(), cmd)
+		}
+		return cmd
+	case m.matchView.opponent.chatSeq != prev.opponent.chatSeq && !m.options.muteChat:
+		return tea.Batch(wait, m.setStatus(opponent+": "+m.matchView.opponent.chat, _chatDuration))
+	}
+	return wait
+}
+
+// viewMatchResult describes the result of the round once the player has
+// solved the answer.
+func (m *model) viewMatchResult() string {
+	if m.matchView.won {
+		return "You win the round!"
+	}
+	return "You solved it, but not first."
+}
+
+// viewOpponent renders the opponent's progress as a grid of colors, without
+// the letters.
+func (m *model) viewOpponent() string {
+	if !m.matchView.started && m.inviteCode != "" {
+		rows := []string{m.styles.subtext.Render("Invite code:"), m.styles.highlight.Render(m.inviteCode)}
+		return m.styles.box.Render(lipgloss.JoinVertical(lipgloss.Left, rows...))
+	}
+	if !m.matchView.started {
+		return m.styles.box.Render(m.styles.subtext.Render("Waiting for an\nopponent..."))
+	}
+
+	opponent := m.matchView.opponent
+	name := opponent.name
+	if name == "" {
+		name = "anonymous"
+	}
+	if opponent.left {
+		name += " (left)"
+	}
+
+	rows := make([]string, 0, _numGuesses+1)
+	rows = append(rows, m.styles.text.Render(name))
+	for i := 0; i < _numGuesses; i++ {
+		tiles := make([]string, _numChars)
+		for j := range tiles {
+			tiles[j] = m.styles.opponentTiles[_keyStateUnselected]
+			if i < len(opponent.feedbacks) {
+				tiles[j] = m.styles.opponentTiles[keyState(opponent.feedbacks[i][j])]
+			}
+		}
+		rows = append(rows, strings.Join(tiles, " "))
+	}
+	return m.styles.box.Render(lipgloss.JoinVertical(lipgloss.Left, rows...))
+}
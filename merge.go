@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/ajeetdsouza/clidle/store"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/pkg/errors"
+)
+
+// _anonymousPrefix selects the games played without a key under a name, as in
+// anonymous:alice. Players with a key are identified by its fingerprint, or by
+// _certPrefix and the key ID of a trusted certificate.
+const _anonymousPrefix = "anonymous:"
+
+// mergePlayers moves every game of one player to another, and logs the merge.
+// Merging again moves nothing, and isn't logged. Anonymous games can always be
+// merged into a keyed player, but merging two keyed players requires force,
+// since it can't be undone.
+func mergePlayers(ctx context.Context, queries *store.Queries, from, to string, force bool, now time.Time) (int64, error) {
+	if to == "" || strings.HasPrefix(to, _anonymousPrefix) || isGuest(to) {
+		return 0, errors.New("games can only be merged into a player with a key")
+	}
+	if from == to {
+		return 0, errors.New("can't merge a player into itself")
+	}
+	name, anonymous := strings.CutPrefix(from, _anonymousPrefix)
+	if !anonymous && !force {
+		return 0, errors.New("both players have keys, use --force to merge them anyway")
+	}
+
+	var merged int64
+	err := store.Retry(ctx, func() error {
+		return queries.InTx(ctx, func(tx *store.Queries) (err error) {
+			if anonymous {
+				merged, err = tx.MergeAnonymousGames(ctx, store.MergeAnonymousGamesParams{
+					Player:     sql.NullString{String: to, Valid: true},
+					PlayerName: sql.NullString{String: name, Valid: true},
+				})
+			} else {
+				merged, err = tx.MergePlayerGames(ctx, store.MergePlayerGamesParams{
+					Player:   sql.NullString{String: to, Valid: true},
+					Player_2: sql.NullString{String: from, Valid: true},
+				})
+			}
+			if err != nil || merged == 0 {
+				return err
+			}
+			return tx.CreatePlayerMerge(ctx, store.CreatePlayerMergeParams{
+				FromPlayer: from,
+				ToPlayer:   to,
+				Games:      merged,
+				MergedBy:   "operator",
+				MergedAt:   now,
+			})
+		})
+	})
+	return merged, err
+}
+
+// claimAnonymousGames moves the anonymous games played under the given name
+// from the given address to a keyed player, and logs the claim. Claiming again
+// moves nothing, and isn't logged.
+func claimAnonymousGames(ctx context.Context, queries *store.Queries, player, name, ip string, now time.Time) (int64, error) {
+	var claimed int64
+	err := store.Retry(ctx, func() error {
+		return queries.InTx(ctx, func(tx *store.Queries) (err error) {
+			claimed, err = tx.ClaimAnonymousGames(ctx, store.ClaimAnonymousGamesParams{
+				Player:     sql.NullString{String: player, Valid: true},
+				PlayerName: sql.NullString{String: name, Valid: true},
+				RemoteIp:   sql.NullString{String: ip, Valid: true},
+			})
+			if err != nil || claimed == 0 {
+				return err
+			}
+			return tx.CreatePlayerMerge(ctx, store.CreatePlayerMergeParams{
+				FromPlayer: _anonymousPrefix + name + "@" + ip,
+				ToPlayer:   player,
+				Games:      claimed,
+				MergedBy:   "player",
+				MergedAt:   now,
+			})
+		})
+	})
+	return claimed, err
+}
+
+// remoteIP returns the IP address of a remote address, without the port.
+func remoteIP(addr net.Addr) string {
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	return host
+}
+
+// canClaim checks if the player has a key, and could have played anonymously
+// under the same name from the same address. Guests don't have a key.
+func (m *model) canClaim() bool {
+	return m.player != "" && !isGuest(m.player) && m.playerName != "" && m.remoteIP != ""
+}
+
+// doClaimAnonymous claims the anonymous games that the player played under
+// the same name from the same address, such as before they set up a key.
+func (m *model) doClaimAnonymous() tea.Cmd {
+	if !m.canClaim() {
+		return nil
+	}
+	player, name, ip := m.player, m.playerName, m.remoteIP
+	return m.writes.enqueue(func() tea.Msg {
+		ctx, cancel := context.WithTimeout(m.writeCtx, 5*time.Second)
+		defer cancel()
+		claimed, err := claimAnonymousGames(ctx, m.store, player, name, ip, m.clock.Now())
+		return msgClaimed{games: claimed, err: err}
+	})
+}
+
+// msgClaimed is sent when anonymous games have been claimed.
+type msgClaimed struct {
+	games int64
+	err   error
+}
+
+// runMerge moves every game of one player to another, for operators to fix up
+// players who played both with and without a key.
+func runMerge(args []string) error {
+	flags := flag.NewFlagSet("merge", flag.ExitOnError)
+	flagFrom := flags.String("from", "", "Player to move games from, as a key fingerprint, cert:KEY_ID or anonymous:NAME")
+	flagTo := flags.String("to", "", "Key fingerprint or cert:KEY_ID of the player to move games to")
+	flagForce := flags.Bool("force", false, "Allows merging two players who both have keys")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *flagFrom == "" || *flagTo == "" {
+		return errors.New("--from and --to are required")
+	}
+
+	queries, err := getStore()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	merged, err := mergePlayers(ctx, queries, *flagFrom, *flagTo, *flagForce, time.Now())
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Merged %d games from %s into %s\n", merged, *flagFrom, *flagTo)
+	return nil
+}
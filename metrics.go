@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Game modes by which metrics are labeled. Daily puzzles are counted as daily
+// even in ultra-hard mode, and every other game as free.
+const (
+	_metricModeDaily = "daily"
+	_metricModeFree  = "free"
+	_metricModeHard  = "hard"
+)
+
+var _metricModes = []string{_metricModeDaily, _metricModeFree, _metricModeHard}
+
+// _metrics holds every metric exported with --metrics-addr. Metrics are shared
+// by every session on the server, so they are registered once, here, rather
+// than when models are created.
+var (
+	_metrics = &metricsRegistry{}
+
+	_metricGuessesPerWin = _metrics.register(newHistogram(
+		"clidle_guesses_per_win",
+		"Number of guesses taken to win a game.",
+		[]float64{1, 2, 3, 4, 5, 6},
+	))
+	_metricSolveSeconds = _metrics.register(newHistogram(
+		"clidle_solve_duration_seconds",
+		"Time taken to win a game, in seconds.",
+		[]float64{15, 30, 60, 120, 300, 600, 1200, 1800},
+	))
+)
+
+// metricsRegistry exports histograms in the Prometheus text format.
+type metricsRegistry struct {
+	mu         sync.Mutex
+	histograms []*histogram
+}
+
+// register adds a histogram to the registry. Registering two histograms under
+// the same name is a programming error, so it panics.
+func (r *metricsRegistry) register(h *histogram) *histogram {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, other := range r.histograms {
+		if other.name == h.name {
+			panic(fmt.Sprintf("metric %s registered twice", h.name))
+		}
+	}
+	r.histograms = append(r.histograms, h)
+	return h
+}
+
+// handler returns the handler serving the metrics at /metrics.
+func (r *metricsRegistry) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /metrics", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		r.write(w)
+	})
+	return mux
+}
+
+// write writes every metric in the Prometheus text format.
+func (r *metricsRegistry) write(w io.Writer) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, h := range r.histograms {
+		h.write(w)
+	}
+}
+
+// histogram counts observations in cumulative buckets, per game mode.
+type histogram struct {
+	name    string
+	help    string
+	buckets []float64
+
+	mu     sync.Mutex
+	series map[string]*histogramSeries
+}
+
+// histogramSeries holds the observations of a single mode. counts[i] is the
+// number of observations in bucket i alone; they are summed up when written.
+type histogramSeries struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// newHistogram creates a histogram with the given upper bounds, in increasing
+// order. Every mode starts out empty, so that it is exported before anything
+// is observed.
+func newHistogram(name, help string, buckets []float64) *histogram {
+	h := &histogram{name: name, help: help, buckets: buckets, series: make(map[string]*histogramSeries)}
+	for _, mode := range _metricModes {
+		h.series[mode] = &histogramSeries{counts: make([]uint64, len(buckets))}
+	}
+	return h
+}
+
+// observe records a value for a mode.
+func (h *histogram) observe(mode string, value float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, ok := h.series[mode]
+	if !ok {
+		s = &histogramSeries{counts: make([]uint64, len(h.buckets))}
+		h.series[mode] = s
+	}
+	if i := sort.SearchFloat64s(h.buckets, value); i < len(h.buckets) {
+		s.counts[i]++
+	}
+	s.sum += value
+	s.count++
+}
+
+func (h *histogram) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	modes := make([]string, 0, len(h.series))
+	for mode := range h.series {
+		modes = append(modes, mode)
+	}
+	sort.Strings(modes)
+
+	fmt.Fprintf(w, "# HELP %s %s\n", h.name, h.help)
+	fmt.Fprintf(w, "# TYPE %s histogram\n", h.name)
+	for _, mode := range modes {
+		s := h.series[mode]
+		var cumulative uint64
+		for i, bound := range h.buckets {
+			cumulative += s.counts[i]
+			fmt.Fprintf(w, "%s_bucket{mode=%q,le=%q} %d\n", h.name, mode, formatMetricValue(bound), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket{mode=%q,le=\"+Inf\"} %d\n", h.name, mode, s.count)
+		fmt.Fprintf(w, "%s_sum{mode=%q} %s\n", h.name, mode, formatMetricValue(s.sum))
+		fmt.Fprintf(w, "%s_count{mode=%q} %d\n", h.name, mode, s.count)
+	}
+}
+
+// formatMetricValue formats a value as Prometheus does, without trailing
+// zeros.
+func formatMetricValue(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// metricMode returns the mode under which the current game is counted.
+func (m *model) metricMode() string {
+	switch {
+	case m.daily != "":
+		return _metricModeDaily
+	case m.options.ultraHard:
+		return _metricModeHard
+	default:
+		return _metricModeFree
+	}
+}
+
+// observeWin records the number of guesses and the solve time of a won game.
+// Practice games aren't counted.
+func (m *model) observeWin() {
+	if m.practice {
+		return
+	}
+	mode := m.metricMode()
+	_metricGuessesPerWin.observe(mode, float64(len(m.game.Guesses())))
+	_metricSolveSeconds.observe(mode, m.clock.Now().Sub(m.startedAt).Round(time.Millisecond).Seconds())
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// scrapeMetrics returns the metrics as served at /metrics.
+func scrapeMetrics(t *testing.T) string {
+	t.Helper()
+	server := httptest.NewServer(_metrics.handler())
+	defer server.Close()
+	resp, err := http.Get(server.URL + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(body)
+}
+
+// metricValue returns the value of a series in the scraped metrics.
+func metricValue(t *testing.T, metrics, series string) float64 {
+	t.Helper()
+	for _, line := range strings.Split(metrics, "\n") {
+		if value, ok := strings.CutPrefix(line, series+" "); ok {
+			v, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return v
+		}
+	}
+	t.Fatalf("series %s not found in:\n%s", series, metrics)
+	return 0
+}
+
+func TestMetrics(t *testing.T) {
+	before := scrapeMetrics(t)
+
+	// Models are created per session, but share the same metrics.
+	clock := newFakeClock(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC))
+	for range 2 {
+		m := newTestModel(t, "TRACE", 80, 40)
+		m.clock = clock
+		m.options.ultraHard = true
+		m.startGame(m.game.Answer())
+		clock.Advance(45 * time.Second)
+		// Green letters stay in place in ultra-hard mode, so only T and C
+		// are typed.
+		typeKeys(m, "crane\ntc\n")
+	}
+
+	after := scrapeMetrics(t)
+	for _, name := range []string{"clidle_guesses_per_win", "clidle_solve_duration_seconds"} {
+		if n := strings.Count(after, "# TYPE "+name+" histogram\n"); n != 1 {
+			t.Errorf("%s exported %d times, want once", name, n)
+		}
+	}
+
+	// Both wins took 2 guesses and 45 seconds, in ultra-hard mode. Other
+	// tests also win games, so only the difference is checked.
+	for series, want := range map[string]float64{
+		`clidle_guesses_per_win_bucket{mode="hard",le="1"}`:         0,
+		`clidle_guesses_per_win_bucket{mode="hard",le="2"}`:         2,
+		`clidle_guesses_per_win_count{mode="hard"}`:                 2,
+		`clidle_guesses_per_win_sum{mode="hard"}`:                   4,
+		`clidle_solve_duration_seconds_bucket{mode="hard",le="30"}`: 0,
+		`clidle_solve_duration_seconds_bucket{mode="hard",le="60"}`: 2,
+		`clidle_solve_duration_seconds_sum{mode="hard"}`:            90,
+		`clidle_guesses_per_win_count{mode="daily"}`:                0,
+	} {
+		if got := metricValue(t, after, series) - metricValue(t, before, series); got != want {
+			t.Errorf("%s increased by %v, want %v", series, got, want)
+		}
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected registering a metric twice to panic")
+		}
+	}()
+	_metrics.register(newHistogram("clidle_guesses_per_win", "", nil))
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/ajeetdsouza/clidle/pkg/game"
+	"github.com/pkg/errors"
+)
+
+// migrations upgrade databases created by older versions of clidle. The schema
+// in schema.sql always describes the latest version, so migrations[i] must
+// bring a database from version i to version i+1.
+var migrations = []string{
+	// Version 1: player identity, daily puzzles and timings.
+	`ALTER TABLE game ADD COLUMN player TEXT;
+	ALTER TABLE game ADD COLUMN player_name TEXT;
+	ALTER TABLE game ADD COLUMN daily TEXT;
+	ALTER TABLE game ADD COLUMN started_at TIMESTAMP;
+	ALTER TABLE game ADD COLUMN finished_at TIMESTAMP;`,
+	// Version 2: games that fail validation.
+	`ALTER TABLE game ADD COLUMN flagged BOOLEAN NOT NULL DEFAULT FALSE;`,
+	// Version 3: points deducted for using assists.
+	`ALTER TABLE game ADD COLUMN penalty INTEGER NOT NULL DEFAULT 0;`,
+	// Version 4: the rarity of words accepted as guesses.
+	`ALTER TABLE game ADD COLUMN max_rarity INTEGER NOT NULL DEFAULT 1;`,
+	// Version 5: IDs with which finished games can be viewed by others.
+	`ALTER TABLE game ADD COLUMN share_id TEXT;
+	CREATE UNIQUE INDEX game_share_id ON game (share_id);`,
+	// Version 6: points deducted for losing a game.
+	`ALTER TABLE game ADD COLUMN loss_penalty INTEGER NOT NULL DEFAULT 0;`,
+	// Version 7: the number of the daily puzzle, counted from an epoch.
+	`ALTER TABLE game ADD COLUMN daily_number INTEGER;`,
+	// Version 8: IDs with which games are synced between databases, and
+	// whether a game was synced from elsewhere.
+	`ALTER TABLE game ADD COLUMN uuid TEXT;
+	ALTER TABLE game ADD COLUMN imported BOOLEAN NOT NULL DEFAULT FALSE;
+	UPDATE game SET uuid = lower(hex(randomblob(16)));
+	CREATE UNIQUE INDEX game_uuid ON game (uuid);`,
+	// Version 9: the feedback for each guess, backfilled by backfillPatterns.
+	`ALTER TABLE guess ADD COLUMN pattern TEXT;`,
+	// Version 10: the address of anonymous players, so that they can claim
+	// their games, and a log of merged players.
+	`ALTER TABLE game ADD COLUMN remote_ip TEXT;
+	CREATE TABLE player_merge (
+	    id INTEGER PRIMARY KEY AUTOINCREMENT,
+	    from_player TEXT NOT NULL,
+	    to_player TEXT NOT NULL,
+	    games INTEGER NOT NULL,
+	    merged_by TEXT NOT NULL,
+	    merged_at TIMESTAMP NOT NULL
+	);`,
+	// Version 11: preferences of players with a key.
+	`CREATE TABLE player_preference (
+	    player TEXT PRIMARY KEY,
+	    skip_home BOOLEAN NOT NULL DEFAULT FALSE
+	);`,
+	// Version 12: games played through the JSON API.
+	`ALTER TABLE game ADD COLUMN api BOOLEAN NOT NULL DEFAULT FALSE;`,
+	// Version 13: players can hide from the web leaderboard.
+	`ALTER TABLE player_preference ADD COLUMN hide_from_web BOOLEAN NOT NULL DEFAULT FALSE;`,
+	// Version 14: time spent playing.
+	`CREATE TABLE player_playtime (
+	    player TEXT PRIMARY KEY,
+	    seconds INTEGER NOT NULL DEFAULT 0
+	);`,
+	// Version 15: autosaved boards of games in progress.
+	`CREATE TABLE game_autosave (
+	    game_id INTEGER PRIMARY KEY REFERENCES game(id),
+	    snapshot TEXT NOT NULL,
+	    saved_at TIMESTAMP NOT NULL
+	);`,
+	// Version 16: games resumed after clidle was closed, whose solve time
+	// includes the time in between.
+	`ALTER TABLE game ADD COLUMN resumed BOOLEAN NOT NULL DEFAULT FALSE;`,
+}
+
+// backfills compute data for migrations that can't be expressed in SQL.
+// backfills[i] runs right after the migration to version i.
+var backfills = map[int]func(tx *sql.Tx) error{
+	9: backfillPatterns,
+}
+
+// migrate brings the database schema up to date. New databases are created
+// directly from schema.sql, whereas existing databases are upgraded using the
+// migrations that haven't been applied yet, as tracked by PRAGMA user_version.
+// Up-to-date databases are left untouched.
+func migrate(db *sql.DB) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var version int
+	if err := tx.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return errors.Wrap(err, "could not read schema version")
+	}
+	if version > len(migrations) {
+		return errors.Errorf("database schema version %d is newer than supported version %d", version, len(migrations))
+	}
+	if version == len(migrations) {
+		return nil
+	}
+
+	var numTables int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'game'").Scan(&numTables); err != nil {
+		return errors.Wrap(err, "could not inspect schema")
+	}
+
+	if numTables == 0 {
+		if _, err := tx.Exec(schemaSQL); err != nil {
+			return errors.Wrap(err, "could not create schema")
+		}
+	} else {
+		for i := version; i < len(migrations); i++ {
+			if _, err := tx.Exec(migrations[i]); err != nil {
+				return errors.Wrapf(err, "could not migrate schema to version %d", i+1)
+			}
+			if backfill := backfills[i+1]; backfill != nil {
+				if err := backfill(tx); err != nil {
+					return errors.Wrapf(err, "could not backfill schema version %d", i+1)
+				}
+			}
+		}
+	}
+
+	// PRAGMA statements cannot be parameterized.
+	if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", len(migrations))); err != nil {
+		return errors.Wrap(err, "could not update schema version")
+	}
+	return tx.Commit()
+}
+
+// backfillPatterns computes the feedback of guesses saved before it was stored.
+// Guesses that can't be evaluated are left without one.
+func backfillPatterns(tx *sql.Tx) error {
+	rows, err := tx.Query(`SELECT guess.id, guess.guess, game.answer FROM guess
+		INNER JOIN game ON game.id = guess.game_id
+		WHERE guess.pattern IS NULL`)
+	if err != nil {
+		return err
+	}
+	patterns := make(map[int64]string)
+	for rows.Next() {
+		var id int64
+		var guess, answer sql.NullString
+		if err := rows.Scan(&id, &guess, &answer); err != nil {
+			rows.Close()
+			return err
+		}
+		guessWord, err := game.ParseWord(guess.String)
+		if err != nil {
+			continue
+		}
+		answerWord, err := game.ParseWord(answer.String)
+		if err != nil {
+			continue
+		}
+		patterns[id] = game.Evaluate(guessWord, answerWord).Pattern()
+	}
+	if err := rows.Close(); err != nil {
+		return err
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for id, pattern := range patterns {
+		if _, err := tx.Exec("UPDATE guess SET pattern = ? WHERE id = ?", pattern, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -5,65 +5,481 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"math/rand"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"log/slog"
 
+	"github.com/ajeetdsouza/clidle/pkg/game"
 	"github.com/ajeetdsouza/clidle/store"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/pkg/errors"
 )
 
 const (
 	// _numGuesses is the maximum number of guesses you can make.
-	_numGuesses = 6
+	_numGuesses = game.NumGuesses
 	// _numChars is the word size in characters.
-	_numChars = 5
+	_numChars = game.NumChars
+	// _leaderboardSize is the maximum number of players shown on the daily
+	// leaderboard.
+	_leaderboardSize = 10
+	// _autoSubmitDelay is how long a full row waits before it is submitted
+	// when auto-submit is enabled, so that a typo can still be deleted.
+	_autoSubmitDelay = 300 * time.Millisecond
+)
+
+// options holds the user-configurable settings for a game.
+type options struct {
+	// daily plays the puzzle of the day instead of a random word.
+	daily bool
+	// dailyLocation is the time zone in which the daily puzzle changes at
+	// midnight.
+	dailyLocation *time.Location
+	// dailyEpoch is the date of daily puzzle #1, at midnight UTC.
+	dailyEpoch time.Time
+	// expertKeyboard marks letters whose every copy has been located with a
+	// distinct color on the keyboard.
+	expertKeyboard bool
+	// ultraHard rejects guesses that aren't consistent with the feedback for
+	// earlier guesses.
+	ultraHard bool
+	// freePlay accepts any five letters as a guess, in practice games that
+	// are neither scored nor saved.
+	freePlay bool
+	// autoSubmit submits a guess shortly after its last letter is typed.
+	autoSubmit bool
+	// border is the style of border drawn around tiles and the keyboard.
+	border lipgloss.Border
+	// keyboard controls how the keyboard is shown (auto, compact, full, off).
+	keyboard string
+	// tileGap is the number of blank cells between tiles in the grid.
+	tileGap int
+	// lowercase shows letters in lowercase. It only affects how keys are
+	// rendered, and letters are always uppercase everywhere else.
+	lowercase bool
+	// benchmark shows how many guesses a solver would have taken once the
+	// game is over.
+	benchmark bool
+	// walkthrough shows how a solver would have found the answer once a
+	// game is lost.
+	walkthrough bool
+	// revealOnQuit prints the answer of an unfinished practice game after
+	// exiting.
+	revealOnQuit bool
+
+	// quiet suppresses the result that is printed after exiting. Logging is
+	// quieted separately, when it is set up.
+	quiet bool
+	// maxRarity is the highest rarity of words accepted as guesses.
+	maxRarity int
+	// startupStats briefly shows a summary of lifetime stats when the UI
+	// is created.
+	startupStats bool
+	// versus pairs up players on the server to race on the same answer.
+	versus bool
+	// assistEliminate lets the player ask whether any of a set of letters is
+	// in the answer, at the cost of a few points.
+	assistEliminate bool
+	// assistKeys highlights the untried letters on the keyboard that would
+	// narrow down the possible answers the most.
+	assistKeys bool
+	// freebie gives away one letter of the answer at the start of every
+	// game, at the cost of a few points.
+	freebie bool
+	// puzzle is the answer and rules of the first game, if it was started
+	// from a puzzle code.
+	puzzle *puzzle
+	// home shows the home screen when a player connects to the server, unless
+	// they prefer to skip it.
+	home bool
+	// muteChat hides chat messages from the opponent in a duel.
+	muteChat bool
+	// noPersist keeps games in memory instead of saving them to the
+	// database, so that scores only last for the session.
+	noPersist bool
+	// shareable gives finished games a random ID, with which others can view
+	// them over SSH.
+	shareable bool
+	// dailyGuessInterval is the minimum time between accepted guesses of a
+	// player on the daily puzzle on the server, or zero for no minimum.
+	dailyGuessInterval time.Duration
+	// referee keeps the answers of games on the server outside of the
+	// sessions playing them, which only get the feedback for their guesses.
+	referee bool
+	// webAddr is the address on which the server serves a read-only
+	// leaderboard page, or empty for none.
+	webAddr string
+	// snapshotFile is where the board of an unfinished game is saved, so
+	// that it can be restored if clidle is killed, or empty to not save it.
+	snapshotFile string
+	// autosave is how often the board of a game in progress is saved while
+	// the player types, if it has changed, or zero to only save it after
+	// every guess.
+	autosave time.Duration
+	// metricsAddr is the address on which the server exports Prometheus
+	// metrics, or empty for none.
+	metricsAddr string
+	// pprofAddr is the address on which the server serves profiles, or
+	// empty for none.
+	pprofAddr string
+	// webhookURL is the URL to which notable events on the server are
+	// posted, or empty for none. webhookSecret signs their bodies, and
+	// webhookEvents are the types of events sent.
+	webhookURL    string
+	webhookSecret string
+	webhookEvents map[webhookEvent]bool
+	// passphraseFile is the file holding the passphrase of the server's
+	// host key, if it is encrypted. encryptHostKey encrypts a new host key.
+	passphraseFile string
+	encryptHostKey bool
+	// trustedUserCA is the file of the certificate authorities whose user
+	// certificates identify players, and revokedKeys the file of the keys
+	// and certificates that are rejected. Either may be empty.
+	trustedUserCA string
+	revokedKeys   string
+	// rosterFile names players and gives them roles, or is empty for none.
+	rosterFile string
+	// noGuests turns away players without a key, rather than letting them
+	// in as guests.
+	noGuests bool
+	// minWidth and minHeight are the smallest window in which the game is
+	// shown. Smaller windows show a message asking for a larger one instead.
+	minWidth  int
+	minHeight int
+	// maxSessions is the number of players that can connect to the server at
+	// once, or zero if there is no limit.
+	maxSessions int
+	// streaks tells the player when a streak freeze is spent on a loss. It
+	// is only meaningful if the database holds a single player's games.
+	streaks bool
+	// historyFile is the path of a file to which the result of every
+	// completed game is appended, if set.
+	historyFile string
+	// exportImage is the path of an image file to which the board of every
+	// completed game is written, if set. Its extension chooses the format.
+	exportImage string
+	// reducedMotion shows changes right away instead of animating them.
+	reducedMotion bool
+	// title sets the terminal title to the progress of the game.
+	title bool
+	// notify is how the player is notified of rejected guesses, the end of
+	// a game and idle nudges (flash, bell, none).
+	notify string
+	// idleNudge is how long a game waits for a key press before asking
+	// whether the player is still there, or zero to never ask.
+	idleNudge time.Duration
+	// idleTimeout is how long players can go without pressing a key before
+	// they are disconnected from the server, or the game is saved and
+	// clidle exits in the terminal, or zero for no limit.
+	idleTimeout time.Duration
+
+	// lossPenalty is the number of points deducted from the total score for
+	// every lost game.
+	lossPenalty int
+
+	// weeklyRecap offers a recap of the daily puzzles of the last week. Like
+	// streaks, it covers every game in the database, so it is only offered
+	// when playing locally.
+	weeklyRecap bool
+
+	// solveTimes shows how long a won game took next to the average and best
+	// times. Like streaks, these are computed over every game in the database,
+	// so they are only shown when playing locally.
+	solveTimes bool
+	// color overrides the detected color profile (auto, always, never,
+	// truecolor, 256).
+	color string
+}
+
+// screen is what is shown in place of, or alongside, the game. Every screen
+// other than the game handles key presses before the game does.
+type screen int
+
+const (
+	screenGame screen = iota
+	screenHome
+	screenScoring
+	screenSettings
+	screenDuel
+	screenWeekly
 )
 
 type model struct {
 	ctx        context.Context
 	store      *store.Queries
 	dictionary Dictionary
-
-	gameID   int
-	gameOver bool
-
-	score  int
-	answer [_numChars]byte
+	options    options
+	renderer   *lipgloss.Renderer
+	styles     *styles
+	clock      clock
+
+	// writeCtx is used to persist the game. Unlike ctx, it isn't canceled
+	// when the session ends, so that guesses the player has already made
+	// aren't lost if they disconnect right away.
+	writeCtx context.Context
+
+	// player uniquely identifies the player, and is empty if unknown.
+	player     string
+	playerName string
+	// guestToken is the token of a guest profile made for this session,
+	// which is shown when the session ends so that it can be reclaimed.
+	guestToken string
+	// remoteIP is the address the player connected from on the server. It is
+	// only saved with anonymous games, so that they can be claimed later.
+	remoteIP string
+	// host is the name of the server, shown in the terminal title.
+	host string
+
+	// canSetTitle is set if the output is a terminal, whose title can be
+	// set. windowTitle is the title that was last set, and titleSaved is set
+	// once the previous title has been saved, so that it is restored on exit.
+	canSetTitle bool
+	windowTitle string
+	titleSaved  bool
+
+	game   *game.Game
+	record *gameRecord
+	// lastSnapshot is the last snapshot saved of the current game, so that an
+	// unchanged board isn't saved again.
+	lastSnapshot string
+	// practice is set if the current game is unscored, and is never saved.
+	practice bool
+	// daily is the date of the current daily puzzle, and dailyNumber its
+	// number, or empty if the current game isn't a daily puzzle.
+	daily       string
+	dailyNumber int
+	startedAt   time.Time
+
+	// score is the total score, and shownScore is the total as currently
+	// shown, which lags behind while it counts up.
+	score      int
+	shownScore int
+	countUp    *countUp
+
+	// penalty is the number of points deducted from the current game for
+	// using assists.
+	penalty int
+	// freebie is the position of the letter given away at the start of the
+	// current game, or -1 if none was.
+	freebie int
+	// puzzleCode is the code with which the current game can be replayed, or
+	// empty if it can't be shared.
+	puzzleCode string
+
+	// solveTimes compares the solve time of the current game with previous
+	// games, once it has been won and saved.
+	solveTimes *solveTimes
+
+	// invites pairs up players who share an invite code, and is nil unless
+	// playing on the server. inviteCode is the code of the player's match
+	// while it is waiting for a friend.
+	invites    *invites
+	inviteCode string
+	// pacer enforces a minimum interval between guesses on the daily puzzle,
+	// and is nil unless playing on the server.
+	pacer *pacer
+	// referee picks the answers and judges guesses in place of the model, which
+	// then never knows the answer before the game is over. It is nil unless the
+	// server was started with --referee.
+	referee        *referee
+	refereeSession uint64
+	refereeRound   uint64
+	// judging is set while a guess is being judged by the referee.
+	judging bool
+	// duelCode is the code typed into the duel screen.
+	duelCode string
+	// chatting is set while a chat message to the opponent is being typed
+	// into chatInput. lastChatAt is when the player last sent one.
+	chatting   bool
+	chatInput  string
+	lastChatAt time.Time
+
+	// lobby pairs up players for head-to-head matches, and is nil unless
+	// versus is enabled on the server.
+	lobby     *lobby
+	match     *match
+	seat      int
+	matchView matchView
+
+	// screen is the screen currently shown, and back is the one it returns
+	// to once closed.
+	screen screen
+	back   screen
+
+	// home is what the home screen shows, loaded when it is opened, and
+	// homeRow is the selected item. skipHome is set if the player prefers to
+	// go straight to a game.
+	home     *homeSummary
+	homeRow  int
+	skipHome bool
+	// hideFromWeb is set if the player prefers to be left out of the web
+	// leaderboard.
+	hideFromWeb bool
+
+	// sessions counts the players connected to the server, and is nil unless
+	// playing on the server. refreshingSessions is set while a refresh of the
+	// count shown is pending.
+	sessions           *sessions
+	refreshingSessions bool
+
+	// weekly is the weekly recap while it is shown.
+	weekly *weekly
+
+	// settingsRow is the selected setting on the settings screen.
+	settingsRow int
+
+	// eliminating is set while the player is marking letters for the
+	// eliminate assist.
+	eliminating    bool
+	eliminateMarks []byte
 
 	status        string
 	statusPending int
 
+	// lastInputAt is when a key was last pressed. idleCheckPending is set
+	// while a check for inactivity is pending, idleNudged once the player
+	// has been asked whether they are still there, idleWarned once they
+	// have been warned that they are about to be disconnected, and
+	// idleTimedOut once they have been.
+	lastInputAt      time.Time
+	idleCheckPending bool
+	idleNudged       bool
+	idleWarned       bool
+	idleTimedOut     bool
+
+	// connectedAt is when the session started. playtime is the time played
+	// in this session, accrued up to playtimeAt, of which playtimeSaved has
+	// been added to lifetimePlaytime in the store. lifetimePlaytime is the
+	// time played in earlier sessions.
+	connectedAt      time.Time
+	playtimeAt       time.Time
+	playtime         time.Duration
+	playtimeSaved    time.Duration
+	lifetimePlaytime time.Duration
+
+	// flashing is set while the status bar is inverted to notify the player,
+	// and ringing while the bell is in the view. flashSeq identifies the
+	// latest of either, so that an earlier one ending doesn't cut it short.
+	flashing bool
+	ringing  bool
+	flashSeq int
+
+	// autoSubmitPending is set while a full row is waiting to be submitted.
+	// autoSubmitSeq identifies the latest scheduled submission, so that
+	// submissions that were canceled in the meantime are ignored.
+	autoSubmitPending bool
+	autoSubmitSeq     int
+
+	// location is the player's time zone, in which times of day are shown.
+	location *time.Location
+
 	windowHeight int
 	windowWidth  int
 
-	grid      [_numGuesses][_numChars]byte
-	gridRow   int
+	grid    [_numGuesses]game.Word
+	gridRow int
+	// gridLen is the number of letters filled in the current row, and
+	// gridCol the position of the cursor in it, which can be moved back over
+	// them to fix a typo.
+	gridLen   int
 	gridCol   int
-	keyStates map[byte]keyState
+	keyStates keyStates
+	// candidates are the answers that are still possible, and assistKeys the
+	// letters highlighted among them, indexed by ch - 'A'. Both are only kept
+	// for the key assist.
+	candidates []game.Word
+	assistKeys [26]bool
+
+	leaderboard []store.GetDailyLeaderboardRow
+
+	// writes persists the game in the background, in order.
+	writes writeQueue
+
+	// history records completed games in a file, and is nil unless a history
+	// file was given.
+	history *history
+	// webhooks sends notable events to the operator's webhook, and is nil
+	// unless one was given.
+	webhooks *webhooks
+	// settingsFile keeps the settings between sessions in the terminal, and
+	// is nil on the server.
+	settingsFile *settingsFile
+
+	// result describes how the last completed game ended, and is shown in the
+	// status along with the points it earned and the benchmark, which is
+	// computed once as it is slow.
+	result    string
+	benchmark string
+	// walkthrough contains the guesses a solver would have made to find the
+	// answer of the last game, if it was lost and the walkthrough is enabled.
+	walkthrough []game.Word
+
+	// summary is the shareable result of the last completed game.
+	summary string
+	// shareID identifies the last completed game, if it can be viewed by
+	// others.
+	shareID string
 }
 
 var _ tea.Model = (*model)(nil)
 
-func newModel(ctx context.Context, store *store.Queries, dictionary Dictionary) *model {
+func newModel(ctx context.Context, store *store.Queries, dictionary Dictionary, options options, renderer *lipgloss.Renderer) *model {
 	return &model{
 		ctx:        ctx,
+		writeCtx:   context.WithoutCancel(ctx),
 		store:      store,
 		dictionary: dictionary,
-		keyStates:  make(map[byte]keyState, 26),
+		options:    options,
+		renderer:   renderer,
+		styles:     newStyles(renderer, options.border, options.tileGap),
+		clock:      realClock{},
+		location:   time.Local,
+		freebie:    -1,
 	}
 }
 
 // Init is the first function that is called when the UI is created.
 func (m *model) Init() tea.Cmd {
-	m.doRestart()
-	return nil
+	// A puzzle code picks the first game, so it is played instead of an
+	// unfinished one.
+	restore := m.options.puzzle == nil
+	m.connectedAt = m.clock.Now()
+	m.playtimeAt = m.connectedAt
+	m.syncSettingsFile()
+	m.loadPlaytime()
+	cmds := []tea.Cmd{m.doRestart(), m.recordInput()}
+	if restore {
+		m.restoreSnapshot()
+	}
+	if m.options.startupStats {
+		cmds = append(cmds, m.doShowStartupStats())
+	}
+	if m.options.home {
+		if m.loadSkipHome(); !m.skipHome {
+			cmds = append(cmds, m.doShowHome())
+		}
+	}
+	if m.options.webAddr != "" {
+		m.loadHideFromWeb()
+	}
+	cmds = append(cmds, m.schedulePlaytimeSave(), m.scheduleAutosave())
+	return tea.Batch(append(cmds, m.updateTitle())...)
 }
 
 // Update is called when a message is received. It inspects messages and, in response,
 // updates the Model and sends a command.
 func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	_, cmd := m.update(msg)
+	return m, tea.Batch(cmd, m.updateTitle())
+}
+
+// update handles a message, before the terminal title is updated to match.
+func (m *model) update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case msgResetStatus:
 		// If there is more than one pending status message, that means
@@ -74,29 +490,70 @@ func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.resetStatus()
 		}
 		return m, nil
-	case tea.KeyMsg:
-		// If any key is pressed, reset the status message.
-		m.resetStatus()
-
-		switch msg.Type {
-		case tea.KeyCtrlC:
-			return m, m.doExit()
-		case tea.KeyCtrlR:
-			m.doRestart()
+	case msgJudged:
+		return m, m.updateJudged(msg)
+	case msgSaved:
+		if msg.err != nil {
+			slog.Error("error saving game", slog.Any("error", msg.err))
+			return m, m.setStatus("Couldn't save your progress.", 2*time.Second)
+		}
+		return m, nil
+	case msgClaimed:
+		switch {
+		case msg.err != nil:
+			slog.Error("error claiming anonymous games", slog.Any("error", msg.err))
+			return m, m.setStatus("Couldn't claim your games.", 2*time.Second)
+		case msg.games == 0:
+			return m, m.setStatus("No anonymous games to claim.", 2*time.Second)
+		}
+		return m, m.setStatus(fmt.Sprintf("Claimed %d anonymous games.", msg.games), 2*time.Second)
+	case msgInviteExpired:
+		return m, m.updateInviteExpired(msg)
+	case msgRefreshSessions:
+		return m, m.updateRefreshSessions()
+	case msgIdleCheck:
+		return m, m.updateIdleCheck()
+	case msgSavePlaytime:
+		return m, tea.Batch(m.doSavePlaytime(), m.schedulePlaytimeSave())
+	case msgAutosave:
+		return m, tea.Batch(m.saveSnapshot(), m.scheduleAutosave())
+	case msgScore:
+		// A running count-up ends at the new score by itself.
+		m.score = msg.score
+		if m.countUp == nil {
+			m.setShownScore(m.score)
+		}
+		return m, nil
+	case msgCountUp:
+		return m, m.updateCountUp(msg)
+	case msgFlashEnd:
+		return m, m.updateFlashEnd(msg)
+	case msgStreakFrozen:
+		if msg.record != m.record {
+			return m, nil
+		}
+		m.result = fmt.Sprintf("The word was %s. A streak freeze saved your streak of %d! (%d left)", m.game.Answer(), msg.streak, msg.freezes)
+		return m, m.setStatus(m.viewResult(), 0)
+	case msgMatch:
+		return m, m.updateMatch(msg)
+	case msgLeaderboard:
+		if msg.record == m.record {
+			m.leaderboard = msg.leaderboard
+		}
+		return m, nil
+	case msgSolveTimes:
+		if msg.record == m.record {
+			m.solveTimes = &msg.times
+		}
+		return m, nil
+	case msgAutoSubmit:
+		if !m.autoSubmitPending || msg.seq != m.autoSubmitSeq {
 			return m, nil
-		case tea.KeyBackspace:
-			return m, m.doDeleteChar()
-		case tea.KeyEnter:
-			if m.gameOver {
-				m.doRestart()
-				return m, nil
-			}
-			return m, m.doAcceptGuess()
-		case tea.KeyRunes:
-			if len(msg.Runes) == 1 {
-				return m, m.doAcceptChar(msg.Runes[0])
-			}
 		}
+		m.autoSubmitPending = false
+		return m, m.doAcceptGuess()
+	case tea.KeyMsg:
+		return m, tea.Batch(m.recordInput(), m.updateKey(msg))
 	case tea.WindowSizeMsg:
 		// If the window is resized, store its new dimensions.
 		return m, m.doResize(msg)
@@ -104,130 +561,576 @@ func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
+// updateKey handles a key press.
+func (m *model) updateKey(msg tea.KeyMsg) tea.Cmd {
+	// Ignore everything but quitting until the match starts, or while
+	// the game isn't shown. An invite can be withdrawn while waiting.
+	if m.isWaitingForOpponent() && m.inviteCode != "" && msg.Type == tea.KeyEsc {
+		return m.doCancelInvite()
+	}
+	if (m.isWaitingForOpponent() || m.isWindowTooSmall()) && msg.Type != tea.KeyCtrlC {
+		return nil
+	}
+
+	// If any key is pressed, reset the status message.
+	m.resetStatus()
+
+	// Any key other than a letter cancels a pending auto-submit, whether
+	// it edits the row, submits it right away, or leaves it.
+	if msg.Type != tea.KeyRunes {
+		m.cancelAutoSubmit()
+	}
+
+	switch m.screen {
+	case screenHome:
+		return m.updateHome(msg)
+	case screenScoring:
+		return m.updateScoring(msg)
+	case screenSettings:
+		return m.updateSettings(msg)
+	case screenDuel:
+		return m.updateDuel(msg)
+	case screenWeekly:
+		return m.updateWeekly(msg)
+	}
+	if m.chatting {
+		return m.updateChat(msg)
+	}
+	if m.eliminating {
+		return m.updateEliminate(msg)
+	}
+
+	switch msg.Type {
+	case tea.KeyCtrlC:
+		return m.doExit()
+	case tea.KeyCtrlR:
+		return m.doRestart()
+	case tea.KeyBackspace:
+		return m.doDeleteChar()
+	case tea.KeyLeft:
+		return m.doMoveCursor(-1)
+	case tea.KeyRight:
+		return m.doMoveCursor(1)
+	case tea.KeyCtrlU, tea.KeyEsc:
+		return m.doClearRow()
+	case tea.KeyCtrlE:
+		return m.doStartEliminate()
+	case tea.KeyCtrlO:
+		return m.doToggleSettings()
+	case tea.KeyCtrlW:
+		return m.doShowWeekly()
+	case tea.KeyCtrlL:
+		return m.doShowHome()
+	case tea.KeyCtrlT:
+		if m.match != nil {
+			return m.doStartChat()
+		}
+		return m.doToggleDuel()
+	case tea.KeyEnter:
+		if m.isGameOver() {
+			return m.doRestart()
+		}
+		return m.doAcceptGuess()
+	case tea.KeyRunes:
+		if len(msg.Runes) == 1 && msg.Runes[0] == '?' {
+			return m.doToggleScoring()
+		}
+		if len(msg.Runes) == 1 {
+			return m.doAcceptChar(msg.Runes[0])
+		}
+	}
+	return nil
+}
+
 func (m *model) View() string {
+	return m.view() + m.viewBell()
+}
+
+// view renders the screen the player is on.
+func (m *model) view() string {
+	if m.isWindowTooSmall() {
+		msg := fmt.Sprintf("Please use a larger terminal, minimum %dx%d.", m.options.minWidth, m.options.minHeight)
+		msg = m.styles.status.Width(m.windowWidth).Align(lipgloss.Center).Render(msg)
+		return m.renderer.Place(m.windowWidth, m.windowHeight, lipgloss.Center, lipgloss.Center, msg)
+	}
+	if m.gridLayout() == _gridLayoutTooNarrow {
+		return m.viewTooNarrow()
+	}
+
 	status := m.viewStatus()
 	grid := m.viewGrid()
-	keyboard := m.viewKeyboard()
+	switch m.screen {
+	case screenHome:
+		grid = m.viewHome()
+	case screenSettings:
+		// Show the settings in place of the grid, so that the keyboard
+		// previews the changes.
+		grid = m.viewSettings()
+	case screenWeekly:
+		grid = m.viewWeekly()
+	case screenDuel:
+		grid = m.viewDuel()
+	}
+	if m.match != nil && m.screen != screenSettings && m.screen != screenWeekly {
+		grid = lipgloss.JoinHorizontal(lipgloss.Center, grid, "  ", m.viewOpponent())
+	}
+	var keyboard string
+	if m.options.keyboard != "off" && m.screen != screenHome {
+		keyboard = m.viewKeyboard()
+	}
 
-	// Truncate the status if it is too long.
-	if len(status) > m.windowWidth && m.windowWidth > 3 {
-		status = status[:m.windowWidth-3] + "..."
+	// Check if the keyboard fits. The dimensions are measured on the rendered
+	// output, so they account for the width of the chosen border.
+	fits := func(keyboard string) bool {
+		height := lipgloss.Height(status) + lipgloss.Height(grid) + lipgloss.Height(keyboard)
+		width := lipgloss.Width(keyboard)
+		if width < lipgloss.Width(status) || width < lipgloss.Width(grid) {
+			width = 0
+		}
+		return m.windowHeight >= height && m.windowWidth >= width
 	}
 
-	// Drop the keyboard if it doesn't fit.
-	height := lipgloss.Height(status) + lipgloss.Height(grid) + lipgloss.Height(keyboard)
-	width := lipgloss.Width(keyboard)
-	if width < lipgloss.Width(status) || width < lipgloss.Width(grid) {
-		width = 0
+	// On small terminals, such as phones in portrait, fall back to a keyboard
+	// of bare letters. Drop the keyboard if even that doesn't fit, unless it
+	// should always be shown.
+	if m.options.keyboard != "full" && !fits(keyboard) {
+		keyboard = m.viewKeyboardLetters()
+		if !fits(keyboard) {
+			keyboard = ""
+		}
 	}
-	if m.windowHeight < height || m.windowWidth < width {
-		keyboard = ""
+
+	// Once the daily puzzle is over, show the leaderboard and when the next
+	// puzzle starts in place of the keyboard.
+	switch {
+	case m.screen == screenHome:
+	case m.screen == screenScoring:
+		keyboard = m.viewScoring()
+	case m.isGameOver() && len(m.walkthrough) > 0:
+		// Show the walkthrough above the leaderboard of a daily puzzle, in
+		// the lines left over by everything else.
+		var leaderboard string
+		if m.daily != "" {
+			leaderboard = m.viewLeaderboard()
+		}
+		height := m.windowHeight - lipgloss.Height(status) - lipgloss.Height(grid) - lipgloss.Height(leaderboard) - 2
+		keyboard = m.viewWalkthrough(height)
+		if leaderboard != "" {
+			keyboard = lipgloss.JoinVertical(lipgloss.Center, keyboard, leaderboard)
+		}
+	case m.isGameOver() && m.daily != "":
+		keyboard = m.viewLeaderboard()
+	case m.isGameOver() && m.solveTimes != nil:
+		keyboard = m.viewSolveTimes()
 	}
 
-	game := lipgloss.JoinVertical(lipgloss.Center, status, grid, keyboard, _controls)
-	return lipgloss.Place(m.windowWidth, m.windowHeight, lipgloss.Center, lipgloss.Center, game)
+	rows := []string{status, grid, keyboard}
+	if code := m.viewPuzzleCode(); code != "" && m.screen == screenGame {
+		rows = append(rows, code)
+	}
+	rows = append(rows, m.viewControls())
+	game := lipgloss.JoinVertical(lipgloss.Center, rows...)
+	return m.renderer.Place(m.windowWidth, m.windowHeight, lipgloss.Center, lipgloss.Center, game)
 }
 
 // doAcceptGuess accepts the current word.
 func (m *model) doAcceptGuess() tea.Cmd {
-	if m.gameOver {
+	if m.isGameOver() || m.judging {
 		return nil
 	}
 
 	// Only accept a word if it is complete.
-	if m.gridCol != _numChars {
-		return m.setStatus("Your guess must be a 5-letter word.", 1*time.Second)
+	if m.gridLen != _numChars {
+		return tea.Batch(m.notify(), m.setStatus("Your guess must be a 5-letter word.", 1*time.Second))
 	}
 
-	// Check if the input guess is valid.
+	// On the server, guesses on the daily puzzle can't be made faster than
+	// a person would, without using up a row. Every complete guess counts,
+	// including those that turn out not to be valid words.
+	if m.isPaced() && !m.pacer.allow(m.paceKey(), m.clock.Now()) {
+		return tea.Batch(m.notify(), m.setStatus("Not so fast! Wait a moment before your next guess.", 1*time.Second))
+	}
+
+	// Check if the input guess is valid. With a referee, it is checked by the
+	// referee instead, which knows the answer.
 	guess := m.grid[m.gridRow]
-	if !m.dictionary.IsWord(string(guess[:])) {
-		return m.setStatus("That's not a valid word.", 1*time.Second)
+	if m.referee != nil {
+		return m.judgeGuess(guess)
+	}
+	feedback, err := m.game.Guess(guess.String())
+	if err != nil {
+		return m.rejectGuess(guess, err)
+	}
+	return m.acceptGuess(guess, feedback)
+}
+
+// rejectGuess explains why a guess wasn't accepted.
+func (m *model) rejectGuess(guess game.Word, err error) tea.Cmd {
+	var violation *game.Violation
+	switch {
+	case errors.As(err, &violation):
+		return tea.Batch(m.notify(), m.setStatus(viewViolation(violation), 2*time.Second))
+	case m.dictionary.IsKnownWord(guess.String()):
+		return tea.Batch(m.notify(), m.setStatus("That word is too rare to be accepted.", 1*time.Second))
 	}
+	return tea.Batch(m.notify(), m.setStatus("That's not a valid word.", 1*time.Second))
+}
 
+// acceptGuess updates the board, saves the guess and checks if the game is
+// over, once the guess has been made in the game.
+func (m *model) acceptGuess(guess game.Word, feedback game.Feedback) tea.Cmd {
 	// Save the guess.
-	if err := m.saveGuess(string(guess[:])); err != nil {
-		slog.Error("error saving guess", slog.Any("error", err))
+	var save tea.Cmd
+	if !m.practice {
+		save = m.saveGuess(guess.String(), feedback)
 	}
 
 	// Update the state of the used letters.
-	success := true
 	for idx, key := range guess {
-		keyState := _keyStateAbsent
-		if key == m.answer[idx] {
-			keyState = _keyStateCorrect
-		} else {
-			success = false
-			if bytes.IndexByte(m.answer[:], key) != -1 {
-				keyState = _keyStatePresent
-			}
-		}
-		m.keyStates[key] = max(keyState, m.keyStates[key])
+		m.keyStates.set(key, max(keyState(feedback[idx]), m.keyStates.get(key)))
 	}
 
 	// Move the cursor to the next row.
 	m.gridRow++
-	m.gridCol = 0
+	m.gridLen, m.gridCol = 0, 0
+	if !m.isGameOver() {
+		m.skipLocked()
+	}
+	m.updateAssistKeys()
+
+	if m.options.expertKeyboard {
+		m.updateExhaustedKeys()
+	}
+	save = tea.Batch(save, m.saveSnapshot())
+
+	// Let the opponent know about the guess.
+	if m.match != nil {
+		m.match.report(m.seat, feedback, m.game.State())
+		m.matchView = m.match.view(m.seat)
+	}
 
 	// Check if the game is over.
-	if success {
-		return m.doWin()
-	} else if m.gridRow == _numGuesses {
-		return m.doLoss()
+	switch m.game.State() {
+	case game.StateWon:
+		return tea.Batch(save, m.doWin())
+	case game.StateLost:
+		return tea.Batch(save, m.doLoss())
 	}
 
-	return nil
+	return save
 }
 
-func (m *model) saveGuess(guess string) error {
-	ctx, cancel := context.WithTimeout(m.ctx, 5*time.Second)
-	defer cancel()
+// updateExhaustedKeys marks letters as exhausted once every copy of them in
+// the answer has been located in its correct position.
+func (m *model) updateExhaustedKeys() {
+	answer := m.game.Answer()
+	var located [_numChars]bool
+	for _, feedback := range m.game.Feedbacks() {
+		for i, letterState := range feedback {
+			if letterState == game.LetterCorrect {
+				located[i] = true
+			}
+		}
+	}
 
-	// Create a new game if one doesn't exist.
-	if m.gameID == 0 {
-		answer := sql.NullString{String: string(m.answer[:]), Valid: true}
-		game, err := m.store.CreateGame(ctx, answer)
-		if err != nil {
-			return err
+	var counts [256]int
+	for i := 0; i < _numChars; i++ {
+		if located[i] {
+			counts[answer[i]]++
 		}
-		m.gameID = int(game.ID)
 	}
+	for i := 0; i < _numChars; i++ {
+		key := answer[i]
+		if counts[key] == bytes.Count(answer[:], []byte{key}) {
+			m.keyStates.set(key, _keyStateExhausted)
+		}
+	}
+}
 
-	params := store.CreateGuessParams{
-		GameID: sql.NullInt64{Int64: int64(m.gameID), Valid: true},
-		Guess:  sql.NullString{String: guess, Valid: true},
+// saveGuess queues a write of the guess and its feedback to the database,
+// creating the game first if needed.
+func (m *model) saveGuess(guess string, feedback game.Feedback) tea.Cmd {
+	record := m.record
+	// The answer of a refereed game is only saved once it is revealed.
+	answer := m.game.Answer()
+	gameParams := store.CreateGameParams{
+		Answer:      sql.NullString{String: answer.String(), Valid: !m.game.IsHidden()},
+		Player:      sql.NullString{String: m.player, Valid: m.player != ""},
+		PlayerName:  sql.NullString{String: m.playerName, Valid: m.playerName != ""},
+		Daily:       sql.NullString{String: m.daily, Valid: m.daily != ""},
+		StartedAt:   sql.NullTime{Time: m.startedAt, Valid: true},
+		MaxRarity:   int64(m.options.maxRarity),
+		DailyNumber: sql.NullInt64{Int64: int64(m.dailyNumber), Valid: m.daily != ""},
+		RemoteIp:    sql.NullString{String: m.remoteIP, Valid: m.player == "" && m.remoteIP != ""},
 	}
-	_, err := m.store.CreateGuess(ctx, params)
-	if err != nil {
-		return err
+	return m.writes.enqueue(func() tea.Msg {
+		ctx, cancel := context.WithTimeout(m.writeCtx, 5*time.Second)
+		defer cancel()
+
+		// Create a new game if one doesn't exist.
+		if record.id == 0 {
+			uuid, err := newGameUUID()
+			if err != nil {
+				return msgSaved{err: err}
+			}
+			gameParams.Uuid = sql.NullString{String: uuid, Valid: true}
+
+			var row store.Game
+			err = store.Retry(ctx, func() (err error) {
+				row, err = m.store.CreateGame(ctx, gameParams)
+				return err
+			})
+			if err != nil {
+				return msgSaved{err: errors.Wrap(err, "could not create game")}
+			}
+			record.id = row.ID
+		}
+
+		params := store.CreateGuessParams{
+			GameID:  sql.NullInt64{Int64: record.id, Valid: true},
+			Guess:   sql.NullString{String: guess, Valid: true},
+			Pattern: sql.NullString{String: feedback.Pattern(), Valid: true},
+		}
+		err := store.Retry(ctx, func() error {
+			_, err := m.store.CreateGuess(ctx, params)
+			return err
+		})
+		if err != nil {
+			return msgSaved{err: errors.Wrap(err, "could not save guess")}
+		}
+		return msgSaved{}
+	})
+}
+
+// finishGame queues a write recording the end of the current game in the
+// database.
+func (m *model) finishGame() tea.Cmd {
+	record := m.record
+	finishedAt := m.clock.Now()
+	penalty := m.penalty
+	lossPenalty := m.lossPenalty()
+	shareID := m.shareID
+	revealed := m.referee != nil
+	answer := m.game.Answer()
+	return m.writes.enqueue(func() tea.Msg {
+		if record.id == 0 {
+			return msgSaved{}
+		}
+
+		ctx, cancel := context.WithTimeout(m.writeCtx, 5*time.Second)
+		defer cancel()
+
+		if revealed {
+			if err := m.saveAnswer(ctx, record, answer); err != nil {
+				return msgSaved{err: err}
+			}
+		}
+		params := store.FinishGameParams{
+			FinishedAt:  sql.NullTime{Time: finishedAt, Valid: true},
+			Penalty:     int64(penalty),
+			ShareID:     sql.NullString{String: shareID, Valid: shareID != ""},
+			LossPenalty: int64(lossPenalty),
+			ID:          record.id,
+		}
+		err := store.Retry(ctx, func() error { return m.store.FinishGame(ctx, params) })
+		if err != nil {
+			return msgSaved{err: errors.Wrap(err, "could not finish game")}
+		}
+		if err := validateGame(ctx, m.store, m.dictionary, record.id); err != nil {
+			return msgSaved{err: errors.Wrap(err, "could not validate game")}
+		}
+		return msgSaved{}
+	})
+}
+
+// appendHistory queues a write of the result of the current game to the
+// history file, if there is one.
+func (m *model) appendHistory() tea.Cmd {
+	if m.history == nil {
+		return nil
 	}
 
-	return nil
+	guesses := make([]string, len(m.game.Guesses()))
+	for i, guess := range m.game.Guesses() {
+		guesses[i] = guess.String()
+	}
+	patterns := make([]string, len(m.game.Feedbacks()))
+	for i, feedback := range m.game.Feedbacks() {
+		patterns[i] = feedback.Pattern()
+	}
+	result := "lost"
+	if m.game.State() == game.StateWon {
+		result = "won"
+	}
+	mode := "random"
+	score := m.earned()
+	switch {
+	case m.practice:
+		mode = "practice"
+		score = 0
+	case m.match != nil:
+		mode = "versus"
+	case m.daily != "":
+		mode = "daily"
+	}
+	entry := historyEntry{
+		Answer:      m.game.Answer().String(),
+		Guesses:     guesses,
+		Patterns:    patterns,
+		Result:      result,
+		Score:       score,
+		LossPenalty: m.lossPenalty(),
+		Mode:        mode,
+		Daily:       m.daily,
+		DailyNumber: m.dailyNumber,
+		Player:      m.playerName,
+		FinishedAt:  m.clock.Now(),
+	}
+	return m.writes.enqueue(func() tea.Msg {
+		return msgSaved{err: m.history.append(entry)}
+	})
 }
 
-// doAcceptChar adds one input character to the current word.
+// doAcceptChar adds one input character to the current word, at the cursor.
+// If the cursor was moved back over a letter, it is replaced.
 func (m *model) doAcceptChar(ch rune) tea.Cmd {
 	// Only accept a character if the current word is incomplete.
-	if m.gameOver || !(m.gridRow < _numGuesses && m.gridCol < _numChars) {
+	if m.isGameOver() || m.judging || !(m.gridRow < _numGuesses && m.gridCol < _numChars) {
 		return nil
 	}
 
 	ch = toAsciiUpper(ch)
-	if isAsciiUpper(ch) {
-		m.grid[m.gridRow][m.gridCol] = byte(ch)
-		m.gridCol++
+	if !isAsciiUpper(ch) {
+		return nil
 	}
-	return nil
+	m.grid[m.gridRow][m.gridCol] = byte(ch)
+	m.gridCol++
+	m.gridLen = max(m.gridLen, m.gridCol)
+	m.skipLocked()
+
+	var cmd tea.Cmd
+	if m.options.autoSubmit && m.gridCol == _numChars {
+		cmd = m.scheduleAutoSubmit()
+	}
+
+	// Warn about letters that are already known to be absent.
+	if m.keyStates.get(byte(ch)) == _keyStateAbsent {
+		return tea.Batch(cmd, m.setStatus(fmt.Sprintf("%c is not in the word.", ch), 1*time.Second))
+	}
+	return cmd
 }
 
-// doDeleteChar deletes the last character in the current word.
+// scheduleAutoSubmit returns a tea.Cmd that submits the current row after a
+// short delay, unless it is canceled first.
+func (m *model) scheduleAutoSubmit() tea.Cmd {
+	m.autoSubmitSeq++
+	m.autoSubmitPending = true
+	seq := m.autoSubmitSeq
+	after := m.clock.After(_autoSubmitDelay)
+	return func() tea.Msg {
+		<-after
+		return msgAutoSubmit{seq: seq}
+	}
+}
+
+// cancelAutoSubmit cancels the pending auto-submit, if any.
+func (m *model) cancelAutoSubmit() {
+	m.autoSubmitPending = false
+}
+
+// doDeleteChar deletes the character before the cursor in the current word,
+// and moves the ones after it back. Locked letters are skipped over rather than
+// deleted, and stay in place.
 func (m *model) doDeleteChar() tea.Cmd {
-	if !m.gameOver && m.gridCol > 0 {
-		m.gridCol--
+	if m.isGameOver() || m.judging {
+		return nil
 	}
+	locked := m.lockedLetters()
+	var free []int
+	for col := 0; col < m.gridLen; col++ {
+		if locked[col] == 0 {
+			free = append(free, col)
+		}
+	}
+	i := slices.IndexFunc(free, func(col int) bool { return col >= m.gridCol }) - 1
+	if i == -2 {
+		i = len(free) - 1
+	}
+	if i < 0 {
+		return nil
+	}
+	row := &m.grid[m.gridRow]
+	for ; i < len(free)-1; i++ {
+		row[free[i]] = row[free[i+1]]
+	}
+	m.gridLen = free[len(free)-1]
+	m.gridCol = m.prevFreeCol(m.gridCol)
 	return nil
 }
 
+// doMoveCursor moves the cursor back or forward by a letter in the current
+// row, skipping over locked letters. It can't move past the letters filled in.
+func (m *model) doMoveCursor(delta int) tea.Cmd {
+	if m.isGameOver() || m.judging {
+		return nil
+	}
+	if delta < 0 {
+		m.gridCol = m.prevFreeCol(m.gridCol)
+		return nil
+	}
+	locked := m.lockedLetters()
+	for col := m.gridCol + 1; col <= m.gridLen; col++ {
+		if col == _numChars || locked[col] == 0 {
+			m.gridCol = col
+			break
+		}
+	}
+	return nil
+}
+
+// prevFreeCol returns the last column before col that isn't locked, or col if
+// there is none.
+func (m *model) prevFreeCol(col int) int {
+	locked := m.lockedLetters()
+	for prev := col - 1; prev >= 0; prev-- {
+		if locked[prev] == 0 {
+			return prev
+		}
+	}
+	return col
+}
+
+// doClearRow deletes every character in the current word, except for locked
+// letters.
+func (m *model) doClearRow() tea.Cmd {
+	if !m.isGameOver() && !m.judging {
+		m.gridLen, m.gridCol = 0, 0
+		m.skipLocked()
+	}
+	return nil
+}
+
+// lockedLetters returns the letters that every guess has to keep in place in
+// ultra-hard mode, since they were already marked correct, or zero in
+// positions that are free.
+func (m *model) lockedLetters() game.Word {
+	if !m.options.ultraHard {
+		return game.Word{}
+	}
+	return m.game.Constraints().Correct()
+}
+
+// skipLocked fills in the locked letters that follow the cursor in the current
+// row, and moves the cursor past them, so that they never have to be typed.
+func (m *model) skipLocked() {
+	locked := m.lockedLetters()
+	for m.gridCol < _numChars && locked[m.gridCol] != 0 {
+		m.grid[m.gridRow][m.gridCol] = locked[m.gridCol]
+		m.gridCol++
+	}
+	m.gridLen = max(m.gridLen, m.gridCol)
+}
+
 // doExit exits the program.
 func (*model) doExit() tea.Cmd {
 	return tea.Quit
@@ -242,54 +1145,292 @@ func (m *model) doResize(msg tea.WindowSizeMsg) tea.Cmd {
 
 // doWin is called when the user has guessed the word correctly.
 func (m *model) doWin() tea.Cmd {
-	m.gameOver = true
-	m.updateScore()
-	return m.setStatus("You win!", 0)
+	m.observeWin()
+	cmd := m.doGameOver()
+	m.result = "You win!"
+	if m.match != nil {
+		m.result = m.viewMatchResult()
+	}
+	cmd = tea.Batch(cmd, m.notify(), m.setStatus(m.viewResult(), 0))
+	if m.options.solveTimes && !m.practice && m.match == nil {
+		cmd = tea.Batch(cmd, m.checkSolveTimes())
+	}
+	return cmd
 }
 
 // doLoss is called when the user has used up all their guesses.
 func (m *model) doLoss() tea.Cmd {
-	m.gameOver = true
-	m.updateScore()
-	msg := fmt.Sprintf("The word was %s. Better luck next time!", string(m.answer[:]))
-	return m.setStatus(msg, 0)
+	cmd := m.doGameOver()
+	m.result = fmt.Sprintf("The word was %s. Better luck next time!", m.game.Answer())
+	cmd = tea.Batch(cmd, m.notify(), m.setStatus(m.viewResult(), 0))
+	if m.options.streaks && !m.practice && m.match == nil {
+		cmd = tea.Batch(cmd, m.checkStreakFreeze())
+	}
+	return cmd
+}
+
+// checkStreakFreeze queues a check of whether a streak freeze was spent on the
+// game that was just lost, after it has been saved.
+func (m *model) checkStreakFreeze() tea.Cmd {
+	record := m.record
+	return m.writes.enqueue(func() tea.Msg {
+		ctx, cancel := context.WithTimeout(m.ctx, 5*time.Second)
+		defer cancel()
+
+		results, err := m.store.ListGameResults(ctx)
+		if err != nil {
+			slog.Error("error fetching game results", slog.Any("error", err))
+			return nil
+		}
+		s := computeStats(results)
+		if !s.lastFrozen {
+			return nil
+		}
+		return msgStreakFrozen{record: record, streak: s.CurrentStreak, freezes: s.Freezes}
+	})
+}
+
+// isWindowTooSmall checks if the window is smaller than the minimum size. The
+// size is checked on every frame, so the game is shown as soon as the window
+// grows.
+func (m *model) isWindowTooSmall() bool {
+	return m.windowWidth < m.options.minWidth || m.windowHeight < m.options.minHeight
+}
+
+// openScreen shows a screen, which returns to the current one once closed.
+func (m *model) openScreen(s screen) {
+	m.back = m.screen
+	m.screen = s
+}
+
+// closeScreen returns to the screen from which the current one was opened.
+func (m *model) closeScreen() {
+	m.screen = m.back
+	m.back = screenGame
+}
+
+// isGameOver checks if the current game has ended, including when an opponent
+// has solved it first.
+func (m *model) isGameOver() bool {
+	return m.game.State() != game.StateInProgress || m.matchView.lost
+}
+
+// doGameOver is called when the game has ended, whether by a win or a loss.
+func (m *model) doGameOver() tea.Cmd {
+	m.benchmark = m.viewBenchmark()
+	m.walkthrough = m.computeWalkthrough()
+	if m.practice {
+		m.summary = m.viewSummary()
+		return tea.Batch(m.appendHistory(), m.exportImage())
+	}
+	// Daily games aren't shared, since their board would spoil the puzzle.
+	m.shareID = ""
+	if m.options.shareable && m.daily == "" {
+		id, err := newShareID()
+		if err != nil {
+			slog.Error("error generating share ID", slog.Any("error", err))
+		}
+		m.shareID = id
+	}
+	m.summary = m.viewSummary()
+
+	// Count the game towards the total right away, rather than showing a stale
+	// total until the score is fetched again once the game is saved.
+	from := m.shownScore
+	m.score = max(0, m.score+m.earned()-m.lossPenalty())
+	cmds := []tea.Cmd{m.finishGame(), m.removeSnapshot(), m.appendHistory(), m.exportImage(), m.updateScore(), m.doCountUp(from), m.notifyWebhooks()}
+	if m.daily != "" {
+		cmds = append(cmds, m.updateLeaderboard())
+	}
+	return tea.Batch(cmds...)
 }
 
 // doRestart resets the game state and starts a new game.
-func (m *model) doRestart() {
-	// Start a new game.
-	m.gameID = 0
-	m.gameOver = false
+func (m *model) doRestart() tea.Cmd {
+	if m.lobby != nil {
+		return m.doJoinMatch()
+	}
+	m.leaveMatch()
+
+	// Choose the puzzle answer.
+	var answer string
+	if m.options.daily {
+		today := dailyDate(m.clock.Now(), m.options.dailyLocation)
+		m.daily = today.Format(time.DateOnly)
+		m.dailyNumber = dailyNumber(today, m.options.dailyEpoch)
+		if m.referee != nil {
+			return m.startRefereedGame(today)
+		}
+		answer = m.dictionary.GetDailyWord(today)
+	} else if m.options.puzzle != nil {
+		// A puzzle code only picks the answer of the first game.
+		m.daily = ""
+		m.dailyNumber = 0
+		answer = m.options.puzzle.answer
+		m.options.puzzle = nil
+	} else {
+		m.daily = ""
+		m.dailyNumber = 0
+		if m.referee != nil {
+			return m.startRefereedGame(time.Time{})
+		}
+		answer = m.dictionary.GetRandomCommonWord()
+	}
+	var word game.Word
+	copy(word[:], answer)
+	return m.startGame(word)
+}
+
+// startGame resets the game state and starts a new game with the given answer.
+func (m *model) startGame(answer game.Word) tea.Cmd {
+	m.game = game.New(answer, m.newGameDictionary())
+	m.game.SetUltraHard(m.options.ultraHard)
+	return m.resetGame()
+}
 
-	// Set the puzzle answer.
-	answer := m.dictionary.GetRandomCommonWord()
-	copy(m.answer[:], answer)
+// startRefereedGame resets the game state and has the referee start a new
+// game: the daily puzzle of the given date, or a random word if it is zero.
+// The referee picks the answer, which is only known to it until the game is
+// over.
+func (m *model) startRefereedGame(daily time.Time) tea.Cmd {
+	m.refereeRound = m.referee.start(m.refereeSession, daily, m.newGameDictionary(), m.options.ultraHard)
+	m.game = game.NewHidden()
+	return m.resetGame()
+}
+
+// newGameDictionary decides whether the next game is a practice game, and
+// returns the dictionary its guesses are checked against. Practice games are
+// decided for every game, so that they never carry over into a scored one.
+func (m *model) newGameDictionary() game.Dictionary {
+	m.practice = m.options.freePlay && m.daily == "" && m.match == nil && !m.options.ultraHard
+	if m.practice {
+		return nil
+	}
+	return m.dictionary
+}
+
+// resetGame resets the state of the game that was just started.
+func (m *model) resetGame() tea.Cmd {
+	m.judging = false
+	m.puzzleCode = m.encodePuzzleCode()
+	m.record = &gameRecord{}
+	m.lastSnapshot = ""
+	m.startedAt = m.clock.Now()
+	m.leaderboard = nil
+	m.solveTimes = nil
+	m.walkthrough = nil
+	m.penalty = 0
+	m.eliminating = false
+	m.cancelAutoSubmit()
+	m.cancelCountUp()
 
 	// Reset the grid.
-	m.gridCol = 0
+	m.gridLen, m.gridCol = 0, 0
 	m.gridRow = 0
 
 	// Clear the key state.
-	for k := range m.keyStates {
-		delete(m.keyStates, k)
+	m.keyStates = keyStates{}
+	m.freebie = -1
+	// Daily puzzles never give a letter away, including those started from
+	// the home screen.
+	if m.options.freebie && m.match == nil && m.daily == "" {
+		m.giveFreebie()
 	}
+	m.updateAssistKeys()
 
 	// Reset the status message.
-	m.updateScore()
 	m.resetStatus()
+	return m.updateScore()
+}
+
+// updateScore queues a fetch of the current total score from the database,
+// after any pending writes.
+func (m *model) updateScore() tea.Cmd {
+	return m.writes.enqueue(func() tea.Msg {
+		ctx, cancel := context.WithTimeout(m.ctx, 5*time.Second)
+		defer cancel()
+
+		score, err := m.store.GetTotalScore(ctx)
+		if err != nil {
+			slog.Error("error fetching score", slog.Any("error", err))
+			return nil
+		}
+		return msgScore{score: int(score.Float64)}
+	})
 }
 
-// updateScore fetches the current total score from the database.
-func (m *model) updateScore() {
+// updateLeaderboard queues a fetch of the leaderboard for the daily puzzle,
+// after any pending writes. The leaderboard is only fetched once the player
+// has finished the puzzle, so that it can't be used to spoil the answer.
+func (m *model) updateLeaderboard() tea.Cmd {
+	record := m.record
+	player := m.player
+	params := store.GetDailyLeaderboardParams{
+		Daily: sql.NullString{String: m.daily, Valid: true},
+		Limit: _leaderboardSize,
+	}
+	return m.writes.enqueue(func() tea.Msg {
+		ctx, cancel := context.WithTimeout(m.ctx, 5*time.Second)
+		defer cancel()
+
+		finished, err := m.hasFinishedDaily(ctx, record, player, params.Daily)
+		if err != nil {
+			slog.Error("error checking daily completion", slog.Any("error", err))
+			return nil
+		}
+		if !finished {
+			return nil
+		}
+
+		leaderboard, err := m.store.GetDailyLeaderboard(ctx, params)
+		if err != nil {
+			slog.Error("error fetching leaderboard", slog.Any("error", err))
+			return nil
+		}
+		return msgLeaderboard{record: record, leaderboard: leaderboard}
+	})
+}
+
+// doShowStartupStats briefly shows a one-line summary of lifetime stats in the
+// status. Nothing is shown to players who haven't completed a game yet.
+func (m *model) doShowStartupStats() tea.Cmd {
 	ctx, cancel := context.WithTimeout(m.ctx, 5*time.Second)
 	defer cancel()
 
-	score, err := m.store.GetTotalScore(ctx)
+	results, err := m.store.ListGameResults(ctx)
+	if err != nil {
+		slog.Error("error fetching game results", slog.Any("error", err))
+		return nil
+	}
+	s := computeStats(results)
+	if s.Played == 0 {
+		return nil
+	}
+	msg := fmt.Sprintf("%d played · %.0f%% win · streak %d", s.Played, 100*s.WinRate, s.CurrentStreak)
+	if playtime := m.totalPlaytime(); playtime >= time.Hour {
+		msg += fmt.Sprintf(" · %s played", formatPlaytime(playtime))
+	}
+	return m.setStatus(msg, 2*time.Second)
+}
+
+// hasFinishedDaily checks whether the player has finished the daily puzzle.
+// Players without a known identity are checked against their own game.
+func (m *model) hasFinishedDaily(ctx context.Context, record *gameRecord, player string, daily sql.NullString) (bool, error) {
+	if player != "" {
+		params := store.HasFinishedDailyParams{
+			Daily:  daily,
+			Player: sql.NullString{String: player, Valid: true},
+		}
+		return m.store.HasFinishedDaily(ctx, params)
+	}
+	if record.id == 0 {
+		return false, nil
+	}
+	game, err := m.store.GetGame(ctx, record.id)
 	if err != nil {
-		slog.Error("error fetching score", slog.Any("error", err))
-		return
+		return false, err
 	}
-	m.score = int(score.Float64)
+	return game.FinishedAt.Valid && game.Daily == daily, nil
 }
 
 // setStatus sets the status message, and returns a tea.Cmd that restores the
@@ -298,162 +1439,539 @@ func (m *model) setStatus(msg string, duration time.Duration) tea.Cmd {
 	m.status = msg
 	if duration > 0 {
 		m.statusPending++
-		return tea.Tick(duration, func(time.Time) tea.Msg {
+		after := m.clock.After(duration)
+		return func() tea.Msg {
+			<-after
 			return msgResetStatus{}
-		})
+		}
 	}
 	return nil
 }
 
 // resetStatus immediately resets the status message to its default value.
 func (m *model) resetStatus() {
-	m.status = fmt.Sprintf("Score: %d", m.score)
+	m.status = m.defaultStatus()
+}
+
+// defaultStatus returns the status message shown when nothing else is.
+func (m *model) defaultStatus() string {
+	if m.practice {
+		return "Practice (unscored)"
+	}
+	if m.daily != "" {
+		return fmt.Sprintf("Daily #%d · Score: %d", m.dailyNumber, m.shownScore)
+	}
+	return fmt.Sprintf("Score: %d", m.shownScore)
 }
 
-// viewStatus renders the status line.
+// viewStatus renders the status line, truncating it if it is too long. When
+// only common words are accepted, or a letter was given away, this is
+// indicated by a badge.
 func (m *model) viewStatus() string {
-	return lipgloss.NewStyle().Foreground(_colorPrimary).Render(m.status)
+	if m.chatting {
+		return m.viewChatInput()
+	}
+	var badges []string
+	if m.options.maxRarity <= _rarityCommon {
+		badges = append(badges, m.styles.badge)
+	}
+	if freebie := m.viewFreebie(); freebie != "" {
+		badges = append(badges, m.styles.highlight.Render("[given: "+freebie+"]"))
+	}
+	style := m.styles.status
+	if m.flashing {
+		style = m.styles.flash
+	}
+	if len(badges) == 0 {
+		return style.Render(truncate(m.status, m.windowWidth))
+	}
+	badge := strings.Join(badges, " ")
+	status := truncate(m.status, m.windowWidth-lipgloss.Width(badge)-1)
+	return style.Render(status) + " " + badge
 }
 
-// viewGrid renders the grid.
+// truncate shortens s to fit in the given width, ending it with "..." if it
+// had to be shortened. It is measured in cells rather than bytes, and never
+// splits a rune.
+func truncate(s string, width int) string {
+	if width <= 3 || lipgloss.Width(s) <= width {
+		return s
+	}
+	var sb strings.Builder
+	used := 0
+	for _, r := range s {
+		w := lipgloss.Width(string(r))
+		if used+w > width-3 {
+			break
+		}
+		sb.WriteRune(r)
+		used += w
+	}
+	return sb.String() + "..."
+}
+
+// viewGrid renders the grid, in the widest layout that fits the window.
 func (m *model) viewGrid() string {
+	layout := m.gridLayout()
 	var rows [_numGuesses]string
 	for i := 0; i < _numGuesses; i++ {
 		if i < m.gridRow {
-			rows[i] = m.viewGridRowFilled(m.grid[i])
-		} else if i == m.gridRow && !m.gameOver {
-			rows[i] = m.viewGridRowCurrent(m.grid[i], m.gridCol)
+			rows[i] = m.viewGridRowFilled(m.grid[i], m.game.Feedbacks()[i], layout)
+		} else if i == m.gridRow && !m.isGameOver() {
+			rows[i] = m.viewGridRowCurrent(m.grid[i], m.gridLen, m.gridCol, layout)
 		} else {
-			rows[i] = m.viewGridRowEmpty()
+			rows[i] = m.viewGridRowEmpty(layout)
 		}
 	}
-	return lipgloss.JoinVertical(lipgloss.Left, rows[:]...)
-}
-
-// viewGridRowFilled renders a filled-in grid row. It chooses the appropriate
-// color for each key.
-func (m *model) viewGridRowFilled(word [_numChars]byte) string {
-	var keyStates [_numChars]keyState
-	letters := m.answer
-
-	// Mark keyStatusAbsent.
-	for i := 0; i < _numChars; i++ {
-		keyStates[i] = _keyStateAbsent
-	}
-
-	// Mark keyStatusCorrect.
-	for i := 0; i < _numChars; i++ {
-		if word[i] == m.answer[i] {
-			keyStates[i] = _keyStateCorrect
-			letters[i] = 0
+	if m.options.tileGap > 0 && layout == _gridLayoutTiles {
+		for i := 0; i < _numGuesses-1; i++ {
+			rows[i] = m.styles.rowGap.Render(rows[i])
 		}
 	}
+	return lipgloss.JoinVertical(lipgloss.Left, rows[:]...)
+}
 
-	// Mark keyStatusPresent.
-	for i := 0; i < _numChars; i++ {
-		if keyStates[i] == _keyStateCorrect {
-			continue
-		}
-		if foundIdx := bytes.IndexByte(letters[:], word[i]); foundIdx != -1 {
-			keyStates[i] = _keyStatePresent
-			letters[foundIdx] = 0
+// joinTiles joins the tiles of a grid row, separated by the tile gap, or by
+// spaces in the letters layout.
+func (m *model) joinTiles(tiles []string, layout gridLayout) string {
+	switch {
+	case layout == _gridLayoutLetters:
+		return strings.Join(tiles, " ")
+	case layout == _gridLayoutTiles && m.options.tileGap > 0:
+		for i := 0; i < len(tiles)-1; i++ {
+			tiles[i] = m.styles.tileGap.Render(tiles[i])
 		}
 	}
+	return lipgloss.JoinHorizontal(lipgloss.Bottom, tiles...)
+}
 
-	// Render keys.
+// viewGridRowFilled renders a filled-in grid row. It chooses the appropriate
+// color for each key.
+func (m *model) viewGridRowFilled(word game.Word, feedback game.Feedback, layout gridLayout) string {
 	var keys [_numChars]string
 	for i := 0; i < _numChars; i++ {
-		keys[i] = m.viewKey(string(word[i]), keyStates[i].color())
+		keys[i] = m.viewTile(string(word[i]), keyState(feedback[i]), layout, false)
 	}
-	return lipgloss.JoinHorizontal(lipgloss.Bottom, keys[:]...)
+	return m.joinTiles(keys[:], layout)
 }
 
-// viewGridRowCurrent renders the current grid row. It renders an "_" character
-// for the letter being currently input.
-func (m *model) viewGridRowCurrent(row [_numChars]byte, rowIdx int) string {
+// viewGridRowCurrent renders the current grid row, of which length letters
+// are filled in. It renders an "_" character for the letter being currently
+// input, underlines the letter under the cursor if it was moved back, renders
+// letters that are already known to be absent in red, and locked letters in
+// green.
+func (m *model) viewGridRowCurrent(row game.Word, length, cursor int, layout gridLayout) string {
+	locked := m.lockedLetters()
 	var keys [_numChars]string
 	for i := 0; i < _numChars; i++ {
 		var key string
-		if i < rowIdx {
+		state := _keyStateUnselected
+		if locked[i] != 0 {
+			key = string(locked[i])
+			state = _keyStateCorrect
+		} else if i < length {
 			key = string(row[i])
-		} else if i == rowIdx {
+			if m.keyStates.get(row[i]) == _keyStateAbsent {
+				state = _keyStateWarning
+			}
+		} else if i == cursor {
 			key = "_"
 		} else {
 			key = " "
 		}
-		keys[i] = m.viewKey(key, _colorPrimary)
+		keys[i] = m.viewTile(key, state, layout, i == cursor && i < length)
 	}
-	return lipgloss.JoinHorizontal(lipgloss.Bottom, keys[:]...)
+	return m.joinTiles(keys[:], layout)
 }
 
 // viewGridRowEmpty renders an empty grid row. If the grid is locked, the keys
 // are grayed out.
-func (m *model) viewGridRowEmpty() string {
+func (m *model) viewGridRowEmpty(layout gridLayout) string {
 	keyState := _keyStateUnselected
-	if m.gameOver {
+	if m.isGameOver() {
 		keyState = _keyStateAbsent
 	}
-	key := m.viewKey(" ", keyState.color())
+	key := m.viewTile(" ", keyState, layout, false)
 	keys := [_numChars]string{key, key, key, key, key}
-	return lipgloss.JoinHorizontal(lipgloss.Bottom, keys[:]...)
+	return m.joinTiles(keys[:], layout)
 }
 
-// viewKeyboard renders the entire keyboard, including a border. It chooses the
-// appropriate color for keys that have been guessed before.
+// viewKeyboard renders the entire keyboard, including a border unless it is
+// compact. It chooses the appropriate color for keys that have been guessed
+// before.
 func (m *model) viewKeyboard() string {
 	topRow := m.viewKeyboardRow([]string{"Q", "W", "E", "R", "T", "Y", "U", "I", "O", "P"})
 	midRow := m.viewKeyboardRow([]string{"A", "S", "D", "F", "G", "H", "J", "K", "L"})
 	botRow := m.viewKeyboardRow([]string{"ENTER", "Z", "X", "C", "V", "B", "N", "M", "DELETE"})
+
+	// The compact keyboard has no frame, and its rows are simply centered.
+	if m.options.keyboard == "compact" {
+		return lipgloss.JoinVertical(lipgloss.Center, topRow, midRow, botRow)
+	}
+
 	keys := lipgloss.JoinVertical(
 		lipgloss.Left,
-		lipgloss.NewStyle().Padding(0, 2).Render(topRow),
-		lipgloss.NewStyle().Padding(0, 4).Render(midRow),
+		m.styles.padTop.Render(topRow),
+		m.styles.padMid.Render(midRow),
 		botRow,
 	)
-	return lipgloss.NewStyle().
-		Border(lipgloss.NormalBorder()).
-		BorderForeground(_keyStateUnselected.color()).
-		Padding(0, 1).
-		Render(keys)
+	return m.styles.box.Render(keys)
+}
+
+// viewKeyboardLetters renders the keyboard as rows of letters, without any
+// borders, so that it fits on narrow terminals. Enter and delete are left out,
+// since they take up too much room.
+func (m *model) viewKeyboardLetters() string {
+	rows := []string{"QWERTYUIOP", "ASDFGHJKL", "ZXCVBNM"}
+	for i, row := range rows {
+		letters := make([]string, len(row))
+		for j := range row {
+			letter := row[j : j+1]
+			if m.options.lowercase {
+				letter = strings.ToLower(letter)
+			}
+			letters[j] = m.styles.letters[m.keyStateOf(row[j])].Render(letter)
+		}
+		rows[i] = strings.Join(letters, " ")
+	}
+	return lipgloss.JoinVertical(lipgloss.Center, rows...)
 }
 
 // viewKeyboardRow renders a single row of the keyboard. It chooses the
 // appropriate color for keys that have been guessed before.
 func (m *model) viewKeyboardRow(keys []string) string {
-	keysRendered := make([]string, len(keys))
+	keysRendered := make([]string, 0, len(keys))
 	for _, key := range keys {
 		status := _keyStateUnselected
 		if len(key) == 1 {
-			key := key[0]
-			status = m.keyStates[key]
+			status = m.keyStateOf(key[0])
 		}
-		keysRendered = append(keysRendered, m.viewKey(key, status.color()))
+		keysRendered = append(keysRendered, m.viewKey(key, status))
 	}
 	return lipgloss.JoinHorizontal(lipgloss.Bottom, keysRendered...)
 }
 
-// viewKey renders a key with the given name and color.
-func (*model) viewKey(key string, color lipgloss.TerminalColor) string {
-	return lipgloss.NewStyle().
-		Padding(0, 1).
-		Border(lipgloss.NormalBorder()).
-		BorderForeground(color).
-		Foreground(color).
-		Render(key)
+// viewLeaderboard renders the leaderboard for the daily puzzle, if it has
+// been fetched, and when the next puzzle starts, including a border.
+func (m *model) viewLeaderboard() string {
+	rows := make([]string, 0, len(m.leaderboard)+3)
+	if len(m.leaderboard) > 0 {
+		rows = append(rows, m.styles.text.Render("Today's leaderboard"))
+		rows = append(rows, m.viewLeaderboardEntries(m.leaderboard)...)
+		rows = append(rows, "")
+	}
+	rows = append(rows, m.styles.text.Render(m.viewNextDaily()))
+	return m.styles.box.Render(lipgloss.JoinVertical(lipgloss.Left, rows...))
+}
+
+// viewLeaderboardEntries renders one row for every entry of a leaderboard.
+func (m *model) viewLeaderboardEntries(leaderboard []store.GetDailyLeaderboardRow) []string {
+	rows := make([]string, len(leaderboard))
+	for idx, entry := range leaderboard {
+		name := leaderboardName(entry.PlayerName.String, entry.Player.String, 16)
+		duration := entry.FinishedAt.Time.Sub(entry.StartedAt.Time).Round(time.Second)
+		row := fmt.Sprintf("%2d. %-16.16s %d/%d %8s", idx+1, name, entry.NumGuesses, _numGuesses, duration)
+		rows[idx] = m.styles.subtext.Render(row)
+	}
+	return rows
+}
+
+// viewNextDaily describes when the next daily puzzle starts, at the time of day
+// in the player's time zone.
+func (m *model) viewNextDaily() string {
+	now := m.clock.Now()
+	next := nextDailyStart(now, m.options.dailyLocation)
+	return fmt.Sprintf("Next puzzle in %s, at %s", formatDuration(next.Sub(now)), next.In(m.location).Format("15:04 MST"))
+}
+
+// viewReveal returns the answer of an unfinished practice game, to be printed
+// after exiting if revealOnQuit is set. Scored games are never revealed, and
+// neither are games without any guesses.
+func (m *model) viewReveal() string {
+	if !m.options.revealOnQuit || !m.practice || m.isGameOver() || m.gridRow == 0 {
+		return ""
+	}
+	return fmt.Sprintf("The word was %s.\n", m.game.Answer())
+}
+
+// earned returns the number of points earned for the current game, after
+// deducting the penalty for assists. This must be kept in sync with the
+// GetTotalScore query.
+func (m *model) earned() int {
+	return max(0, m.game.Score()-m.penalty)
+}
+
+// lossPenalty returns the number of points deducted from the total score for
+// the current game, which is only the case if it was lost. The total never
+// drops below zero, as in the GetTotalScore query.
+func (m *model) lossPenalty() int {
+	if m.practice || m.game.State() != game.StateLost {
+		return 0
+	}
+	return m.options.lossPenalty
+}
+
+// viewPoints renders the points the current game added to or deducted from
+// the total score.
+func (m *model) viewPoints() string {
+	if penalty := m.lossPenalty(); penalty > 0 {
+		return fmt.Sprintf("-%d", penalty)
+	}
+	return fmt.Sprintf("+%d", m.earned())
+}
+
+// viewResult renders the status shown once the game is over: how it ended,
+// the points it earned, and the new total.
+func (m *model) viewResult() string {
+	if m.practice {
+		return m.result + m.benchmark
+	}
+	return fmt.Sprintf("%s %s (total %s)%s", m.result, m.viewPoints(), formatThousands(m.shownScore), m.benchmark)
+}
+
+// formatThousands formats a number with commas between groups of thousands.
+func formatThousands(n int) string {
+	if n < 0 {
+		return "-" + formatThousands(-n)
+	}
+	s := strconv.Itoa(n)
+	for i := len(s) - 3; i > 0; i -= 3 {
+		s = s[:i] + "," + s[i:]
+	}
+	return s
+}
+
+// viewBenchmark compares the game with an entropy-based solver that started
+// with the same first guess. It is empty unless the benchmark is enabled.
+func (m *model) viewBenchmark() string {
+	if !m.options.benchmark || m.gridRow == 0 {
+		return ""
+	}
+
+	candidates := wordsToBytes(m.dictionary.commonWords)
+	rng := rand.New(rand.NewSource(0))
+	numGuesses, won := solve(candidates, strategyEntropy, m.game.Guesses()[0], m.game.Answer(), rng)
+
+	optimal := "X"
+	if won {
+		optimal = fmt.Sprint(numGuesses)
+	}
+	you := "X"
+	if m.game.State() == game.StateWon {
+		you = fmt.Sprint(len(m.game.Guesses()))
+	}
+	return fmt.Sprintf(" Optimal: %s, You: %s", optimal, you)
+}
+
+// viewSummary renders a spoiler-free summary of the current game, suitable
+// for sharing.
+func (m *model) viewSummary() string {
+	numGuesses := "X"
+	if m.game.State() == game.StateWon {
+		numGuesses = fmt.Sprint(len(m.game.Guesses()))
+	}
+
+	var sb strings.Builder
+	sb.WriteString("clidle ")
+	if m.daily != "" {
+		fmt.Fprintf(&sb, "daily #%d ", m.dailyNumber)
+	}
+	if m.practice {
+		fmt.Fprintf(&sb, "practice %s/%d\n", numGuesses, _numGuesses)
+	} else {
+		fmt.Fprintf(&sb, "%s/%d %s\n", numGuesses, _numGuesses, m.viewPoints())
+	}
+	for _, feedback := range m.game.Feedbacks() {
+		for _, letterState := range feedback {
+			sb.WriteString(keyState(letterState).emoji())
+		}
+		sb.WriteString("\n")
+	}
+	if m.shareID != "" {
+		fmt.Fprintf(&sb, "Board ID: %s (view it with ssh <host> -t -- view %s)\n", m.shareID, m.shareID)
+	}
+	return sb.String()
+}
+
+// viewViolation describes why a guess was rejected in ultra-hard mode.
+func viewViolation(v *game.Violation) string {
+	switch v.Kind {
+	case game.ViolationAbsent:
+		return fmt.Sprintf("%c is not in the word, so it can't be used.", v.Letter)
+	case game.ViolationPosition:
+		return fmt.Sprintf("%c was already yellow in position %d.", v.Letter, v.Position+1)
+	case game.ViolationCount:
+		return fmt.Sprintf("The word has at most %d %c.", v.Count, v.Letter)
+	case game.ViolationCorrect:
+		return fmt.Sprintf("%c must stay green in position %d.", v.Letter, v.Position+1)
+	default:
+		return "That guess isn't allowed in ultra-hard mode."
+	}
+}
+
+// viewControls renders the list of controls shown at the bottom, leaving out
+// the least important ones if they don't fit.
+func (m *model) viewControls() string {
+	return m.styles.renderControls(m.controls(), m.windowWidth)
+}
+
+// controls returns the controls that apply to what is currently shown, most
+// important first.
+func (m *model) controls() []control {
+	switch {
+	case m.isWaitingForOpponent() && m.inviteCode != "":
+		return []control{{"esc", "cancel"}, {"ctrl+c", "quit"}}
+	case m.isWaitingForOpponent():
+		return []control{{"ctrl+c", "quit"}}
+	case m.screen == screenHome:
+		return []control{{"↑/↓", "select"}, {"enter", "choose"}, {"esc", "back"}, {"ctrl+c", "quit"}, {"ctrl+o", "settings"}}
+	case m.screen == screenDuel:
+		return []control{{"enter", "join"}, {"ctrl+n", "invite"}, {"esc", "close"}, {"ctrl+c", "quit"}}
+	case m.chatting:
+		return []control{{"enter", "send"}, {"esc", "cancel"}, {"ctrl+c", "quit"}}
+	case m.screen == screenScoring, m.screen == screenWeekly:
+		return []control{{"any key", "close"}, {"ctrl+c", "quit"}}
+	case m.screen == screenSettings:
+		controls := []control{{"↑/↓", "select"}, {"←/→", "change"}, {"esc", "close"}, {"ctrl+c", "quit"}}
+		if m.canClaim() {
+			controls = append(controls, control{"c", "claim anonymous games"})
+		}
+		return controls
+	case m.eliminating:
+		return []control{{"enter", "ask"}, {"esc", "cancel"}, {"ctrl+c", "quit"}}
+	case m.isGameOver():
+		controls := []control{{"enter", "new game"}, {"ctrl+c", "quit"}, {"ctrl+o", "settings"}}
+		if m.options.home {
+			controls = append(controls, control{"ctrl+l", "home"})
+		}
+		return controls
+	}
+	controls := []control{{"ctrl+c", "quit"}, {"ctrl+r", "restart"}, {"ctrl+u", "clear"}}
+	if m.options.assistEliminate {
+		controls = append(controls, control{"ctrl+e", "eliminate"})
+	}
+	controls = append(controls, control{"?", "scoring"}, control{"ctrl+o", "settings"})
+	if m.options.weeklyRecap {
+		controls = append(controls, control{"ctrl+w", "week"})
+	}
+	if m.invites != nil && m.match == nil {
+		controls = append(controls, control{"ctrl+t", "duel"})
+	}
+	if m.match != nil {
+		controls = append(controls, control{"ctrl+t", "chat"})
+	}
+	if m.options.home {
+		controls = append(controls, control{"ctrl+l", "home"})
+	}
+	return controls
+}
+
+// viewKey renders a key with the given name and state, in the configured
+// letter case.
+func (m *model) viewKey(key string, state keyState) string {
+	if m.options.lowercase {
+		key = strings.ToLower(key)
+	}
+	return m.styles.renderKey(key, state)
+}
+
+// control is a key binding shown at the bottom.
+type control struct {
+	key    string
+	action string
 }
 
 // msgResetStatus is sent when the status line should be reset.
 type msgResetStatus struct{}
 
-const (
-	_colorPrimary   = lipgloss.Color("#d7dadc")
-	_colorSecondary = lipgloss.Color("#626262")
-	_colorSeparator = lipgloss.Color("#9c9c9c")
-	_colorYellow    = lipgloss.Color("#b59f3b")
-	_colorGreen     = lipgloss.Color("#538d4e")
+// msgAutoSubmit is sent when a full row should be submitted.
+type msgAutoSubmit struct {
+	seq int
+}
+
+// msgSaved is sent when a queued write has finished.
+type msgSaved struct {
+	err error
+}
+
+// msgScore is sent when the total score has been fetched.
+type msgScore struct {
+	score int
+}
+
+// msgLeaderboard is sent when the leaderboard of a game has been fetched.
+type msgLeaderboard struct {
+	record      *gameRecord
+	leaderboard []store.GetDailyLeaderboardRow
+}
+
+// msgStreakFrozen is sent when a streak freeze was spent on a lost game.
+type msgStreakFrozen struct {
+	record  *gameRecord
+	streak  int
+	freezes int
+}
+
+// gameRecord identifies a game in the database. It is only accessed by queued
+// writes, since the game is created lazily on the first guess.
+type gameRecord struct {
+	id int64
+}
+
+// Colors are given with explicit 256-color and 16-color fallbacks, rather than
+// leaving it to the terminal library to approximate them.
+var (
+	_colorPrimary   = lipgloss.CompleteColor{TrueColor: "#d7dadc", ANSI256: "253", ANSI: "15"}
+	_colorSecondary = lipgloss.CompleteColor{TrueColor: "#626262", ANSI256: "241", ANSI: "8"}
+	_colorSeparator = lipgloss.CompleteColor{TrueColor: "#9c9c9c", ANSI256: "247", ANSI: "7"}
+	_colorYellow    = lipgloss.CompleteColor{TrueColor: "#b59f3b", ANSI256: "143", ANSI: "3"}
+	_colorGreen     = lipgloss.CompleteColor{TrueColor: "#538d4e", ANSI256: "65", ANSI: "2"}
+	_colorDarkGreen = lipgloss.CompleteColor{TrueColor: "#2f4f2c", ANSI256: "22", ANSI: "2"}
+	_colorRed       = lipgloss.CompleteColor{TrueColor: "#c9504d", ANSI256: "167", ANSI: "1"}
+	_colorBlue      = lipgloss.CompleteColor{TrueColor: "#5b7fa6", ANSI256: "67", ANSI: "4"}
+	// _colorTileText is the color of letters on a colored background.
+	_colorTileText = lipgloss.CompleteColor{TrueColor: "#121213", ANSI256: "233", ANSI: "0"}
 )
 
-// keyState represents the state of a key.
+// _keyboardModes contains the values accepted by --keyboard.
+var _keyboardModes = map[string]struct{}{
+	"auto":    {},
+	"compact": {},
+	"full":    {},
+	"off":     {},
+}
+
+// _borders contains the border styles that can be chosen with --border.
+var _borders = map[string]lipgloss.Border{
+	"normal":  lipgloss.NormalBorder(),
+	"rounded": lipgloss.RoundedBorder(),
+	"thick":   lipgloss.ThickBorder(),
+	"double":  lipgloss.DoubleBorder(),
+}
+
+// keyStates holds the state of each letter key, indexed by ch - 'A'.
+type keyStates [26]keyState
+
+// get returns the state of a key. Keys that aren't uppercase letters are
+// always unselected.
+func (k *keyStates) get(ch byte) keyState {
+	if !isAsciiUpper(rune(ch)) {
+		return _keyStateUnselected
+	}
+	return k[ch-'A']
+}
+
+// set changes the state of a key. Keys that aren't uppercase letters are
+// ignored.
+func (k *keyStates) set(ch byte, state keyState) {
+	if isAsciiUpper(rune(ch)) {
+		k[ch-'A'] = state
+	}
+}
+
+// keyState represents the state of a key. The states up to _keyStateCorrect
+// match the corresponding game.LetterState values, so feedback can be converted
+// to a keyState directly.
 type keyState int
 
 const (
@@ -461,10 +1979,31 @@ const (
 	_keyStateAbsent
 	_keyStatePresent
 	_keyStateCorrect
+	// _keyStateExhausted is used in expert keyboard mode for letters whose
+	// every copy in the answer has been located.
+	_keyStateExhausted
+	// _keyStateWarning is used for letters in the current row that are
+	// already known to be absent. It is never stored in keyStates.
+	_keyStateWarning
+	// _keyStateSuggested is used for untried letters highlighted by the key
+	// assist. It is never stored in keyStates.
+	_keyStateSuggested
 )
 
+// emoji returns the emoji used for the key state in shared results.
+func (s keyState) emoji() string {
+	switch s {
+	case _keyStatePresent:
+		return "🟨"
+	case _keyStateCorrect, _keyStateExhausted:
+		return "🟩"
+	default:
+		return "⬛"
+	}
+}
+
 // color returns the appropriate dark mode color for the given key state.
-func (s keyState) color() lipgloss.Color {
+func (s keyState) color() lipgloss.CompleteColor {
 	switch s {
 	case _keyStateUnselected:
 		return _colorPrimary
@@ -474,19 +2013,17 @@ func (s keyState) color() lipgloss.Color {
 		return _colorYellow
 	case _keyStateCorrect:
 		return _colorGreen
+	case _keyStateExhausted:
+		return _colorDarkGreen
+	case _keyStateWarning:
+		return _colorRed
+	case _keyStateSuggested:
+		return _colorBlue
 	default:
 		panic("invalid key status")
 	}
 }
 
-var _controls = fmt.Sprintf("%s %s %s %s %s",
-	lipgloss.NewStyle().Foreground(_colorPrimary).Render("ctrl+c"),
-	lipgloss.NewStyle().Foreground(_colorSecondary).Render("quit"),
-	lipgloss.NewStyle().Foreground(_colorSeparator).Render("//"),
-	lipgloss.NewStyle().Foreground(_colorPrimary).Render("ctrl+r"),
-	lipgloss.NewStyle().Foreground(_colorSecondary).Render("restart"),
-)
-
 // isAsciiUpper checks if a rune is between A-Z.
 func isAsciiUpper(r rune) bool {
 	return 'A' <= r && r <= 'Z'
@@ -0,0 +1,1631 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/ajeetdsouza/clidle/pkg/game"
+	"github.com/ajeetdsouza/clidle/store"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+	"github.com/pkg/errors"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+var update = flag.Bool("update", false, "Updates the golden files")
+
+// testDictionary is a small, deterministic dictionary for tests.
+var testDictionary = Dictionary{
+	commonWords: []string{"CRANE", "TRACE"},
+	allWords: map[string]struct{}{
+		"CRANE": {}, "TRACE": {}, "SLATE": {}, "MOULD": {}, "EERIE": {},
+	},
+}
+
+// newTestModel creates a model backed by an in-memory database, with a pinned
+// color profile and the given answer.
+func newTestModel(tb testing.TB, answer string, width, height int) *model {
+	tb.Helper()
+	return newTestModelContext(tb, context.Background(), answer, width, height)
+}
+
+// newTestModelContext is like newTestModel, but creates the model with the
+// given context, as if it belonged to a session.
+func newTestModelContext(tb testing.TB, ctx context.Context, answer string, width, height int) *model {
+	tb.Helper()
+
+	db, err := sql.Open("sqlite", "file::memory:?_time_format=sqlite")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { db.Close() })
+	db.SetMaxOpenConns(1)
+	if err := migrate(db); err != nil {
+		tb.Fatal(err)
+	}
+	return newTestModelStore(tb, ctx, store.New(db), answer, width, height)
+}
+
+// newTestModelStore is like newTestModelContext, but creates the model with
+// the given store, e.g. to share it with another model.
+func newTestModelStore(tb testing.TB, ctx context.Context, queries *store.Queries, answer string, width, height int) *model {
+	tb.Helper()
+
+	renderer := lipgloss.NewRenderer(io.Discard)
+	renderer.SetColorProfile(termenv.ANSI256)
+
+	// Animations are skipped, so that views don't depend on timing.
+	options := options{border: lipgloss.NormalBorder(), dailyLocation: time.UTC, maxRarity: _rarityUncommon, reducedMotion: true}
+	m := newModel(ctx, queries, testDictionary, options, renderer)
+	tb.Cleanup(m.writes.flush)
+	m.Init()
+	m.Update(tea.WindowSizeMsg{Width: width, Height: height})
+
+	word, err := game.ParseWord(answer)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	m.startGame(word)
+	return m
+}
+
+// typeKeys sends each character of s to the model as a key press. A newline
+// is sent as the enter key.
+func typeKeys(m *model, s string) {
+	for _, r := range s {
+		if r == '\n' {
+			m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+		} else {
+			m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}})
+		}
+	}
+}
+
+// normalizeANSI makes escape sequences visible, so that golden files can be
+// read and diffed as text.
+func normalizeANSI(s string) string {
+	return strings.ReplaceAll(s, "\x1b", `\e`)
+}
+
+// assertGolden compares the output with the golden file of the given name,
+// or updates the golden file if -update is set.
+func assertGolden(t *testing.T, name, got string) {
+	t.Helper()
+
+	path := filepath.Join("testdata", name+".golden")
+	got = normalizeANSI(got)
+	if *update {
+		if err := os.WriteFile(path, []byte(got), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != string(want) {
+		t.Errorf("output does not match %s (run with -update to regenerate)\ngot:\n%s\nwant:\n%s", path, got, want)
+	}
+}
+
+func TestViewGolden(t *testing.T) {
+	tests := []struct {
+		name   string
+		width  int
+		height int
+		input  string
+	}{
+		{name: "fresh", width: 80, height: 40},
+		{name: "mid_game", width: 80, height: 40, input: "crane\nslate\nee"},
+		{name: "invalid_word", width: 80, height: 40, input: "crane\nabcde\n"},
+		{name: "won", width: 80, height: 40, input: "crane\ntrace\n"},
+		{name: "lost", width: 80, height: 40, input: "crane\ncrane\ncrane\ncrane\ncrane\ncrane\n"},
+		{name: "tiny_window", width: 30, height: 20, input: "crane\n"},
+		{name: "portrait", width: 30, height: 26, input: "crane\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestModel(t, "TRACE", tt.width, tt.height)
+			typeKeys(m, tt.input)
+			assertGolden(t, tt.name, m.View())
+		})
+	}
+}
+
+func TestViewStatusTruncated(t *testing.T) {
+	m := newTestModel(t, "TRACE", 12, 40)
+	m.status = "Déjà éliminé : ce mot n'est pas accepté. 😕"
+	status := m.viewStatus()
+	if !utf8.ValidString(status) {
+		t.Fatalf("status is not valid UTF-8: %q", status)
+	}
+	if w := lipgloss.Width(status); w > 12 {
+		t.Errorf("status is %d cells wide, want at most 12: %q", w, status)
+	}
+
+	for width := 4; width < 20; width++ {
+		got := truncate("éèêëàâäôöûüç 😕 ñ", width)
+		if !utf8.ValidString(got) || lipgloss.Width(got) > width {
+			t.Errorf("truncate(..., %d) = %q", width, got)
+		}
+	}
+}
+
+func TestCommonWordsOnly(t *testing.T) {
+	m := newTestModel(t, "TRACE", 80, 40)
+	m.options.maxRarity = _rarityCommon
+	if status := m.viewStatus(); !strings.Contains(status, "[common]") {
+		t.Errorf("status is missing the badge: %q", status)
+	}
+
+	typeKeys(m, "crane\n")
+	m.writes.flush()
+	game, err := m.store.GetGame(context.Background(), m.record.id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if game.MaxRarity != _rarityCommon {
+		t.Errorf("game was saved with max rarity %d, want %d", game.MaxRarity, _rarityCommon)
+	}
+}
+
+func TestFreePlay(t *testing.T) {
+	m := newTestModel(t, "TRACE", 80, 40)
+	m.options.freePlay = true
+	m.startGame(m.game.Answer())
+
+	typeKeys(m, "qqqqq\ntrace\n")
+	if n := len(m.game.Guesses()); n != 2 {
+		t.Fatalf("expected both guesses to be accepted, got %d", n)
+	}
+	if !strings.HasPrefix(m.summary, "clidle practice 2/6\n") {
+		t.Errorf("unexpected summary: %q", m.summary)
+	}
+	m.writes.flush()
+	if m.record.id != 0 {
+		t.Errorf("practice game was saved with id %d", m.record.id)
+	}
+
+	// Practice is decided per game, so it doesn't outlive the setting.
+	m.options.freePlay = false
+	m.startGame(m.game.Answer())
+	typeKeys(m, "qqqqq\n")
+	if n := len(m.game.Guesses()); n != 0 {
+		t.Errorf("invalid word was accepted after leaving free play")
+	}
+}
+
+func TestSharedGame(t *testing.T) {
+	m := newTestModel(t, "TRACE", 80, 40)
+	m.options.shareable = true
+	typeKeys(m, "crane\ntrace\n")
+	m.writes.flush()
+
+	id := m.shareID
+	if len(id) != _shareIDLength {
+		t.Fatalf("unexpected share ID %q", id)
+	}
+	if !strings.Contains(m.summary, id) {
+		t.Errorf("summary is missing the share ID: %q", m.summary)
+	}
+	board, err := viewSharedGame(context.Background(), m.store, m.renderer, m.options, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(board, "2/6") || strings.Count(board, "┌───┐") != 2*_numChars {
+		t.Errorf("unexpected board:\n%s", board)
+	}
+
+	// Games in progress can't be viewed, even if they have a share ID.
+	m.startGame(m.game.Answer())
+	typeKeys(m, "crane\n")
+	m.writes.flush()
+	const unfinished = "abcdefghjk"
+	params := store.FinishGameParams{ShareID: sql.NullString{String: unfinished, Valid: true}, ID: m.record.id}
+	if err := m.store.FinishGame(context.Background(), params); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := viewSharedGame(context.Background(), m.store, m.renderer, m.options, unfinished); err == nil {
+		t.Error("game in progress could be viewed")
+	}
+	if other, _ := newShareID(); other == id {
+		t.Errorf("share IDs are not random: %q", id)
+	}
+}
+
+func TestMinimumWindowSize(t *testing.T) {
+	m := newTestModel(t, "TRACE", 20, 10)
+	m.options.minWidth, m.options.minHeight = 25, 20
+
+	if view := m.View(); !strings.Contains(view, "25x20") {
+		t.Fatalf("expected a message about the window size, got:\n%s", view)
+	}
+	typeKeys(m, "crane\n")
+	if n := len(m.game.Guesses()); n != 0 {
+		t.Fatalf("keys were accepted while the game wasn't shown")
+	}
+
+	m.Update(tea.WindowSizeMsg{Width: 80, Height: 40})
+	if view := m.View(); strings.Contains(view, "25x20") {
+		t.Fatalf("message is still shown after growing the window:\n%s", view)
+	}
+}
+
+func TestChooseGridLayout(t *testing.T) {
+	for _, tt := range []struct {
+		width, numChars, tileGap int
+		want                     gridLayout
+	}{
+		{0, 5, 1, _gridLayoutTiles},
+		{80, 5, 1, _gridLayoutTiles},
+		{29, 5, 1, _gridLayoutTiles},
+		{28, 5, 1, _gridLayoutSlim},
+		{25, 5, 0, _gridLayoutTiles},
+		{24, 5, 0, _gridLayoutSlim},
+		{15, 5, 0, _gridLayoutSlim},
+		{14, 5, 0, _gridLayoutLetters},
+		{9, 5, 0, _gridLayoutLetters},
+		{8, 5, 0, _gridLayoutCompact},
+		{5, 5, 0, _gridLayoutCompact},
+		{4, 5, 0, _gridLayoutTooNarrow},
+		{80, 7, 2, _gridLayoutTiles},
+		{40, 7, 2, _gridLayoutSlim},
+		{21, 7, 0, _gridLayoutSlim},
+		{20, 7, 0, _gridLayoutLetters},
+		{12, 7, 0, _gridLayoutCompact},
+		{6, 7, 0, _gridLayoutTooNarrow},
+		{40, 8, 0, _gridLayoutTiles},
+		{39, 8, 0, _gridLayoutSlim},
+		{15, 8, 0, _gridLayoutLetters},
+		{8, 8, 0, _gridLayoutCompact},
+		{7, 8, 0, _gridLayoutTooNarrow},
+	} {
+		if got := chooseGridLayout(tt.width, tt.numChars, tt.tileGap); got != tt.want {
+			t.Errorf("chooseGridLayout(%d, %d, %d) = %d, want %d", tt.width, tt.numChars, tt.tileGap, got, tt.want)
+		}
+	}
+}
+
+func TestNarrowWindow(t *testing.T) {
+	m := newTestModel(t, "TRACE", 12, 40)
+	typeKeys(m, "crane\n")
+
+	// The grid shrinks to fit, rather than overflowing the window.
+	if m.gridLayout() != _gridLayoutLetters {
+		t.Errorf("layout = %d, want letters", m.gridLayout())
+	}
+	if width := lipgloss.Width(m.viewGrid()); width > 12 {
+		t.Errorf("grid is %d columns wide, want at most 12", width)
+	}
+
+	m.Update(tea.WindowSizeMsg{Width: 4, Height: 40})
+	if view := m.View(); view != m.viewTooNarrow() {
+		t.Errorf("expected a message asking for a wider window, got:\n%s", view)
+	}
+}
+
+func TestStreakFreeze(t *testing.T) {
+	var results []store.ListGameResultsRow
+	play := func(won bool) stats {
+		results = append(results, store.ListGameResultsRow{Answer: sql.NullString{String: "TRACE", Valid: true}, NumGuesses: _numGuesses, Won: won})
+		return computeStats(results)
+	}
+
+	for i := 0; i < _winsPerFreeze; i++ {
+		play(true)
+	}
+	if s := play(false); !s.lastFrozen || s.CurrentStreak != _winsPerFreeze || s.Freezes != 0 {
+		t.Fatalf("expected a freeze to save the streak, got %+v", s)
+	}
+	if s := play(false); s.lastFrozen || s.CurrentStreak != 0 {
+		t.Fatalf("expected the streak to end without a freeze, got %+v", s)
+	}
+
+	// Freezes can only be saved up to a limit.
+	for i := 0; i < (_maxFreezes+1)*_winsPerFreeze; i++ {
+		play(true)
+	}
+	if s := play(true); s.Freezes != _maxFreezes {
+		t.Fatalf("expected %d freezes, got %d", _maxFreezes, s.Freezes)
+	}
+}
+
+func TestLowercase(t *testing.T) {
+	m := newTestModel(t, "TRACE", 80, 40)
+	m.options.lowercase = true
+	typeKeys(m, "crane\n")
+
+	for _, key := range []string{"C", "ENTER"} {
+		if got, want := m.viewKey(key, _keyStatePresent), m.styles.renderKey(strings.ToLower(key), _keyStatePresent); got != want {
+			t.Errorf("viewKey(%s) = %q, want %q", key, got, want)
+		}
+	}
+	if got := m.grid[0].String(); got != "CRANE" {
+		t.Errorf("grid holds %q, want CRANE", got)
+	}
+}
+
+func TestTerminalTitle(t *testing.T) {
+	m := newTestModel(t, "TRACE", 80, 40)
+	m.options.title = true
+	typeKeys(m, "c")
+	if m.windowTitle != "" {
+		t.Errorf("expected no title when the output isn't a terminal, got %q", m.windowTitle)
+	}
+
+	m.canSetTitle = true
+	var out strings.Builder
+	m.saveTitle(&out)
+	typeKeys(m, "rane\n")
+	if want := "clidle — guess 2/6"; m.windowTitle != want {
+		t.Errorf("title = %q, want %q", m.windowTitle, want)
+	}
+	m.host = "example.com"
+	m.daily, m.dailyNumber = "2024-03-01", 87
+	typeKeys(m, "trace\n")
+	if want := "clidle @ example.com — daily #87, solved in 2/6"; m.windowTitle != want {
+		t.Errorf("title = %q, want %q", m.windowTitle, want)
+	}
+
+	// The title is left alone once the setting is off, and the previous
+	// one is restored on exit.
+	m.options.title = false
+	typeKeys(m, "\n")
+	if want := "clidle @ example.com — daily #87, solved in 2/6"; m.windowTitle != want {
+		t.Errorf("title = %q, want %q", m.windowTitle, want)
+	}
+	m.restoreTitle(&out)
+	if got := out.String(); got != _saveTitle+_restoreTitle {
+		t.Errorf("wrote %q, want the title saved and restored", got)
+	}
+}
+
+func TestScoringScreen(t *testing.T) {
+	m := newTestModel(t, "TRACE", 80, 40)
+	m.renderer.SetColorProfile(termenv.Ascii)
+	typeKeys(m, "cr?")
+	if m.screen != screenScoring {
+		t.Fatal("scoring screen not shown")
+	}
+	for _, want := range []string{"Solved in 1 guess    +100", "Solved in 6 guesses  +50", "Not solved           +0"} {
+		if !strings.Contains(m.View(), want) {
+			t.Errorf("scoring screen doesn't contain %q", want)
+		}
+	}
+
+	// Any key closes the screen without being typed.
+	typeKeys(m, "a")
+	if m.screen == screenScoring {
+		t.Error("scoring screen still shown")
+	}
+	if got := strings.TrimRight(m.grid[0].String(), "\x00"); got != "CR" {
+		t.Errorf("grid holds %q, want CR", got)
+	}
+}
+
+func TestSettingsScreen(t *testing.T) {
+	m := newTestModel(t, "TRACE", 80, 40)
+	m.Update(tea.KeyMsg{Type: tea.KeyCtrlO})
+	if m.screen != screenSettings {
+		t.Fatal("settings screen not shown")
+	}
+
+	// Changes apply right away, and letters aren't typed into the grid.
+	m.Update(tea.KeyMsg{Type: tea.KeyRight})
+	if m.options.border != lipgloss.RoundedBorder() {
+		t.Errorf("border = %+v, want rounded", m.options.border)
+	}
+	m.Update(tea.KeyMsg{Type: tea.KeyDown})
+	m.Update(tea.KeyMsg{Type: tea.KeyDown})
+	m.Update(tea.KeyMsg{Type: tea.KeyLeft})
+	if !m.options.lowercase {
+		t.Error("letter case not changed to lower")
+	}
+	typeKeys(m, "c")
+	if m.gridLen != 0 {
+		t.Errorf("gridLen = %d, want 0", m.gridLen)
+	}
+
+	m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	if m.screen == screenSettings {
+		t.Error("settings screen still shown")
+	}
+}
+
+func TestLossPenalty(t *testing.T) {
+	m := newTestModel(t, "TRACE", 80, 40)
+	m.options.lossPenalty = 30
+	lose := func() {
+		m.startGame(m.game.Answer())
+		typeKeys(m, "crane\ncrane\ncrane\ncrane\ncrane\ncrane\n")
+	}
+
+	// The total can't go below zero, and later wins count in full.
+	typeKeys(m, "crane\ncrane\ncrane\ncrane\ncrane\ncrane\n")
+	if m.score != 0 {
+		t.Fatalf("expected a total of 0, got %d", m.score)
+	}
+	m.startGame(m.game.Answer())
+	typeKeys(m, "trace\n")
+	lose()
+	if m.score != 70 || !strings.Contains(m.status, "-30 (total 70)") {
+		t.Fatalf("expected a total of 70 after the penalty, got %d (%q)", m.score, m.status)
+	}
+	m.options.lossPenalty = 100
+	lose()
+	if m.score != 0 {
+		t.Fatalf("expected a total of 0, got %d", m.score)
+	}
+
+	// The penalties are recorded, so the stored total agrees.
+	m.writes.flush()
+	total, err := m.store.GetTotalScore(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if int(total.Float64) != m.score {
+		t.Errorf("stored total = %v, want %d", total.Float64, m.score)
+	}
+	results, err := m.store.ListGameResults(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := computeStats(results); s.Points != 100 || s.LossPenalties != 160 {
+		t.Errorf("stats show %d points and %d in penalties, want 100 and 160", s.Points, s.LossPenalties)
+	}
+}
+
+func TestGuessPatterns(t *testing.T) {
+	m := newTestModel(t, "TRACE", 80, 40)
+	typeKeys(m, "eerie\ncrane\n")
+	m.writes.flush()
+
+	ctx := context.Background()
+	guesses, err := m.store.ListGuesses(ctx, sql.NullInt64{Int64: m.record.id, Valid: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"AAPAC", "PCCAC"}
+	if len(guesses) != len(want) {
+		t.Fatalf("got %d guesses, want %d", len(guesses), len(want))
+	}
+	for i, guess := range guesses {
+		if guess.Pattern.String != want[i] {
+			t.Errorf("guess %d has pattern %q, want %q", i+1, guess.Pattern.String, want[i])
+		}
+	}
+}
+
+func TestBackfillPatterns(t *testing.T) {
+	db, err := sql.Open("sqlite", "file::memory:?_time_format=sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	if err := migrate(db); err != nil {
+		t.Fatal(err)
+	}
+
+	// Guesses saved before patterns were stored don't have one.
+	for _, stmt := range []string{
+		"INSERT INTO game (id, answer) VALUES (1, 'TRACE')",
+		"INSERT INTO guess (game_id, guess) VALUES (1, 'EERIE'), (1, 'TRACE')",
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := backfillPatterns(tx); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	guesses, err := store.New(db).ListGuesses(context.Background(), sql.NullInt64{Int64: 1, Valid: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []string{"AAPAC", "CCCCC"} {
+		if guesses[i].Pattern.String != want {
+			t.Errorf("guess %d has pattern %q, want %q", i+1, guesses[i].Pattern.String, want)
+		}
+	}
+}
+
+func TestSync(t *testing.T) {
+	local := newTestModel(t, "TRACE", 80, 40)
+	typeKeys(local, "crane\ntrace\n")
+	local.startGame(local.game.Answer())
+	typeKeys(local, "crane\ncrane\ncrane\ncrane\ncrane\ncrane\n")
+	local.startGame(local.game.Answer())
+	typeKeys(local, "slate\n")
+	local.writes.flush()
+
+	ctx := context.Background()
+	results, err := local.store.ListGameResults(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	games, err := exportGames(ctx, local.store, results)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(games) != 2 {
+		t.Fatalf("exported %d games, want the 2 completed ones", len(games))
+	}
+	var input bytes.Buffer
+	for _, g := range games {
+		if err := json.NewEncoder(&input).Encode(g); err != nil {
+			t.Fatal(err)
+		}
+	}
+	input.WriteString(`{"uuid":"forged","answer":"TRACE","guesses":["TRACE","TRACE"]}` + "\n")
+	input.WriteString(`{"uuid":"bonus","answer":"TRACE","guesses":["TRACE"],"penalty":-1000}` + "\n")
+	input.WriteString(`{"uuid":"early","answer":"TRACE","guesses":["TRACE"],"started_at":"2024-03-02T00:00:00Z","finished_at":"2024-03-01T00:00:00Z"}` + "\n")
+
+	// Syncing again skips the games that are already on the server.
+	server := newTestModel(t, "TRACE", 80, 40).store
+	for _, want := range []syncResult{{Imported: 2, Rejected: 3}, {Skipped: 2, Rejected: 3}} {
+		got, err := importGames(ctx, server, testDictionary, "player", "name", bytes.NewReader(input.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("importGames() = %+v, want %+v", got, want)
+		}
+	}
+
+	results, err = listPlayerResults(ctx, server, "player")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := computeStats(results); s.Played != 2 || s.Won != 1 || s.Distribution[1] != 1 {
+		t.Errorf("merged stats = %+v, want 1 win in 2 guesses and 1 loss", s)
+	}
+	if results, _ := listPlayerResults(ctx, server, "other"); len(results) != 0 {
+		t.Errorf("another player has %d games, want 0", len(results))
+	}
+	if top, err := server.GetTopScores(ctx, 10); err != nil || len(top) != 0 {
+		t.Errorf("expected synced games to stay off the top scores, got %+v, %v", top, err)
+	}
+
+	// The fields that scores depend on are bounded.
+	g := syncGame{UUID: "a", Answer: "TRACE", Guesses: []string{"TRACE"}, Penalty: 500, LossPenalty: 5, MaxRarity: 7}
+	if err := checkSyncGame(testDictionary, &g); err != nil || g.Penalty != 100 || g.LossPenalty != 0 || g.MaxRarity != _rarityUncommon {
+		t.Errorf("checkSyncGame() = %v, got %+v", err, g)
+	}
+}
+
+func TestProfileFile(t *testing.T) {
+	games := []syncGame{{UUID: "a", Answer: "TRACE", Guesses: []string{"CRANE", "TRACE"}}}
+	var file bytes.Buffer
+	if err := writeProfile(&file, games, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	r, err := readProfile(bytes.NewReader(file.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := newTestModel(t, "TRACE", 80, 40).store
+	result, err := importGames(context.Background(), server, testDictionary, "", "", r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != (syncResult{Imported: 1}) {
+		t.Errorf("importGames() = %+v, want 1 game imported", result)
+	}
+
+	if _, err := readProfile(strings.NewReader(`{"uuid":"a"}`)); err == nil {
+		t.Error("expected an error reading a file that isn't a profile")
+	}
+}
+
+func TestClaimAnonymousGames(t *testing.T) {
+	m := newTestModel(t, "TRACE", 80, 40)
+	m.playerName, m.remoteIP = "alice", "192.0.2.1"
+	typeKeys(m, "crane\ntrace\n")
+	m.startGame(m.game.Answer())
+	m.remoteIP = "192.0.2.2"
+	typeKeys(m, "trace\n")
+	m.writes.flush()
+
+	// Only games from the same address are claimed, and only once.
+	m.player, m.remoteIP = "SHA256:alice", "192.0.2.1"
+	m.Update(tea.KeyMsg{Type: tea.KeyCtrlO})
+	for _, want := range []string{"Claimed 1 anonymous games.", "No anonymous games to claim."} {
+		_, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("c")})
+		m.Update(cmd())
+		if m.status != want {
+			t.Errorf("status = %q, want %q", m.status, want)
+		}
+	}
+
+	// The rest can be merged by an operator, but keyed players need force.
+	ctx := context.Background()
+	if merged, err := mergePlayers(ctx, m.store, "anonymous:alice", "SHA256:alice", false, time.Now()); err != nil || merged != 1 {
+		t.Errorf("mergePlayers() = %d, %v, want 1 game merged", merged, err)
+	}
+	if _, err := mergePlayers(ctx, m.store, "SHA256:alice", "SHA256:bob", false, time.Now()); err == nil {
+		t.Error("expected merging two keyed players without force to fail")
+	}
+	if merged, err := mergePlayers(ctx, m.store, "SHA256:alice", "SHA256:bob", true, time.Now()); err != nil || merged != 2 {
+		t.Errorf("mergePlayers() = %d, %v, want 2 games merged", merged, err)
+	}
+	if results, _ := listPlayerResults(ctx, m.store, "SHA256:bob"); len(results) != 2 {
+		t.Errorf("merged player has %d games, want 2", len(results))
+	}
+}
+
+func TestFreebie(t *testing.T) {
+	m := newTestModel(t, "TRACE", 80, 40)
+	m.renderer.SetColorProfile(termenv.Ascii)
+	m.options.freebie = true
+	m.startGame(m.game.Answer())
+
+	letter := m.game.Answer()[m.freebie]
+	if m.keyStates.get(letter) != _keyStateCorrect {
+		t.Errorf("expected %c to be marked correct on the keyboard", letter)
+	}
+	if status := m.viewStatus(); !strings.Contains(status, "[given: "+m.viewFreebie()+"]") {
+		t.Errorf("expected the given letter in the status, got %q", status)
+	}
+
+	// The given letter costs points.
+	typeKeys(m, "crane\ntrace\n")
+	if want := game.Score(2, true) - _freebiePenalty; m.earned() != want {
+		t.Errorf("earned %d points, want %d", m.earned(), want)
+	}
+}
+
+func TestMoveCursor(t *testing.T) {
+	m := newTestModel(t, "TRACE", 80, 40)
+	left := func() { m.Update(tea.KeyMsg{Type: tea.KeyLeft}) }
+	right := func() { m.Update(tea.KeyMsg{Type: tea.KeyRight}) }
+	row := func() string { return m.grid[m.gridRow].String()[:m.gridLen] }
+
+	// Typing over a letter replaces it, and leaves the rest of the row.
+	typeKeys(m, "crame")
+	left()
+	left()
+	typeKeys(m, "n")
+	if row() != "CRANE" || m.gridCol != 4 {
+		t.Fatalf("row = %q with the cursor at %d, want CRANE at 4", row(), m.gridCol)
+	}
+
+	// The cursor can't move past the letters filled in.
+	right()
+	right()
+	if m.gridCol != _numChars {
+		t.Fatalf("cursor at %d, want %d", m.gridCol, _numChars)
+	}
+
+	// Deleting removes the letter before the cursor, and moves the rest back.
+	left()
+	left()
+	left()
+	m.Update(tea.KeyMsg{Type: tea.KeyBackspace})
+	if row() != "CANE" || m.gridCol != 1 {
+		t.Fatalf("row = %q with the cursor at %d, want CANE at 1", row(), m.gridCol)
+	}
+
+	// A row with the cursor moved back still has to be complete.
+	typeKeys(m, "\n")
+	if len(m.game.Guesses()) != 0 {
+		t.Fatal("expected an incomplete row to be rejected")
+	}
+	for range 4 {
+		left()
+	}
+	if m.gridCol != 0 {
+		t.Fatalf("cursor at %d, want 0", m.gridCol)
+	}
+	m.Update(tea.KeyMsg{Type: tea.KeyCtrlU})
+	typeKeys(m, "trace\n")
+	if m.game.State() != game.StateWon {
+		t.Errorf("expected the game to be won, got %v", m.game.State())
+	}
+}
+
+func TestUltraHardLockedLetters(t *testing.T) {
+	m := newTestModel(t, "TRACE", 80, 40)
+	m.options.ultraHard = true
+	m.startGame(m.game.Answer())
+
+	// R, A and E are green, so they are filled in and skipped over.
+	typeKeys(m, "crane\n")
+	if m.gridCol != 0 {
+		t.Fatalf("expected the cursor at the start of the row, got %d", m.gridCol)
+	}
+	typeKeys(m, "t")
+	if m.gridCol != 3 {
+		t.Fatalf("expected the cursor to skip the locked letters, got %d", m.gridCol)
+	}
+
+	// Moving the cursor skips over them too.
+	m.Update(tea.KeyMsg{Type: tea.KeyLeft})
+	if m.gridCol != 0 {
+		t.Fatalf("expected the cursor to move back over the locked letters, got %d", m.gridCol)
+	}
+	m.Update(tea.KeyMsg{Type: tea.KeyRight})
+	if m.gridCol != 3 {
+		t.Fatalf("expected the cursor to move forward over the locked letters, got %d", m.gridCol)
+	}
+
+	// Deleting skips back over them instead of removing them.
+	m.Update(tea.KeyMsg{Type: tea.KeyBackspace})
+	if m.gridCol != 0 {
+		t.Fatalf("expected the cursor back at the start of the row, got %d", m.gridCol)
+	}
+	m.Update(tea.KeyMsg{Type: tea.KeyBackspace})
+	if m.gridCol != 0 {
+		t.Fatalf("expected the cursor to stay at the start of the row, got %d", m.gridCol)
+	}
+
+	typeKeys(m, "tc")
+	if m.gridLen != _numChars || m.grid[m.gridRow].String() != "TRACE" {
+		t.Fatalf("expected the row to be completed, got %q", m.grid[m.gridRow])
+	}
+	typeKeys(m, "\n")
+	if m.game.State() != game.StateWon {
+		t.Errorf("expected the game to be won, got %v", m.game.State())
+	}
+}
+
+func TestAssistKeys(t *testing.T) {
+	m := newTestModel(t, "TRACE", 80, 40)
+	m.options.assistKeys = true
+	m.startGame(m.game.Answer())
+
+	// Only N and T tell CRANE and TRACE apart.
+	for _, key := range []byte("ACENRT") {
+		if suggested := m.keyStateOf(key) == _keyStateSuggested; suggested != (key == 'N' || key == 'T') {
+			t.Errorf("%c suggested = %v", key, suggested)
+		}
+	}
+
+	// Once only TRACE is left, no letter tells anything more.
+	typeKeys(m, "slate\n")
+	if len(m.candidates) != 1 || m.assistKeys != [26]bool{} {
+		t.Errorf("expected no suggestions with %d candidates, got %v", len(m.candidates), m.assistKeys)
+	}
+}
+
+func TestPuzzleCode(t *testing.T) {
+	want := puzzle{answer: "TRACE", ultraHard: true, maxRarity: _rarityCommon}
+	code, err := encodePuzzle(testDictionary, want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := decodePuzzle(testDictionary, code); err != nil || got != want {
+		t.Fatalf("decoded %+v, %v, want %+v", got, err, want)
+	}
+
+	// Typos are caught by the checksum, and codes made with another list of
+	// answers are rejected instead of giving a different word.
+	typo := []byte(code)
+	typo[4] ^= 1
+	if _, err := decodePuzzle(testDictionary, string(typo)); err == nil {
+		t.Error("expected a code with a typo to be rejected")
+	}
+	other := Dictionary{commonWords: []string{"CRANE", "SLATE", "TRACE"}}
+	if _, err := decodePuzzle(other, code); err == nil || !strings.Contains(err.Error(), "dictionary") {
+		t.Errorf("expected a code from another dictionary to be rejected, got %v", err)
+	}
+
+	// The code picks the answer of the first game only, and is shown once it
+	// is over so that it can be passed on. The rules are set from the code
+	// along with the other flags.
+	m := newTestModel(t, "CRANE", 80, 40)
+	m.options.puzzle = &want
+	m.options.ultraHard, m.options.maxRarity = want.ultraHard, want.maxRarity
+	m.doRestart()
+	if answer := m.game.Answer().String(); answer != "TRACE" {
+		t.Fatalf("expected the puzzle answer, got %s", answer)
+	}
+	if m.viewPuzzleCode() != "" {
+		t.Error("expected no code before the game is over")
+	}
+	typeKeys(m, "trace\n")
+	if m.puzzleCode != code || !strings.Contains(m.View(), code) {
+		t.Errorf("expected code %s on the end screen, got %q", code, m.puzzleCode)
+	}
+	if m.doRestart(); m.options.puzzle != nil {
+		t.Error("expected the puzzle to be used up by the first game")
+	}
+}
+
+func TestHomeScreen(t *testing.T) {
+	m := newTestModel(t, "TRACE", 80, 40)
+	m.renderer.SetColorProfile(termenv.Ascii)
+	m.options.home = true
+	m.options.freebie = true
+	m.player, m.playerName = "SHA256:alice", "alice"
+	m.doShowHome()
+	if m.screen != screenHome {
+		t.Fatal("home screen not shown")
+	}
+	for _, want := range []string{"Welcome, alice", "> Daily #", "not played yet", "Quick play", "0 played"} {
+		if !strings.Contains(m.View(), want) {
+			t.Errorf("home screen doesn't contain %q", want)
+		}
+	}
+
+	// Entries are chosen with the arrow keys, and the leaderboard is only
+	// shown once the daily puzzle is finished.
+	m.Update(tea.KeyMsg{Type: tea.KeyUp})
+	m.Update(tea.KeyMsg{Type: tea.KeyUp})
+	if view := m.View(); !strings.Contains(view, "> Leaderboard") || !strings.Contains(view, "Finish today's puzzle") {
+		t.Errorf("expected the leaderboard to be hidden, got:\n%s", view)
+	}
+	m.Update(tea.KeyMsg{Type: tea.KeyDown})
+	m.Update(tea.KeyMsg{Type: tea.KeyDown})
+	m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if m.screen != screenGame || m.daily == "" {
+		t.Fatal("expected the daily puzzle to start")
+	}
+	if m.freebie != -1 {
+		t.Error("expected no letter to be given away on the daily puzzle")
+	}
+	typeKeys(m, strings.ToLower(m.game.Answer().String())+"\n")
+	m.writes.flush()
+	m.Update(tea.KeyMsg{Type: tea.KeyCtrlL})
+	if view := m.View(); !strings.Contains(view, "solved in 1/6") {
+		t.Errorf("expected the daily puzzle to be solved, got:\n%s", view)
+	}
+	m.Update(tea.KeyMsg{Type: tea.KeyDown})
+	m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if m.screen != screenGame || m.daily != "" || m.freebie == -1 {
+		t.Fatal("expected a random game to start, with a letter given away")
+	}
+
+	// Settings opened from the home screen return to it, and skipping it is
+	// saved for the player.
+	m.Update(tea.KeyMsg{Type: tea.KeyCtrlL})
+	m.Update(tea.KeyMsg{Type: tea.KeyCtrlO})
+	m.Update(tea.KeyMsg{Type: tea.KeyUp})
+	m.Update(tea.KeyMsg{Type: tea.KeyRight})
+	m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	if m.screen != screenHome || !m.skipHome {
+		t.Fatalf("expected to skip the home screen from now on, screen %d", m.screen)
+	}
+	m.writes.flush()
+	m.screen, m.skipHome = screenGame, false
+	m.Init()
+	if m.screen != screenGame || !m.skipHome {
+		t.Error("expected the saved preference to skip the home screen")
+	}
+}
+
+func TestWalkthrough(t *testing.T) {
+	m := newTestModel(t, "TRACE", 80, 36)
+	m.renderer.SetColorProfile(termenv.Ascii)
+	m.options.walkthrough = true
+	typeKeys(m, strings.Repeat("crane\n", _numGuesses))
+
+	want := []game.Word{{'C', 'R', 'A', 'N', 'E'}, {'T', 'R', 'A', 'C', 'E'}}
+	if !slices.Equal(m.walkthrough, want) {
+		t.Fatalf("walkthrough = %v, want %v", m.walkthrough, want)
+	}
+	// Without colors, the feedback is spelled out next to each row.
+	if view := m.View(); !strings.Contains(view, "How the solver") || !strings.Contains(view, "T R A C E  CCCCC") {
+		t.Errorf("walkthrough not shown:\n%s", view)
+	}
+
+	// Rows that don't fit the window are left out.
+	m.Update(tea.WindowSizeMsg{Width: 80, Height: 26})
+	if view := m.View(); !strings.Contains(view, "C R A N E") || strings.Contains(view, "T R A C E") {
+		t.Errorf("expected only the first row to fit:\n%s", view)
+	}
+	m.Update(tea.WindowSizeMsg{Width: 80, Height: 25})
+	if view := m.View(); strings.Contains(view, "How the solver") {
+		t.Errorf("expected no walkthrough in a small window:\n%s", view)
+	}
+
+	// Won games have none.
+	m.startGame(m.game.Answer())
+	typeKeys(m, "crane\ntrace\n")
+	if m.walkthrough != nil {
+		t.Errorf("won game has a walkthrough: %v", m.walkthrough)
+	}
+}
+
+func TestDuelInvites(t *testing.T) {
+	clock := newFakeClock(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC))
+	iv := newInvites(testDictionary, clock)
+	newPlayer := func() *model {
+		m := newTestModel(t, "TRACE", 80, 40)
+		m.invites = iv
+		return m
+	}
+	invite := func(m *model) string {
+		m.Update(tea.KeyMsg{Type: tea.KeyCtrlT})
+		m.Update(tea.KeyMsg{Type: tea.KeyCtrlN})
+		return m.inviteCode
+	}
+	join := func(m *model, code string) {
+		if m.screen != screenDuel {
+			m.Update(tea.KeyMsg{Type: tea.KeyCtrlT})
+		}
+		m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(strings.ToLower(code))})
+		m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	}
+
+	a, b, c := newPlayer(), newPlayer(), newPlayer()
+	code := invite(a)
+	if len(code) != _inviteCodeLength || strings.ContainsAny(code, "0O1IL") || !a.isWaitingForOpponent() {
+		t.Fatalf("expected to wait with an unambiguous code, got %q", code)
+	}
+	join(b, code)
+	if b.match != a.match || !b.matchView.started {
+		t.Fatal("expected the invited player to join the duel")
+	}
+
+	// Codes can only be used once.
+	join(c, code)
+	if c.match != nil || c.status != "No duel has that code." {
+		t.Errorf("expected the used code to be rejected, got %q", c.status)
+	}
+
+	// Codes expire.
+	code = invite(newPlayer())
+	clock.Advance(_inviteTTL + time.Second)
+	join(c, code)
+	if c.match != nil || c.status != "That code has expired." {
+		t.Errorf("expected the expired code to be rejected, got %q", c.status)
+	}
+
+	// Invites are withdrawn when the player stops waiting.
+	d := newPlayer()
+	code = invite(d)
+	d.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	if d.match != nil {
+		t.Error("expected esc to withdraw the invite")
+	}
+	if _, err := iv.join(code, "c"); !errors.Is(err, errInviteNotFound) {
+		t.Errorf("join() = %v, want errInviteNotFound", err)
+	}
+}
+
+func TestDuelChat(t *testing.T) {
+	clock := newFakeClock(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC))
+	iv := newInvites(testDictionary, clock)
+	a, b := newTestModel(t, "TRACE", 80, 40), newTestModel(t, "TRACE", 80, 40)
+	for i, m := range []*model{a, b} {
+		m.invites, m.clock, m.playerName = iv, clock, []string{"alice", "bob"}[i]
+	}
+	code, mt, err := iv.create("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.inviteCode = code
+	a.startMatch(mt, 0)
+	if _, err := iv.join(code, "bob"); err != nil {
+		t.Fatal(err)
+	}
+	b.startMatch(mt, 1)
+	a.Update(msgMatch{match: mt})
+
+	say := func(text string) {
+		a.Update(tea.KeyMsg{Type: tea.KeyCtrlT})
+		a.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(text)})
+		a.Update(tea.KeyMsg{Type: tea.KeyEnter})
+		b.Update(msgMatch{match: mt})
+	}
+
+	// Messages don't enter letters into the grid, and are sanitized.
+	say("gg \x1b[2Jwp")
+	if a.gridLen != 0 {
+		t.Errorf("expected the grid to be untouched, got %d letters", a.gridLen)
+	}
+	if b.status != "alice: gg [2Jwp" {
+		t.Errorf("opponent's status = %q, want the sanitized message", b.status)
+	}
+
+	// Messages sent too quickly are dropped.
+	say("again")
+	if b.status != "alice: gg [2Jwp" {
+		t.Errorf("expected the second message to be rate-limited, got %q", b.status)
+	}
+	clock.Advance(_chatInterval)
+	b.options.muteChat = true
+	say("muted")
+	if b.status == "alice: muted" {
+		t.Error("expected the message to be hidden while chat is muted")
+	}
+}
+
+func TestDuelLostRound(t *testing.T) {
+	clock := newFakeClock(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC))
+	iv := newInvites(testDictionary, clock)
+	a, b := newTestModel(t, "TRACE", 80, 40), newTestModel(t, "TRACE", 80, 40)
+	for _, m := range []*model{a, b} {
+		m.invites, m.clock = iv, clock
+	}
+	code, mt, err := iv.create("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.inviteCode = code
+	a.startMatch(mt, 0)
+
+	// Time spent waiting for an opponent doesn't count.
+	clock.Advance(time.Minute)
+	if _, err := iv.join(code, "bob"); err != nil {
+		t.Fatal(err)
+	}
+	b.startMatch(mt, 1)
+	a.Update(msgMatch{match: mt})
+	if !a.startedAt.Equal(clock.Now()) {
+		t.Errorf("expected the game to start with the match, got %v", a.startedAt)
+	}
+
+	// The player who was beaten to it has their game finished and recorded.
+	guess := "crane"
+	if mt.answer.String() == "CRANE" {
+		guess = "slate"
+	}
+	typeKeys(b, guess+"\n")
+	typeKeys(a, strings.ToLower(mt.answer.String())+"\n")
+	b.Update(msgMatch{match: mt})
+	if !b.isGameOver() || b.summary == "" {
+		t.Fatal("expected the lost round to end the game")
+	}
+	b.writes.flush()
+	row, err := b.store.GetGame(context.Background(), b.record.id)
+	if err != nil || !row.FinishedAt.Valid || row.Flagged {
+		t.Errorf("expected the game to be finished and unflagged, got %+v, %v", row, err)
+	}
+
+	// The loss counts towards the stats, and can be exported.
+	results, err := b.store.ListGameResults(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := computeStats(results); s.Played != 1 || s.Won != 0 || s.CurrentStreak != 0 {
+		t.Errorf("stats = %+v, want one game played and lost", s)
+	}
+	games, err := exportGames(context.Background(), b.store, results)
+	if err != nil || len(games) != 1 {
+		t.Fatalf("exportGames() = %v, %v, want one game", games, err)
+	}
+	if err := checkSyncGame(testDictionary, &games[0]); err != nil {
+		t.Errorf("checkSyncGame() = %v, want the lost round accepted", err)
+	}
+}
+
+func TestLoadHostKey(t *testing.T) {
+	dir := t.TempDir()
+	fingerprint := func(path string) string {
+		t.Helper()
+		signer, err := loadHostKey(path, nil, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return gossh.FingerprintSHA256(signer.PublicKey())
+	}
+
+	// A new key is saved, and reused on the next start.
+	path := filepath.Join(dir, "clidle", "hostkey")
+	if first, second := fingerprint(path), fingerprint(path); first != second {
+		t.Errorf("host key changed from %s to %s", first, second)
+	}
+
+	// If it can't be saved, a temporary key is used instead.
+	blocker := filepath.Join(dir, "file")
+	if err := os.WriteFile(blocker, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	path = filepath.Join(blocker, "hostkey")
+	if first, second := fingerprint(path), fingerprint(path); first == second {
+		t.Errorf("expected a new temporary host key on every start, got %s twice", first)
+	}
+}
+
+func TestLoadHostKeyEncrypted(t *testing.T) {
+	dir := t.TempDir()
+	passphraseFile := filepath.Join(dir, "passphrase")
+	if err := os.WriteFile(passphraseFile, []byte("hunter2\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	passphrase := hostKeyPassphrase(passphraseFile)
+
+	// A new key is encrypted, and can be loaded again with the passphrase.
+	path := filepath.Join(dir, "hostkey")
+	first, err := loadHostKey(path, passphrase, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, err := os.ReadFile(path); err != nil {
+		t.Fatal(err)
+	} else if _, err := gossh.ParsePrivateKey(b); err == nil {
+		t.Fatal("expected the host key to be encrypted")
+	}
+	second, err := loadHostKey(path, passphrase, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a, b := gossh.FingerprintSHA256(first.PublicKey()), gossh.FingerprintSHA256(second.PublicKey()); a != b {
+		t.Errorf("host key changed from %s to %s", a, b)
+	}
+
+	// A wrong passphrase is told apart from a corrupt key.
+	t.Setenv(_envHostKeyPassphrase, "wrong")
+	if _, err := loadHostKey(path, hostKeyPassphrase(""), false); !errors.Is(err, errWrongPassphrase) {
+		t.Errorf("expected a wrong passphrase, got %v", err)
+	}
+	corrupt := filepath.Join(dir, "corrupt")
+	if err := os.WriteFile(corrupt, []byte("not a key"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadHostKey(corrupt, passphrase, false); err == nil || errors.Is(err, errWrongPassphrase) {
+		t.Errorf("expected an invalid host key, got %v", err)
+	}
+}
+
+func TestAnswersAccepted(t *testing.T) {
+	for _, maxRarity := range []int{_rarityCommon, _rarityUncommon} {
+		dictionary := EnglishDictionary.WithMaxRarity(maxRarity)
+		for _, word := range dictionary.commonWords {
+			if !dictionary.IsWord(word) {
+				t.Errorf("answer %s isn't accepted as a guess at rarity %d", word, maxRarity)
+			}
+		}
+	}
+
+	// Answers that aren't accepted are drawn again.
+	dictionary := Dictionary{
+		commonWords: []string{"CRANE", "TRACE"},
+		allWords:    map[string]struct{}{"TRACE": {}},
+	}
+	draws := []int{0, 0, 1}
+	intn := func(int) int {
+		idx := draws[0]
+		draws = draws[1:]
+		return idx
+	}
+	if got := dictionary.pickAnswer(intn); got != "TRACE" {
+		t.Errorf("pickAnswer() = %s, want TRACE", got)
+	}
+}
+
+func TestWeeklyRecap(t *testing.T) {
+	today := time.Date(2026, 1, 15, 0, 0, 0, 0, time.UTC)
+	result := func(daily string, numGuesses int64, won bool) store.ListGameResultsRow {
+		return store.ListGameResultsRow{
+			Daily:       sql.NullString{String: daily, Valid: daily != ""},
+			NumGuesses:  numGuesses,
+			Won:         won,
+			LossPenalty: 10,
+		}
+	}
+	results := []store.ListGameResultsRow{
+		result("2026-01-08", 1, true), // Before the week.
+		result("2026-01-09", 3, true),
+		result("2026-01-10", _numGuesses, false),
+		result("", 1, true),            // Not a daily.
+		result("2026-01-12", 2, false), // Still in progress.
+		result("2026-01-15", 4, true),
+		result("2026-01-15", 1, true), // Not the first attempt.
+	}
+
+	week := computeWeekly(results, today)
+	if week.Completed != 3 || week.AverageGuesses != 3.5 || week.Points != 80+70-10 {
+		t.Errorf("got %d completed, %.1f average guesses and %d points, want 3, 3.5 and 140", week.Completed, week.AverageGuesses, week.Points)
+	}
+
+	var sb strings.Builder
+	if err := writeWeeklyText(&sb, week); err != nil {
+		t.Fatal(err)
+	}
+	// Days that weren't played are shown rather than skipped.
+	if want := " F S S M T W T\n ■ × □ □ □ □ ■\n"; !strings.Contains(sb.String(), want) {
+		t.Errorf("calendar strip not found in:\n%s", sb.String())
+	}
+
+	m := newTestModel(t, "TRACE", 80, 40)
+	m.options.weeklyRecap = true
+	m.Update(tea.KeyMsg{Type: tea.KeyCtrlW})
+	if m.weekly == nil || !strings.Contains(m.View(), "This week") {
+		t.Fatal("weekly recap not shown")
+	}
+	typeKeys(m, "c")
+	if m.weekly != nil || m.gridLen != 0 {
+		t.Error("expected the key to close the recap without being typed")
+	}
+}
+
+func TestRevealOnQuit(t *testing.T) {
+	m := newTestModel(t, "TRACE", 80, 40)
+	m.options.freePlay = true
+	m.options.revealOnQuit = true
+	m.startGame(m.game.Answer())
+	if got := m.viewReveal(); got != "" {
+		t.Errorf("revealed a game without guesses: %q", got)
+	}
+	typeKeys(m, "crane\n")
+	if got, want := m.viewReveal(), "The word was TRACE.\n"; got != want {
+		t.Errorf("viewReveal() = %q, want %q", got, want)
+	}
+
+	// Scored games are never revealed.
+	m.options.freePlay = false
+	m.startGame(m.game.Answer())
+	typeKeys(m, "crane\n")
+	if got := m.viewReveal(); got != "" {
+		t.Errorf("revealed a scored game: %q", got)
+	}
+}
+
+func TestHistoryFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	m := newTestModel(t, "TRACE", 80, 40)
+	var err error
+	if m.history, err = openHistory(path); err != nil {
+		t.Fatal(err)
+	}
+	defer m.history.Close()
+
+	typeKeys(m, "crane\ntrace\n")
+	m.startGame(m.game.Answer())
+	typeKeys(m, "crane\ncrane\ncrane\ncrane\ncrane\ncrane\n")
+	m.writes.flush()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 entries, got %d:\n%s", len(lines), data)
+	}
+	var entry historyEntry
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry.Answer != "TRACE" || entry.Result != "won" || entry.Score != 90 || entry.Mode != "random" || len(entry.Guesses) != 2 {
+		t.Errorf("unexpected entry: %+v", entry)
+	}
+	if err := json.Unmarshal([]byte(lines[1]), &entry); err != nil || entry.Result != "lost" {
+		t.Errorf("unexpected entry: %+v, %v", entry, err)
+	}
+}
+
+func TestExportImage(t *testing.T) {
+	dir := t.TempDir()
+	m := newTestModel(t, "TRACE", 80, 40)
+	m.options.exportImage = filepath.Join(dir, "board.svg")
+	typeKeys(m, "crane\ntrace\n")
+	m.writes.flush()
+
+	data, err := os.ReadFile(m.options.exportImage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The background, and a tile for every letter of both guesses.
+	if n := strings.Count(string(data), "<rect"); n != 1+2*_numChars {
+		t.Errorf("expected %d rects, got %d:\n%s", 1+2*_numChars, n, data)
+	}
+	if !strings.Contains(string(data), `fill="`+_colorYellow.TrueColor+`"`) {
+		t.Errorf("expected the present C to be yellow:\n%s", data)
+	}
+
+	path := filepath.Join(dir, "board.png")
+	if err := writeBoardImage(path, m.game.Answer(), m.game.Guesses()); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	img, err := png.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	width, height := boardImageSize(2)
+	if size := img.Bounds().Size(); size.X != width || size.Y != height {
+		t.Errorf("expected a %dx%d image, got %v", width, height, size)
+	}
+}
+
+func TestReferee(t *testing.T) {
+	m := newTestModel(t, "TRACE", 80, 40)
+	answers := testDictionary
+	answers.commonWords = []string{"TRACE"}
+	m.referee = newReferee(answers)
+	m.refereeSession = m.referee.join()
+	m.startRefereedGame(time.Time{})
+
+	// Guesses are judged by the referee, and only their feedback is recorded.
+	judge := func(guess string) {
+		t.Helper()
+		typeKeys(m, guess)
+		_, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+		if !m.judging {
+			t.Fatalf("expected %s to be sent to the referee", guess)
+		}
+		m.Update(tea.KeyMsg{Type: tea.KeyBackspace})
+		typeKeys(m, "x")
+		if m.gridLen != _numChars || m.grid[m.gridRow].String() != strings.ToUpper(guess) {
+			t.Fatalf("expected input to be ignored while %s is judged", guess)
+		}
+		cmds := []tea.Cmd{cmd}
+		for len(cmds) > 0 {
+			cmd, cmds = cmds[0], cmds[1:]
+			if cmd == nil {
+				continue
+			}
+			switch msg := cmd().(type) {
+			case tea.BatchMsg:
+				cmds = append(cmds, msg...)
+			case msgJudged:
+				m.Update(msg)
+			}
+		}
+	}
+	judge("zzzzz")
+	if m.gridRow != 0 || m.status != "That's not a valid word." {
+		t.Fatalf("expected the guess to be rejected, got row %d and %q", m.gridRow, m.status)
+	}
+	m.doClearRow()
+	judge("crane")
+	if m.gridRow != 1 || !m.game.IsHidden() || m.game.Answer() != (game.Word{}) {
+		t.Fatalf("expected the answer to stay hidden, got row %d and %q", m.gridRow, m.game.Answer())
+	}
+	if m.viewPuzzleCode() != "" {
+		t.Error("expected no puzzle code before the game is over")
+	}
+	m.writes.flush()
+	if row, err := m.store.GetGame(context.Background(), m.record.id); err != nil || row.Answer.Valid {
+		t.Errorf("expected the answer not to be saved before the game is over, got %+v, %v", row, err)
+	}
+
+	judge("trace")
+	if m.game.State() != game.StateWon || m.game.Answer().String() != "TRACE" {
+		t.Fatalf("expected the answer to be revealed, got %q", m.game.Answer())
+	}
+	if m.puzzleCode == "" {
+		t.Error("expected a puzzle code once the game is over")
+	}
+	m.writes.flush()
+	results, err := m.store.ListGameResults(context.Background())
+	if err != nil || len(results) != 1 || results[0].Answer.String != "TRACE" {
+		t.Errorf("expected the game to be saved with its answer, got %+v, %v", results, err)
+	}
+
+	// A guess sent before the game was replaced isn't judged in the new one.
+	m.startRefereedGame(time.Time{})
+	typeKeys(m, "crane")
+	stale := m.judgeGuess(m.grid[m.gridRow])
+	m.startRefereedGame(time.Time{})
+	if msg := stale().(msgJudged); !errors.Is(msg.err, errStaleRound) {
+		t.Errorf("expected the guess to be turned away, got %v", msg.err)
+	}
+	if guesses := m.referee.games[m.refereeSession].game.Guesses(); len(guesses) != 0 {
+		t.Errorf("expected no guesses in the new game, got %v", guesses)
+	}
+
+	// The answer of a game left unfinished is saved when the session ends.
+	judge("crane")
+	m.leaveReferee()
+	m.writes.flush()
+	if row, err := m.store.GetGame(context.Background(), m.record.id); err != nil || row.Answer.String != "TRACE" {
+		t.Errorf("expected the answer to be saved on leaving, got %+v, %v", row, err)
+	}
+}
+
+func TestFormatThousands(t *testing.T) {
+	for n, want := range map[int]string{0: "0", 80: "80", 999: "999", 1320: "1,320", 1234567: "1,234,567", -1500: "-1,500"} {
+		if got := formatThousands(n); got != want {
+			t.Errorf("formatThousands(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
+
+func TestSaveGuessAfterDisconnect(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	m := newTestModelContext(t, ctx, "TRACE", 80, 40)
+	typeKeys(m, "crane")
+
+	// The session ends as the guess is submitted, before it is written.
+	cancel()
+	_, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	cmds, saved := []tea.Cmd{cmd}, 0
+	for len(cmds) > 0 {
+		cmd, cmds = cmds[0], cmds[1:]
+		if cmd == nil {
+			continue
+		}
+		switch msg := cmd().(type) {
+		case tea.BatchMsg:
+			cmds = append(cmds, msg...)
+		case msgSaved:
+			if msg.err != nil {
+				t.Fatalf("unexpected result of saving guess: %v", msg.err)
+			}
+			saved++
+		}
+	}
+	if saved == 0 {
+		t.Fatal("expected the guess to be saved")
+	}
+
+	guesses, err := m.store.ListGuesses(context.Background(), sql.NullInt64{Int64: m.record.id, Valid: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(guesses) != 1 || guesses[0].Guess.String != "CRANE" {
+		t.Fatalf("expected CRANE to be saved, got %v", guesses)
+	}
+}
+
+func BenchmarkView(b *testing.B) {
+	m := newTestModel(b, "TRACE", 80, 40)
+	typeKeys(m, "crane\nslate\nee")
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = m.View()
+	}
+}
+
+func BenchmarkViewKeyboard(b *testing.B) {
+	m := newTestModel(b, "TRACE", 80, 40)
+	typeKeys(m, "crane\nslate\nee")
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = m.viewKeyboard()
+	}
+}
+
+func BenchmarkIsWord(b *testing.B) {
+	dictionary := EnglishDictionary.WithMaxRarity(_rarityCommon)
+	words := make([]string, 0, len(dictionary.allWords))
+	for word := range dictionary.allWords {
+		words = append(words, word)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = dictionary.IsWord(words[i%len(words)])
+	}
+}
+
+func BenchmarkRarity(b *testing.B) {
+	words := make([]string, 0, len(EnglishDictionary.allWords))
+	for word := range EnglishDictionary.allWords {
+		words = append(words, word)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = EnglishDictionary.Rarity(words[i%len(words)])
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshot.json")
+	m := newTestModel(t, "TRACE", 80, 40)
+	m.options.snapshotFile = path
+	typeKeys(m, "crane\n")
+	m.writes.flush()
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("expected a snapshot after a guess: %v", err)
+	}
+
+	// A new session on the same database picks up the game where it was
+	// left off.
+	restored := newTestModelStore(t, context.Background(), m.store, "SLATE", 80, 40)
+	restored.options.snapshotFile = path
+	restored.restoreSnapshot()
+	if restored.game.Answer() != m.game.Answer() || restored.gridRow != 1 || restored.grid[0].String() != "CRANE" {
+		t.Fatalf("expected the game to be restored, got %s at row %d", restored.game.Answer(), restored.gridRow)
+	}
+	if restored.record.id != m.record.id || restored.keyStates != m.keyStates {
+		t.Errorf("expected the same game and keys, got game %d", restored.record.id)
+	}
+
+	// Finishing the game removes the snapshot, and guesses are saved to the
+	// same game.
+	typeKeys(restored, "trace\n")
+	restored.writes.flush()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("expected the snapshot to be removed, got %v", err)
+	}
+	guesses, err := m.store.ListGuesses(context.Background(), sql.NullInt64{Int64: m.record.id, Valid: true})
+	if err != nil || len(guesses) != 2 {
+		t.Errorf("expected 2 guesses in the game, got %d, %v", len(guesses), err)
+	}
+
+	// The game is marked as resumed, so that its solve time isn't counted.
+	results, err := m.store.ListGameResults(context.Background())
+	if err != nil || len(results) != 1 || !results[0].Resumed {
+		t.Errorf("expected the game to be marked as resumed, got %+v, %v", results, err)
+	}
+	if s := computeStats(results); s.Won != 1 || s.AverageSolveTime != 0 {
+		t.Errorf("expected no solve time for a resumed game, got %+v", s)
+	}
+
+	// A snapshot of a game in progress whose keyboard holds a state that is
+	// never stored, such as a warning.
+	unfinished := newTestModelStore(t, context.Background(), m.store, "TRACE", 80, 40)
+	unfinished.options.snapshotFile = path
+	typeKeys(unfinished, "crane\n")
+	unfinished.writes.flush()
+	var snapshot map[string]any
+	if data, err := os.ReadFile(path); err != nil {
+		t.Fatal(err)
+	} else if err := json.Unmarshal(data, &snapshot); err != nil {
+		t.Fatal(err)
+	}
+	snapshot["key_states"].([]any)[0] = int(_keyStateWarning)
+	warning, err := json.Marshal(snapshot)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Snapshots that are corrupt, or of a finished game, are ignored and
+	// removed.
+	for _, data := range []string{`{"game_id": `, `{"game_id": 1, "answer": "TRACE", "guesses": ["CRANE"]}`, string(warning)} {
+		if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+		other := newTestModelStore(t, context.Background(), m.store, "SLATE", 80, 40)
+		other.options.snapshotFile = path
+		other.restoreSnapshot()
+		if other.game.Answer().String() != "SLATE" || other.gridRow != 0 {
+			t.Errorf("expected %q to be ignored, got %s at row %d", data, other.game.Answer(), other.gridRow)
+		}
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("expected %q to be removed, got %v", data, err)
+		}
+	}
+}
+
+func TestServerAutosave(t *testing.T) {
+	m := newTestModel(t, "TRACE", 80, 40)
+	m.player = "SHA256:alice"
+	m.startGame(m.game.Answer())
+	typeKeys(m, "crane\nsl")
+	m.saveSnapshot()
+	m.writes.flush()
+
+	// A player with a key picks up their game when they reconnect, without a
+	// snapshot file.
+	restored := newTestModelStore(t, context.Background(), m.store, "SLATE", 80, 40)
+	restored.player = m.player
+	restored.restoreSnapshot()
+	if restored.record.id != m.record.id || restored.gridRow != 1 || string(restored.grid[1][:restored.gridLen]) != "SL" {
+		t.Fatalf("expected the game to be restored, got game %d at row %d", restored.record.id, restored.gridRow)
+	}
+	if !restored.isSaved() {
+		t.Error("expected the game to be reported as saved")
+	}
+
+	// Players without a key can't be told apart, so their games aren't
+	// picked up, and aren't reported as saved.
+	anonymous := newTestModelStore(t, context.Background(), m.store, "SLATE", 80, 40)
+	anonymous.restoreSnapshot()
+	if anonymous.game.Answer().String() != "SLATE" || anonymous.isSaved() {
+		t.Errorf("expected a new unsaved game, got %s", anonymous.game.Answer())
+	}
+}
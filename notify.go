@@ -0,0 +1,67 @@
+package main
+
+import (
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// _notifyModes contains the values accepted by --notify.
+var _notifyModes = map[string]struct{}{
+	"flash": {},
+	"bell":  {},
+	"none":  {},
+}
+
+// _flashDuration is how long the status bar stays inverted when flashing, and
+// how long the bell stays in the view when ringing it.
+const _flashDuration = 150 * time.Millisecond
+
+// msgFlashEnd is sent when a flash of the status bar, or a ring of the bell,
+// should end.
+type msgFlashEnd struct {
+	seq int
+}
+
+// notify draws the player's attention, such as when a guess is rejected or the
+// game is over, by ringing the terminal bell or flashing the status bar,
+// depending on their preference. Every such event goes through it, so that the
+// preference is honored everywhere.
+func (m *model) notify() tea.Cmd {
+	switch m.options.notify {
+	case "bell":
+		m.ringing = true
+	case "flash":
+		m.flashing = true
+	default:
+		return nil
+	}
+	m.flashSeq++
+	seq := m.flashSeq
+	after := m.clock.After(_flashDuration)
+	return func() tea.Msg {
+		<-after
+		return msgFlashEnd{seq: seq}
+	}
+}
+
+// updateFlashEnd ends the flash of the status bar or the ring of the bell,
+// unless another one has started since.
+func (m *model) updateFlashEnd(msg msgFlashEnd) tea.Cmd {
+	if msg.seq == m.flashSeq {
+		m.flashing = false
+		m.ringing = false
+	}
+	return nil
+}
+
+// viewBell returns the bell character while the bell is ringing. It is part of
+// the view, rather than written to the terminal directly, so that it doesn't
+// interleave with the output of the renderer. The renderer only writes lines
+// that change, so the bell rings once.
+func (m *model) viewBell() string {
+	if m.ringing {
+		return "\a"
+	}
+	return ""
+}
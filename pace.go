@@ -0,0 +1,84 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// _defaultDailyGuessInterval is the minimum time between accepted guesses on
+// the daily puzzle. Players thinking about their next guess rarely need less.
+const _defaultDailyGuessInterval = 2 * time.Second
+
+// _pacerPruneInterval is how often the pacer forgets players who could guess
+// again right away.
+const _pacerPruneInterval = time.Minute
+
+// pacer enforces a minimum interval between the accepted guesses of each
+// player on the daily puzzle, so that scripted clients can't race up the
+// leaderboard. It is shared by every session of the server, so that a player
+// can't get around it by connecting twice.
+type pacer struct {
+	interval time.Duration
+
+	mu   sync.Mutex
+	last map[string]time.Time
+}
+
+func newPacer(interval time.Duration) *pacer {
+	return &pacer{interval: interval, last: make(map[string]time.Time)}
+}
+
+// allow checks whether a guess by the player can be accepted now, and if so
+// notes it as their last. Checking and noting happen together, so that two
+// sessions of the same player can't both get a guess in.
+func (p *pacer) allow(player string, now time.Time) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if last, ok := p.last[player]; ok && now.Before(last.Add(p.interval)) {
+		return false
+	}
+	p.last[player] = now
+	return true
+}
+
+// prune forgets the players who could guess again right away.
+func (p *pacer) prune(now time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for player, last := range p.last {
+		if now.Sub(last) >= p.interval {
+			delete(p.last, player)
+		}
+	}
+}
+
+// pruneEvery prunes the pacer at every tick of the clock, until stop is
+// closed.
+func (p *pacer) pruneEvery(d time.Duration, clock clock, stop <-chan struct{}) {
+	ticker := clock.NewTicker(d)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case now := <-ticker.C():
+				p.prune(now)
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// paceKey identifies the player to the pacer: by their key if they have one,
+// and otherwise by their name and address, as anonymous games are claimed.
+func (m *model) paceKey() string {
+	if m.player != "" {
+		return m.player
+	}
+	return _anonymousPrefix + m.playerName + "@" + m.remoteIP
+}
+
+// isPaced checks whether guesses on the current game are paced.
+func (m *model) isPaced() bool {
+	return m.pacer != nil && m.daily != ""
+}
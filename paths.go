@@ -0,0 +1,32 @@
+package main
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// dataDir returns the directory that clidle keeps its data in, which is a
+// clidle directory in the platform's data directory.
+func dataDir() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(dataHome(os.Getenv, home), "clidle")
+}
+
+// sqliteDSN returns the data source name of the SQLite database at path, with
+// the given query parameters. The path is escaped as a URI, so that it may
+// contain spaces, non-ASCII characters, or characters such as ? and # that
+// would otherwise end it.
+func sqliteDSN(path string, query string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	path = filepath.ToSlash(path)
+	// Windows paths start with a volume name, as in file:///C:/Users.
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	u := url.URL{Scheme: "file", Path: path, RawQuery: query}
+	return u.String()
+}
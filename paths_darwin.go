@@ -0,0 +1,17 @@
+//go:build darwin
+
+package main
+
+import "path/filepath"
+
+// dataHome returns the base directory for user data, which is ~/Library/
+// Application Support, unless $XDG_DATA_HOME is set to an absolute path.
+func dataHome(getenv func(string) string, home string) string {
+	if dir := getenv("XDG_DATA_HOME"); filepath.IsAbs(dir) {
+		return dir
+	}
+	return filepath.Join(home, "Library", "Application Support")
+}
+
+// _ttyPath is the controlling terminal of the process.
+const _ttyPath = "/dev/tty"
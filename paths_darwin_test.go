@@ -0,0 +1,24 @@
+//go:build darwin
+
+package main
+
+import "testing"
+
+func TestDataHome(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		env  map[string]string
+		want string
+	}{
+		{"default", nil, "/Users/me/Library/Application Support"},
+		{"xdg", map[string]string{"XDG_DATA_HOME": "/Users/me/.local/share"}, "/Users/me/.local/share"},
+		{"relative xdg", map[string]string{"XDG_DATA_HOME": "data"}, "/Users/me/Library/Application Support"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			getenv := func(key string) string { return tt.env[key] }
+			if got := dataHome(getenv, "/Users/me"); got != tt.want {
+				t.Errorf("dataHome() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
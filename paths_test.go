@@ -0,0 +1,33 @@
+package main
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSqliteDSN(t *testing.T) {
+	for _, name := range []string{"plain", "with spaces", "ünïcödé", "hash#and?query%"} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), name, "clidle.db")
+			if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+				t.Fatal(err)
+			}
+			db, err := sql.Open("sqlite", sqliteDSN(path, "_time_format=sqlite"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+			if _, err := db.Exec("CREATE TABLE t (x INTEGER)"); err != nil {
+				t.Fatal(err)
+			}
+
+			// The database is created at the path as given, rather than at
+			// one cut short or left escaped.
+			if _, err := os.Stat(path); err != nil {
+				t.Errorf("database not created at %s: %v", path, err)
+			}
+		})
+	}
+}
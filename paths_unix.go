@@ -0,0 +1,18 @@
+//go:build !windows && !darwin
+
+package main
+
+import "path/filepath"
+
+// dataHome returns the base directory for user data, following the XDG Base
+// Directory Specification: $XDG_DATA_HOME, or ~/.local/share. Relative paths
+// in $XDG_DATA_HOME are invalid, and are ignored.
+func dataHome(getenv func(string) string, home string) string {
+	if dir := getenv("XDG_DATA_HOME"); filepath.IsAbs(dir) {
+		return dir
+	}
+	return filepath.Join(home, ".local", "share")
+}
+
+// _ttyPath is the controlling terminal of the process.
+const _ttyPath = "/dev/tty"
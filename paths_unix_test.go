@@ -0,0 +1,24 @@
+//go:build !windows && !darwin
+
+package main
+
+import "testing"
+
+func TestDataHome(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		env  map[string]string
+		want string
+	}{
+		{"default", nil, "/home/me/.local/share"},
+		{"xdg", map[string]string{"XDG_DATA_HOME": "/data"}, "/data"},
+		{"relative xdg", map[string]string{"XDG_DATA_HOME": "data"}, "/home/me/.local/share"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			getenv := func(key string) string { return tt.env[key] }
+			if got := dataHome(getenv, "/home/me"); got != tt.want {
+				t.Errorf("dataHome() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,18 @@
+//go:build windows
+
+package main
+
+import "path/filepath"
+
+// dataHome returns the base directory for user data, which is %LOCALAPPDATA%.
+// $XDG_DATA_HOME is ignored: shells such as MSYS2 and Cygwin set it to a path
+// inside their own installation, which native programs shouldn't use.
+func dataHome(getenv func(string) string, home string) string {
+	if dir := getenv("LOCALAPPDATA"); filepath.IsAbs(dir) {
+		return dir
+	}
+	return filepath.Join(home, "AppData", "Local")
+}
+
+// _ttyPath is the console input of the process.
+const _ttyPath = "CONIN$"
@@ -0,0 +1,32 @@
+//go:build windows
+
+package main
+
+import "testing"
+
+func TestDataHome(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		env  map[string]string
+		want string
+	}{
+		{"local app data", map[string]string{"LOCALAPPDATA": `D:\Profiles\Me Ünïcödé\AppData\Local`}, `D:\Profiles\Me Ünïcödé\AppData\Local`},
+		{"default", nil, `C:\Users\Me\AppData\Local`},
+		{"xdg is ignored", map[string]string{"XDG_DATA_HOME": `C:\msys64\home\me\.local\share`}, `C:\Users\Me\AppData\Local`},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			getenv := func(key string) string { return tt.env[key] }
+			if got := dataHome(getenv, `C:\Users\Me`); got != tt.want {
+				t.Errorf("dataHome() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSqliteDSNWindows(t *testing.T) {
+	got := sqliteDSN(`C:\Users\Me Ünïcödé\AppData\Local\clidle\clidle.db`, "mode=ro")
+	want := "file:///C:/Users/Me%20%C3%9Cn%C3%AFc%C3%B6d%C3%A9/AppData/Local/clidle/clidle.db?mode=ro"
+	if got != want {
+		t.Errorf("sqliteDSN() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,112 @@
+// Package config resolves the effective value of every option of clidle from
+// the places it can be set, so that the CLI and the server agree on which one
+// wins.
+package config
+
+import (
+	"sort"
+	"strings"
+)
+
+// Source is where the effective value of an option comes from. Later sources
+// take precedence over earlier ones.
+type Source int
+
+const (
+	// SourceDefault is the built-in default.
+	SourceDefault Source = iota
+	// SourceFile is the settings file.
+	SourceFile
+	// SourceEnv is an environment variable, named by EnvName.
+	SourceEnv
+	// SourceFlag is a flag given on the command line.
+	SourceFlag
+)
+
+func (s Source) String() string {
+	switch s {
+	case SourceFile:
+		return "file"
+	case SourceEnv:
+		return "env"
+	case SourceFlag:
+		return "flag"
+	default:
+		return "default"
+	}
+}
+
+// _envPrefix is the prefix of the environment variables setting options.
+const _envPrefix = "CLIDLE_"
+
+// Option is an option that can be configured, along with its default.
+type Option struct {
+	Name    string
+	Default string
+}
+
+// Setting is the effective value of an option, and where it comes from.
+type Setting struct {
+	Name   string
+	Value  string
+	Source Source
+}
+
+// Sources holds the values of options set in each source, keyed by option
+// name. Any of them may be nil.
+type Sources struct {
+	File  map[string]string
+	Env   map[string]string
+	Flags map[string]string
+}
+
+// Resolve returns the effective value of every option, sorted by name. Flags
+// take precedence over environment variables, which take precedence over the
+// settings file, which takes precedence over the defaults. Values of unknown
+// options are ignored.
+func Resolve(options []Option, sources Sources) []Setting {
+	settings := make([]Setting, 0, len(options))
+	for _, option := range options {
+		setting := Setting{Name: option.Name, Value: option.Default, Source: SourceDefault}
+		for _, layer := range []struct {
+			values map[string]string
+			source Source
+		}{
+			{sources.File, SourceFile},
+			{sources.Env, SourceEnv},
+			{sources.Flags, SourceFlag},
+		} {
+			if value, ok := layer.values[option.Name]; ok {
+				setting.Value, setting.Source = value, layer.source
+			}
+		}
+		settings = append(settings, setting)
+	}
+	sort.Slice(settings, func(i, j int) bool { return settings[i].Name < settings[j].Name })
+	return settings
+}
+
+// EnvName returns the environment variable that sets an option, e.g.
+// CLIDLE_MAX_RARITY for max-rarity.
+func EnvName(option string) string {
+	return _envPrefix + strings.ToUpper(strings.ReplaceAll(option, "-", "_"))
+}
+
+// FromEnviron returns the values of the options set in the environment, given
+// as key=value pairs like os.Environ. Variables that are set but empty are
+// ignored, so that they can be used to unset an option.
+func FromEnviron(options []Option, environ []string) map[string]string {
+	vars := make(map[string]string, len(environ))
+	for _, kv := range environ {
+		if key, value, ok := strings.Cut(kv, "="); ok {
+			vars[key] = value
+		}
+	}
+	values := make(map[string]string)
+	for _, option := range options {
+		if value := vars[EnvName(option.Name)]; value != "" {
+			values[option.Name] = value
+		}
+	}
+	return values
+}
@@ -0,0 +1,74 @@
+package config
+
+import "testing"
+
+func TestResolve(t *testing.T) {
+	options := []Option{{Name: "border", Default: "normal"}}
+	tests := []struct {
+		name       string
+		sources    Sources
+		wantValue  string
+		wantSource Source
+	}{
+		{"default", Sources{}, "normal", SourceDefault},
+		{"file", Sources{File: map[string]string{"border": "thick"}}, "thick", SourceFile},
+		{"env", Sources{Env: map[string]string{"border": "double"}}, "double", SourceEnv},
+		{"flag", Sources{Flags: map[string]string{"border": "rounded"}}, "rounded", SourceFlag},
+		{
+			"env over file",
+			Sources{File: map[string]string{"border": "thick"}, Env: map[string]string{"border": "double"}},
+			"double", SourceEnv,
+		},
+		{
+			"flag over file",
+			Sources{File: map[string]string{"border": "thick"}, Flags: map[string]string{"border": "rounded"}},
+			"rounded", SourceFlag,
+		},
+		{
+			"flag over env",
+			Sources{Env: map[string]string{"border": "double"}, Flags: map[string]string{"border": "rounded"}},
+			"rounded", SourceFlag,
+		},
+		{
+			"flag over everything",
+			Sources{
+				File:  map[string]string{"border": "thick"},
+				Env:   map[string]string{"border": "double"},
+				Flags: map[string]string{"border": "rounded"},
+			},
+			"rounded", SourceFlag,
+		},
+		{"flag set to the default", Sources{Flags: map[string]string{"border": "normal"}}, "normal", SourceFlag},
+		{"other options", Sources{File: map[string]string{"keyboard": "off"}}, "normal", SourceDefault},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Resolve(options, tt.sources)
+			if len(got) != 1 {
+				t.Fatalf("expected 1 setting, got %+v", got)
+			}
+			if got[0].Value != tt.wantValue || got[0].Source != tt.wantSource {
+				t.Errorf("got %s from %s, want %s from %s", got[0].Value, got[0].Source, tt.wantValue, tt.wantSource)
+			}
+		})
+	}
+}
+
+func TestResolveOrder(t *testing.T) {
+	options := []Option{{Name: "keyboard"}, {Name: "border"}, {Name: "case"}}
+	got := Resolve(options, Sources{})
+	for i, want := range []string{"border", "case", "keyboard"} {
+		if got[i].Name != want {
+			t.Errorf("setting %d is %s, want %s", i, got[i].Name, want)
+		}
+	}
+}
+
+func TestFromEnviron(t *testing.T) {
+	options := []Option{{Name: "max-rarity"}, {Name: "border"}, {Name: "keyboard"}}
+	environ := []string{"CLIDLE_MAX_RARITY=2", "CLIDLE_BORDER=", "KEYBOARD=off", "CLIDLE_UNKNOWN=1", "PATH=/bin"}
+	got := FromEnviron(options, environ)
+	if len(got) != 1 || got["max-rarity"] != "2" {
+		t.Errorf("unexpected values: %v", got)
+	}
+}
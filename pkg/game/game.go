@@ -0,0 +1,445 @@
+// Package game implements the rules of clidle, independently of how the game
+// is displayed.
+package game
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// NumGuesses is the maximum number of guesses you can make.
+	NumGuesses = 6
+	// NumChars is the word size in characters.
+	NumChars = 5
+)
+
+var (
+	// ErrGameOver is returned when guessing after the game has ended.
+	ErrGameOver = errors.New("game is over")
+	// ErrInvalidLength is returned when a guess doesn't have NumChars letters.
+	ErrInvalidLength = errors.New("guess must be a 5-letter word")
+	// ErrInvalidWord is returned when a guess is not in the dictionary.
+	ErrInvalidWord = errors.New("guess is not a valid word")
+	// ErrHiddenAnswer is returned when guessing in a game whose answer is
+	// kept elsewhere, which only records the feedback it is given.
+	ErrHiddenAnswer = errors.New("answer is hidden")
+)
+
+// Word is a word of NumChars uppercase letters.
+type Word [NumChars]byte
+
+// ParseWord converts a string to a Word. Lowercase letters are converted to
+// uppercase.
+func ParseWord(s string) (Word, error) {
+	var word Word
+	if len(s) != NumChars {
+		return word, ErrInvalidLength
+	}
+	for i := 0; i < NumChars; i++ {
+		ch := s[i]
+		if 'a' <= ch && ch <= 'z' {
+			ch -= 'a' - 'A'
+		}
+		if ch < 'A' || ch > 'Z' {
+			return word, ErrInvalidWord
+		}
+		word[i] = ch
+	}
+	return word, nil
+}
+
+// String returns the word as a string.
+func (w Word) String() string {
+	return string(w[:])
+}
+
+// LetterState is the feedback given for a single letter of a guess.
+type LetterState int
+
+const (
+	// LetterUnknown is the state of a letter that hasn't been guessed.
+	LetterUnknown LetterState = iota
+	// LetterAbsent means that the letter is not in the answer, or that all of
+	// its copies in the answer are already accounted for.
+	LetterAbsent
+	// LetterPresent means that the letter is in the answer, but at another
+	// position.
+	LetterPresent
+	// LetterCorrect means that the letter is in the answer at this position.
+	LetterCorrect
+)
+
+// String returns the name of the letter state, as used in machine-readable
+// output.
+func (s LetterState) String() string {
+	switch s {
+	case LetterUnknown:
+		return "unknown"
+	case LetterAbsent:
+		return "absent"
+	case LetterPresent:
+		return "present"
+	case LetterCorrect:
+		return "correct"
+	default:
+		return "invalid"
+	}
+}
+
+// Feedback is the state of each letter of a guess.
+type Feedback [NumChars]LetterState
+
+// Pattern encodes the feedback as one character per letter, as stored in the
+// database: C for correct, P for present and A for absent.
+func (f Feedback) Pattern() string {
+	var b [NumChars]byte
+	for i, s := range f {
+		switch s {
+		case LetterCorrect:
+			b[i] = 'C'
+		case LetterPresent:
+			b[i] = 'P'
+		case LetterAbsent:
+			b[i] = 'A'
+		default:
+			b[i] = '?'
+		}
+	}
+	return string(b[:])
+}
+
+// Solved checks if every letter of the guess was correct.
+func (f Feedback) Solved() bool {
+	for _, s := range f {
+		if s != LetterCorrect {
+			return false
+		}
+	}
+	return true
+}
+
+// Evaluate computes the feedback for a guess against the answer. Repeated
+// letters are only marked present as many times as they occur in the answer,
+// with correctly placed letters taking priority.
+func Evaluate(guess, answer Word) Feedback {
+	var feedback Feedback
+	letters := answer
+
+	// Mark LetterAbsent.
+	for i := 0; i < NumChars; i++ {
+		feedback[i] = LetterAbsent
+	}
+
+	// Mark LetterCorrect.
+	for i := 0; i < NumChars; i++ {
+		if guess[i] == answer[i] {
+			feedback[i] = LetterCorrect
+			letters[i] = 0
+		}
+	}
+
+	// Mark LetterPresent.
+	for i := 0; i < NumChars; i++ {
+		if feedback[i] == LetterCorrect {
+			continue
+		}
+		if foundIdx := bytes.IndexByte(letters[:], guess[i]); foundIdx != -1 {
+			feedback[i] = LetterPresent
+			letters[foundIdx] = 0
+		}
+	}
+
+	return feedback
+}
+
+// Score returns the number of points earned for a game. This must be kept in
+// sync with the GetTotalScore query.
+func Score(numGuesses int, won bool) int {
+	if !won {
+		return 0
+	}
+	return 10 * (11 - numGuesses)
+}
+
+// State is the state of a game.
+type State int
+
+const (
+	// StateInProgress means that the game hasn't ended yet.
+	StateInProgress State = iota
+	// StateWon means that the answer was guessed.
+	StateWon
+	// StateLost means that all guesses were used without finding the answer.
+	StateLost
+)
+
+// Dictionary decides which words are accepted as guesses.
+type Dictionary interface {
+	IsWord(word string) bool
+}
+
+// Game is a single game of clidle.
+type Game struct {
+	answer     Word
+	dictionary Dictionary
+	ultraHard  bool
+	// hidden is set for games whose answer is kept elsewhere, until it is
+	// revealed.
+	hidden    bool
+	guesses   []Word
+	feedbacks []Feedback
+}
+
+// New creates a game with the given answer. Guesses are checked against the
+// dictionary; if it is nil, any word is accepted.
+func New(answer Word, dictionary Dictionary) *Game {
+	return &Game{
+		answer:     answer,
+		dictionary: dictionary,
+		guesses:    make([]Word, 0, NumGuesses),
+		feedbacks:  make([]Feedback, 0, NumGuesses),
+	}
+}
+
+// NewHidden creates a game whose answer is kept elsewhere, such as by a server.
+// Its guesses are judged there and recorded with Record, and its answer is
+// unknown until it is revealed with Reveal.
+func NewHidden() *Game {
+	g := New(Word{}, nil)
+	g.hidden = true
+	return g
+}
+
+// Record records a guess of a hidden game, along with the feedback it was
+// given. The guess is assumed to have been checked where it was judged.
+func (g *Game) Record(guess Word, feedback Feedback) error {
+	if !g.hidden {
+		return errors.New("only guesses of hidden games can be recorded")
+	}
+	if g.State() != StateInProgress {
+		return ErrGameOver
+	}
+	g.guesses = append(g.guesses, guess)
+	g.feedbacks = append(g.feedbacks, feedback)
+	return nil
+}
+
+// Reveal makes the answer of a hidden game known, usually once it is over.
+func (g *Game) Reveal(answer Word) {
+	g.answer = answer
+	g.hidden = false
+}
+
+// IsHidden checks whether the answer of the game is kept elsewhere, and hasn't
+// been revealed yet.
+func (g *Game) IsHidden() bool {
+	return g.hidden
+}
+
+// SetUltraHard enables or disables ultra-hard mode, in which guesses must be
+// consistent with the feedback for earlier guesses. Guesses that aren't are
+// rejected with a *Violation.
+func (g *Game) SetUltraHard(ultraHard bool) {
+	g.ultraHard = ultraHard
+}
+
+// Clone returns a copy of the game, whose guesses can be made without
+// affecting the original.
+func (g *Game) Clone() *Game {
+	c := *g
+	c.guesses = append(make([]Word, 0, NumGuesses), g.guesses...)
+	c.feedbacks = append(make([]Feedback, 0, NumGuesses), g.feedbacks...)
+	return &c
+}
+
+// Guess makes a guess, and returns the feedback for it. Invalid guesses don't
+// count towards the number of guesses made.
+func (g *Game) Guess(s string) (Feedback, error) {
+	if g.State() != StateInProgress {
+		return Feedback{}, ErrGameOver
+	}
+	if g.hidden {
+		return Feedback{}, ErrHiddenAnswer
+	}
+	word, err := ParseWord(s)
+	if err != nil {
+		return Feedback{}, err
+	}
+	if g.dictionary != nil && !g.dictionary.IsWord(word.String()) {
+		return Feedback{}, ErrInvalidWord
+	}
+	if g.ultraHard {
+		if err := g.Constraints().Check(word); err != nil {
+			return Feedback{}, err
+		}
+	}
+
+	feedback := Evaluate(word, g.answer)
+	g.guesses = append(g.guesses, word)
+	g.feedbacks = append(g.feedbacks, feedback)
+	return feedback, nil
+}
+
+// State returns whether the game is in progress, won or lost.
+func (g *Game) State() State {
+	if n := len(g.feedbacks); n > 0 && g.feedbacks[n-1].Solved() {
+		return StateWon
+	} else if n == NumGuesses {
+		return StateLost
+	}
+	return StateInProgress
+}
+
+// Answer returns the word that has to be guessed, or the zero Word if it is
+// hidden.
+func (g *Game) Answer() Word {
+	return g.answer
+}
+
+// Guesses returns the guesses made so far.
+func (g *Game) Guesses() []Word {
+	return g.guesses
+}
+
+// Feedbacks returns the feedback for each of the guesses made so far.
+func (g *Game) Feedbacks() []Feedback {
+	return g.feedbacks
+}
+
+// Score returns the number of points earned for the game, which is zero until
+// the game is won.
+func (g *Game) Score() int {
+	return Score(len(g.guesses), g.State() == StateWon)
+}
+
+// Constraints returns what the feedback for the guesses made so far reveals
+// about the answer.
+func (g *Game) Constraints() Constraints {
+	var c Constraints
+	for i := range c.maxCounts {
+		c.maxCounts[i] = NumChars
+	}
+	for i, guess := range g.guesses {
+		c.add(guess, g.feedbacks[i])
+	}
+	return c
+}
+
+// Constraints are the facts about the answer that ultra-hard mode holds
+// guesses to: letters known to be absent, the most copies of a letter that the
+// answer can contain, positions where a letter was already marked present, and
+// letters already marked correct. Letters are indexed by ch - 'A'.
+type Constraints struct {
+	maxCounts [26]int
+	excluded  [26][NumChars]bool
+	correct   Word
+}
+
+// add records the feedback for a guess. A letter that is marked absent caps
+// its count at the number of its copies that were marked present or correct
+// in the same guess, so that it is only fully excluded if none were.
+func (c *Constraints) add(guess Word, feedback Feedback) {
+	var found, absent [26]int
+	for i, ch := range guess {
+		letter := ch - 'A'
+		switch feedback[i] {
+		case LetterAbsent:
+			absent[letter]++
+		case LetterPresent:
+			found[letter]++
+			c.excluded[letter][i] = true
+		case LetterCorrect:
+			found[letter]++
+			c.correct[i] = ch
+		}
+	}
+	for letter := range absent {
+		if absent[letter] > 0 {
+			c.maxCounts[letter] = min(c.maxCounts[letter], found[letter])
+		}
+	}
+}
+
+// Correct returns the letter known to be in the answer at each position, or
+// zero where it isn't known yet.
+func (c Constraints) Correct() Word {
+	return c.correct
+}
+
+// Check returns a *Violation describing the first constraint that the guess
+// doesn't satisfy, or nil if it satisfies all of them.
+func (c Constraints) Check(guess Word) error {
+	for i, ch := range c.correct {
+		if ch != 0 && guess[i] != ch {
+			return &Violation{Kind: ViolationCorrect, Letter: ch, Position: i}
+		}
+	}
+	var counts [26]int
+	for i, ch := range guess {
+		letter := ch - 'A'
+		if c.maxCounts[letter] == 0 {
+			return &Violation{Kind: ViolationAbsent, Letter: ch}
+		}
+		if c.excluded[letter][i] {
+			return &Violation{Kind: ViolationPosition, Letter: ch, Position: i}
+		}
+		counts[letter]++
+	}
+	for i, ch := range guess {
+		letter := ch - 'A'
+		if counts[letter] > c.maxCounts[letter] {
+			return &Violation{Kind: ViolationCount, Letter: guess[i], Count: c.maxCounts[letter]}
+		}
+	}
+	return nil
+}
+
+// ViolationKind is the kind of constraint that a guess violates.
+type ViolationKind int
+
+const (
+	// ViolationAbsent means that the guess contains a letter that is known
+	// not to be in the answer.
+	ViolationAbsent ViolationKind = iota
+	// ViolationPosition means that the guess places a letter where it was
+	// already marked present.
+	ViolationPosition
+	// ViolationCount means that the guess contains more copies of a letter
+	// than the answer can.
+	ViolationCount
+	// ViolationCorrect means that the guess doesn't keep a letter where it
+	// was already marked correct.
+	ViolationCorrect
+)
+
+// Violation is returned in ultra-hard mode for a guess that isn't consistent
+// with earlier feedback.
+type Violation struct {
+	Kind ViolationKind
+	// Letter is the letter that violates the constraint.
+	Letter byte
+	// Position is the zero-based position of the letter, for
+	// ViolationPosition and ViolationCorrect.
+	Position int
+	// Count is the most copies of the letter the answer can contain, for
+	// ViolationCount.
+	Count int
+}
+
+func (v *Violation) Error() string {
+	switch v.Kind {
+	case ViolationAbsent:
+		return fmt.Sprintf("guess contains %c, which is not in the answer", v.Letter)
+	case ViolationPosition:
+		return fmt.Sprintf("guess has %c in position %d, where it was already present", v.Letter, v.Position+1)
+	case ViolationCount:
+		return fmt.Sprintf("guess has more than %d copies of %c", v.Count, v.Letter)
+	case ViolationCorrect:
+		return fmt.Sprintf("guess doesn't have %c in position %d, where it was already correct", v.Letter, v.Position+1)
+	default:
+		return "guess violates an unknown constraint"
+	}
+}
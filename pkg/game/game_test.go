@@ -0,0 +1,261 @@
+package game
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	a = LetterAbsent
+	p = LetterPresent
+	c = LetterCorrect
+)
+
+func word(s string) Word {
+	w, err := ParseWord(s)
+	if err != nil {
+		panic(err)
+	}
+	return w
+}
+
+func TestEvaluate(t *testing.T) {
+	tests := []struct {
+		name   string
+		guess  string
+		answer string
+		want   Feedback
+	}{
+		{"all correct", "CRANE", "CRANE", Feedback{c, c, c, c, c}},
+		{"all absent", "CRANE", "MOULD", Feedback{a, a, a, a, a}},
+		{"all present", "ABCDE", "EABCD", Feedback{p, p, p, p, p}},
+		{"mixed", "CRANE", "TRACE", Feedback{p, c, c, a, c}},
+		// A repeated letter is only marked as many times as it occurs in the
+		// answer.
+		{"repeated guess letter, single in answer", "SPEED", "ABIDE", Feedback{a, a, p, a, p}},
+		{"repeated guess letter, one correct and one present", "EERIE", "THEME", Feedback{p, a, a, a, c}},
+		{"repeated guess letter, correct takes priority", "LLAMA", "HELLO", Feedback{p, p, a, a, a}},
+		{"repeated guess letter, correct after present", "ALLOY", "HELLO", Feedback{a, p, c, p, a}},
+		{"repeated answer letter, single in guess", "ROBOT", "FLOOR", Feedback{p, p, a, c, a}},
+		{"repeated letters on both sides", "ABBEY", "BABES", Feedback{p, p, c, c, a}},
+		{"repeated letters, more in guess", "EEEEE", "GEESE", Feedback{a, c, c, a, c}},
+		{"repeated letters, more in answer", "SKILL", "LLAMA", Feedback{a, a, a, p, p}},
+		{"triple letter in guess", "BOBBY", "BLOBS", Feedback{c, p, a, c, a}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Evaluate(word(tt.guess), word(tt.answer))
+			if got != tt.want {
+				t.Errorf("Evaluate(%s, %s) = %v, want %v", tt.guess, tt.answer, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestEvaluateCounts checks invariants of the feedback for every pair of words
+// from a small list: a letter is never marked more often than it occurs in the
+// answer, and correct letters are exactly the matching positions.
+func TestEvaluateCounts(t *testing.T) {
+	words := []string{"CRANE", "TRACE", "SPEED", "ABIDE", "EERIE", "THEME", "LLAMA", "HELLO", "GEESE", "BOBBY", "BLOBS", "MAMMA"}
+	for _, guess := range words {
+		for _, answer := range words {
+			g, ans := word(guess), word(answer)
+			feedback := Evaluate(g, ans)
+
+			var marked, available [256]int
+			for i := 0; i < NumChars; i++ {
+				available[ans[i]]++
+				if (feedback[i] == LetterCorrect) != (g[i] == ans[i]) {
+					t.Errorf("Evaluate(%s, %s): position %d is %v", guess, answer, i, feedback[i])
+				}
+				if feedback[i] != LetterAbsent {
+					marked[g[i]]++
+				}
+			}
+			for ch := range marked {
+				if marked[ch] > available[ch] {
+					t.Errorf("Evaluate(%s, %s): %c marked %d times, but occurs %d times", guess, answer, ch, marked[ch], available[ch])
+				}
+			}
+			if feedback.Solved() != (guess == answer) {
+				t.Errorf("Evaluate(%s, %s).Solved() = %v", guess, answer, feedback.Solved())
+			}
+		}
+	}
+}
+
+func TestParseWord(t *testing.T) {
+	if w, err := ParseWord("crane"); err != nil || w != word("CRANE") {
+		t.Errorf("ParseWord(crane) = %v, %v", w, err)
+	}
+	if _, err := ParseWord("CRAN"); !errors.Is(err, ErrInvalidLength) {
+		t.Errorf("ParseWord(CRAN) error = %v, want %v", err, ErrInvalidLength)
+	}
+	if _, err := ParseWord("CR4NE"); !errors.Is(err, ErrInvalidWord) {
+		t.Errorf("ParseWord(CR4NE) error = %v, want %v", err, ErrInvalidWord)
+	}
+}
+
+type dictionary map[string]bool
+
+func (d dictionary) IsWord(word string) bool {
+	return d[word]
+}
+
+func TestGameWin(t *testing.T) {
+	g := New(word("TRACE"), dictionary{"CRANE": true, "TRACE": true})
+
+	if _, err := g.Guess("XXXXX"); !errors.Is(err, ErrInvalidWord) {
+		t.Fatalf("Guess(XXXXX) error = %v, want %v", err, ErrInvalidWord)
+	}
+	if _, err := g.Guess("CRA"); !errors.Is(err, ErrInvalidLength) {
+		t.Fatalf("Guess(CRA) error = %v, want %v", err, ErrInvalidLength)
+	}
+	if n := len(g.Guesses()); n != 0 {
+		t.Fatalf("invalid guesses were counted: %d", n)
+	}
+
+	if feedback, err := g.Guess("crane"); err != nil || feedback != (Feedback{p, c, c, a, c}) {
+		t.Fatalf("Guess(crane) = %v, %v", feedback, err)
+	}
+	if state := g.State(); state != StateInProgress {
+		t.Fatalf("State() = %v, want %v", state, StateInProgress)
+	}
+	clone := g.Clone()
+	if _, err := clone.Guess("TRACE"); err != nil || clone.State() != StateWon || len(g.Guesses()) != 1 {
+		t.Fatalf("guessing in a clone changed the game: %v, %v", g.Guesses(), err)
+	}
+	if _, err := g.Guess("TRACE"); err != nil {
+		t.Fatalf("Guess(TRACE) error = %v", err)
+	}
+	if state := g.State(); state != StateWon {
+		t.Fatalf("State() = %v, want %v", state, StateWon)
+	}
+	if score := g.Score(); score != 90 {
+		t.Errorf("Score() = %d, want 90", score)
+	}
+	if _, err := g.Guess("CRANE"); !errors.Is(err, ErrGameOver) {
+		t.Errorf("Guess after win error = %v, want %v", err, ErrGameOver)
+	}
+	if answer := g.Answer().String(); answer != "TRACE" {
+		t.Errorf("Answer() = %s, want TRACE", answer)
+	}
+}
+
+func TestGameLoss(t *testing.T) {
+	g := New(word("TRACE"), nil)
+	for i := 0; i < NumGuesses; i++ {
+		if state := g.State(); state != StateInProgress {
+			t.Fatalf("State() after %d guesses = %v, want %v", i, state, StateInProgress)
+		}
+		if _, err := g.Guess("ZZZZZ"); err != nil {
+			t.Fatalf("Guess(ZZZZZ) error = %v", err)
+		}
+	}
+	if state := g.State(); state != StateLost {
+		t.Fatalf("State() = %v, want %v", state, StateLost)
+	}
+	if score := g.Score(); score != 0 {
+		t.Errorf("Score() = %d, want 0", score)
+	}
+	if _, err := g.Guess("TRACE"); !errors.Is(err, ErrGameOver) {
+		t.Errorf("Guess after loss error = %v, want %v", err, ErrGameOver)
+	}
+}
+
+func TestUltraHardCorrect(t *testing.T) {
+	g := New(word("ABIDE"), nil)
+	g.SetUltraHard(true)
+	if _, err := g.Guess("AMPLE"); err != nil {
+		t.Fatal(err)
+	}
+	if correct := g.Constraints().Correct(); correct != (Word{'A', 0, 0, 0, 'E'}) {
+		t.Errorf("Correct() = %q, want A___E", correct)
+	}
+	var violation *Violation
+	if _, err := g.Guess("EMAIL"); !errors.As(err, &violation) || *violation != (Violation{Kind: ViolationCorrect, Letter: 'A', Position: 0}) {
+		t.Errorf("Guess(EMAIL) error = %v, want A kept in position 1", err)
+	}
+	if _, err := g.Guess("ABIDE"); err != nil {
+		t.Errorf("Guess(ABIDE) error = %v", err)
+	}
+}
+
+func TestGameHidden(t *testing.T) {
+	g := NewHidden()
+	if _, err := g.Guess("CRANE"); !errors.Is(err, ErrHiddenAnswer) {
+		t.Fatalf("Guess error = %v, want %v", err, ErrHiddenAnswer)
+	}
+	if err := g.Record(word("CRANE"), Evaluate(word("CRANE"), word("TRACE"))); err != nil {
+		t.Fatalf("Record(CRANE) error = %v", err)
+	}
+	if err := g.Record(word("TRACE"), Evaluate(word("TRACE"), word("TRACE"))); err != nil {
+		t.Fatalf("Record(TRACE) error = %v", err)
+	}
+	if state := g.State(); state != StateWon {
+		t.Fatalf("State() = %v, want %v", state, StateWon)
+	}
+	if answer := g.Answer(); answer != (Word{}) || !g.IsHidden() {
+		t.Fatalf("Answer() = %q before reveal, want it hidden", answer)
+	}
+	g.Reveal(word("TRACE"))
+	if answer := g.Answer(); answer != word("TRACE") || g.IsHidden() {
+		t.Errorf("Answer() = %q after reveal, want TRACE", answer)
+	}
+	if err := New(word("TRACE"), nil).Record(word("CRANE"), Feedback{}); err == nil {
+		t.Error("Record on a game with a known answer succeeded")
+	}
+}
+
+func TestScore(t *testing.T) {
+	for numGuesses, want := range map[int]int{1: 100, 2: 90, 3: 80, 4: 70, 5: 60, 6: 50} {
+		if got := Score(numGuesses, true); got != want {
+			t.Errorf("Score(%d, true) = %d, want %d", numGuesses, got, want)
+		}
+		if got := Score(numGuesses, false); got != 0 {
+			t.Errorf("Score(%d, false) = %d, want 0", numGuesses, got)
+		}
+	}
+}
+
+func TestUltraHard(t *testing.T) {
+	tests := []struct {
+		name  string
+		guess string
+		want  *Violation
+	}{
+		{"consistent", "ABIDE", nil},
+		{"unrelated letters", "MONTH", nil},
+		{"absent letter", "SLATE", &Violation{Kind: ViolationAbsent, Letter: 'S'}},
+		{"present letter in the same position", "OMEGA", &Violation{Kind: ViolationPosition, Letter: 'E', Position: 2}},
+		// E was present once and absent once, so it can still be used, but
+		// only once.
+		{"repeated letter, single copy", "EDICT", nil},
+		{"repeated letter, too many copies", "EERIE", &Violation{Kind: ViolationCount, Letter: 'E', Count: 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := New(word("ABIDE"), nil)
+			g.SetUltraHard(true)
+			if feedback, err := g.Guess("SPEED"); err != nil || feedback != (Feedback{a, a, p, a, p}) {
+				t.Fatalf("Guess(SPEED) = %v, %v", feedback, err)
+			}
+
+			_, err := g.Guess(tt.guess)
+			if tt.want == nil {
+				if err != nil {
+					t.Errorf("Guess(%s) error = %v, want nil", tt.guess, err)
+				}
+				return
+			}
+			var violation *Violation
+			if !errors.As(err, &violation) || *violation != *tt.want {
+				t.Errorf("Guess(%s) error = %v, want %v", tt.guess, err, tt.want)
+			}
+			if n := len(g.Guesses()); n != 1 {
+				t.Errorf("rejected guess was counted: %d", n)
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/ajeetdsouza/clidle/store"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/pkg/errors"
+)
+
+// _playtimeIdle is how long after a key press the player still counts as
+// playing. Time on the game screen beyond it isn't counted.
+const _playtimeIdle = 2 * time.Minute
+
+// _playtimeSaveInterval is how often the time played is saved, so that little
+// of it is lost if the session ends abruptly.
+const _playtimeSaveInterval = time.Minute
+
+// msgSavePlaytime is sent when the time played should be saved.
+type msgSavePlaytime struct{}
+
+// keepsPlaytime reports whether the time played is kept across sessions. It
+// is kept for the local player and for players who can be recognized when
+// they reconnect, but not for those who connect to a server without a key.
+func (m *model) keepsPlaytime() bool {
+	return m.player != "" || m.remoteIP == ""
+}
+
+// loadPlaytime fetches the time the player has played in earlier sessions.
+func (m *model) loadPlaytime() {
+	if !m.keepsPlaytime() {
+		return
+	}
+	ctx, cancel := context.WithTimeout(m.ctx, 5*time.Second)
+	defer cancel()
+
+	seconds, err := m.store.GetPlaytime(ctx, m.player)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		slog.Error("error fetching time played", slog.Any("error", err))
+	}
+	m.lifetimePlaytime = time.Duration(seconds) * time.Second
+}
+
+// accruePlaytime adds the time spent on the game screen since it was last
+// accrued to the time played, up to _playtimeIdle after the last key press.
+func (m *model) accruePlaytime(now time.Time) {
+	m.playtime += m.unaccruedPlaytime(now)
+	m.playtimeAt = now
+}
+
+// unaccruedPlaytime returns the time played since it was last accrued.
+func (m *model) unaccruedPlaytime(now time.Time) time.Duration {
+	end := m.lastInputAt.Add(_playtimeIdle)
+	if now.Before(end) {
+		end = now
+	}
+	if m.screen != screenGame || !end.After(m.playtimeAt) {
+		return 0
+	}
+	return end.Sub(m.playtimeAt)
+}
+
+// schedulePlaytimeSave returns a tea.Cmd that asks for the time played to be
+// saved after _playtimeSaveInterval.
+func (m *model) schedulePlaytimeSave() tea.Cmd {
+	after := m.clock.After(_playtimeSaveInterval)
+	return func() tea.Msg {
+		select {
+		case <-after:
+			return msgSavePlaytime{}
+		case <-m.ctx.Done():
+			return nil
+		}
+	}
+}
+
+// doSavePlaytime queues adding the whole seconds played since the time played
+// was last saved to the player's total. Writes outlive the session, so it is
+// also called once the program has exited.
+func (m *model) doSavePlaytime() tea.Cmd {
+	m.accruePlaytime(m.clock.Now())
+	delta := (m.playtime - m.playtimeSaved).Truncate(time.Second)
+	if delta <= 0 || !m.keepsPlaytime() {
+		return nil
+	}
+	m.playtimeSaved += delta
+	params := store.AddPlaytimeParams{Player: m.player, Seconds: int64(delta / time.Second)}
+	return m.writes.enqueue(func() tea.Msg {
+		ctx, cancel := context.WithTimeout(m.writeCtx, 5*time.Second)
+		defer cancel()
+		err := store.Retry(ctx, func() error { return m.store.AddPlaytime(ctx, params) })
+		return msgSaved{err: err}
+	})
+}
+
+// totalPlaytime returns the time the player has played, including this
+// session.
+func (m *model) totalPlaytime() time.Duration {
+	return m.lifetimePlaytime + m.playtime + m.unaccruedPlaytime(m.clock.Now())
+}
+
+// formatPlaytime formats a time played coarsely, as in "14h", "1h 5m" or
+// "25m".
+func formatPlaytime(d time.Duration) string {
+	hours, minutes := int(d.Hours()), int(d.Minutes())%60
+	switch {
+	case hours >= 10 || hours > 0 && minutes == 0:
+		return fmt.Sprintf("%dh", hours)
+	case hours > 0:
+		return fmt.Sprintf("%dh %dm", hours, minutes)
+	default:
+		return fmt.Sprintf("%dm", minutes)
+	}
+}
+
+// appendPlaytime appends how long the player has been connected and how long
+// they have played in all to the rows of a stats screen, aligned to the right
+// in the bottom corner.
+func (m *model) appendPlaytime(rows []string) []string {
+	connected := formatPlaytime(m.clock.Now().Sub(m.connectedAt))
+	text := m.styles.subtext.Render(fmt.Sprintf("Connected %s · %s played", connected, formatPlaytime(m.totalPlaytime())))
+	width := lipgloss.Width(lipgloss.JoinVertical(lipgloss.Left, rows...))
+	return append(rows, "", lipgloss.PlaceHorizontal(width, lipgloss.Right, text))
+}
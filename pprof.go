@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	httppprof "net/http/pprof"
+	"runtime/pprof"
+	"time"
+
+	"github.com/charmbracelet/ssh"
+)
+
+// newPprofServer creates the server of the profiling endpoints under
+// /debug/pprof/, as served by net/http/pprof.
+func newPprofServer() *http.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", httppprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", httppprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", httppprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", httppprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", httppprof.Trace)
+	// CPU profiles and traces run for as long as asked, 30 seconds by
+	// default, so writes aren't given a timeout.
+	return &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+}
+
+// isLoopbackAddr reports whether an address given as host:port only accepts
+// connections from the local machine. An empty host listens on every
+// interface, so it isn't.
+func isLoopbackAddr(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// pprofLabelMiddleware labels the goroutines of each session with the player's
+// identity and user name, so that they can be told apart in profiles.
+// Goroutines started by the session, such as its program's, inherit them.
+func pprofLabelMiddleware(trust *keyTrust) func(ssh.Handler) ssh.Handler {
+	return func(next ssh.Handler) ssh.Handler {
+		return func(session ssh.Session) {
+			player := "anonymous"
+			if key := session.PublicKey(); key != nil {
+				player = trust.player(key)
+			}
+			labels := pprof.Labels("player", player, "user", session.User())
+			pprof.Do(session.Context(), labels, func(context.Context) { next(session) })
+		}
+	}
+}
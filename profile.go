@@ -0,0 +1,212 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/charmbracelet/ssh"
+	"github.com/charmbracelet/wish"
+	"github.com/pkg/errors"
+)
+
+const (
+	// _profileFormat identifies profile files.
+	_profileFormat = "clidle-profile"
+	// _profileVersion is the version of the profile file format.
+	_profileVersion = 1
+)
+
+// profileHeader is the first line of a profile file. It is followed by one
+// line of JSON for every game, as sent by clidle sync, and the whole file is
+// compressed with gzip, whose checksum catches files that were corrupted.
+type profileHeader struct {
+	Format     string    `json:"format"`
+	Version    int       `json:"version"`
+	ExportedAt time.Time `json:"exported_at"`
+	Games      int       `json:"games"`
+}
+
+// writeProfile writes a profile file containing the given games.
+func writeProfile(w io.Writer, games []syncGame, exportedAt time.Time) error {
+	gz := gzip.NewWriter(w)
+	encoder := json.NewEncoder(gz)
+	header := profileHeader{
+		Format:     _profileFormat,
+		Version:    _profileVersion,
+		ExportedAt: exportedAt,
+		Games:      len(games),
+	}
+	if err := encoder.Encode(header); err != nil {
+		return err
+	}
+	for _, g := range games {
+		if err := encoder.Encode(g); err != nil {
+			return err
+		}
+	}
+	return gz.Close()
+}
+
+// readProfile checks the header of a profile file, and returns a reader for
+// the games that follow it, as read by importGames.
+func readProfile(r io.Reader) (io.Reader, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "not a profile file")
+	}
+	decoder := json.NewDecoder(gz)
+	var header profileHeader
+	if err := decoder.Decode(&header); err != nil || header.Format != _profileFormat {
+		return nil, errors.New("not a profile file")
+	}
+	if header.Version > _profileVersion {
+		return nil, errors.Errorf("profile file version %d is newer than supported version %d", header.Version, _profileVersion)
+	}
+	return io.MultiReader(decoder.Buffered(), gz), nil
+}
+
+// profileMiddleware handles the export command (ssh host -- export > FILE),
+// which writes a profile file of the connecting player's games, so that they
+// can be imported elsewhere with clidle profile import.
+func profileMiddleware(trust *keyTrust) wish.Middleware {
+	return func(next ssh.Handler) ssh.Handler {
+		return func(session ssh.Session) {
+			args := session.Command()
+			if len(args) == 0 || args[0] != "export" {
+				next(session)
+				return
+			}
+			if len(args) != 1 {
+				wish.Fatalln(session, "usage: export")
+				return
+			}
+			key := session.PublicKey()
+			if key == nil {
+				wish.Fatalln(session, "export requires a public key, which identifies your profile")
+				return
+			}
+			player := trust.player(key)
+
+			queries, err := getStore()
+			if err != nil {
+				wish.Fatalln(session, "could not open database")
+				return
+			}
+			ctx, cancel := context.WithTimeout(session.Context(), time.Minute)
+			defer cancel()
+
+			results, err := listPlayerResults(ctx, queries, player)
+			if err != nil {
+				wish.Fatalln(session, err)
+				return
+			}
+			games, err := exportGames(ctx, queries, results)
+			if err != nil {
+				wish.Fatalln(session, err)
+				return
+			}
+			if err := writeProfile(session, games, time.Now()); err != nil {
+				slog.Error("error exporting profile", slog.String("player", player), slog.Any("error", err))
+			}
+		}
+	}
+}
+
+// runProfile exports the completed games in the local database to a profile
+// file, or imports one into it.
+func runProfile(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: clidle profile export|import")
+	}
+	switch args[0] {
+	case "export":
+		return runProfileExport(args[1:])
+	case "import":
+		return runProfileImport(args[1:])
+	default:
+		return errors.Errorf("unknown profile command: %s", args[0])
+	}
+}
+
+func runProfileExport(args []string) error {
+	flags := flag.NewFlagSet("profile export", flag.ExitOnError)
+	flagOut := flags.String("out", "", "Path to write the profile file to")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *flagOut == "" {
+		return errors.New("--out is required")
+	}
+
+	queries, err := getStoreReadOnly()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	results, err := queries.ListGameResults(ctx)
+	if err != nil {
+		return errors.Wrap(err, "could not read games")
+	}
+	games, err := exportGames(ctx, queries, results)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(*flagOut, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return errors.Wrap(err, "could not create profile file")
+	}
+	if err := writeProfile(f, games, time.Now()); err != nil {
+		f.Close()
+		return errors.Wrap(err, "could not write profile file")
+	}
+	if err := f.Close(); err != nil {
+		return errors.Wrap(err, "could not write profile file")
+	}
+	fmt.Printf("Exported %d games to %s\n", len(games), *flagOut)
+	return nil
+}
+
+func runProfileImport(args []string) error {
+	flags := flag.NewFlagSet("profile import", flag.ExitOnError)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("usage: clidle profile import FILE")
+	}
+
+	f, err := os.Open(flags.Arg(0))
+	if err != nil {
+		return errors.Wrap(err, "could not open profile file")
+	}
+	defer f.Close()
+	r, err := readProfile(f)
+	if err != nil {
+		return err
+	}
+
+	queries, err := getStore()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	result, err := importGames(ctx, queries, EnglishDictionary, "", "", r)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Imported %d new games (%d already present)\n", result.Imported, result.Skipped)
+	if result.Rejected > 0 {
+		fmt.Printf("%d games were rejected as invalid\n", result.Rejected)
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"hash/crc32"
+	"hash/fnv"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// _puzzleVersion is the version of the puzzle code format.
+const _puzzleVersion = 1
+
+const (
+	_puzzleUltraHard = 1 << iota
+	_puzzleFreePlay
+	_puzzleCommonOnly
+)
+
+// puzzle is a game that can be shared as a code, so that a friend can play
+// the same word with the same rules.
+type puzzle struct {
+	answer    string
+	ultraHard bool
+	freePlay  bool
+	maxRarity int
+}
+
+// dictionaryVersion identifies the list of answers, which puzzle codes index
+// into. Codes made with another list would give a different word.
+func dictionaryVersion(dictionary Dictionary) uint16 {
+	h := fnv.New32a()
+	for _, word := range dictionary.commonWords {
+		h.Write([]byte(word))
+	}
+	sum := h.Sum32()
+	return uint16(sum>>16) ^ uint16(sum)
+}
+
+// encodePuzzle returns the code of a puzzle. The code holds the format
+// version, the dictionary version, the word length, the index of the answer
+// and the rules, followed by a checksum to catch typos. The index is masked
+// with the dictionary version, so that it can't be read at a glance.
+func encodePuzzle(dictionary Dictionary, p puzzle) (string, error) {
+	idx := sort.SearchStrings(dictionary.commonWords, p.answer)
+	if idx == len(dictionary.commonWords) || dictionary.commonWords[idx] != p.answer {
+		return "", errors.Errorf("%s is not a possible answer", p.answer)
+	}
+
+	var flags byte
+	if p.ultraHard {
+		flags |= _puzzleUltraHard
+	}
+	if p.freePlay {
+		flags |= _puzzleFreePlay
+	}
+	if p.maxRarity <= _rarityCommon {
+		flags |= _puzzleCommonOnly
+	}
+
+	version := dictionaryVersion(dictionary)
+	b := make([]byte, 0, 8)
+	b = append(b, _puzzleVersion)
+	b = binary.BigEndian.AppendUint16(b, version)
+	b = append(b, _numChars)
+	b = binary.BigEndian.AppendUint16(b, uint16(idx)^version)
+	b = append(b, flags)
+	b = append(b, byte(crc32.ChecksumIEEE(b)))
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// decodePuzzle returns the puzzle with the given code, checking that it was
+// made by a compatible version of clidle.
+func decodePuzzle(dictionary Dictionary, code string) (puzzle, error) {
+	b, err := base64.RawURLEncoding.DecodeString(code)
+	if err != nil || len(b) != 8 || b[7] != byte(crc32.ChecksumIEEE(b[:7])) {
+		return puzzle{}, errors.New("invalid puzzle code, check for typos")
+	}
+	if b[0] != _puzzleVersion {
+		return puzzle{}, errors.Errorf("puzzle code version %d is not supported by this version of clidle", b[0])
+	}
+	version := binary.BigEndian.Uint16(b[1:3])
+	if version != dictionaryVersion(dictionary) {
+		return puzzle{}, errors.New("puzzle code was made with a different dictionary, update clidle to play it")
+	}
+	if b[3] != _numChars {
+		return puzzle{}, errors.Errorf("puzzle code is for %d-letter words", b[3])
+	}
+	idx := int(binary.BigEndian.Uint16(b[4:6]) ^ version)
+	if idx >= len(dictionary.commonWords) {
+		return puzzle{}, errors.New("invalid puzzle code, check for typos")
+	}
+
+	flags := b[6]
+	p := puzzle{
+		answer:    dictionary.commonWords[idx],
+		ultraHard: flags&_puzzleUltraHard != 0,
+		freePlay:  flags&_puzzleFreePlay != 0,
+		maxRarity: _rarityUncommon,
+	}
+	if flags&_puzzleCommonOnly != 0 {
+		p.maxRarity = _rarityCommon
+	}
+	return p, nil
+}
+
+// encodePuzzleCode returns the code of the current game, or an empty string if
+// it has none. Daily puzzles and matches have none, and neither do games whose
+// answer is still hidden.
+func (m *model) encodePuzzleCode() string {
+	if m.daily != "" || m.match != nil || m.game.IsHidden() {
+		return ""
+	}
+	code, _ := encodePuzzle(m.dictionary, puzzle{
+		answer:    m.game.Answer().String(),
+		ultraHard: m.options.ultraHard,
+		freePlay:  m.practice,
+		maxRarity: m.options.maxRarity,
+	})
+	return code
+}
+
+// viewPuzzleCode renders the code of the current game once it is over, so
+// that it can be shared, or returns an empty string if it has none.
+func (m *model) viewPuzzleCode() string {
+	if !m.isGameOver() || m.puzzleCode == "" {
+		return ""
+	}
+	return m.styles.subtext.Render("Puzzle code: " + m.puzzleCode)
+}
@@ -1,19 +1,223 @@
 -- name: CreateGame :one
-INSERT INTO game (answer)
-VALUES (?)
+INSERT INTO game (answer, player, player_name, daily, started_at, max_rarity, daily_number, uuid, remote_ip, api)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 RETURNING *;
 
+-- name: FinishGame :exec
+UPDATE game
+SET finished_at = ?, penalty = ?, share_id = ?, loss_penalty = ?
+WHERE id = ?;
+
+-- name: GetGame :one
+SELECT * FROM game
+WHERE id = ?;
+
+-- name: GetSharedGame :one
+SELECT * FROM game
+WHERE share_id = ? AND finished_at IS NOT NULL;
+
+-- name: ImportGame :one
+INSERT INTO game (uuid, answer, player, player_name, daily, daily_number, started_at, finished_at, penalty, loss_penalty, max_rarity, imported)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, TRUE)
+ON CONFLICT (uuid) DO NOTHING
+RETURNING id;
+
+-- name: SetGameAnswer :exec
+UPDATE game
+SET answer = ?
+WHERE id = ?;
+
+-- name: MarkGameResumed :exec
+UPDATE game
+SET resumed = TRUE
+WHERE id = ?;
+
+-- name: FlagGame :exec
+UPDATE game
+SET flagged = TRUE
+WHERE id = ?;
+
 -- name: CreateGuess :one
-INSERT INTO guess (game_id, guess)
-VALUES (?, ?)
+INSERT INTO guess (game_id, guess, pattern)
+VALUES (?, ?, ?)
 RETURNING *;
 
+-- name: ListGuesses :many
+SELECT * FROM guess
+WHERE game_id = ?
+ORDER BY id;
+
 -- name: GetTotalScore :one
+WITH RECURSIVE game_scores AS (
+    SELECT game.id, MAX(0, 10 * (11 - COUNT(guess.id)) - game.penalty) AS score
+    FROM game
+    INNER JOIN guess victory ON game.id = victory.game_id AND game.answer = victory.guess
+    INNER JOIN guess ON game.id = guess.game_id
+    GROUP BY game.id
+    UNION ALL
+    SELECT id, -loss_penalty AS score
+    FROM game
+    WHERE loss_penalty > 0
+),
+ordered_scores AS MATERIALIZED (
+    SELECT ROW_NUMBER() OVER (ORDER BY id) AS n, score
+    FROM game_scores
+),
+totals (n, total) AS (
+    SELECT 0, 0
+    UNION ALL
+    SELECT ordered_scores.n, MAX(0, totals.total + ordered_scores.score)
+    FROM totals
+    INNER JOIN ordered_scores ON ordered_scores.n = totals.n + 1
+)
+SELECT total FROM totals ORDER BY n DESC LIMIT 1;
+
+-- name: GetDailyLeaderboard :many
+WITH first_games AS (
+    SELECT MIN(id) AS id
+    FROM game
+    WHERE daily = ? AND NOT imported
+    GROUP BY player
+)
+SELECT game.player, game.player_name, COUNT(guess.id) AS num_guesses, game.started_at, game.finished_at
+FROM game
+INNER JOIN first_games ON game.id = first_games.id
+INNER JOIN guess victory ON game.id = victory.game_id AND game.answer = victory.guess
+INNER JOIN guess ON game.id = guess.game_id
+WHERE game.finished_at IS NOT NULL AND NOT game.flagged
+GROUP BY game.id
+ORDER BY num_guesses ASC, julianday(game.finished_at) - julianday(game.started_at) ASC
+LIMIT ?;
+
+-- name: HasFinishedDaily :one
+SELECT CAST(EXISTS(
+    SELECT 1 FROM game
+    WHERE daily = ? AND player = ? AND finished_at IS NOT NULL
+) AS BOOLEAN) AS finished;
+
+-- name: ListGameResults :many
+SELECT game.id, game.answer, game.daily, game.started_at, game.finished_at, game.penalty, game.loss_penalty, game.resumed,
+    COUNT(guess.id) AS num_guesses,
+    CAST(MAX(guess.guess = game.answer) AS BOOLEAN) AS won
+FROM game
+INNER JOIN guess ON game.id = guess.game_id
+GROUP BY game.id
+ORDER BY game.started_at, game.id;
+
+-- name: ListPlayerGameResults :many
+SELECT game.id, game.answer, game.daily, game.started_at, game.finished_at, game.penalty, game.loss_penalty, game.resumed,
+    COUNT(guess.id) AS num_guesses,
+    CAST(MAX(guess.guess = game.answer) AS BOOLEAN) AS won
+FROM game
+INNER JOIN guess ON game.id = guess.game_id
+WHERE game.player = ?
+GROUP BY game.id
+ORDER BY game.started_at, game.id;
+
+-- name: ClaimAnonymousGames :execrows
+UPDATE game
+SET player = ?, remote_ip = NULL
+WHERE player IS NULL AND player_name = ? AND remote_ip = ?;
+
+-- name: MergeAnonymousGames :execrows
+UPDATE game
+SET player = ?, remote_ip = NULL
+WHERE player IS NULL AND player_name = ?;
+
+-- name: MergePlayerGames :execrows
+UPDATE game
+SET player = ?
+WHERE player = ?;
+
+-- name: CreatePlayerMerge :exec
+INSERT INTO player_merge (from_player, to_player, games, merged_by, merged_at)
+VALUES (?, ?, ?, ?, ?);
+
+-- name: GetSkipHome :one
+SELECT skip_home FROM player_preference
+WHERE player = ?;
+
+-- name: SetSkipHome :exec
+INSERT INTO player_preference (player, skip_home)
+VALUES (?, ?)
+ON CONFLICT (player) DO UPDATE SET skip_home = excluded.skip_home;
+
+-- name: GetHideFromWeb :one
+SELECT hide_from_web FROM player_preference
+WHERE player = ?;
+
+-- name: SetHideFromWeb :exec
+INSERT INTO player_preference (player, hide_from_web)
+VALUES (?, ?)
+ON CONFLICT (player) DO UPDATE SET hide_from_web = excluded.hide_from_web;
+
+-- name: GetPlaytime :one
+SELECT seconds FROM player_playtime
+WHERE player = ?;
+
+-- name: AddPlaytime :exec
+INSERT INTO player_playtime (player, seconds)
+VALUES (?, ?)
+ON CONFLICT (player) DO UPDATE SET seconds = seconds + excluded.seconds;
+
+-- name: SaveAutosave :exec
+INSERT INTO game_autosave (game_id, snapshot, saved_at)
+VALUES (?, ?, ?)
+ON CONFLICT (game_id) DO UPDATE SET snapshot = excluded.snapshot, saved_at = excluded.saved_at;
+
+-- name: DeleteAutosave :exec
+DELETE FROM game_autosave
+WHERE game_id = ?;
+
+-- name: GetPlayerAutosave :one
+SELECT game_autosave.game_id, game_autosave.snapshot
+FROM game_autosave
+INNER JOIN game ON game.id = game_autosave.game_id
+WHERE game.player = ? AND game.finished_at IS NULL AND COALESCE(game.daily, '') = ?
+ORDER BY game_autosave.saved_at DESC
+LIMIT 1;
+
+-- name: GetWebDailyLeaderboard :many
+WITH first_games AS (
+    SELECT MIN(id) AS id
+    FROM game
+    WHERE daily = ? AND NOT imported
+    GROUP BY player
+)
+SELECT game.player, game.player_name, COUNT(guess.id) AS num_guesses, game.started_at, game.finished_at
+FROM game
+INNER JOIN first_games ON game.id = first_games.id
+INNER JOIN guess victory ON game.id = victory.game_id AND game.answer = victory.guess
+INNER JOIN guess ON game.id = guess.game_id
+LEFT JOIN player_preference ON game.player = player_preference.player
+WHERE game.finished_at IS NOT NULL AND NOT game.flagged
+    AND NOT COALESCE(player_preference.hide_from_web, FALSE)
+GROUP BY game.id
+ORDER BY num_guesses ASC, julianday(game.finished_at) - julianday(game.started_at) ASC
+LIMIT ?;
+
+-- name: GetTopScores :many
 WITH game_scores AS (
-    SELECT game.id, (10 * (11 - COUNT(guess.id))) AS score
+    SELECT game.player, MAX(0, 10 * (11 - COUNT(guess.id)) - game.penalty) AS score
     FROM game
     INNER JOIN guess victory ON game.id = victory.game_id AND game.answer = victory.guess
     INNER JOIN guess ON game.id = guess.game_id
+    WHERE game.player IS NOT NULL AND game.finished_at IS NOT NULL AND NOT game.flagged AND NOT game.imported
     GROUP BY game.id
 )
-SELECT SUM(score) FROM game_scores;
+SELECT game_scores.player,
+    CAST(COALESCE((SELECT player_name FROM game WHERE game.player = game_scores.player ORDER BY game.id DESC LIMIT 1), '') AS TEXT) AS player_name,
+    CAST(SUM(game_scores.score) AS INTEGER) AS points,
+    COUNT(*) AS wins
+FROM game_scores
+LEFT JOIN player_preference ON game_scores.player = player_preference.player
+WHERE NOT COALESCE(player_preference.hide_from_web, FALSE)
+GROUP BY game_scores.player
+ORDER BY points DESC
+LIMIT ?;
+
+-- name: CountDailySolves :one
+SELECT COUNT(DISTINCT game.id) AS solves
+FROM game
+INNER JOIN guess victory ON game.id = victory.game_id AND game.answer = victory.guess
+WHERE game.daily = ? AND game.finished_at IS NOT NULL AND NOT game.flagged AND NOT game.imported;
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+
+	"github.com/ajeetdsouza/clidle/pkg/game"
+	"github.com/ajeetdsouza/clidle/store"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/pkg/errors"
+)
+
+// referee picks the answers of every session on the server and judges their
+// guesses, so that the answer of a game never reaches the model playing it
+// until the game is over. Models only get the feedback for their guesses, and
+// record it in a hidden game.
+type referee struct {
+	answers Dictionary
+
+	mu    sync.Mutex
+	next  uint64
+	games map[uint64]refereeGame
+}
+
+// newReferee creates a referee picking answers from the dictionary.
+func newReferee(answers Dictionary) *referee {
+	return &referee{answers: answers, games: make(map[uint64]refereeGame)}
+}
+
+// refereeGame is the game of a session, along with the round in which it was
+// started.
+type refereeGame struct {
+	round uint64
+	game  *game.Game
+}
+
+// errStaleRound is returned when a guess is judged for a game that the session
+// has since replaced.
+var errStaleRound = errors.New("game has been replaced")
+
+// judgement is the outcome of a guess judged by the referee. The answer is only
+// set once the game is over.
+type judgement struct {
+	feedback game.Feedback
+	answer   game.Word
+	over     bool
+}
+
+// join registers a new session, and returns the key under which its games are
+// kept.
+func (r *referee) join() uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.next++
+	return r.next
+}
+
+// start starts a new game for the session, replacing the previous one, and
+// returns its round. The answer is the daily puzzle of the given date, or a
+// random word if it is zero. Guesses are checked against the dictionary; if it
+// is nil, any word is accepted.
+func (r *referee) start(session uint64, daily time.Time, dictionary game.Dictionary, ultraHard bool) uint64 {
+	var answer game.Word
+	if daily.IsZero() {
+		copy(answer[:], r.answers.GetRandomCommonWord())
+	} else {
+		copy(answer[:], r.answers.GetDailyWord(daily))
+	}
+	g := game.New(answer, dictionary)
+	g.SetUltraHard(ultraHard)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	round := r.games[session].round + 1
+	r.games[session] = refereeGame{round: round, game: g}
+	return round
+}
+
+// judge makes a guess in the session's game, as long as it is still the one
+// started in the given round. Invalid guesses are rejected with the same
+// errors as game.Game.Guess.
+func (r *referee) judge(session, round uint64, guess string) (judgement, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rg, ok := r.games[session]
+	if !ok {
+		return judgement{}, errors.New("no game in progress")
+	}
+	if rg.round != round {
+		return judgement{}, errStaleRound
+	}
+	g := rg.game
+	feedback, err := g.Guess(guess)
+	if err != nil {
+		return judgement{}, err
+	}
+	j := judgement{feedback: feedback, over: g.State() != game.StateInProgress}
+	if j.over {
+		j.answer = g.Answer()
+	}
+	return j, nil
+}
+
+// leave forgets the session's game, e.g. when it disconnects, and returns its
+// answer.
+func (r *referee) leave(session uint64) game.Word {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var answer game.Word
+	if rg, ok := r.games[session]; ok {
+		answer = rg.game.Answer()
+	}
+	delete(r.games, session)
+	return answer
+}
+
+// msgJudged is sent when the referee has judged a guess.
+type msgJudged struct {
+	game      *game.Game
+	guess     game.Word
+	judgement judgement
+	err       error
+}
+
+// judgeGuess sends a guess to the referee. Input is ignored until it has been
+// judged.
+func (m *model) judgeGuess(guess game.Word) tea.Cmd {
+	m.judging = true
+	g, session, round := m.game, m.refereeSession, m.refereeRound
+	return func() tea.Msg {
+		j, err := m.referee.judge(session, round, guess.String())
+		return msgJudged{game: g, guess: guess, judgement: j, err: err}
+	}
+}
+
+// updateJudged records the feedback for a guess judged by the referee, and
+// reveals the answer if the game is over. Judgements of a game that has since
+// been replaced are ignored.
+func (m *model) updateJudged(msg msgJudged) tea.Cmd {
+	if msg.game != m.game || errors.Is(msg.err, errStaleRound) {
+		return nil
+	}
+	m.judging = false
+	if msg.err != nil {
+		return m.rejectGuess(msg.guess, msg.err)
+	}
+	if err := m.game.Record(msg.guess, msg.judgement.feedback); err != nil {
+		return nil
+	}
+	if msg.judgement.over {
+		m.game.Reveal(msg.judgement.answer)
+		m.puzzleCode = m.encodePuzzleCode()
+	}
+	return m.acceptGuess(msg.guess, msg.judgement.feedback)
+}
+
+// leaveReferee forfeits the current game when the session ends. Its answer is
+// revealed, so that it can be printed like that of any unfinished game, and
+// saved along with it.
+func (m *model) leaveReferee() {
+	if m.referee == nil {
+		return
+	}
+	answer := m.referee.leave(m.refereeSession)
+	if !m.game.IsHidden() {
+		return
+	}
+	m.game.Reveal(answer)
+	record := m.record
+	m.writes.enqueue(func() tea.Msg {
+		ctx, cancel := context.WithTimeout(m.writeCtx, 5*time.Second)
+		defer cancel()
+		if err := m.saveAnswer(ctx, record, answer); err != nil {
+			return msgSaved{err: err}
+		}
+		return nil
+	})
+}
+
+// saveAnswer saves the answer of a refereed game, once it has been revealed.
+// Games that weren't saved yet have nothing to save it to.
+func (m *model) saveAnswer(ctx context.Context, record *gameRecord, answer game.Word) error {
+	if record.id == 0 {
+		return nil
+	}
+	params := store.SetGameAnswerParams{Answer: sql.NullString{String: answer.String(), Valid: true}, ID: record.id}
+	err := store.Retry(ctx, func() error { return m.store.SetGameAnswer(ctx, params) })
+	return errors.Wrap(err, "could not save answer")
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ajeetdsouza/clidle/pkg/game"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/pkg/errors"
+)
+
+// runRender prints the grid of a game with the given answer and guesses, with
+// every row colored, and exits. It is meant for documentation and bug reports,
+// and is intentionally left out of the usage. With --export-image, the grid is
+// written to the image instead, so that images can be made by scripts.
+func runRender(args []string, options options) error {
+	flags := flag.NewFlagSet("render", flag.ExitOnError)
+	flagWord := flags.String("word", "", "The answer of the game")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	dictionary := EnglishDictionary.WithMaxRarity(options.maxRarity)
+	answer, err := game.ParseWord(strings.ToUpper(*flagWord))
+	if err != nil || !dictionary.IsWord(answer.String()) {
+		return errors.Errorf("invalid answer: %q", *flagWord)
+	}
+
+	renderer := lipgloss.NewRenderer(os.Stdout)
+	setColorProfile(renderer, options.color, os.Getenv("NO_COLOR") != "")
+	m := newModel(context.Background(), nil, dictionary, options, renderer)
+
+	g := game.New(answer, dictionary)
+	for _, guess := range flags.Args() {
+		if _, err := g.Guess(strings.ToUpper(guess)); err != nil {
+			return errors.Wrapf(err, "invalid guess %q", guess)
+		}
+	}
+	if options.exportImage != "" {
+		return writeBoardImage(options.exportImage, answer, g.Guesses())
+	}
+	fmt.Println(m.viewBoard(answer, g.Guesses()))
+	return nil
+}
+
+// viewBoard renders the rows of a game with the given answer and guesses,
+// with every row colored. Unlike the grid, it has no empty rows.
+func (m *model) viewBoard(answer game.Word, guesses []game.Word) string {
+	rows := make([]string, len(guesses))
+	for i, guess := range guesses {
+		rows[i] = m.viewGridRowFilled(guess, game.Evaluate(guess, answer), _gridLayoutTiles)
+	}
+	if m.options.tileGap > 0 {
+		for i := 0; i < len(rows)-1; i++ {
+			rows[i] = m.styles.rowGap.Render(rows[i])
+		}
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, rows...)
+}
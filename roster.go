@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"github.com/pkg/errors"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// role is what a player named in the roster may do on the server.
+type role string
+
+const (
+	_rolePlayer role = "player"
+	// _roleAdmin is for the server's operators, who are let in even when
+	// the server is full.
+	_roleAdmin role = "admin"
+)
+
+// rosterEntry names a player in the roster, who is matched by the fingerprint
+// of their key, or by a principal of their certificate.
+type rosterEntry struct {
+	Fingerprint string `json:"fingerprint,omitempty"`
+	Principal   string `json:"principal,omitempty"`
+	Name        string `json:"name,omitempty"`
+	Role        role   `json:"role,omitempty"`
+}
+
+// roster maps the players of a family or team server to names and roles, so
+// that players who share a key or a certificate authority are told apart. It
+// is read from a file on startup, and again on SIGHUP. A nil *roster names no
+// one.
+type roster struct {
+	path string
+
+	mu           sync.RWMutex
+	fingerprints map[string]rosterEntry
+	principals   map[string]rosterEntry
+}
+
+// loadRoster reads the roster from the given file.
+func loadRoster(path string) (*roster, error) {
+	r := &roster{path: path}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// reload reads the roster from its file again. If it can't be read, the roster
+// is left as it was.
+func (r *roster) reload() error {
+	b, err := os.ReadFile(r.path)
+	if err != nil {
+		return errors.Wrap(err, "could not read roster")
+	}
+	var file struct {
+		Players []rosterEntry `json:"players"`
+	}
+	if err := json.Unmarshal(b, &file); err != nil {
+		return errors.Wrapf(err, "invalid roster %s", r.path)
+	}
+
+	fingerprints := make(map[string]rosterEntry)
+	principals := make(map[string]rosterEntry)
+	for i, entry := range file.Players {
+		if entry.Role == "" {
+			entry.Role = _rolePlayer
+		}
+		if entry.Role != _rolePlayer && entry.Role != _roleAdmin {
+			return errors.Errorf("invalid roster %s: player %d has unknown role %q", r.path, i+1, entry.Role)
+		}
+		switch {
+		case entry.Fingerprint != "" && entry.Principal == "":
+			fingerprints[entry.Fingerprint] = entry
+		case entry.Principal != "" && entry.Fingerprint == "":
+			principals[entry.Principal] = entry
+		default:
+			return errors.Errorf("invalid roster %s: player %d needs either a fingerprint or a principal", r.path, i+1)
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.fingerprints, r.principals = fingerprints, principals
+	return nil
+}
+
+// reloadOnHangup reloads the roster whenever the process receives SIGHUP,
+// until stop is closed.
+func (r *roster) reloadOnHangup(stop <-chan struct{}) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	go func() {
+		defer signal.Stop(hangup)
+		for {
+			select {
+			case <-hangup:
+				if err := r.reload(); err != nil {
+					slog.Error("could not reload roster, keeping the previous one", slog.Any("error", err))
+				} else {
+					slog.Info("reloaded roster", slog.String("path", r.path))
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// lookup returns the entry of the player with the given key. Principals are
+// only matched for certificates signed by a trusted authority.
+func (r *roster) lookup(key gossh.PublicKey, trust *keyTrust) (rosterEntry, bool) {
+	if r == nil || key == nil {
+		return rosterEntry{}, false
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if cert, ok := key.(*gossh.Certificate); ok && trust.trusted(cert) {
+		for _, principal := range cert.ValidPrincipals {
+			if entry, ok := r.principals[principal]; ok {
+				return entry, true
+			}
+		}
+	}
+	entry, ok := r.fingerprints[gossh.FingerprintSHA256(key)]
+	return entry, ok
+}
+
+// admin reports whether the player with the given key is an admin.
+func (r *roster) admin(key gossh.PublicKey, trust *keyTrust) bool {
+	entry, ok := r.lookup(key, trust)
+	return ok && entry.Role == _roleAdmin
+}
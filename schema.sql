@@ -1,10 +1,59 @@
 CREATE TABLE IF NOT EXISTS game (
     id INTEGER PRIMARY KEY AUTOINCREMENT,
-    answer TEXT
+    answer TEXT,
+    player TEXT,
+    player_name TEXT,
+    daily TEXT,
+    started_at TIMESTAMP,
+    finished_at TIMESTAMP,
+    flagged BOOLEAN NOT NULL DEFAULT FALSE,
+    penalty INTEGER NOT NULL DEFAULT 0,
+    max_rarity INTEGER NOT NULL DEFAULT 1,
+    share_id TEXT,
+    loss_penalty INTEGER NOT NULL DEFAULT 0,
+    daily_number INTEGER,
+    uuid TEXT,
+    imported BOOLEAN NOT NULL DEFAULT FALSE,
+    remote_ip TEXT,
+    api BOOLEAN NOT NULL DEFAULT FALSE,
+    resumed BOOLEAN NOT NULL DEFAULT FALSE
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS game_share_id ON game (share_id);
+
+CREATE UNIQUE INDEX IF NOT EXISTS game_uuid ON game (uuid);
+
+-- The snapshot is the JSON of the board, as in the local snapshot file.
+CREATE TABLE IF NOT EXISTS game_autosave (
+    game_id INTEGER PRIMARY KEY REFERENCES game(id),
+    snapshot TEXT NOT NULL,
+    saved_at TIMESTAMP NOT NULL
 );
 
 CREATE TABLE IF NOT EXISTS guess (
     id INTEGER PRIMARY KEY AUTOINCREMENT,
     game_id INTEGER REFERENCES game(id),
-    guess TEXT
+    guess TEXT,
+    pattern TEXT
+);
+
+CREATE TABLE IF NOT EXISTS player_merge (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    from_player TEXT NOT NULL,
+    to_player TEXT NOT NULL,
+    games INTEGER NOT NULL,
+    merged_by TEXT NOT NULL,
+    merged_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS player_preference (
+    player TEXT PRIMARY KEY,
+    skip_home BOOLEAN NOT NULL DEFAULT FALSE,
+    hide_from_web BOOLEAN NOT NULL DEFAULT FALSE
+);
+
+-- The local player, who has no key, is stored as ''.
+CREATE TABLE IF NOT EXISTS player_playtime (
+    player TEXT PRIMARY KEY,
+    seconds INTEGER NOT NULL DEFAULT 0
 );
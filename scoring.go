@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/ajeetdsouza/clidle/pkg/game"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// doToggleScoring shows or hides the scoring screen.
+func (m *model) doToggleScoring() tea.Cmd {
+	if m.screen == screenScoring {
+		m.closeScreen()
+	} else {
+		m.openScreen(screenScoring)
+	}
+	return nil
+}
+
+// updateScoring handles key presses while the scoring screen is shown. Any key
+// other than quitting closes it.
+func (m *model) updateScoring(msg tea.KeyMsg) tea.Cmd {
+	if msg.Type == tea.KeyCtrlC {
+		return m.doExit()
+	}
+	m.closeScreen()
+	return nil
+}
+
+// viewScoring renders the scoring screen, including a border. The points are
+// computed with the same functions that score games, so that the explanation
+// always matches the rules in effect.
+func (m *model) viewScoring() string {
+	rows := []string{m.styles.text.Render("Scoring"), ""}
+	if m.practice {
+		rows = append(rows, m.styles.subtext.Render("Free play games aren't scored."))
+		rows = m.appendPlaytime(rows)
+		return m.styles.box.Render(lipgloss.JoinVertical(lipgloss.Left, rows...))
+	}
+
+	for numGuesses := 1; numGuesses <= _numGuesses; numGuesses++ {
+		label := fmt.Sprintf("Solved in %d guesses", numGuesses)
+		if numGuesses == 1 {
+			label = "Solved in 1 guess"
+		}
+		row := fmt.Sprintf("%-21s+%d", label, game.Score(numGuesses, true))
+		rows = append(rows, m.styles.subtext.Render(row))
+	}
+	row := fmt.Sprintf("%-21s+%d", "Not solved", game.Score(_numGuesses, false))
+	if m.options.lossPenalty > 0 {
+		row = fmt.Sprintf("%-21s-%d", "Not solved", m.options.lossPenalty)
+	}
+	rows = append(rows, m.styles.subtext.Render(row))
+
+	if m.options.assistEliminate {
+		example := game.Score(4, true)
+		rows = append(rows,
+			"",
+			m.styles.subtext.Render(fmt.Sprintf("Each eliminate question costs %d points.", _eliminatePenalty)),
+			m.styles.subtext.Render(fmt.Sprintf("e.g. solved in 4 after 2 questions: +%d", max(0, example-2*_eliminatePenalty))),
+		)
+	}
+	if m.options.freebie {
+		rows = append(rows, "", m.styles.subtext.Render(fmt.Sprintf("The given letter costs %d points.", _freebiePenalty)))
+	}
+	rows = m.appendPlaytime(rows)
+	return m.styles.box.Render(lipgloss.JoinVertical(lipgloss.Left, rows...))
+}
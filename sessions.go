@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/ssh"
+	"github.com/charmbracelet/wish"
+)
+
+// _sessionsRefresh is how often the number of players is refreshed while it is
+// shown.
+const _sessionsRefresh = 10 * time.Second
+
+// sessions counts the players connected to the server, and turns away new ones
+// once it is full.
+type sessions struct {
+	mu     sync.Mutex
+	active int
+	// limit is the number of players that can connect at once, or zero if
+	// there is no limit.
+	limit int
+}
+
+func newSessions(limit int) *sessions {
+	return &sessions{limit: limit}
+}
+
+// add counts a new player, unless the server is full and they aren't exempt
+// from the limit.
+func (s *sessions) add(exempt bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !exempt && s.limit > 0 && s.active >= s.limit {
+		return false
+	}
+	s.active++
+	return true
+}
+
+// remove stops counting a player who has disconnected.
+func (s *sessions) remove() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.active--
+}
+
+// String describes how many players are connected, as in "42/100 playing
+// now", or "42 playing now" if there is no limit.
+func (s *sessions) String() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.limit > 0 {
+		return fmt.Sprintf("%d/%d playing now", s.active, s.limit)
+	}
+	return fmt.Sprintf("%d playing now", s.active)
+}
+
+// middleware counts the players of interactive sessions while they play, and
+// turns them away if the server is full, unless exempt reports that they may
+// always connect.
+func (s *sessions) middleware(exempt func(ssh.Session) bool) wish.Middleware {
+	return func(next ssh.Handler) ssh.Handler {
+		return func(session ssh.Session) {
+			if !s.add(exempt(session)) {
+				wish.Fatalf(session, "The server is full (%s), try again later.\n", s)
+				return
+			}
+			defer s.remove()
+			next(session)
+		}
+	}
+}
+
+// banner is shown by SSH clients as they connect, so that players know how
+// busy the server is before they are let in.
+func (s *sessions) banner(ssh.Context) string {
+	return "clidle: " + s.String() + "\n"
+}
+
+// msgRefreshSessions is sent when the number of players shown should be
+// refreshed.
+type msgRefreshSessions struct{}
+
+// refreshSessions queues a refresh of the number of players while the home
+// screen is shown. Only one refresh is pending at a time.
+func (m *model) refreshSessions() tea.Cmd {
+	if m.sessions == nil || m.refreshingSessions {
+		return nil
+	}
+	m.refreshingSessions = true
+	after := m.clock.After(_sessionsRefresh)
+	return func() tea.Msg {
+		select {
+		case <-after:
+			return msgRefreshSessions{}
+		case <-m.ctx.Done():
+			return nil
+		}
+	}
+}
+
+// updateRefreshSessions re-renders the number of players, and keeps refreshing
+// it for as long as the home screen is shown.
+func (m *model) updateRefreshSessions() tea.Cmd {
+	m.refreshingSessions = false
+	if m.screen != screenHome {
+		return nil
+	}
+	return m.refreshSessions()
+}
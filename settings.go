@@ -0,0 +1,217 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// setting is a display option that can be changed from the settings screen
+// while playing.
+type setting struct {
+	name   string
+	values []string
+	// flag is the command-line flag that sets the setting, if any. Flags
+	// given on the command line take precedence over the settings file.
+	flag string
+	// flagValues are the values of the flag matching each of values, and
+	// are nil if they are the same.
+	flagValues []string
+	// get returns the current value of the setting.
+	get func(m *model) string
+	// set applies a new value of the setting.
+	set func(m *model, value string)
+	// save persists the setting in the database, and is nil for settings
+	// that are kept in the settings file when playing in the terminal, and
+	// otherwise only last for the session.
+	save func(m *model) tea.Cmd
+	// available checks whether the setting applies, and is nil for settings
+	// that always do.
+	available func(m *model) bool
+}
+
+// _settings contains the settings shown on the settings screen, in order.
+var _settings = []setting{
+	{
+		name:   "Border",
+		values: []string{"normal", "rounded", "thick", "double"},
+		flag:   "border",
+		get: func(m *model) string {
+			for name, border := range _borders {
+				if border == m.options.border {
+					return name
+				}
+			}
+			return ""
+		},
+		set: func(m *model, value string) {
+			m.options.border = _borders[value]
+			m.styles = newStyles(m.renderer, m.options.border, m.options.tileGap)
+		},
+	},
+	{
+		name:   "Keyboard",
+		values: []string{"auto", "compact", "full", "off"},
+		flag:   "keyboard",
+		get:    func(m *model) string { return m.options.keyboard },
+		set:    func(m *model, value string) { m.options.keyboard = value },
+	},
+	{
+		name:   "Letter case",
+		values: []string{"upper", "lower"},
+		flag:   "case",
+		get: func(m *model) string {
+			if m.options.lowercase {
+				return "lower"
+			}
+			return "upper"
+		},
+		set: func(m *model, value string) { m.options.lowercase = value == "lower" },
+	},
+	{
+		name:       "Reduced motion",
+		values:     []string{"off", "on"},
+		flag:       "reduced-motion",
+		flagValues: []string{"false", "true"},
+		get: func(m *model) string {
+			if m.options.reducedMotion {
+				return "on"
+			}
+			return "off"
+		},
+		set: func(m *model, value string) { m.options.reducedMotion = value == "on" },
+	},
+	{
+		name:       "Terminal title",
+		values:     []string{"on", "off"},
+		flag:       "title",
+		flagValues: []string{"true", "false"},
+		get: func(m *model) string {
+			if m.options.title {
+				return "on"
+			}
+			return "off"
+		},
+		set: func(m *model, value string) { m.options.title = value == "on" },
+	},
+	{
+		name:   "Notifications",
+		values: []string{"flash", "bell", "none"},
+		flag:   "notify",
+		get:    func(m *model) string { return m.options.notify },
+		set:    func(m *model, value string) { m.options.notify = value },
+	},
+	{
+		name:   "Duel chat",
+		values: []string{"on", "muted"},
+		get: func(m *model) string {
+			if m.options.muteChat {
+				return "muted"
+			}
+			return "on"
+		},
+		set: func(m *model, value string) { m.options.muteChat = value == "muted" },
+	},
+	{
+		name:   "Home screen",
+		values: []string{"shown", "skipped"},
+		get: func(m *model) string {
+			if m.skipHome {
+				return "skipped"
+			}
+			return "shown"
+		},
+		set:       func(m *model, value string) { m.skipHome = value == "skipped" },
+		save:      (*model).doSaveSkipHome,
+		available: func(m *model) bool { return m.options.home },
+	},
+	{
+		name:   "Web leaderboard",
+		values: []string{"shown", "hidden"},
+		get: func(m *model) string {
+			if m.hideFromWeb {
+				return "hidden"
+			}
+			return "shown"
+		},
+		set:       func(m *model, value string) { m.hideFromWeb = value == "hidden" },
+		save:      (*model).doSaveHideFromWeb,
+		available: func(m *model) bool { return m.options.webAddr != "" && m.player != "" },
+	},
+}
+
+// settings returns the settings that apply to the current session, in order.
+func (m *model) settings() []setting {
+	settings := make([]setting, 0, len(_settings))
+	for _, setting := range _settings {
+		if setting.available == nil || setting.available(m) {
+			settings = append(settings, setting)
+		}
+	}
+	return settings
+}
+
+// doToggleSettings shows or hides the settings screen.
+func (m *model) doToggleSettings() tea.Cmd {
+	if m.screen == screenSettings {
+		m.closeScreen()
+	} else {
+		m.openScreen(screenSettings)
+	}
+	m.settingsRow = 0
+	return nil
+}
+
+// updateSettings handles key presses while the settings screen is shown.
+// Changes take effect right away, so the game behind the screen previews them.
+func (m *model) updateSettings(msg tea.KeyMsg) tea.Cmd {
+	switch msg.Type {
+	case tea.KeyCtrlC:
+		return m.doExit()
+	case tea.KeyEsc, tea.KeyEnter, tea.KeyCtrlO:
+		m.closeScreen()
+	case tea.KeyUp:
+		numSettings := len(m.settings())
+		m.settingsRow = (m.settingsRow + numSettings - 1) % numSettings
+	case tea.KeyDown, tea.KeyTab:
+		m.settingsRow = (m.settingsRow + 1) % len(m.settings())
+	case tea.KeyLeft:
+		return m.doCycleSetting(-1)
+	case tea.KeyRight, tea.KeySpace:
+		return m.doCycleSetting(1)
+	case tea.KeyRunes:
+		if string(msg.Runes) == "c" {
+			return m.doClaimAnonymous()
+		}
+	}
+	return nil
+}
+
+// doCycleSetting changes the selected setting to the next or previous value,
+// and saves it.
+func (m *model) doCycleSetting(delta int) tea.Cmd {
+	setting := m.settings()[m.settingsRow]
+	idx := max(0, slices.Index(setting.values, setting.get(m)))
+	idx = (idx + delta + len(setting.values)) % len(setting.values)
+	setting.set(m, setting.values[idx])
+	if setting.save != nil {
+		return setting.save(m)
+	}
+	return m.doSaveSettingsFile()
+}
+
+// viewSettings renders the settings screen, including a border.
+func (m *model) viewSettings() string {
+	rows := []string{m.styles.text.Render("Settings"), ""}
+	for i, setting := range m.settings() {
+		row := fmt.Sprintf("%-16s< %s >", setting.name, setting.get(m))
+		if i == m.settingsRow {
+			rows = append(rows, m.styles.text.Render("> "+row))
+		} else {
+			rows = append(rows, m.styles.subtext.Render("  "+row))
+		}
+	}
+	return m.styles.box.Render(lipgloss.JoinVertical(lipgloss.Left, rows...))
+}
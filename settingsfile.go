@@ -0,0 +1,350 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ajeetdsouza/clidle/pkg/config"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/pkg/errors"
+)
+
+// _settingsFileVersion is the version of the format of the settings file. It
+// is bumped whenever the format changes in a way that older versions of clidle
+// can't read.
+const _settingsFileVersion = 1
+
+// errNewerSettings is returned when the settings file was written by a newer
+// version of clidle. It is left untouched, so that it isn't downgraded.
+var errNewerSettings = errors.New("settings file was written by a newer version of clidle")
+
+// _settingsLockStale is the age after which the lock on the settings file is
+// assumed to be left behind by a process that died, and is taken over.
+const _settingsLockStale = 10 * time.Second
+
+// _settingsLockPoll is how often a save checks whether the lock on the
+// settings file has been released.
+const _settingsLockPoll = 50 * time.Millisecond
+
+// settingsFileData is the content of the settings file. Settings are keyed by
+// settingKey, and their values are those shown on the settings screen.
+type settingsFileData struct {
+	Version  int               `json:"version"`
+	Settings map[string]string `json:"settings"`
+}
+
+// settingsFile keeps the settings changed on the settings screen between
+// sessions in the terminal. Since it can also be written by clidle config set
+// while a game is open, every save merges with the file: only the settings
+// changed since they were last synced overwrite those in the file.
+type settingsFile struct {
+	path string
+
+	mu sync.Mutex
+	// synced are the values of the settings when they were last read from
+	// or written to the file.
+	synced map[string]string
+}
+
+func newSettingsFile(path string) *settingsFile {
+	return &settingsFile{path: path, synced: map[string]string{}}
+}
+
+// read returns the settings in the file. A missing file has no settings, and a
+// file that can't be read is ignored with a warning, so that clidle still
+// starts with the defaults.
+func (f *settingsFile) read() map[string]string {
+	settings, err := readSettingsFile(f.path)
+	if err != nil {
+		slog.Warn("ignoring settings file, using defaults", slog.String("path", f.path), slog.Any("error", err))
+		return map[string]string{}
+	}
+	return settings
+}
+
+// sync records the current values of the settings, such as once the file has
+// been applied, so that the next save only writes those that change.
+func (f *settingsFile) sync(current map[string]string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.synced = maps.Clone(current)
+}
+
+// save writes the settings that changed since they were last synced, and keeps
+// the rest of the file as is. The file is locked while it is read and written,
+// so that saves from several processes don't undo each other.
+func (f *settingsFile) save(current map[string]string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	unlock, err := lockSettingsFile(f.path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	settings, err := readSettingsFile(f.path)
+	if errors.Is(err, errNewerSettings) {
+		return err
+	} else if err != nil {
+		slog.Warn("replacing unreadable settings file", slog.String("path", f.path), slog.Any("error", err))
+		settings = map[string]string{}
+	}
+	for key, value := range current {
+		if synced, ok := f.synced[key]; !ok || synced != value {
+			settings[key] = value
+		}
+	}
+
+	data, err := json.MarshalIndent(settingsFileData{Version: _settingsFileVersion, Settings: settings}, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "could not encode settings")
+	}
+	if err := writeFileAtomic(f.path, append(data, '\n')); err != nil {
+		return errors.Wrap(err, "could not write settings")
+	}
+	f.synced = maps.Clone(current)
+	return nil
+}
+
+// lockSettingsFile takes the lock on the settings file, which is held for as
+// long as a lock file next to it exists, and returns a function releasing it.
+func lockSettingsFile(path string) (unlock func(), err error) {
+	lock := path + ".lock"
+	if err := os.MkdirAll(filepath.Dir(lock), 0700); err != nil {
+		return nil, errors.Wrap(err, "could not create directory")
+	}
+	for {
+		f, err := os.OpenFile(lock, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(lock) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, errors.Wrap(err, "could not lock settings")
+		}
+		if info, err := os.Stat(lock); err == nil && time.Since(info.ModTime()) > _settingsLockStale {
+			slog.Warn("taking over stale settings lock", slog.String("path", lock))
+			os.Remove(lock)
+			continue
+		}
+		time.Sleep(_settingsLockPoll)
+	}
+}
+
+// readSettingsFile reads the settings in the file, which are empty if it
+// doesn't exist.
+func readSettingsFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return map[string]string{}, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "could not read settings")
+	}
+	var file settingsFileData
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, errors.Wrap(err, "could not decode settings")
+	}
+	switch {
+	case file.Version == 0:
+		return nil, errors.New("settings file has no version")
+	case file.Version > _settingsFileVersion:
+		return nil, errNewerSettings
+	}
+	if file.Settings == nil {
+		file.Settings = map[string]string{}
+	}
+	return file.Settings, nil
+}
+
+// settingKey returns the key of a setting in the settings file and in clidle
+// config, e.g. letter-case.
+func settingKey(s setting) string {
+	return strings.ReplaceAll(strings.ToLower(s.name), " ", "-")
+}
+
+// fileSettings returns the settings kept in the settings file, which are those
+// that aren't saved in the database.
+func fileSettings() []setting {
+	var settings []setting
+	for _, s := range _settings {
+		if s.save == nil {
+			settings = append(settings, s)
+		}
+	}
+	return settings
+}
+
+// currentFileSettings returns the current values of the settings kept in the
+// settings file.
+func (m *model) currentFileSettings() map[string]string {
+	current := make(map[string]string)
+	for _, s := range fileSettings() {
+		current[settingKey(s)] = s.get(m)
+	}
+	return current
+}
+
+// syncSettingsFile records the settings as they are at startup, once the
+// settings file has been applied along with the flags, so that saving only
+// writes those changed on the settings screen.
+func (m *model) syncSettingsFile() {
+	if m.settingsFile != nil {
+		m.settingsFile.sync(m.currentFileSettings())
+	}
+}
+
+// fileFlagValues converts the settings in the settings file to the values of
+// their flags. Settings without a flag, or with an invalid value, are left
+// out.
+func fileFlagValues(saved map[string]string) map[string]string {
+	values := make(map[string]string)
+	for _, s := range fileSettings() {
+		idx := slices.Index(s.values, saved[settingKey(s)])
+		if s.flag == "" || idx < 0 {
+			continue
+		}
+		if s.flagValues != nil {
+			values[s.flag] = s.flagValues[idx]
+		} else {
+			values[s.flag] = s.values[idx]
+		}
+	}
+	return values
+}
+
+// resolveConfig works out the effective value of every flag from the command
+// line, the environment and the settings file, and sets the flags that aren't
+// given on the command line accordingly. The settings file only applies to
+// the terminal, not to the server.
+func resolveConfig(flags *flag.FlagSet, environ []string, path string) ([]config.Setting, error) {
+	var options []config.Option
+	flags.VisitAll(func(f *flag.Flag) {
+		options = append(options, config.Option{Name: f.Name, Default: f.DefValue})
+	})
+	given := make(map[string]string)
+	flags.Visit(func(f *flag.Flag) { given[f.Name] = f.Value.String() })
+	env := config.FromEnviron(options, environ)
+
+	var file map[string]string
+	if given["serve"] == "" && env["serve"] == "" {
+		file = fileFlagValues(newSettingsFile(path).read())
+	}
+
+	settings := config.Resolve(options, config.Sources{File: file, Env: env, Flags: given})
+	for _, s := range settings {
+		if s.Source != config.SourceFile && s.Source != config.SourceEnv {
+			continue
+		}
+		if err := flags.Set(s.Name, s.Value); err != nil {
+			if s.Source == config.SourceEnv {
+				return nil, errors.Wrapf(err, "invalid value %q for %s", s.Value, config.EnvName(s.Name))
+			}
+			return nil, errors.Wrapf(err, "invalid value %q for %s in %s", s.Value, s.Name, path)
+		}
+	}
+	return settings, nil
+}
+
+// showConfig prints the effective value of every option, and where it comes
+// from. Secrets are masked.
+func showConfig(w io.Writer, settings []config.Setting) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "OPTION\tVALUE\tSOURCE")
+	for _, s := range settings {
+		value := s.Value
+		switch {
+		case isSecretOption(s.Name) && value != "":
+			value = "********"
+		case value == "":
+			value = `""`
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", s.Name, value, s.Source)
+	}
+	tw.Flush()
+}
+
+// isSecretOption checks whether the value of an option must be masked. Webhook
+// URLs often embed a token, so they count as secrets.
+func isSecretOption(name string) bool {
+	return strings.Contains(name, "secret") || name == "webhook-url"
+}
+
+// doSaveSettingsFile queues saving the settings to the settings file.
+func (m *model) doSaveSettingsFile() tea.Cmd {
+	if m.settingsFile == nil {
+		return nil
+	}
+	current := m.currentFileSettings()
+	return m.writes.enqueue(func() tea.Msg {
+		return msgSaved{err: m.settingsFile.save(current)}
+	})
+}
+
+// runConfig handles the config command, which lists the settings in the
+// settings file (clidle config), changes one (clidle config set KEY VALUE), or
+// shows the effective value of every option and its source (clidle config
+// show).
+func runConfig(args []string, settings []config.Setting) error {
+	flags := flag.NewFlagSet("config", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: clidle config [set KEY VALUE | show]")
+		for _, s := range fileSettings() {
+			fmt.Fprintf(flags.Output(), "  %-16s%s\n", settingKey(s), strings.Join(s.values, ", "))
+		}
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	f := newSettingsFile(pathSettings)
+	saved, err := readSettingsFile(pathSettings)
+	if err != nil {
+		return err
+	}
+	switch flags.Arg(0) {
+	case "":
+		for _, s := range fileSettings() {
+			value, ok := saved[settingKey(s)]
+			if !ok {
+				value = "(default)"
+			}
+			fmt.Printf("%-16s%s\n", settingKey(s), value)
+		}
+		return nil
+	case "show":
+		showConfig(os.Stdout, settings)
+		return nil
+	case "set":
+		if flags.NArg() != 3 {
+			flags.Usage()
+			return errors.New("expected a key and a value")
+		}
+		key, value := flags.Arg(1), flags.Arg(2)
+		i := slices.IndexFunc(fileSettings(), func(s setting) bool { return settingKey(s) == key })
+		if i < 0 {
+			return errors.Errorf("unknown setting: %s", key)
+		}
+		if s := fileSettings()[i]; !slices.Contains(s.values, value) {
+			return errors.Errorf("invalid value for %s: %s (expected %s)", key, value, strings.Join(s.values, ", "))
+		}
+		f.sync(saved)
+		current := maps.Clone(saved)
+		current[key] = value
+		return f.save(current)
+	default:
+		flags.Usage()
+		return errors.Errorf("unknown config command: %s", flags.Arg(0))
+	}
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ajeetdsouza/clidle/pkg/config"
+	"github.com/charmbracelet/lipgloss"
+)
+
+func TestSettingsFileMerge(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "settings.json")
+	data := `{"version": 1, "settings": {"border": "thick", "keyboard": "off", "unknown": "kept"}}`
+	if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// The settings at startup, from the file and the flags, aren't saved
+	// unless they change.
+	m := newTestModel(t, "TRACE", 80, 40)
+	m.settingsFile = newSettingsFile(path)
+	m.options.border = lipgloss.ThickBorder()
+	m.options.keyboard = "full"
+	m.syncSettingsFile()
+
+	// Another writer, such as clidle config set, changes a setting while the
+	// game is open.
+	other := newSettingsFile(path)
+	saved := other.read()
+	other.sync(saved)
+	saved["letter-case"] = "lower"
+	if err := other.save(saved); err != nil {
+		t.Fatal(err)
+	}
+
+	// Changing another setting on the settings screen keeps both changes,
+	// along with settings that clidle doesn't know about.
+	m.settingsRow = 3
+	if name := m.settings()[m.settingsRow].name; name != "Reduced motion" {
+		t.Fatalf("unexpected setting %q", name)
+	}
+	m.doCycleSetting(1)
+	m.writes.flush()
+	got, err := readSettingsFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"border":         "thick",
+		"keyboard":       "off",
+		"letter-case":    "lower",
+		"reduced-motion": "off",
+		"unknown":        "kept",
+	}
+	for key, value := range want {
+		if got[key] != value {
+			t.Errorf("%s = %q, want %q", key, got[key], value)
+		}
+	}
+}
+
+func TestSettingsFileCorrupt(t *testing.T) {
+	for name, data := range map[string]string{
+		"partial":    `{"version": 1, "settings": {"border": "th`,
+		"no version": `{"settings": {"border": "thick"}}`,
+		"newer":      `{"version": 99, "settings": {"border": "thick"}}`,
+		"not json":   "\x00\x01",
+	} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "settings.json")
+			if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			// The defaults are used, rather than failing to start.
+			m := newTestModel(t, "TRACE", 80, 40)
+			m.settingsFile = newSettingsFile(path)
+			if saved := m.settingsFile.read(); len(saved) != 0 {
+				t.Errorf("expected no settings, got %v", saved)
+			}
+
+			// Saving replaces a corrupt file, but leaves one written by a
+			// newer version alone.
+			err := m.settingsFile.save(m.currentFileSettings())
+			contents, _ := os.ReadFile(path)
+			if name == "newer" {
+				if err == nil || string(contents) != data {
+					t.Errorf("expected the newer file to be kept, got %v: %s", err, contents)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := readSettingsFile(path); err != nil {
+				t.Errorf("expected a valid file after saving, got %v: %s", err, contents)
+			}
+		})
+	}
+}
+
+func TestSettingsFileLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "settings.json")
+	lock := path + ".lock"
+	if err := os.WriteFile(lock, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// A save waits for another process to release the lock.
+	f := newSettingsFile(path)
+	saved := make(chan error)
+	go func() { saved <- f.save(map[string]string{"border": "thick"}) }()
+	select {
+	case err := <-saved:
+		t.Fatalf("expected the save to wait for the lock, got %v", err)
+	case <-time.After(5 * _settingsLockPoll):
+	}
+	os.Remove(lock)
+	if err := <-saved; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(lock); !os.IsNotExist(err) {
+		t.Errorf("expected the lock to be released, got %v", err)
+	}
+
+	// A lock left behind by a process that died is taken over.
+	if err := os.WriteFile(lock, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	stale := time.Now().Add(-2 * _settingsLockStale)
+	if err := os.Chtimes(lock, stale, stale); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.save(map[string]string{"border": "double"}); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := readSettingsFile(path); err != nil || got["border"] != "double" {
+		t.Errorf("expected the setting to be saved, got %v, %v", got, err)
+	}
+}
+
+func TestResolveConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "settings.json")
+	data := `{"version": 1, "settings": {"border": "thick", "keyboard": "off", "reduced-motion": "on"}}`
+	if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	flags := flag.NewFlagSet("clidle", flag.ContinueOnError)
+	border := flags.String("border", "normal", "")
+	keyboard := flags.String("keyboard", "auto", "")
+	reducedMotion := flags.Bool("reduced-motion", false, "")
+	ultraHard := flags.Bool("ultra-hard", false, "")
+	flags.String("serve", "", "")
+	if err := flags.Parse([]string{"--keyboard", "full"}); err != nil {
+		t.Fatal(err)
+	}
+
+	environ := []string{"CLIDLE_BORDER=double", "CLIDLE_ULTRA_HARD=true"}
+	settings, err := resolveConfig(flags, environ, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *border != "double" || *keyboard != "full" || !*reducedMotion || !*ultraHard {
+		t.Errorf("unexpected flags: border=%s keyboard=%s reduced-motion=%t ultra-hard=%t",
+			*border, *keyboard, *reducedMotion, *ultraHard)
+	}
+	sources := make(map[string]config.Source)
+	for _, s := range settings {
+		sources[s.Name] = s.Source
+	}
+	want := map[string]config.Source{
+		"border":         config.SourceEnv,
+		"keyboard":       config.SourceFlag,
+		"reduced-motion": config.SourceFile,
+		"serve":          config.SourceDefault,
+		"ultra-hard":     config.SourceEnv,
+	}
+	for name, source := range want {
+		if sources[name] != source {
+			t.Errorf("%s: source = %s, want %s", name, sources[name], source)
+		}
+	}
+
+	// Invalid values in the environment are reported rather than ignored.
+	flags = flag.NewFlagSet("clidle", flag.ContinueOnError)
+	flags.Bool("ultra-hard", false, "")
+	if _, err := resolveConfig(flags, []string{"CLIDLE_ULTRA_HARD=maybe"}, path); err == nil {
+		t.Error("expected an error for an invalid value")
+	}
+}
+
+func TestShowConfig(t *testing.T) {
+	settings := []config.Setting{
+		{Name: "webhook-secret", Value: "hunter2", Source: config.SourceEnv},
+		{Name: "webhook-url", Value: "https://example.com/hooks/token", Source: config.SourceFlag},
+		{Name: "border", Value: "thick", Source: config.SourceFile},
+	}
+	var sb strings.Builder
+	showConfig(&sb, settings)
+	if out := sb.String(); strings.Contains(out, "hunter2") || strings.Contains(out, "token") || !strings.Contains(out, "thick") {
+		t.Errorf("expected secrets to be masked, got:\n%s", out)
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base32"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ajeetdsouza/clidle/pkg/game"
+	"github.com/ajeetdsouza/clidle/store"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/ssh"
+	"github.com/charmbracelet/wish"
+	wtea "github.com/charmbracelet/wish/bubbletea"
+	"github.com/pkg/errors"
+)
+
+// _shareIDLength is the number of characters in a share ID. IDs are random,
+// rather than derived from the game's ID, so that games can't be found by
+// guessing.
+const _shareIDLength = 10
+
+// shareEncoding encodes share IDs in lowercase, so that they are easy to type.
+var shareEncoding = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
+
+// newShareID returns a random ID with which a finished game can be viewed.
+func newShareID() (string, error) {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", errors.Wrap(err, "could not generate share ID")
+	}
+	return shareEncoding.EncodeToString(b[:])[:_shareIDLength], nil
+}
+
+// shareMiddleware handles the view command (ssh host -t -- view ID), which
+// shows a finished game read-only instead of starting a new one.
+func shareMiddleware(options options) wish.Middleware {
+	return func(next ssh.Handler) ssh.Handler {
+		return func(session ssh.Session) {
+			args := session.Command()
+			if len(args) == 0 || args[0] != "view" {
+				next(session)
+				return
+			}
+			if len(args) != 2 {
+				wish.Fatalln(session, "usage: view ID")
+				return
+			}
+
+			store, err := openStore(options)
+			if err != nil {
+				wish.Fatalln(session, "could not open database")
+				return
+			}
+			ctx, cancel := context.WithTimeout(session.Context(), 5*time.Second)
+			defer cancel()
+
+			renderer := wtea.MakeRenderer(session)
+			setColorProfile(renderer, options.color, hasEnv(session.Environ(), "NO_COLOR"))
+			board, err := viewSharedGame(ctx, store, renderer, options, args[1])
+			if err != nil {
+				wish.Fatalln(session, err)
+				return
+			}
+			wish.Print(session, strings.ReplaceAll(board, "\n", "\r\n")+"\r\n")
+		}
+	}
+}
+
+// viewSharedGame renders the finished game with the given share ID. Games that
+// are still in progress don't have a share ID, so they can't be viewed.
+func viewSharedGame(ctx context.Context, queries *store.Queries, renderer *lipgloss.Renderer, options options, id string) (string, error) {
+	row, err := queries.GetSharedGame(ctx, sql.NullString{String: strings.ToLower(id), Valid: true})
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", errors.Errorf("no finished game with ID %q", id)
+	} else if err != nil {
+		return "", errors.Wrap(err, "could not fetch game")
+	}
+	guessRows, err := queries.ListGuesses(ctx, sql.NullInt64{Int64: row.ID, Valid: true})
+	if err != nil {
+		return "", errors.Wrap(err, "could not fetch guesses")
+	}
+
+	answer, err := game.ParseWord(row.Answer.String)
+	if err != nil {
+		return "", errors.Wrap(err, "invalid answer")
+	}
+	guesses := make([]game.Word, 0, len(guessRows))
+	for _, guessRow := range guessRows {
+		guess, err := game.ParseWord(guessRow.Guess.String)
+		if err != nil {
+			return "", errors.Wrap(err, "invalid guess")
+		}
+		guesses = append(guesses, guess)
+	}
+
+	numGuesses := "X"
+	if n := len(guesses); n > 0 && guesses[n-1] == answer {
+		numGuesses = fmt.Sprint(n)
+	}
+	name := row.PlayerName.String
+	if name == "" {
+		name = "anonymous"
+	}
+
+	m := newModel(ctx, queries, EnglishDictionary, options, renderer)
+	title := m.styles.text.Render(fmt.Sprintf("%s %s/%d", name, numGuesses, _numGuesses))
+	return lipgloss.JoinVertical(lipgloss.Center, title, m.viewBoard(answer, guesses)), nil
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/ajeetdsouza/clidle/pkg/game"
+	"github.com/pkg/errors"
+)
+
+// simulation contains the results of playing many games with one opener.
+type simulation struct {
+	Opener       string           `json:"opener"`
+	Games        int              `json:"games"`
+	Average      float64          `json:"average"`
+	FailureRate  float64          `json:"failure_rate"`
+	Distribution [_numGuesses]int `json:"distribution"`
+	Failures     int              `json:"failures"`
+}
+
+// runSimulate plays many games headlessly with a solver strategy, and reports
+// how well each of the given openers performed.
+func runSimulate(args []string) error {
+	flags := flag.NewFlagSet("simulate", flag.ExitOnError)
+	flagGames := flags.Int("games", 1000, "Number of games to play per opener")
+	flagOpener := flags.String("opener", "CRANE", "Comma-separated list of opening words to compare")
+	flagStrategy := flags.String("strategy", "filter", "Strategy used to pick guesses after the opener (filter)")
+	flagFormat := flags.String("format", "text", "Output format (text, csv, json)")
+	flagSeed := flags.Int64("seed", 0, "Seed for choosing answers, for reproducible runs (default random)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	dictionary := EnglishDictionary
+	strategy, ok := strategies[*flagStrategy]
+	if !ok {
+		return errors.Errorf("unknown strategy: %s", *flagStrategy)
+	}
+	if *flagGames <= 0 {
+		return errors.Errorf("invalid number of games: %d", *flagGames)
+	}
+	seed := *flagSeed
+	if seed == 0 {
+		seed = rand.Int63()
+	}
+
+	var simulations []simulation
+	for _, opener := range strings.Split(*flagOpener, ",") {
+		opener = strings.ToUpper(strings.TrimSpace(opener))
+		if !dictionary.IsWord(opener) {
+			return errors.Errorf("invalid opener: %s", opener)
+		}
+		simulations = append(simulations, simulate(dictionary, strategy, opener, *flagGames, seed))
+	}
+
+	switch *flagFormat {
+	case "text":
+		return writeSimulationsText(os.Stdout, simulations)
+	case "csv":
+		return writeSimulationsCSV(os.Stdout, simulations)
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(simulations)
+	default:
+		return errors.Errorf("unknown format: %s", *flagFormat)
+	}
+}
+
+// simulate plays the given number of games in parallel, using every CPU core.
+// Each game is played against a random answer.
+func simulate(dictionary Dictionary, strategy strategy, opener string, games int, seed int64) simulation {
+	candidates := wordsToBytes(dictionary.commonWords)
+	var openerWord game.Word
+	copy(openerWord[:], opener)
+
+	workers := runtime.NumCPU()
+	results := make([]simulation, workers)
+	var wg sync.WaitGroup
+	for worker := 0; worker < workers; worker++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			rng := rand.New(rand.NewSource(seed + int64(worker)))
+			result := &results[worker]
+			for game := worker; game < games; game += workers {
+				answer := candidates[rng.Intn(len(candidates))]
+				numGuesses, won := solve(candidates, strategy, openerWord, answer, rng)
+				if won {
+					result.Distribution[numGuesses-1]++
+				} else {
+					result.Failures++
+				}
+			}
+		}(worker)
+	}
+	wg.Wait()
+
+	total := simulation{Opener: opener, Games: games}
+	numGuesses := 0
+	for _, result := range results {
+		for i, count := range result.Distribution {
+			total.Distribution[i] += count
+			numGuesses += (i + 1) * count
+		}
+		total.Failures += result.Failures
+	}
+	if wins := games - total.Failures; wins > 0 {
+		total.Average = float64(numGuesses) / float64(wins)
+	}
+	total.FailureRate = float64(total.Failures) / float64(games)
+	return total
+}
+
+func writeSimulationsText(w io.Writer, simulations []simulation) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprint(tw, "opener\tgames\taverage\tfailures\t")
+	for i := 1; i <= _numGuesses; i++ {
+		fmt.Fprintf(tw, "%d\t", i)
+	}
+	fmt.Fprintln(tw)
+	for _, s := range simulations {
+		fmt.Fprintf(tw, "%s\t%d\t%.3f\t%.1f%%\t", s.Opener, s.Games, s.Average, 100*s.FailureRate)
+		for _, count := range s.Distribution {
+			fmt.Fprintf(tw, "%d\t", count)
+		}
+		fmt.Fprintln(tw)
+	}
+	return tw.Flush()
+}
+
+func writeSimulationsCSV(w io.Writer, simulations []simulation) error {
+	cw := csv.NewWriter(w)
+	header := []string{"opener", "games", "average", "failure_rate"}
+	for i := 1; i <= _numGuesses; i++ {
+		header = append(header, strconv.Itoa(i))
+	}
+	header = append(header, "failures")
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	for _, s := range simulations {
+		record := []string{
+			s.Opener,
+			strconv.Itoa(s.Games),
+			strconv.FormatFloat(s.Average, 'f', 3, 64),
+			strconv.FormatFloat(s.FailureRate, 'f', 4, 64),
+		}
+		for _, count := range s.Distribution {
+			record = append(record, strconv.Itoa(count))
+		}
+		record = append(record, strconv.Itoa(s.Failures))
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
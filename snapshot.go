@@ -0,0 +1,310 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ajeetdsouza/clidle/pkg/game"
+	"github.com/ajeetdsouza/clidle/store"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/pkg/errors"
+)
+
+// snapshot is the state of an unfinished game, which is saved after every
+// guess and autosaved while the player types, so that the game can be picked
+// up where it was left off if clidle is killed. Guesses are also in the
+// database, but the snapshot keeps the rest of the board along with them.
+type snapshot struct {
+	GameID    int64    `json:"game_id"`
+	Answer    string   `json:"answer"`
+	UltraHard bool     `json:"ultra_hard"`
+	Guesses   []string `json:"guesses"`
+	Row       string   `json:"row"`
+	KeyStates [26]int  `json:"key_states"`
+	Penalty   int      `json:"penalty"`
+	Freebie   int      `json:"freebie"`
+}
+
+// saveSnapshot queues a write of the snapshot of the current game, once its
+// guesses are saved. It is autosaved to the store, and also written to the
+// snapshot file when playing in the terminal. A snapshot that hasn't changed
+// since the last one is only written once.
+func (m *model) saveSnapshot() tea.Cmd {
+	if m.practice || m.match != nil || m.referee != nil || m.isGameOver() {
+		return nil
+	}
+	s := snapshot{
+		Answer:    m.game.Answer().String(),
+		UltraHard: m.options.ultraHard,
+		Row:       string(m.grid[m.gridRow][:m.gridLen]),
+		Penalty:   m.penalty,
+		Freebie:   m.freebie,
+	}
+	for _, guess := range m.grid[:m.gridRow] {
+		s.Guesses = append(s.Guesses, guess.String())
+	}
+	for i, state := range m.keyStates {
+		s.KeyStates[i] = int(state)
+	}
+	// The game ID isn't known yet, but it doesn't change during a game.
+	data, err := json.Marshal(s)
+	if err != nil || string(data) == m.lastSnapshot {
+		return nil
+	}
+	m.lastSnapshot = string(data)
+
+	record, path, savedAt := m.record, m.options.snapshotFile, m.clock.Now()
+	return m.writes.enqueue(func() tea.Msg {
+		if record.id == 0 {
+			return nil
+		}
+		s.GameID = record.id
+		if path != "" {
+			if err := writeSnapshot(path, s); err != nil {
+				slog.Error("error saving snapshot", slog.Any("error", err))
+			}
+		}
+
+		data, err := json.Marshal(s)
+		if err != nil {
+			return msgSaved{err: errors.Wrap(err, "could not encode snapshot")}
+		}
+		ctx, cancel := context.WithTimeout(m.writeCtx, 5*time.Second)
+		defer cancel()
+		params := store.SaveAutosaveParams{GameID: record.id, Snapshot: string(data), SavedAt: savedAt}
+		err = store.Retry(ctx, func() error { return m.store.SaveAutosave(ctx, params) })
+		return msgSaved{err: errors.Wrap(err, "could not autosave game")}
+	})
+}
+
+// removeSnapshot queues removing the snapshot once the game is over.
+func (m *model) removeSnapshot() tea.Cmd {
+	m.lastSnapshot = ""
+	record, path := m.record, m.options.snapshotFile
+	return m.writes.enqueue(func() tea.Msg {
+		if path != "" {
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				slog.Error("error removing snapshot", slog.Any("error", err))
+			}
+		}
+		if record.id == 0 {
+			return nil
+		}
+		ctx, cancel := context.WithTimeout(m.writeCtx, 5*time.Second)
+		defer cancel()
+		err := store.Retry(ctx, func() error { return m.store.DeleteAutosave(ctx, record.id) })
+		return msgSaved{err: errors.Wrap(err, "could not remove autosave")}
+	})
+}
+
+// msgAutosave is sent when the board should be autosaved.
+type msgAutosave struct{}
+
+// scheduleAutosave returns a tea.Cmd that asks for the board to be autosaved
+// after the autosave interval, or nil if autosaving is turned off.
+func (m *model) scheduleAutosave() tea.Cmd {
+	if m.options.autosave <= 0 {
+		return nil
+	}
+	after := m.clock.After(m.options.autosave)
+	return func() tea.Msg {
+		select {
+		case <-after:
+			return msgAutosave{}
+		case <-m.ctx.Done():
+			return nil
+		}
+	}
+}
+
+// writeSnapshot writes a snapshot atomically.
+func writeSnapshot(path string, s snapshot) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return errors.Wrap(err, "could not encode snapshot")
+	}
+	return errors.Wrap(writeFileAtomic(path, data), "could not write snapshot")
+}
+
+// writeFileAtomic writes data to a temporary file next to path, and moves it
+// into place, so that readers never see a partially written file, even if
+// clidle crashes or another process writes it at the same time.
+func writeFileAtomic(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return errors.Wrap(err, "could not create directory")
+	}
+	f, err := os.CreateTemp(dir, "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// restoreSnapshot picks up the game in the snapshot, if it is still unfinished
+// and matches the game being played, e.g. the same daily puzzle. Snapshots that
+// can't be restored are removed, and the new game is kept. On the server, the
+// snapshot is the latest autosave of the player's games.
+func (m *model) restoreSnapshot() {
+	if m.practice || m.match != nil || m.referee != nil {
+		return
+	}
+	if m.options.snapshotFile == "" {
+		m.restoreAutosave()
+		return
+	}
+	data, err := os.ReadFile(m.options.snapshotFile)
+	if os.IsNotExist(err) {
+		return
+	}
+	if err == nil {
+		err = m.loadSnapshot(data)
+	}
+	if err != nil {
+		slog.Warn("ignoring snapshot", slog.String("path", m.options.snapshotFile), slog.Any("error", err))
+		if err := os.Remove(m.options.snapshotFile); err != nil && !os.IsNotExist(err) {
+			slog.Error("error removing snapshot", slog.Any("error", err))
+		}
+	}
+}
+
+// restoreAutosave picks up the player's latest unfinished game of the same
+// mode from its autosave. Players without a key have no games to pick up, as
+// their games can't be told apart from those of other players.
+func (m *model) restoreAutosave() {
+	if m.player == "" {
+		return
+	}
+	ctx, cancel := context.WithTimeout(m.ctx, 5*time.Second)
+	defer cancel()
+	params := store.GetPlayerAutosaveParams{Player: sql.NullString{String: m.player, Valid: true}, Daily: m.daily}
+	autosave, err := m.store.GetPlayerAutosave(ctx, params)
+	if errors.Is(err, sql.ErrNoRows) {
+		return
+	} else if err != nil {
+		slog.Error("error fetching autosave", slog.Any("error", err))
+		return
+	}
+	if err := m.loadSnapshot([]byte(autosave.Snapshot)); err != nil {
+		slog.Warn("ignoring autosave", slog.Int64("game", autosave.GameID), slog.Any("error", err))
+		err = store.Retry(ctx, func() error { return m.store.DeleteAutosave(ctx, autosave.GameID) })
+		if err != nil {
+			slog.Error("error removing autosave", slog.Any("error", err))
+		}
+	}
+}
+
+// isSaved checks whether the game will still be there when the player comes
+// back: finished games are, and so are unfinished ones that are autosaved, as
+// long as the player can be told apart from others.
+func (m *model) isSaved() bool {
+	if m.isGameOver() {
+		return !m.practice
+	}
+	return (m.player != "" || m.options.snapshotFile != "") && !m.practice && m.match == nil && m.referee == nil
+}
+
+// loadSnapshot checks a snapshot against the database, and replays it. The
+// current game is only replaced once the snapshot is known to be valid.
+func (m *model) loadSnapshot(data []byte) error {
+	var s snapshot
+	if err := json.Unmarshal(data, &s); err != nil {
+		return errors.Wrap(err, "could not decode snapshot")
+	}
+	answer, err := game.ParseWord(s.Answer)
+	if err != nil {
+		return errors.Wrap(err, "invalid answer")
+	}
+	if len(s.Guesses) >= _numGuesses || len(s.Row) > _numChars || s.Freebie < -1 || s.Freebie >= _numChars || s.Penalty < 0 {
+		return errors.New("invalid board")
+	}
+	if s.UltraHard != m.options.ultraHard {
+		return errors.New("snapshot is of another mode")
+	}
+	for _, state := range s.KeyStates {
+		if state < 0 || state > int(_keyStateExhausted) {
+			return errors.Errorf("invalid key state %d", state)
+		}
+	}
+	for _, r := range s.Row {
+		if !isAsciiUpper(r) {
+			return errors.Errorf("invalid letter %q", r)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(m.ctx, 5*time.Second)
+	defer cancel()
+	row, err := m.store.GetGame(ctx, s.GameID)
+	if err != nil {
+		return errors.Wrap(err, "could not fetch game")
+	}
+	if row.FinishedAt.Valid || row.Answer.String != s.Answer || row.Daily.String != m.daily {
+		return errors.New("game is finished, or doesn't match the snapshot")
+	}
+	guesses, err := m.store.ListGuesses(ctx, sql.NullInt64{Int64: row.ID, Valid: true})
+	if err != nil {
+		return errors.Wrap(err, "could not fetch guesses")
+	}
+	if len(guesses) != len(s.Guesses) {
+		return errors.New("guesses don't match the snapshot")
+	}
+	for i, guess := range guesses {
+		if guess.Guess.String != s.Guesses[i] {
+			return errors.New("guesses don't match the snapshot")
+		}
+	}
+
+	// Replay the guesses in a new game before replacing the current one, so
+	// that they are checked like any other.
+	g := game.New(answer, m.dictionary)
+	g.SetUltraHard(m.options.ultraHard)
+	for _, guess := range s.Guesses {
+		if _, err := g.Guess(guess); err != nil {
+			return errors.Wrapf(err, "could not replay %s", guess)
+		}
+	}
+	if g.State() != game.StateInProgress {
+		return errors.New("game is over")
+	}
+	err = store.Retry(ctx, func() error { return m.store.MarkGameResumed(ctx, row.ID) })
+	if err != nil {
+		return errors.Wrap(err, "could not mark game as resumed")
+	}
+
+	m.startGame(answer)
+	m.record.id = row.ID
+	m.startedAt = row.StartedAt.Time
+	for i, state := range s.KeyStates {
+		m.keyStates[i] = keyState(state)
+	}
+	m.penalty, m.freebie = s.Penalty, s.Freebie
+	for i, guess := range s.Guesses {
+		m.game.Guess(guess)
+		m.grid[i] = m.game.Guesses()[i]
+		m.gridRow++
+		m.updateAssistKeys()
+	}
+	m.gridLen = copy(m.grid[m.gridRow][:], s.Row)
+	m.gridCol = m.gridLen
+	if m.gridLen == 0 {
+		m.skipLocked()
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+
+	"github.com/ajeetdsouza/clidle/pkg/game"
+)
+
+// strategy picks the next guess from the answers that are still possible.
+// candidates is never empty.
+type strategy func(candidates []game.Word, rng *rand.Rand) game.Word
+
+// strategies contains all the built-in solver strategies, by name.
+var strategies = map[string]strategy{
+	"filter":  strategyFilter,
+	"entropy": strategyEntropy,
+}
+
+// strategyFilter guesses a random word among the remaining candidates.
+func strategyFilter(candidates []game.Word, rng *rand.Rand) game.Word {
+	return candidates[rng.Intn(len(candidates))]
+}
+
+// strategyEntropy guesses the candidate that is expected to give the most
+// information about the answer, i.e. the one whose feedback patterns over the
+// remaining candidates have the highest entropy.
+func strategyEntropy(candidates []game.Word, _ *rand.Rand) game.Word {
+	best := candidates[0]
+	bestEntropy := -1.0
+	for _, guess := range candidates {
+		if entropy := guessEntropy(candidates, guess); entropy > bestEntropy {
+			best = guess
+			bestEntropy = entropy
+		}
+	}
+	return best
+}
+
+// guessEntropy computes the entropy of the feedback patterns that the guess
+// would produce for each of the candidates.
+func guessEntropy(candidates []game.Word, guess game.Word) float64 {
+	var counts [_numPatterns]int
+	for _, candidate := range candidates {
+		counts[patternIndex(game.Evaluate(guess, candidate))]++
+	}
+	return countsEntropy(counts[:], len(candidates))
+}
+
+// countsEntropy computes the entropy of a distribution given as the number of
+// times each outcome occurs, out of the total.
+func countsEntropy(counts []int, total int) float64 {
+	entropy := 0.0
+	for _, count := range counts {
+		if count > 0 {
+			p := float64(count) / float64(total)
+			entropy -= p * math.Log2(p)
+		}
+	}
+	return entropy
+}
+
+// _numPatterns is the number of possible feedback patterns for a guess, since
+// each letter can be absent, present or correct.
+const _numPatterns = 243
+
+// patternIndex converts a feedback pattern to a unique number below
+// _numPatterns.
+func patternIndex(feedback game.Feedback) int {
+	idx := 0
+	for _, letterState := range feedback {
+		idx = 3*idx + int(letterState-game.LetterAbsent)
+	}
+	return idx
+}
+
+// filterCandidates returns the candidates that would have produced the given
+// feedback for the guess, had they been the answer.
+func filterCandidates(candidates []game.Word, guess game.Word, feedback game.Feedback) []game.Word {
+	var filtered []game.Word
+	for _, candidate := range candidates {
+		if game.Evaluate(guess, candidate) == feedback {
+			filtered = append(filtered, candidate)
+		}
+	}
+	return filtered
+}
+
+// solve plays a game against the given answer, starting with the opener and
+// using the strategy for every following guess. It returns the number of
+// guesses made, and whether the answer was found.
+func solve(candidates []game.Word, strategy strategy, opener, answer game.Word, rng *rand.Rand) (int, bool) {
+	guesses := solvePath(candidates, strategy, opener, answer, rng)
+	if guesses[len(guesses)-1] != answer {
+		return _numGuesses, false
+	}
+	return len(guesses), true
+}
+
+// solvePath is like solve, but returns every guess made, up to and including
+// the answer if it was found in time.
+func solvePath(candidates []game.Word, strategy strategy, opener, answer game.Word, rng *rand.Rand) []game.Word {
+	guesses := []game.Word{opener}
+	for guess := opener; guess != answer && len(guesses) < _numGuesses; {
+		candidates = filterCandidates(candidates, guess, game.Evaluate(guess, answer))
+		if len(candidates) == 0 {
+			break
+		}
+		guess = strategy(candidates, rng)
+		guesses = append(guesses, guess)
+	}
+	return guesses
+}
+
+// wordsToBytes converts a list of words to game.Words.
+func wordsToBytes(words []string) []game.Word {
+	result := make([]game.Word, len(words))
+	for i, word := range words {
+		copy(result[i][:], word)
+	}
+	return result
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// solveTimes compares how long the last game took to solve with the player's
+// previous games.
+type solveTimes struct {
+	time    time.Duration
+	average time.Duration
+	best    time.Duration
+	// newRecord is set if the game beat the previous best time.
+	newRecord bool
+}
+
+// msgSolveTimes is sent when the solve times of a won game have been computed.
+type msgSolveTimes struct {
+	record *gameRecord
+	times  solveTimes
+}
+
+// checkSolveTimes queues a comparison of the solve time of the game that was
+// just won with those of previous games, after it has been saved. Resumed
+// games aren't timed, as they include the time clidle was closed.
+func (m *model) checkSolveTimes() tea.Cmd {
+	record := m.record
+	return m.writes.enqueue(func() tea.Msg {
+		if record.id == 0 {
+			return nil
+		}
+
+		ctx, cancel := context.WithTimeout(m.ctx, 5*time.Second)
+		defer cancel()
+
+		results, err := m.store.ListGameResults(ctx)
+		if err != nil {
+			slog.Error("error fetching game results", slog.Any("error", err))
+			return nil
+		}
+		var times solveTimes
+		previous := results[:0:0]
+		for _, result := range results {
+			if result.ID != record.id {
+				previous = append(previous, result)
+				continue
+			}
+			if !result.StartedAt.Valid || !result.FinishedAt.Valid || result.Resumed {
+				return nil
+			}
+			times.time = result.FinishedAt.Time.Sub(result.StartedAt.Time)
+		}
+
+		s := computeStats(results)
+		times.average = secondsToDuration(s.AverageSolveTime)
+		times.best = secondsToDuration(s.BestSolveTime)
+		if best := computeStats(previous).BestSolveTime; best > 0 {
+			times.newRecord = times.time < secondsToDuration(best)
+		}
+		return msgSolveTimes{record: record, times: times}
+	})
+}
+
+// secondsToDuration converts a number of seconds, as used in the stats, to a
+// duration.
+func secondsToDuration(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// formatDuration formats a duration for people to read, e.g. "1m 42s".
+func formatDuration(d time.Duration) string {
+	d = d.Round(time.Second)
+	hours, minutes, seconds := int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60
+	switch {
+	case hours > 0:
+		return fmt.Sprintf("%dh %dm", hours, minutes)
+	case minutes > 0:
+		return fmt.Sprintf("%dm %ds", minutes, seconds)
+	default:
+		return fmt.Sprintf("%ds", seconds)
+	}
+}
+
+// viewSolveTimes renders the solve time of the last game next to the
+// player's average and best, including a border.
+func (m *model) viewSolveTimes() string {
+	times := m.solveTimes
+	row := fmt.Sprintf("%-10s%s", "Time", formatDuration(times.time))
+	rows := []string{m.styles.text.Render(row)}
+	if times.newRecord {
+		rows[0] = lipgloss.JoinHorizontal(lipgloss.Top, rows[0], "  ", m.styles.highlight.Render("New record!"))
+	}
+	rows = append(rows,
+		m.styles.subtext.Render(fmt.Sprintf("%-10s%s", "Average", formatDuration(times.average))),
+		m.styles.subtext.Render(fmt.Sprintf("%-10s%s", "Best", formatDuration(times.best))),
+	)
+	return m.styles.box.Render(lipgloss.JoinVertical(lipgloss.Left, rows...))
+}
@@ -0,0 +1,300 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ajeetdsouza/clidle/pkg/game"
+	"github.com/ajeetdsouza/clidle/store"
+	"github.com/pkg/errors"
+)
+
+// stats summarizes a player's completed games.
+type stats struct {
+	Played           int              `json:"played"`
+	Won              int              `json:"won"`
+	WinRate          float64          `json:"win_rate"`
+	CurrentStreak    int              `json:"current_streak"`
+	MaxStreak        int              `json:"max_streak"`
+	Freezes          int              `json:"streak_freezes"`
+	Points           int              `json:"points"`
+	LossPenalties    int              `json:"loss_penalties"`
+	Distribution     [_numGuesses]int `json:"distribution"`
+	Best             []wordResult     `json:"best"`
+	Worst            []wordResult     `json:"worst"`
+	AverageSolveTime float64          `json:"average_solve_time_seconds"`
+	BestSolveTime    float64          `json:"best_solve_time_seconds"`
+	PlaytimeSeconds  int64            `json:"playtime_seconds,omitempty"`
+
+	// lastFrozen is set if a streak freeze was spent on the last game.
+	lastFrozen bool
+}
+
+// wordResult is the result of a single game, as shown in the best and worst
+// words.
+type wordResult struct {
+	Answer     string `json:"answer"`
+	NumGuesses int    `json:"guesses"`
+	Won        bool   `json:"won"`
+}
+
+// _statsWords is the number of best and worst words shown.
+const _statsWords = 3
+
+const (
+	// _winsPerFreeze is the number of wins for which a streak freeze is
+	// awarded.
+	_winsPerFreeze = 5
+	// _maxFreezes is the number of streak freezes that can be saved up.
+	_maxFreezes = 2
+)
+
+// isCompleted reports whether a game has ended. This is decided by finished_at
+// rather than the number of guesses, since a lost duel round ends early. Games
+// saved before finished_at existed fall back to the guesses.
+func isCompleted(result store.ListGameResultsRow) bool {
+	return result.FinishedAt.Valid || result.Won || result.NumGuesses >= _numGuesses
+}
+
+// computeStats summarizes the results of a list of games, ordered from oldest
+// to newest. Games that are still in progress are ignored.
+//
+// A streak freeze is awarded every _winsPerFreeze wins, and is spent on the
+// next loss that would otherwise end a streak. Freezes are replayed from the
+// results rather than stored, so they always agree with the games played.
+func computeStats(results []store.ListGameResultsRow) stats {
+	var s stats
+	var completed []wordResult
+	var solveTime time.Duration
+	var numTimed int
+
+	for _, result := range results {
+		if !isCompleted(result) {
+			continue
+		}
+		numGuesses := int(result.NumGuesses)
+
+		s.Played++
+		s.lastFrozen = false
+		completed = append(completed, wordResult{
+			Answer:     result.Answer.String,
+			NumGuesses: numGuesses,
+			Won:        result.Won,
+		})
+
+		if !result.Won {
+			s.LossPenalties += int(result.LossPenalty)
+			if s.CurrentStreak > 0 && s.Freezes > 0 {
+				s.Freezes--
+				s.lastFrozen = true
+			} else {
+				s.CurrentStreak = 0
+			}
+			continue
+		}
+		s.Won++
+		s.Points += max(0, game.Score(numGuesses, true)-int(result.Penalty))
+		if s.Won%_winsPerFreeze == 0 {
+			s.Freezes = min(s.Freezes+1, _maxFreezes)
+		}
+		s.Distribution[numGuesses-1]++
+		s.CurrentStreak++
+		s.MaxStreak = max(s.MaxStreak, s.CurrentStreak)
+		// Resumed games are left out of the solve times, as they include the
+		// time clidle was closed.
+		if result.StartedAt.Valid && result.FinishedAt.Valid && !result.Resumed {
+			duration := result.FinishedAt.Time.Sub(result.StartedAt.Time)
+			solveTime += duration
+			numTimed++
+			if numTimed == 1 || duration.Seconds() < s.BestSolveTime {
+				s.BestSolveTime = duration.Seconds()
+			}
+		}
+	}
+
+	if s.Played > 0 {
+		s.WinRate = float64(s.Won) / float64(s.Played)
+	}
+	if numTimed > 0 {
+		s.AverageSolveTime = (solveTime / time.Duration(numTimed)).Seconds()
+	}
+
+	// Rank games by the number of guesses taken, counting losses as the worst.
+	// Among equal results, the most recent game comes first.
+	rank := func(r wordResult) int {
+		if !r.Won {
+			return _numGuesses + 1
+		}
+		return r.NumGuesses
+	}
+	for i := len(completed) - 1; i >= 0; i-- {
+		s.Best = insertRanked(s.Best, completed[i], func(a, b wordResult) bool { return rank(a) < rank(b) })
+		s.Worst = insertRanked(s.Worst, completed[i], func(a, b wordResult) bool { return rank(a) > rank(b) })
+	}
+	return s
+}
+
+// insertRanked inserts a result into a list of at most _statsWords results,
+// keeping it ordered by the given comparison.
+func insertRanked(results []wordResult, result wordResult, less func(a, b wordResult) bool) []wordResult {
+	idx := len(results)
+	for idx > 0 && less(result, results[idx-1]) {
+		idx--
+	}
+	if idx >= _statsWords {
+		return results
+	}
+	results = append(results[:idx], append([]wordResult{result}, results[idx:]...)...)
+	if len(results) > _statsWords {
+		results = results[:_statsWords]
+	}
+	return results
+}
+
+// runStats prints a report of all completed games to stdout, or with the
+// "weekly" argument, a recap of the daily puzzles of the last week.
+func runStats(args []string, options options) error {
+	weekly := len(args) > 0 && args[0] == "weekly"
+	if weekly {
+		args = args[1:]
+	}
+
+	flags := flag.NewFlagSet("stats", flag.ExitOnError)
+	flagJSON := flags.Bool("json", false, "Prints the report as JSON")
+	flagSince := flags.String("since", "", "Only includes games started within the given duration (e.g. 30d, 12h)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	var since time.Time
+	if *flagSince != "" {
+		duration, err := parseDays(*flagSince)
+		if err != nil {
+			return errors.Wrapf(err, "invalid duration: %s", *flagSince)
+		}
+		since = time.Now().Add(-duration)
+	}
+
+	queries, err := getStoreReadOnly()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	results, err := queries.ListGameResults(ctx)
+	if err != nil {
+		return errors.Wrap(err, "could not read games")
+	}
+	if !since.IsZero() {
+		filtered := results[:0]
+		for _, result := range results {
+			if result.StartedAt.Valid && !result.StartedAt.Time.Before(since) {
+				filtered = append(filtered, result)
+			}
+		}
+		results = filtered
+	}
+
+	if weekly {
+		week := computeWeekly(results, dailyDate(time.Now(), options.dailyLocation))
+		if *flagJSON {
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(week)
+		}
+		return writeWeeklyText(os.Stdout, week)
+	}
+
+	s := computeStats(results)
+	// Time played isn't kept per game, so it is left out of reports that
+	// only cover recent games.
+	if since.IsZero() {
+		s.PlaytimeSeconds, err = queries.GetPlaytime(ctx, "")
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return errors.Wrap(err, "could not read time played")
+		}
+	}
+	if *flagJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(s)
+	}
+	return writeStatsText(os.Stdout, s)
+}
+
+// parseDays parses a duration, additionally accepting a number of days such as
+// "30d".
+func parseDays(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(s)
+}
+
+// _statsBarWidth is the width of the longest bar in the guess distribution.
+const _statsBarWidth = 30
+
+func writeStatsText(w io.Writer, s stats) error {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Played:         %d\n", s.Played)
+	fmt.Fprintf(&sb, "Win rate:       %.0f%%\n", 100*s.WinRate)
+	fmt.Fprintf(&sb, "Current streak: %d\n", s.CurrentStreak)
+	fmt.Fprintf(&sb, "Max streak:     %d\n", s.MaxStreak)
+	fmt.Fprintf(&sb, "Streak freezes: %d\n", s.Freezes)
+	fmt.Fprintf(&sb, "Points earned:  %s\n", formatThousands(s.Points))
+	if s.LossPenalties > 0 {
+		fmt.Fprintf(&sb, "Loss penalties: -%s\n", formatThousands(s.LossPenalties))
+	}
+	if s.AverageSolveTime > 0 {
+		solveTime := time.Duration(s.AverageSolveTime * float64(time.Second)).Round(time.Second)
+		fmt.Fprintf(&sb, "Average time:   %s\n", solveTime)
+		bestTime := secondsToDuration(s.BestSolveTime).Round(time.Second)
+		fmt.Fprintf(&sb, "Best time:      %s\n", bestTime)
+	}
+	if s.PlaytimeSeconds > 0 {
+		fmt.Fprintf(&sb, "Time played:    %s\n", formatPlaytime(time.Duration(s.PlaytimeSeconds)*time.Second))
+	}
+
+	sb.WriteString("\nGuess distribution:\n")
+	maxCount := 1
+	for _, count := range s.Distribution {
+		maxCount = max(maxCount, count)
+	}
+	for i, count := range s.Distribution {
+		bar := strings.Repeat("█", count*_statsBarWidth/maxCount)
+		fmt.Fprintf(&sb, "  %d │%s %d\n", i+1, bar, count)
+	}
+
+	writeWords := func(title string, results []wordResult) {
+		if len(results) == 0 {
+			return
+		}
+		words := make([]string, len(results))
+		for i, result := range results {
+			numGuesses := "X"
+			if result.Won {
+				numGuesses = strconv.Itoa(result.NumGuesses)
+			}
+			words[i] = fmt.Sprintf("%s (%s/%d)", result.Answer, numGuesses, _numGuesses)
+		}
+		fmt.Fprintf(&sb, "%-16s%s\n", title, strings.Join(words, ", "))
+	}
+	sb.WriteString("\n")
+	writeWords("Best words:", s.Best)
+	writeWords("Worst words:", s.Worst)
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
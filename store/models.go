@@ -6,15 +6,59 @@ package store
 
 import (
 	"database/sql"
+	"time"
 )
 
 type Game struct {
-	ID     int64
-	Answer sql.NullString
+	ID          int64
+	Answer      sql.NullString
+	Player      sql.NullString
+	PlayerName  sql.NullString
+	Daily       sql.NullString
+	StartedAt   sql.NullTime
+	FinishedAt  sql.NullTime
+	Flagged     bool
+	Penalty     int64
+	MaxRarity   int64
+	ShareID     sql.NullString
+	LossPenalty int64
+	DailyNumber sql.NullInt64
+	Uuid        sql.NullString
+	Imported    bool
+	RemoteIp    sql.NullString
+	Api         bool
+	Resumed     bool
+}
+
+type GameAutosave struct {
+	GameID   int64
+	Snapshot string
+	SavedAt  time.Time
 }
 
 type Guess struct {
-	ID     int64
-	GameID sql.NullInt64
-	Guess  sql.NullString
+	ID      int64
+	GameID  sql.NullInt64
+	Guess   sql.NullString
+	Pattern sql.NullString
+}
+
+type PlayerMerge struct {
+	ID         int64
+	FromPlayer string
+	ToPlayer   string
+	Games      int64
+	MergedBy   string
+	MergedAt   time.Time
+}
+
+type PlayerPlaytime struct {
+	Player  string
+	Seconds int64
+}
+
+type PlayerPreference struct {
+	Player      string
+	SkipHome    bool
+	HideFromWeb bool
 }
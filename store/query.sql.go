@@ -8,53 +8,852 @@ package store
 import (
 	"context"
 	"database/sql"
+	"time"
 )
 
 const createGame = `-- name: CreateGame :one
-INSERT INTO game (answer)
-VALUES (?)
-RETURNING id, answer
+INSERT INTO game (answer, player, player_name, daily, started_at, max_rarity, daily_number, uuid, remote_ip, api)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+RETURNING id, answer, player, player_name, daily, started_at, finished_at, flagged, penalty, max_rarity, share_id, loss_penalty, daily_number, uuid, imported, remote_ip, api, resumed
 `
 
-func (q *Queries) CreateGame(ctx context.Context, answer sql.NullString) (Game, error) {
-	row := q.db.QueryRowContext(ctx, createGame, answer)
+type CreateGameParams struct {
+	Answer      sql.NullString
+	Player      sql.NullString
+	PlayerName  sql.NullString
+	Daily       sql.NullString
+	StartedAt   sql.NullTime
+	MaxRarity   int64
+	DailyNumber sql.NullInt64
+	Uuid        sql.NullString
+	RemoteIp    sql.NullString
+	Api         bool
+}
+
+func (q *Queries) CreateGame(ctx context.Context, arg CreateGameParams) (Game, error) {
+	row := q.db.QueryRowContext(ctx, createGame,
+		arg.Answer,
+		arg.Player,
+		arg.PlayerName,
+		arg.Daily,
+		arg.StartedAt,
+		arg.MaxRarity,
+		arg.DailyNumber,
+		arg.Uuid,
+		arg.RemoteIp,
+		arg.Api,
+	)
 	var i Game
-	err := row.Scan(&i.ID, &i.Answer)
+	err := row.Scan(
+		&i.ID,
+		&i.Answer,
+		&i.Player,
+		&i.PlayerName,
+		&i.Daily,
+		&i.StartedAt,
+		&i.FinishedAt,
+		&i.Flagged,
+		&i.Penalty,
+		&i.MaxRarity,
+		&i.ShareID,
+		&i.LossPenalty,
+		&i.DailyNumber,
+		&i.Uuid,
+		&i.Imported,
+		&i.RemoteIp,
+		&i.Api,
+		&i.Resumed,
+	)
 	return i, err
 }
 
+const finishGame = `-- name: FinishGame :exec
+UPDATE game
+SET finished_at = ?, penalty = ?, share_id = ?, loss_penalty = ?
+WHERE id = ?
+`
+
+type FinishGameParams struct {
+	FinishedAt  sql.NullTime
+	Penalty     int64
+	ShareID     sql.NullString
+	LossPenalty int64
+	ID          int64
+}
+
+func (q *Queries) FinishGame(ctx context.Context, arg FinishGameParams) error {
+	_, err := q.db.ExecContext(ctx, finishGame,
+		arg.FinishedAt,
+		arg.Penalty,
+		arg.ShareID,
+		arg.LossPenalty,
+		arg.ID,
+	)
+	return err
+}
+
+const getGame = `-- name: GetGame :one
+SELECT id, answer, player, player_name, daily, started_at, finished_at, flagged, penalty, max_rarity, share_id, loss_penalty, daily_number, uuid, imported, remote_ip, api, resumed FROM game
+WHERE id = ?
+`
+
+func (q *Queries) GetGame(ctx context.Context, id int64) (Game, error) {
+	row := q.db.QueryRowContext(ctx, getGame, id)
+	var i Game
+	err := row.Scan(
+		&i.ID,
+		&i.Answer,
+		&i.Player,
+		&i.PlayerName,
+		&i.Daily,
+		&i.StartedAt,
+		&i.FinishedAt,
+		&i.Flagged,
+		&i.Penalty,
+		&i.MaxRarity,
+		&i.ShareID,
+		&i.LossPenalty,
+		&i.DailyNumber,
+		&i.Uuid,
+		&i.Imported,
+		&i.RemoteIp,
+		&i.Api,
+		&i.Resumed,
+	)
+	return i, err
+}
+
+const getSharedGame = `-- name: GetSharedGame :one
+SELECT id, answer, player, player_name, daily, started_at, finished_at, flagged, penalty, max_rarity, share_id, loss_penalty, daily_number, uuid, imported, remote_ip, api, resumed FROM game
+WHERE share_id = ? AND finished_at IS NOT NULL
+`
+
+func (q *Queries) GetSharedGame(ctx context.Context, shareID sql.NullString) (Game, error) {
+	row := q.db.QueryRowContext(ctx, getSharedGame, shareID)
+	var i Game
+	err := row.Scan(
+		&i.ID,
+		&i.Answer,
+		&i.Player,
+		&i.PlayerName,
+		&i.Daily,
+		&i.StartedAt,
+		&i.FinishedAt,
+		&i.Flagged,
+		&i.Penalty,
+		&i.MaxRarity,
+		&i.ShareID,
+		&i.LossPenalty,
+		&i.DailyNumber,
+		&i.Uuid,
+		&i.Imported,
+		&i.RemoteIp,
+		&i.Api,
+		&i.Resumed,
+	)
+	return i, err
+}
+
+const importGame = `-- name: ImportGame :one
+INSERT INTO game (uuid, answer, player, player_name, daily, daily_number, started_at, finished_at, penalty, loss_penalty, max_rarity, imported)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, TRUE)
+ON CONFLICT (uuid) DO NOTHING
+RETURNING id
+`
+
+type ImportGameParams struct {
+	Uuid        sql.NullString
+	Answer      sql.NullString
+	Player      sql.NullString
+	PlayerName  sql.NullString
+	Daily       sql.NullString
+	DailyNumber sql.NullInt64
+	StartedAt   sql.NullTime
+	FinishedAt  sql.NullTime
+	Penalty     int64
+	LossPenalty int64
+	MaxRarity   int64
+}
+
+func (q *Queries) ImportGame(ctx context.Context, arg ImportGameParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, importGame,
+		arg.Uuid,
+		arg.Answer,
+		arg.Player,
+		arg.PlayerName,
+		arg.Daily,
+		arg.DailyNumber,
+		arg.StartedAt,
+		arg.FinishedAt,
+		arg.Penalty,
+		arg.LossPenalty,
+		arg.MaxRarity,
+	)
+	var id int64
+	err := row.Scan(&id)
+	return id, err
+}
+
+const setGameAnswer = `-- name: SetGameAnswer :exec
+UPDATE game
+SET answer = ?
+WHERE id = ?
+`
+
+type SetGameAnswerParams struct {
+	Answer sql.NullString
+	ID     int64
+}
+
+func (q *Queries) SetGameAnswer(ctx context.Context, arg SetGameAnswerParams) error {
+	_, err := q.db.ExecContext(ctx, setGameAnswer, arg.Answer, arg.ID)
+	return err
+}
+
+const markGameResumed = `-- name: MarkGameResumed :exec
+UPDATE game
+SET resumed = TRUE
+WHERE id = ?
+`
+
+func (q *Queries) MarkGameResumed(ctx context.Context, id int64) error {
+	_, err := q.db.ExecContext(ctx, markGameResumed, id)
+	return err
+}
+
+const flagGame = `-- name: FlagGame :exec
+UPDATE game
+SET flagged = TRUE
+WHERE id = ?
+`
+
+func (q *Queries) FlagGame(ctx context.Context, id int64) error {
+	_, err := q.db.ExecContext(ctx, flagGame, id)
+	return err
+}
+
 const createGuess = `-- name: CreateGuess :one
-INSERT INTO guess (game_id, guess)
-VALUES (?, ?)
-RETURNING id, game_id, guess
+INSERT INTO guess (game_id, guess, pattern)
+VALUES (?, ?, ?)
+RETURNING id, game_id, guess, pattern
 `
 
 type CreateGuessParams struct {
-	GameID sql.NullInt64
-	Guess  sql.NullString
+	GameID  sql.NullInt64
+	Guess   sql.NullString
+	Pattern sql.NullString
 }
 
 func (q *Queries) CreateGuess(ctx context.Context, arg CreateGuessParams) (Guess, error) {
-	row := q.db.QueryRowContext(ctx, createGuess, arg.GameID, arg.Guess)
+	row := q.db.QueryRowContext(ctx, createGuess, arg.GameID, arg.Guess, arg.Pattern)
 	var i Guess
-	err := row.Scan(&i.ID, &i.GameID, &i.Guess)
+	err := row.Scan(&i.ID, &i.GameID, &i.Guess, &i.Pattern)
 	return i, err
 }
 
+const listGuesses = `-- name: ListGuesses :many
+SELECT id, game_id, guess, pattern FROM guess
+WHERE game_id = ?
+ORDER BY id
+`
+
+func (q *Queries) ListGuesses(ctx context.Context, gameID sql.NullInt64) ([]Guess, error) {
+	rows, err := q.db.QueryContext(ctx, listGuesses, gameID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Guess
+	for rows.Next() {
+		var i Guess
+		if err := rows.Scan(&i.ID, &i.GameID, &i.Guess, &i.Pattern); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getTotalScore = `-- name: GetTotalScore :one
-WITH game_scores AS (
-    SELECT game.id, (10 * (11 - COUNT(guess.id))) AS score
+WITH RECURSIVE game_scores AS (
+    SELECT game.id, MAX(0, 10 * (11 - COUNT(guess.id)) - game.penalty) AS score
     FROM game
     INNER JOIN guess victory ON game.id = victory.game_id AND game.answer = victory.guess
     INNER JOIN guess ON game.id = guess.game_id
     GROUP BY game.id
+    UNION ALL
+    SELECT id, -loss_penalty AS score
+    FROM game
+    WHERE loss_penalty > 0
+),
+ordered_scores AS MATERIALIZED (
+    SELECT ROW_NUMBER() OVER (ORDER BY id) AS n, score
+    FROM game_scores
+),
+totals (n, total) AS (
+    SELECT 0, 0
+    UNION ALL
+    SELECT ordered_scores.n, MAX(0, totals.total + ordered_scores.score)
+    FROM totals
+    INNER JOIN ordered_scores ON ordered_scores.n = totals.n + 1
 )
-SELECT SUM(score) FROM game_scores
+SELECT total FROM totals ORDER BY n DESC LIMIT 1
 `
 
 func (q *Queries) GetTotalScore(ctx context.Context) (sql.NullFloat64, error) {
 	row := q.db.QueryRowContext(ctx, getTotalScore)
-	var sum sql.NullFloat64
-	err := row.Scan(&sum)
-	return sum, err
+	var total sql.NullFloat64
+	err := row.Scan(&total)
+	return total, err
+}
+
+const getDailyLeaderboard = `-- name: GetDailyLeaderboard :many
+WITH first_games AS (
+    SELECT MIN(id) AS id
+    FROM game
+    WHERE daily = ? AND NOT imported
+    GROUP BY player
+)
+SELECT game.player, game.player_name, COUNT(guess.id) AS num_guesses, game.started_at, game.finished_at
+FROM game
+INNER JOIN first_games ON game.id = first_games.id
+INNER JOIN guess victory ON game.id = victory.game_id AND game.answer = victory.guess
+INNER JOIN guess ON game.id = guess.game_id
+WHERE game.finished_at IS NOT NULL AND NOT game.flagged
+GROUP BY game.id
+ORDER BY num_guesses ASC, julianday(game.finished_at) - julianday(game.started_at) ASC
+LIMIT ?
+`
+
+type GetDailyLeaderboardParams struct {
+	Daily sql.NullString
+	Limit int64
+}
+
+type GetDailyLeaderboardRow struct {
+	Player     sql.NullString
+	PlayerName sql.NullString
+	NumGuesses int64
+	StartedAt  sql.NullTime
+	FinishedAt sql.NullTime
+}
+
+func (q *Queries) GetDailyLeaderboard(ctx context.Context, arg GetDailyLeaderboardParams) ([]GetDailyLeaderboardRow, error) {
+	rows, err := q.db.QueryContext(ctx, getDailyLeaderboard, arg.Daily, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetDailyLeaderboardRow
+	for rows.Next() {
+		var i GetDailyLeaderboardRow
+		if err := rows.Scan(
+			&i.Player,
+			&i.PlayerName,
+			&i.NumGuesses,
+			&i.StartedAt,
+			&i.FinishedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const hasFinishedDaily = `-- name: HasFinishedDaily :one
+SELECT CAST(EXISTS(
+    SELECT 1 FROM game
+    WHERE daily = ? AND player = ? AND finished_at IS NOT NULL
+) AS BOOLEAN) AS finished
+`
+
+type HasFinishedDailyParams struct {
+	Daily  sql.NullString
+	Player sql.NullString
+}
+
+func (q *Queries) HasFinishedDaily(ctx context.Context, arg HasFinishedDailyParams) (bool, error) {
+	row := q.db.QueryRowContext(ctx, hasFinishedDaily, arg.Daily, arg.Player)
+	var finished bool
+	err := row.Scan(&finished)
+	return finished, err
+}
+
+const listGameResults = `-- name: ListGameResults :many
+SELECT game.id, game.answer, game.daily, game.started_at, game.finished_at, game.penalty, game.loss_penalty, game.resumed,
+    COUNT(guess.id) AS num_guesses,
+    CAST(MAX(guess.guess = game.answer) AS BOOLEAN) AS won
+FROM game
+INNER JOIN guess ON game.id = guess.game_id
+GROUP BY game.id
+ORDER BY game.started_at, game.id
+`
+
+type ListGameResultsRow struct {
+	ID          int64
+	Answer      sql.NullString
+	Daily       sql.NullString
+	StartedAt   sql.NullTime
+	FinishedAt  sql.NullTime
+	Penalty     int64
+	LossPenalty int64
+	Resumed     bool
+	NumGuesses  int64
+	Won         bool
+}
+
+func (q *Queries) ListGameResults(ctx context.Context) ([]ListGameResultsRow, error) {
+	rows, err := q.db.QueryContext(ctx, listGameResults)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListGameResultsRow
+	for rows.Next() {
+		var i ListGameResultsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Answer,
+			&i.Daily,
+			&i.StartedAt,
+			&i.FinishedAt,
+			&i.Penalty,
+			&i.LossPenalty,
+			&i.Resumed,
+			&i.NumGuesses,
+			&i.Won,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listPlayerGameResults = `-- name: ListPlayerGameResults :many
+SELECT game.id, game.answer, game.daily, game.started_at, game.finished_at, game.penalty, game.loss_penalty, game.resumed,
+    COUNT(guess.id) AS num_guesses,
+    CAST(MAX(guess.guess = game.answer) AS BOOLEAN) AS won
+FROM game
+INNER JOIN guess ON game.id = guess.game_id
+WHERE game.player = ?
+GROUP BY game.id
+ORDER BY game.started_at, game.id
+`
+
+type ListPlayerGameResultsRow struct {
+	ID          int64
+	Answer      sql.NullString
+	Daily       sql.NullString
+	StartedAt   sql.NullTime
+	FinishedAt  sql.NullTime
+	Penalty     int64
+	LossPenalty int64
+	Resumed     bool
+	NumGuesses  int64
+	Won         bool
+}
+
+func (q *Queries) ListPlayerGameResults(ctx context.Context, player sql.NullString) ([]ListPlayerGameResultsRow, error) {
+	rows, err := q.db.QueryContext(ctx, listPlayerGameResults, player)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListPlayerGameResultsRow
+	for rows.Next() {
+		var i ListPlayerGameResultsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Answer,
+			&i.Daily,
+			&i.StartedAt,
+			&i.FinishedAt,
+			&i.Penalty,
+			&i.LossPenalty,
+			&i.Resumed,
+			&i.NumGuesses,
+			&i.Won,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const claimAnonymousGames = `-- name: ClaimAnonymousGames :execrows
+UPDATE game
+SET player = ?, remote_ip = NULL
+WHERE player IS NULL AND player_name = ? AND remote_ip = ?
+`
+
+type ClaimAnonymousGamesParams struct {
+	Player     sql.NullString
+	PlayerName sql.NullString
+	RemoteIp   sql.NullString
+}
+
+func (q *Queries) ClaimAnonymousGames(ctx context.Context, arg ClaimAnonymousGamesParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, claimAnonymousGames, arg.Player, arg.PlayerName, arg.RemoteIp)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const mergeAnonymousGames = `-- name: MergeAnonymousGames :execrows
+UPDATE game
+SET player = ?, remote_ip = NULL
+WHERE player IS NULL AND player_name = ?
+`
+
+type MergeAnonymousGamesParams struct {
+	Player     sql.NullString
+	PlayerName sql.NullString
+}
+
+func (q *Queries) MergeAnonymousGames(ctx context.Context, arg MergeAnonymousGamesParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, mergeAnonymousGames, arg.Player, arg.PlayerName)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const mergePlayerGames = `-- name: MergePlayerGames :execrows
+UPDATE game
+SET player = ?
+WHERE player = ?
+`
+
+type MergePlayerGamesParams struct {
+	Player   sql.NullString
+	Player_2 sql.NullString
+}
+
+func (q *Queries) MergePlayerGames(ctx context.Context, arg MergePlayerGamesParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, mergePlayerGames, arg.Player, arg.Player_2)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const createPlayerMerge = `-- name: CreatePlayerMerge :exec
+INSERT INTO player_merge (from_player, to_player, games, merged_by, merged_at)
+VALUES (?, ?, ?, ?, ?)
+`
+
+type CreatePlayerMergeParams struct {
+	FromPlayer string
+	ToPlayer   string
+	Games      int64
+	MergedBy   string
+	MergedAt   time.Time
+}
+
+func (q *Queries) CreatePlayerMerge(ctx context.Context, arg CreatePlayerMergeParams) error {
+	_, err := q.db.ExecContext(ctx, createPlayerMerge,
+		arg.FromPlayer,
+		arg.ToPlayer,
+		arg.Games,
+		arg.MergedBy,
+		arg.MergedAt,
+	)
+	return err
+}
+
+const getSkipHome = `-- name: GetSkipHome :one
+SELECT skip_home FROM player_preference
+WHERE player = ?
+`
+
+func (q *Queries) GetSkipHome(ctx context.Context, player string) (bool, error) {
+	row := q.db.QueryRowContext(ctx, getSkipHome, player)
+	var skip_home bool
+	err := row.Scan(&skip_home)
+	return skip_home, err
+}
+
+const setSkipHome = `-- name: SetSkipHome :exec
+INSERT INTO player_preference (player, skip_home)
+VALUES (?, ?)
+ON CONFLICT (player) DO UPDATE SET skip_home = excluded.skip_home
+`
+
+type SetSkipHomeParams struct {
+	Player   string
+	SkipHome bool
+}
+
+func (q *Queries) SetSkipHome(ctx context.Context, arg SetSkipHomeParams) error {
+	_, err := q.db.ExecContext(ctx, setSkipHome, arg.Player, arg.SkipHome)
+	return err
+}
+
+const getHideFromWeb = `-- name: GetHideFromWeb :one
+SELECT hide_from_web FROM player_preference
+WHERE player = ?
+`
+
+func (q *Queries) GetHideFromWeb(ctx context.Context, player string) (bool, error) {
+	row := q.db.QueryRowContext(ctx, getHideFromWeb, player)
+	var hide_from_web bool
+	err := row.Scan(&hide_from_web)
+	return hide_from_web, err
+}
+
+const setHideFromWeb = `-- name: SetHideFromWeb :exec
+INSERT INTO player_preference (player, hide_from_web)
+VALUES (?, ?)
+ON CONFLICT (player) DO UPDATE SET hide_from_web = excluded.hide_from_web
+`
+
+type SetHideFromWebParams struct {
+	Player      string
+	HideFromWeb bool
+}
+
+func (q *Queries) SetHideFromWeb(ctx context.Context, arg SetHideFromWebParams) error {
+	_, err := q.db.ExecContext(ctx, setHideFromWeb, arg.Player, arg.HideFromWeb)
+	return err
+}
+
+const getPlaytime = `-- name: GetPlaytime :one
+SELECT seconds FROM player_playtime
+WHERE player = ?
+`
+
+func (q *Queries) GetPlaytime(ctx context.Context, player string) (int64, error) {
+	row := q.db.QueryRowContext(ctx, getPlaytime, player)
+	var seconds int64
+	err := row.Scan(&seconds)
+	return seconds, err
+}
+
+const addPlaytime = `-- name: AddPlaytime :exec
+INSERT INTO player_playtime (player, seconds)
+VALUES (?, ?)
+ON CONFLICT (player) DO UPDATE SET seconds = seconds + excluded.seconds
+`
+
+type AddPlaytimeParams struct {
+	Player  string
+	Seconds int64
+}
+
+func (q *Queries) AddPlaytime(ctx context.Context, arg AddPlaytimeParams) error {
+	_, err := q.db.ExecContext(ctx, addPlaytime, arg.Player, arg.Seconds)
+	return err
+}
+
+const saveAutosave = `-- name: SaveAutosave :exec
+INSERT INTO game_autosave (game_id, snapshot, saved_at)
+VALUES (?, ?, ?)
+ON CONFLICT (game_id) DO UPDATE SET snapshot = excluded.snapshot, saved_at = excluded.saved_at
+`
+
+type SaveAutosaveParams struct {
+	GameID   int64
+	Snapshot string
+	SavedAt  time.Time
+}
+
+func (q *Queries) SaveAutosave(ctx context.Context, arg SaveAutosaveParams) error {
+	_, err := q.db.ExecContext(ctx, saveAutosave, arg.GameID, arg.Snapshot, arg.SavedAt)
+	return err
+}
+
+const deleteAutosave = `-- name: DeleteAutosave :exec
+DELETE FROM game_autosave
+WHERE game_id = ?
+`
+
+func (q *Queries) DeleteAutosave(ctx context.Context, gameID int64) error {
+	_, err := q.db.ExecContext(ctx, deleteAutosave, gameID)
+	return err
+}
+
+const getPlayerAutosave = `-- name: GetPlayerAutosave :one
+SELECT game_autosave.game_id, game_autosave.snapshot
+FROM game_autosave
+INNER JOIN game ON game.id = game_autosave.game_id
+WHERE game.player = ? AND game.finished_at IS NULL AND COALESCE(game.daily, '') = ?
+ORDER BY game_autosave.saved_at DESC
+LIMIT 1
+`
+
+type GetPlayerAutosaveParams struct {
+	Player sql.NullString
+	Daily  string
+}
+
+type GetPlayerAutosaveRow struct {
+	GameID   int64
+	Snapshot string
+}
+
+func (q *Queries) GetPlayerAutosave(ctx context.Context, arg GetPlayerAutosaveParams) (GetPlayerAutosaveRow, error) {
+	row := q.db.QueryRowContext(ctx, getPlayerAutosave, arg.Player, arg.Daily)
+	var i GetPlayerAutosaveRow
+	err := row.Scan(&i.GameID, &i.Snapshot)
+	return i, err
+}
+
+const getWebDailyLeaderboard = `-- name: GetWebDailyLeaderboard :many
+WITH first_games AS (
+    SELECT MIN(id) AS id
+    FROM game
+    WHERE daily = ? AND NOT imported
+    GROUP BY player
+)
+SELECT game.player, game.player_name, COUNT(guess.id) AS num_guesses, game.started_at, game.finished_at
+FROM game
+INNER JOIN first_games ON game.id = first_games.id
+INNER JOIN guess victory ON game.id = victory.game_id AND game.answer = victory.guess
+INNER JOIN guess ON game.id = guess.game_id
+LEFT JOIN player_preference ON game.player = player_preference.player
+WHERE game.finished_at IS NOT NULL AND NOT game.flagged
+    AND NOT COALESCE(player_preference.hide_from_web, FALSE)
+GROUP BY game.id
+ORDER BY num_guesses ASC, julianday(game.finished_at) - julianday(game.started_at) ASC
+LIMIT ?
+`
+
+type GetWebDailyLeaderboardParams struct {
+	Daily sql.NullString
+	Limit int64
+}
+
+type GetWebDailyLeaderboardRow struct {
+	Player     sql.NullString
+	PlayerName sql.NullString
+	NumGuesses int64
+	StartedAt  sql.NullTime
+	FinishedAt sql.NullTime
+}
+
+func (q *Queries) GetWebDailyLeaderboard(ctx context.Context, arg GetWebDailyLeaderboardParams) ([]GetWebDailyLeaderboardRow, error) {
+	rows, err := q.db.QueryContext(ctx, getWebDailyLeaderboard, arg.Daily, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetWebDailyLeaderboardRow
+	for rows.Next() {
+		var i GetWebDailyLeaderboardRow
+		if err := rows.Scan(
+			&i.Player,
+			&i.PlayerName,
+			&i.NumGuesses,
+			&i.StartedAt,
+			&i.FinishedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getTopScores = `-- name: GetTopScores :many
+WITH game_scores AS (
+    SELECT game.player, MAX(0, 10 * (11 - COUNT(guess.id)) - game.penalty) AS score
+    FROM game
+    INNER JOIN guess victory ON game.id = victory.game_id AND game.answer = victory.guess
+    INNER JOIN guess ON game.id = guess.game_id
+    WHERE game.player IS NOT NULL AND game.finished_at IS NOT NULL AND NOT game.flagged AND NOT game.imported
+    GROUP BY game.id
+)
+SELECT game_scores.player,
+    CAST(COALESCE((SELECT player_name FROM game WHERE game.player = game_scores.player ORDER BY game.id DESC LIMIT 1), '') AS TEXT) AS player_name,
+    CAST(SUM(game_scores.score) AS INTEGER) AS points,
+    COUNT(*) AS wins
+FROM game_scores
+LEFT JOIN player_preference ON game_scores.player = player_preference.player
+WHERE NOT COALESCE(player_preference.hide_from_web, FALSE)
+GROUP BY game_scores.player
+ORDER BY points DESC
+LIMIT ?
+`
+
+type GetTopScoresRow struct {
+	Player     sql.NullString
+	PlayerName string
+	Points     int64
+	Wins       int64
+}
+
+func (q *Queries) GetTopScores(ctx context.Context, limit int64) ([]GetTopScoresRow, error) {
+	rows, err := q.db.QueryContext(ctx, getTopScores, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetTopScoresRow
+	for rows.Next() {
+		var i GetTopScoresRow
+		if err := rows.Scan(
+			&i.Player,
+			&i.PlayerName,
+			&i.Points,
+			&i.Wins,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const countDailySolves = `-- name: CountDailySolves :one
+SELECT COUNT(DISTINCT game.id) AS solves
+FROM game
+INNER JOIN guess victory ON game.id = victory.game_id AND game.answer = victory.guess
+WHERE game.daily = ? AND game.finished_at IS NOT NULL AND NOT game.flagged AND NOT game.imported
+`
+
+func (q *Queries) CountDailySolves(ctx context.Context, daily sql.NullString) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countDailySolves, daily)
+	var solves int64
+	err := row.Scan(&solves)
+	return solves, err
 }
@@ -0,0 +1,58 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+const (
+	// _retryInitialDelay is the delay before the first retry, which doubles
+	// after every attempt.
+	_retryInitialDelay = 10 * time.Millisecond
+	// _retryBudget bounds the total time spent waiting between retries.
+	_retryBudget = time.Second
+)
+
+// Retry runs fn, retrying it with exponential backoff for as long as it fails
+// because the database is busy or locked by another connection. Other errors
+// are returned immediately. fn must be safe to run more than once, e.g. a
+// single write statement.
+func Retry(ctx context.Context, fn func() error) error {
+	delay := _retryInitialDelay
+	var waited time.Duration
+	for {
+		err := fn()
+		if !IsBusy(err) || waited+delay > _retryBudget {
+			return err
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		waited += delay
+		delay *= 2
+	}
+}
+
+// IsBusy checks if an error was caused by the database being busy or locked,
+// in which case the operation may succeed if it is retried.
+func IsBusy(err error) bool {
+	var sqliteErr *sqlite.Error
+	if !errors.As(err, &sqliteErr) {
+		return false
+	}
+	// Extended result codes keep the primary result code in the lowest byte.
+	switch sqliteErr.Code() & 0xff {
+	case sqlite3.SQLITE_BUSY, sqlite3.SQLITE_LOCKED:
+		return true
+	}
+	return false
+}
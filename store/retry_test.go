@@ -0,0 +1,100 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// openLockedDB opens two connections to a new database, and locks it from the
+// first one. It returns the second connection and a function that releases
+// the lock.
+func openLockedDB(t *testing.T) (*sql.DB, func()) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "test.db")
+	open := func() *sql.DB {
+		db, err := sql.Open("sqlite", "file:"+path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { db.Close() })
+		db.SetMaxOpenConns(1)
+		return db
+	}
+
+	locker := open()
+	if _, err := locker.Exec("CREATE TABLE guess (guess TEXT)"); err != nil {
+		t.Fatal(err)
+	}
+	tx, err := locker.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.Exec("INSERT INTO guess VALUES ('CRANE')"); err != nil {
+		t.Fatal(err)
+	}
+	return open(), func() { tx.Commit() }
+}
+
+func TestRetryBusy(t *testing.T) {
+	db, unlock := openLockedDB(t)
+	defer unlock()
+
+	attempts := 0
+	start := time.Now()
+	err := Retry(context.Background(), func() error {
+		attempts++
+		_, err := db.Exec("INSERT INTO guess VALUES ('SLATE')")
+		return err
+	})
+	if !IsBusy(err) {
+		t.Fatalf("expected a busy error, got %v", err)
+	}
+	if attempts < 2 {
+		t.Fatalf("expected the write to be retried, got %d attempts", attempts)
+	}
+	if elapsed := time.Since(start); elapsed > 2*_retryBudget {
+		t.Fatalf("retries took %v, expected at most %v", elapsed, _retryBudget)
+	}
+}
+
+func TestRetryUnlocked(t *testing.T) {
+	db, unlock := openLockedDB(t)
+	time.AfterFunc(50*time.Millisecond, unlock)
+
+	err := Retry(context.Background(), func() error {
+		_, err := db.Exec("INSERT INTO guess VALUES ('SLATE')")
+		return err
+	})
+	if err != nil {
+		t.Fatalf("expected the write to succeed once unlocked, got %v", err)
+	}
+
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM guess").Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Fatalf("expected 2 guesses, got %d", count)
+	}
+}
+
+func TestRetryOtherError(t *testing.T) {
+	attempts := 0
+	want := errors.New("not busy")
+	err := Retry(context.Background(), func() error {
+		attempts++
+		return want
+	})
+	if err != want {
+		t.Fatalf("expected %v, got %v", want, err)
+	}
+	if attempts != 1 {
+		t.Fatalf("expected a single attempt, got %d", attempts)
+	}
+}
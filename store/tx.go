@@ -0,0 +1,27 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/pkg/errors"
+)
+
+// InTx runs fn with queries in a transaction, which is committed if fn succeeds
+// and rolled back otherwise. The queries must have been created from a
+// *sql.DB rather than a transaction.
+func (q *Queries) InTx(ctx context.Context, fn func(*Queries) error) error {
+	db, ok := q.db.(*sql.DB)
+	if !ok {
+		return errors.New("transactions can't be nested")
+	}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(q.WithTx(tx)); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// _numKeyStates is the number of distinct key states.
+const _numKeyStates = int(_keyStateSuggested) + 1
+
+// styles contains the lipgloss styles used by a model. Styles are built once
+// when the model is created instead of on every frame, and rendered keys are
+// memoized since the same few keys are drawn over and over again.
+type styles struct {
+	keys [_numKeyStates]lipgloss.Style
+	// letters are used for keys on the keyboard for narrow terminals, which
+	// has no borders, and for the grid in the letters layout.
+	letters [_numKeyStates]lipgloss.Style
+	// slimTiles and compactTiles are used for the grid in the slim and
+	// compact layouts.
+	slimTiles    [_numKeyStates]lipgloss.Style
+	compactTiles [_numKeyStates]lipgloss.Style
+	status       lipgloss.Style
+	// flash is the inverted status, shown briefly to notify the player.
+	flash   lipgloss.Style
+	box     lipgloss.Style
+	text    lipgloss.Style
+	subtext lipgloss.Style
+	// highlight calls attention to good news, such as a new record.
+	highlight lipgloss.Style
+	padTop    lipgloss.Style
+	padMid    lipgloss.Style
+	tileGap   lipgloss.Style
+	rowGap    lipgloss.Style
+
+	// separator is drawn between controls in the footer.
+	separator string
+
+	// badge is shown next to the status when only common words are accepted.
+	badge string
+
+	// opponentTiles contains a small tile for each key state, used to show
+	// an opponent's progress without the letters.
+	opponentTiles [_numKeyStates]string
+
+	// renderedKeys caches the output of renderKey.
+	renderedKeys map[renderedKey]string
+	// renderedControls caches the rendered controls shown in the footer.
+	renderedControls map[control]string
+}
+
+// renderedKey identifies a key rendered in a given state and grid layout, and
+// whether it is under the cursor.
+type renderedKey struct {
+	key    string
+	state  keyState
+	layout gridLayout
+	cursor bool
+}
+
+func newStyles(renderer *lipgloss.Renderer, border lipgloss.Border, tileGap int) *styles {
+	s := &styles{
+		status:           renderer.NewStyle().Foreground(_colorPrimary),
+		box:              renderer.NewStyle().Border(border).BorderForeground(_keyStateUnselected.color()).Padding(0, 1),
+		text:             renderer.NewStyle().Foreground(_colorPrimary),
+		subtext:          renderer.NewStyle().Foreground(_colorSecondary),
+		padTop:           renderer.NewStyle().Padding(0, 2),
+		padMid:           renderer.NewStyle().Padding(0, 4),
+		tileGap:          renderer.NewStyle().MarginRight(tileGap),
+		rowGap:           renderer.NewStyle().MarginBottom(tileGap),
+		renderedKeys:     make(map[renderedKey]string),
+		renderedControls: make(map[control]string),
+	}
+	for state := range s.keys {
+		color := keyState(state).color()
+		s.opponentTiles[state] = renderer.NewStyle().Foreground(color).Render("■")
+		s.letters[state] = renderer.NewStyle().Foreground(color)
+		s.keys[state] = renderer.NewStyle().
+			Padding(0, 1).
+			Border(border).
+			BorderForeground(color).
+			Foreground(color)
+		s.slimTiles[state] = s.keys[state].Padding(0)
+		s.compactTiles[state] = renderer.NewStyle().Background(color).Foreground(_colorTileText)
+	}
+
+	s.flash = s.status.Reverse(true)
+	s.highlight = renderer.NewStyle().Foreground(_colorYellow)
+	s.badge = s.highlight.Render("[common]")
+
+	s.separator = " " + renderer.NewStyle().Foreground(_colorSeparator).Render("//") + " "
+	return s
+}
+
+// renderControls renders a list of controls, separated from each other. If
+// they don't all fit in the given width, the ones at the end are left out. A
+// width of zero means that there is no limit.
+func (s *styles) renderControls(controls []control, width int) string {
+	var sb strings.Builder
+	used := 0
+	for i, c := range controls {
+		rendered, ok := s.renderedControls[c]
+		if !ok {
+			rendered = s.text.Render(c.key) + " " + s.subtext.Render(c.action)
+			s.renderedControls[c] = rendered
+		}
+		if i > 0 {
+			rendered = s.separator + rendered
+		}
+		w := lipgloss.Width(rendered)
+		if width > 0 && used+w > width {
+			break
+		}
+		sb.WriteString(rendered)
+		used += w
+	}
+	return sb.String()
+}
+
+// renderKey renders a key with the given name and state.
+func (s *styles) renderKey(key string, state keyState) string {
+	k := renderedKey{key: key, state: state}
+	if rendered, ok := s.renderedKeys[k]; ok {
+		return rendered
+	}
+	rendered := s.keys[state].Render(key)
+	s.renderedKeys[k] = rendered
+	return rendered
+}
+
+// renderTile renders a tile of the grid with the given letter and state in the
+// given layout. The letter under the cursor is underlined.
+func (s *styles) renderTile(key string, state keyState, layout gridLayout, cursor bool) string {
+	k := renderedKey{key: key, state: state, layout: layout, cursor: cursor}
+	if rendered, ok := s.renderedKeys[k]; ok {
+		return rendered
+	}
+	var style lipgloss.Style
+	switch layout {
+	case _gridLayoutSlim:
+		style = s.slimTiles[state]
+	case _gridLayoutLetters:
+		// Without a border, empty tiles would be invisible.
+		if key == " " {
+			key = "·"
+		}
+		style = s.letters[state]
+	case _gridLayoutCompact:
+		style = s.compactTiles[state]
+	default:
+		style = s.keys[state]
+	}
+	rendered := style.Underline(cursor).UnderlineSpaces(false).Render(key)
+	s.renderedKeys[k] = rendered
+	return rendered
+}
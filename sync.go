@@ -0,0 +1,444 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ajeetdsouza/clidle/pkg/game"
+	"github.com/ajeetdsouza/clidle/store"
+	"github.com/charmbracelet/ssh"
+	"github.com/charmbracelet/wish"
+	"github.com/pkg/errors"
+	gossh "golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// _maxSyncGames is the largest number of games that can be synced at once.
+const _maxSyncGames = 100_000
+
+// newGameUUID returns a random ID that identifies a game across databases, so
+// that syncing the same game twice doesn't duplicate it.
+func newGameUUID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", errors.Wrap(err, "could not generate game UUID")
+	}
+	return hex.EncodeToString(b[:]), nil
+}
+
+// syncGame is a completed game, as sent from a local database to the server.
+type syncGame struct {
+	UUID        string    `json:"uuid"`
+	Answer      string    `json:"answer"`
+	Guesses     []string  `json:"guesses"`
+	Daily       string    `json:"daily,omitempty"`
+	DailyNumber int64     `json:"daily_number,omitempty"`
+	StartedAt   time.Time `json:"started_at"`
+	FinishedAt  time.Time `json:"finished_at"`
+	Penalty     int64     `json:"penalty"`
+	LossPenalty int64     `json:"loss_penalty"`
+	MaxRarity   int64     `json:"max_rarity"`
+}
+
+// syncResult is the server's reply to a sync.
+type syncResult struct {
+	Imported int    `json:"imported"`
+	Skipped  int    `json:"skipped"`
+	Rejected int    `json:"rejected"`
+	Stats    *stats `json:"stats,omitempty"`
+}
+
+// exportGames returns the completed games among the given results, in the
+// same order. Games in progress are left out, since they may still change.
+func exportGames(ctx context.Context, queries *store.Queries, results []store.ListGameResultsRow) ([]syncGame, error) {
+	var games []syncGame
+	for _, result := range results {
+		if !result.FinishedAt.Valid {
+			continue
+		}
+		row, err := queries.GetGame(ctx, result.ID)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not read game")
+		}
+		if !row.Uuid.Valid {
+			continue
+		}
+		guessRows, err := queries.ListGuesses(ctx, sql.NullInt64{Int64: row.ID, Valid: true})
+		if err != nil {
+			return nil, errors.Wrap(err, "could not read guesses")
+		}
+		guesses := make([]string, len(guessRows))
+		for i, guess := range guessRows {
+			guesses[i] = guess.Guess.String
+		}
+		games = append(games, syncGame{
+			UUID:        row.Uuid.String,
+			Answer:      row.Answer.String,
+			Guesses:     guesses,
+			Daily:       row.Daily.String,
+			DailyNumber: row.DailyNumber.Int64,
+			StartedAt:   row.StartedAt.Time,
+			FinishedAt:  row.FinishedAt.Time,
+			Penalty:     row.Penalty,
+			LossPenalty: row.LossPenalty,
+			MaxRarity:   row.MaxRarity,
+		})
+	}
+	return games, nil
+}
+
+// importGames adds games read as lines of JSON to a player's profile, or to
+// the local profile if player is empty. Games that were imported before are
+// skipped, so syncing is append-only and can be repeated safely. Games that
+// couldn't have been played legitimately are rejected. Each game is imported
+// in its own transaction, so a game is never left without its guesses.
+func importGames(ctx context.Context, queries *store.Queries, dictionary Dictionary, player, playerName string, r io.Reader) (syncResult, error) {
+	var result syncResult
+	decoder := json.NewDecoder(r)
+	for n := 0; ; n++ {
+		var g syncGame
+		if err := decoder.Decode(&g); errors.Is(err, io.EOF) {
+			return result, nil
+		} else if err != nil {
+			return result, errors.Wrap(err, "invalid game")
+		}
+		if n >= _maxSyncGames {
+			return result, errors.Errorf("can't sync more than %d games at once", _maxSyncGames)
+		}
+
+		if checkSyncGame(dictionary, &g) != nil {
+			result.Rejected++
+			continue
+		}
+		answer, _ := game.ParseWord(g.Answer)
+		params := store.ImportGameParams{
+			Uuid:        sql.NullString{String: g.UUID, Valid: true},
+			Answer:      sql.NullString{String: g.Answer, Valid: true},
+			Player:      sql.NullString{String: player, Valid: player != ""},
+			PlayerName:  sql.NullString{String: playerName, Valid: playerName != ""},
+			Daily:       sql.NullString{String: g.Daily, Valid: g.Daily != ""},
+			DailyNumber: sql.NullInt64{Int64: g.DailyNumber, Valid: g.Daily != ""},
+			StartedAt:   sql.NullTime{Time: g.StartedAt, Valid: true},
+			FinishedAt:  sql.NullTime{Time: g.FinishedAt, Valid: true},
+			Penalty:     g.Penalty,
+			LossPenalty: g.LossPenalty,
+			MaxRarity:   g.MaxRarity,
+		}
+
+		var imported bool
+		err := store.Retry(ctx, func() error {
+			return queries.InTx(ctx, func(tx *store.Queries) error {
+				id, err := tx.ImportGame(ctx, params)
+				if errors.Is(err, sql.ErrNoRows) {
+					imported = false
+					return nil
+				} else if err != nil {
+					return err
+				}
+				for _, guess := range g.Guesses {
+					guessWord, _ := game.ParseWord(guess)
+					_, err := tx.CreateGuess(ctx, store.CreateGuessParams{
+						GameID:  sql.NullInt64{Int64: id, Valid: true},
+						Guess:   sql.NullString{String: guess, Valid: true},
+						Pattern: sql.NullString{String: game.Evaluate(guessWord, answer).Pattern(), Valid: true},
+					})
+					if err != nil {
+						return err
+					}
+				}
+				imported = true
+				return nil
+			})
+		})
+		if err != nil {
+			return result, errors.Wrap(err, "could not import game")
+		}
+		if imported {
+			result.Imported++
+		} else {
+			result.Skipped++
+		}
+	}
+}
+
+// checkSyncGame checks that a synced game could have been played
+// legitimately. Since the client could send anything, the fields that scores
+// depend on are also bounded: the penalty can't exceed the points the guesses
+// earned, won games have no loss penalty, and the max rarity is one of the
+// known ones.
+func checkSyncGame(dictionary Dictionary, g *syncGame) error {
+	if g.UUID == "" {
+		return errors.New("missing UUID")
+	}
+	if err := checkGuesses(dictionary, g.Answer, g.Guesses, true); err != nil {
+		return err
+	}
+	if g.Penalty < 0 || g.LossPenalty < 0 {
+		return errors.New("negative penalty")
+	}
+	if g.FinishedAt.Before(g.StartedAt) {
+		return errors.New("game finished before it started")
+	}
+
+	// checkGuesses only accepts finished games with at least one guess.
+	won := g.Guesses[len(g.Guesses)-1] == g.Answer
+	g.Penalty = min(g.Penalty, int64(game.Score(len(g.Guesses), won)))
+	if won {
+		g.LossPenalty = 0
+	}
+	g.MaxRarity = min(max(g.MaxRarity, _rarityCommon), _rarityUncommon)
+	return nil
+}
+
+// listPlayerResults returns the results of a single player's games, oldest
+// first.
+func listPlayerResults(ctx context.Context, queries *store.Queries, player string) ([]store.ListGameResultsRow, error) {
+	rows, err := queries.ListPlayerGameResults(ctx, sql.NullString{String: player, Valid: true})
+	if err != nil {
+		return nil, errors.Wrap(err, "could not read games")
+	}
+	results := make([]store.ListGameResultsRow, len(rows))
+	for i, row := range rows {
+		results[i] = store.ListGameResultsRow(row)
+	}
+	return results, nil
+}
+
+// syncMiddleware handles the sync command (ssh host -- sync), which imports
+// games sent by clidle sync into the profile of the connecting player. With
+// --pull, the player's merged stats are sent back.
+func syncMiddleware(dictionary Dictionary, trust *keyTrust) wish.Middleware {
+	return func(next ssh.Handler) ssh.Handler {
+		return func(session ssh.Session) {
+			args := session.Command()
+			if len(args) == 0 || args[0] != "sync" {
+				next(session)
+				return
+			}
+			pull := len(args) == 2 && args[1] == "--pull"
+			if len(args) > 2 || (len(args) == 2 && !pull) {
+				wish.Fatalln(session, "usage: sync [--pull]")
+				return
+			}
+			key := session.PublicKey()
+			if key == nil {
+				wish.Fatalln(session, "sync requires a public key, which identifies your profile")
+				return
+			}
+			player := trust.player(key)
+
+			queries, err := getStore()
+			if err != nil {
+				wish.Fatalln(session, "could not open database")
+				return
+			}
+			ctx, cancel := context.WithTimeout(session.Context(), time.Minute)
+			defer cancel()
+
+			result, err := importGames(ctx, queries, dictionary, player, session.User(), session)
+			if err != nil {
+				slog.Error("error syncing games", slog.String("player", player), slog.Any("error", err))
+				wish.Fatalln(session, err)
+				return
+			}
+			slog.Info("synced games",
+				slog.String("player", player),
+				slog.Int("imported", result.Imported),
+				slog.Int("skipped", result.Skipped),
+				slog.Int("rejected", result.Rejected),
+			)
+			if pull {
+				results, err := listPlayerResults(ctx, queries, player)
+				if err != nil {
+					wish.Fatalln(session, err)
+					return
+				}
+				s := computeStats(results)
+				result.Stats = &s
+			}
+			if err := json.NewEncoder(session).Encode(result); err != nil {
+				slog.Error("error writing sync result", slog.Any("error", err))
+			}
+		}
+	}
+}
+
+// runSync sends the completed games in the local database to a server, which
+// merges them into the profile of the SSH key used to connect.
+func runSync(args []string) error {
+	flags := flag.NewFlagSet("sync", flag.ExitOnError)
+	flagRemote := flags.String("remote", "", "Server to sync with (e.g. ssh://clidle.example.com:3000)")
+	flagIdentity := flags.String("identity", "", "Private key that identifies your profile (default: ssh-agent and ~/.ssh/id_*)")
+	flagPull := flags.Bool("pull", false, "Prints your merged stats from the server")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *flagRemote == "" {
+		return errors.New("--remote is required")
+	}
+
+	addr, user, err := parseRemote(*flagRemote)
+	if err != nil {
+		return err
+	}
+	auth, err := syncAuth(*flagIdentity)
+	if err != nil {
+		return err
+	}
+	hostKeyCallback, err := knownhosts.New(filepath.Join(os.Getenv("HOME"), ".ssh", "known_hosts"))
+	if err != nil {
+		return errors.Wrap(err, "could not read known hosts, connect with ssh once to add the server")
+	}
+
+	queries, err := getStoreReadOnly()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	results, err := queries.ListGameResults(ctx)
+	if err != nil {
+		return errors.Wrap(err, "could not read games")
+	}
+	games, err := exportGames(ctx, queries, results)
+	if err != nil {
+		return err
+	}
+	var input bytes.Buffer
+	encoder := json.NewEncoder(&input)
+	for _, g := range games {
+		if err := encoder.Encode(g); err != nil {
+			return err
+		}
+	}
+
+	client, err := gossh.Dial("tcp", addr, &gossh.ClientConfig{
+		User:            user,
+		Auth:            auth,
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         10 * time.Second,
+	})
+	if err != nil {
+		return errors.Wrapf(err, "could not connect to %s", addr)
+	}
+	defer client.Close()
+	session, err := client.NewSession()
+	if err != nil {
+		return errors.Wrap(err, "could not start session")
+	}
+	defer session.Close()
+
+	var stdout, stderr bytes.Buffer
+	session.Stdin = &input
+	session.Stdout = &stdout
+	session.Stderr = &stderr
+	command := "sync"
+	if *flagPull {
+		command += " --pull"
+	}
+	if err := session.Run(command); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return errors.Errorf("sync failed: %s", msg)
+		}
+		return errors.Wrap(err, "sync failed")
+	}
+
+	var result syncResult
+	if err := json.NewDecoder(&stdout).Decode(&result); err != nil {
+		return errors.Wrap(err, "invalid reply from server")
+	}
+	fmt.Printf("Synced %d new games (%d already on the server)\n", result.Imported, result.Skipped)
+	if result.Rejected > 0 {
+		fmt.Printf("%d games were rejected as invalid\n", result.Rejected)
+	}
+	if result.Stats != nil {
+		fmt.Println()
+		return writeStatsText(os.Stdout, *result.Stats)
+	}
+	return nil
+}
+
+// parseRemote parses a server given as ssh://[user@]host[:port] into an address
+// to dial and a user name, which default to port 22 and the local user.
+func parseRemote(remote string) (addr, user string, err error) {
+	u, err := url.Parse(remote)
+	if err != nil || u.Scheme != "ssh" || u.Hostname() == "" {
+		return "", "", errors.Errorf("invalid remote %q, expected ssh://host[:port]", remote)
+	}
+	port := u.Port()
+	if port == "" {
+		port = "22"
+	}
+	user = u.User.Username()
+	if user == "" {
+		user = os.Getenv("USER")
+	}
+	return net.JoinHostPort(u.Hostname(), port), user, nil
+}
+
+// syncAuth returns the methods with which to authenticate to the server: the
+// given private key, or otherwise the keys in ssh-agent and the default key
+// files. Keys protected by a passphrase are only usable through ssh-agent.
+func syncAuth(identity string) ([]gossh.AuthMethod, error) {
+	var signers []gossh.Signer
+	if identity != "" {
+		signer, err := readPrivateKey(identity)
+		if err != nil {
+			return nil, err
+		}
+		signers = append(signers, signer)
+	} else {
+		if sock := os.Getenv("SSH_AUTH_SOCK"); sock != "" {
+			if conn, err := net.Dial("unix", sock); err == nil {
+				agentSigners, err := agent.NewClient(conn).Signers()
+				if err == nil {
+					signers = append(signers, agentSigners...)
+				}
+			}
+		}
+		for _, name := range []string{"id_ed25519", "id_ecdsa", "id_rsa"} {
+			path := filepath.Join(os.Getenv("HOME"), ".ssh", name)
+			if _, err := os.Stat(path); err != nil {
+				continue
+			}
+			signer, err := readPrivateKey(path)
+			if err != nil {
+				slog.Debug("skipping private key", slog.String("path", path), slog.Any("error", err))
+				continue
+			}
+			signers = append(signers, signer)
+		}
+	}
+	if len(signers) == 0 {
+		return nil, errors.New("no SSH keys found, use --identity to choose one")
+	}
+	return []gossh.AuthMethod{gossh.PublicKeys(signers...)}, nil
+}
+
+// readPrivateKey reads an unencrypted private key from a file.
+func readPrivateKey(path string) (gossh.Signer, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not read private key")
+	}
+	signer, err := gossh.ParsePrivateKey(b)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not parse private key %s", path)
+	}
+	return signer, nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ajeetdsouza/clidle/pkg/game"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// _saveTitle and _restoreTitle push the terminal title onto the terminal's
+// stack of titles, and pop it back. Terminals that don't keep such a stack
+// ignore them.
+const (
+	_saveTitle    = "\x1b[22;0t"
+	_restoreTitle = "\x1b[23;0t"
+)
+
+// title returns the terminal title, such as "clidle — guess 3/6" in the
+// terminal, or "clidle @ host — daily #87, guess 3/6" on a server.
+func (m *model) title() string {
+	title := "clidle"
+	if m.host != "" {
+		title += " @ " + m.host
+	}
+	if m.game == nil || m.screen == screenHome || m.isWaitingForOpponent() {
+		return title
+	}
+
+	var progress []string
+	if m.daily != "" {
+		progress = append(progress, fmt.Sprintf("daily #%d", m.dailyNumber))
+	}
+	switch m.game.State() {
+	case game.StateWon:
+		progress = append(progress, fmt.Sprintf("solved in %d/%d", m.gridRow, _numGuesses))
+	case game.StateLost:
+		progress = append(progress, fmt.Sprintf("X/%d", _numGuesses))
+	default:
+		progress = append(progress, fmt.Sprintf("guess %d/%d", min(m.gridRow+1, _numGuesses), _numGuesses))
+	}
+	return title + " — " + strings.Join(progress, ", ")
+}
+
+// updateTitle sets the terminal title if it has changed, unless the output
+// isn't a terminal or the title setting is off.
+func (m *model) updateTitle() tea.Cmd {
+	if !m.canSetTitle || !m.options.title {
+		return nil
+	}
+	title := m.title()
+	if title == m.windowTitle {
+		return nil
+	}
+	m.windowTitle = title
+	return tea.SetWindowTitle(title)
+}
+
+// saveTitle saves the terminal title before the program starts, if it is going
+// to be changed.
+func (m *model) saveTitle(w io.Writer) {
+	if m.canSetTitle && m.options.title {
+		io.WriteString(w, _saveTitle)
+		m.titleSaved = true
+	}
+}
+
+// restoreTitle restores the terminal title saved by saveTitle once the program
+// has exited.
+func (m *model) restoreTitle(w io.Writer) {
+	if m.titleSaved {
+		io.WriteString(w, _restoreTitle)
+	}
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+
+	"github.com/charmbracelet/ssh"
+	"github.com/pkg/errors"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// _certPrefix identifies players who connect with a certificate signed by a
+// trusted certificate authority, as in cert:alice. Unlike fingerprints, the
+// identity stays the same when their certificate is renewed.
+const _certPrefix = "cert:"
+
+// keyTrust decides which keys are let in, and who they belong to. Players
+// with a plain key are identified by its fingerprint, and players with a user
+// certificate signed by a trusted certificate authority by its key ID, so both
+// kinds of identities coexist. A nil *keyTrust trusts no authority, and revokes
+// nothing.
+type keyTrust struct {
+	// authorities are the keys of the trusted certificate authorities.
+	authorities []gossh.PublicKey
+	// revoked are the revoked keys and certificates, if any.
+	revoked *revocationList
+	clock   clock
+}
+
+// loadKeyTrust reads the trusted certificate authorities from caPath, in the
+// authorized_keys format, and the revoked keys from krlPath. Either path may
+// be empty.
+func loadKeyTrust(caPath, krlPath string) (*keyTrust, error) {
+	trust := &keyTrust{clock: realClock{}}
+	if caPath != "" {
+		b, err := os.ReadFile(caPath)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not read trusted user CA keys")
+		}
+		for rest := b; len(bytes.TrimSpace(rest)) > 0; {
+			var key gossh.PublicKey
+			key, _, _, rest, err = gossh.ParseAuthorizedKey(rest)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid trusted user CA keys %s", caPath)
+			}
+			trust.authorities = append(trust.authorities, key)
+		}
+		if len(trust.authorities) == 0 {
+			return nil, errors.Errorf("no keys in trusted user CA keys %s", caPath)
+		}
+	}
+	if krlPath != "" {
+		revoked, err := loadRevocationList(krlPath)
+		if err != nil {
+			return nil, err
+		}
+		trust.revoked = revoked
+	}
+	return trust, nil
+}
+
+// authorize checks whether a key is let in. Plain keys are, unless they are
+// revoked. Once an authority is trusted, certificates are only let in if they
+// are user certificates signed by one, currently valid, not revoked, and name
+// the player. Otherwise, they are treated like plain keys.
+func (t *keyTrust) authorize(key gossh.PublicKey) error {
+	if t == nil {
+		return nil
+	}
+	if t.revoked.revoked(key) {
+		return errors.New("key is revoked")
+	}
+	cert, ok := key.(*gossh.Certificate)
+	if !ok || len(t.authorities) == 0 {
+		return nil
+	}
+	if !t.trusted(cert) {
+		return errors.New("certificate is not signed by a trusted authority")
+	}
+	if cert.CertType != gossh.UserCert {
+		return errors.Errorf("certificate has type %d, not a user certificate", cert.CertType)
+	}
+	if certName(cert) == "" {
+		return errors.New("certificate has no key ID or principals")
+	}
+	checker := gossh.CertChecker{
+		Clock:     t.clock.Now,
+		IsRevoked: func(cert *gossh.Certificate) bool { return t.revoked.revoked(cert) },
+	}
+	// Players aren't bound to the user name they connect with, so any
+	// principal in the certificate is accepted.
+	var principal string
+	if len(cert.ValidPrincipals) > 0 {
+		principal = cert.ValidPrincipals[0]
+	}
+	return checker.CheckCert(principal, cert)
+}
+
+// trusted reports whether a certificate is signed by a trusted authority.
+func (t *keyTrust) trusted(cert *gossh.Certificate) bool {
+	if t == nil {
+		return false
+	}
+	for _, authority := range t.authorities {
+		if bytes.Equal(authority.Marshal(), cert.SignatureKey.Marshal()) {
+			return true
+		}
+	}
+	return false
+}
+
+// player returns the identity of the player with the given key, or "" if there
+// is none. Certificates are only identified by their key ID if they are signed
+// by a trusted authority, and otherwise by their fingerprint like plain keys.
+func (t *keyTrust) player(key gossh.PublicKey) string {
+	if key == nil {
+		return ""
+	}
+	if cert, ok := key.(*gossh.Certificate); ok && t.trusted(cert) {
+		return _certPrefix + certName(cert)
+	}
+	return gossh.FingerprintSHA256(key)
+}
+
+// displayName returns the name shown for a player, which is the first principal
+// of a trusted certificate, or else the user name they connect with.
+func (t *keyTrust) displayName(key gossh.PublicKey, user string) string {
+	if cert, ok := key.(*gossh.Certificate); ok && t.trusted(cert) && len(cert.ValidPrincipals) > 0 {
+		return cert.ValidPrincipals[0]
+	}
+	return user
+}
+
+// certName returns the name that identifies the holder of a certificate: its
+// key ID, or its first principal if it has none.
+func certName(cert *gossh.Certificate) string {
+	if cert.KeyId != "" {
+		return cert.KeyId
+	}
+	if len(cert.ValidPrincipals) > 0 {
+		return cert.ValidPrincipals[0]
+	}
+	return ""
+}
+
+// ctxKeyRejectedKey is set in the context of a connection once a key offered
+// by the client was rejected, so that it doesn't fall back to playing
+// anonymously.
+type ctxKeyRejectedKey struct{}
+
+// publicKeyHandler lets in the keys that the trust allows. The certificate's
+// critical options are passed on, so that a source-address restriction is
+// enforced.
+func (t *keyTrust) publicKeyHandler(ctx ssh.Context, key ssh.PublicKey) bool {
+	if err := t.authorize(key); err != nil {
+		slog.Info("rejected key",
+			slog.String("user", ctx.User()),
+			slog.String("remote", ctx.RemoteAddr().String()),
+			slog.String("fingerprint", gossh.FingerprintSHA256(key)),
+			slog.Any("error", err),
+		)
+		ctx.SetValue(ctxKeyRejectedKey{}, err.Error())
+		return false
+	}
+	var criticalOptions map[string]string
+	if cert, ok := key.(*gossh.Certificate); ok && t.trusted(cert) {
+		criticalOptions = cert.CriticalOptions
+	}
+	ctx.Permissions().CriticalOptions = criticalOptions
+	return true
+}
+
+// keyboardInteractiveHandler lets in players without a key, unless a key they
+// offered was rejected.
+func (t *keyTrust) keyboardInteractiveHandler(ctx ssh.Context, _ gossh.KeyboardInteractiveChallenge) bool {
+	return ctx.Value(ctxKeyRejectedKey{}) == nil
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	gossh "golang.org/x/crypto/ssh"
+)
+
+func TestKeyTrust(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	ca, caSigner := newTestKey(t)
+	_, otherSigner := newTestKey(t)
+	plain, _ := newTestKey(t)
+	revokedPlain, _ := newTestKey(t)
+
+	newCert := func(signer gossh.Signer, serial uint64, keyID string, principals []string, validBefore time.Time) *gossh.Certificate {
+		t.Helper()
+		key, _ := newTestKey(t)
+		cert := &gossh.Certificate{
+			Key:             key,
+			Serial:          serial,
+			CertType:        gossh.UserCert,
+			KeyId:           keyID,
+			ValidPrincipals: principals,
+			ValidAfter:      uint64(now.Add(-time.Hour).Unix()),
+			ValidBefore:     uint64(validBefore.Unix()),
+		}
+		if err := cert.SignCert(rand.Reader, signer); err != nil {
+			t.Fatal(err)
+		}
+		return cert
+	}
+	tomorrow := now.Add(24 * time.Hour)
+	alice := newCert(caSigner, 1, "alice@example.com", []string{"alice"}, tomorrow)
+	renewed := newCert(caSigner, 2, "alice@example.com", []string{"alice"}, tomorrow)
+	expired := newCert(caSigner, 3, "bob@example.com", []string{"bob"}, now.Add(-time.Minute))
+	untrusted := newCert(otherSigner, 4, "mallory@example.com", []string{"mallory"}, tomorrow)
+	bySerial := newCert(caSigner, 10, "carol@example.com", []string{"carol"}, tomorrow)
+	byRange := newCert(caSigner, 105, "dave@example.com", nil, tomorrow)
+	byKeyID := newCert(caSigner, 20, "eve@example.com", []string{"eve"}, tomorrow)
+
+	caPath := filepath.Join(dir, "ca.pub")
+	if err := os.WriteFile(caPath, gossh.MarshalAuthorizedKey(ca), 0600); err != nil {
+		t.Fatal(err)
+	}
+	krlPath := filepath.Join(dir, "revoked.krl")
+	krl := testKRL(ca,
+		krlSerials(10),
+		krlRange(100, 110),
+		krlKeyIDs("eve@example.com"),
+	)
+	krl = append(krl, testKRLSection(_krlSectionExplicitKey, testString(revokedPlain.Marshal()))...)
+	if err := os.WriteFile(krlPath, krl, 0600); err != nil {
+		t.Fatal(err)
+	}
+	trust, err := loadKeyTrust(caPath, krlPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	trust.clock = newFakeClock(now)
+
+	for _, tt := range []struct {
+		name   string
+		key    gossh.PublicKey
+		player string
+		ok     bool
+	}{
+		{"plain key", plain, gossh.FingerprintSHA256(plain), true},
+		{"certificate", alice, "cert:alice@example.com", true},
+		{"renewed certificate", renewed, "cert:alice@example.com", true},
+		{"expired certificate", expired, "", false},
+		{"untrusted authority", untrusted, "", false},
+		{"revoked serial", bySerial, "", false},
+		{"revoked serial range", byRange, "", false},
+		{"revoked key ID", byKeyID, "", false},
+		{"revoked plain key", revokedPlain, "", false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			err := trust.authorize(tt.key)
+			if (err == nil) != tt.ok {
+				t.Fatalf("authorize() = %v, want ok = %t", err, tt.ok)
+			}
+			if tt.ok {
+				if player := trust.player(tt.key); player != tt.player {
+					t.Errorf("player() = %q, want %q", player, tt.player)
+				}
+			}
+		})
+	}
+
+	if name := trust.displayName(alice, "someone"); name != "alice" {
+		t.Errorf("expected the principal as the display name, got %q", name)
+	}
+	if name := trust.displayName(plain, "someone"); name != "someone" {
+		t.Errorf("expected the user name as the display name, got %q", name)
+	}
+
+	// Without a trusted authority, certificates are identified like plain
+	// keys, by their fingerprint.
+	var none *keyTrust
+	if err := none.authorize(untrusted); err != nil {
+		t.Errorf("expected certificates to be let in without a trusted authority, got %v", err)
+	}
+	if player := none.player(untrusted); player != gossh.FingerprintSHA256(untrusted) {
+		t.Errorf("expected the fingerprint of an untrusted certificate, got %q", player)
+	}
+}
+
+func TestRevocationListText(t *testing.T) {
+	revoked, _ := newTestKey(t)
+	other, _ := newTestKey(t)
+	path := filepath.Join(t.TempDir(), "revoked_keys")
+	data := append([]byte("# revoked\n\n"), gossh.MarshalAuthorizedKey(revoked)...)
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	krl, err := loadRevocationList(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !krl.revoked(revoked) || krl.revoked(other) {
+		t.Error("expected only the listed key to be revoked")
+	}
+
+	// A truncated KRL is an error, rather than revoking nothing.
+	if _, err := parseKRL([]byte(_krlMagic + "\x00\x00")); err == nil {
+		t.Error("expected an error for a truncated KRL")
+	}
+}
+
+func newTestKey(t *testing.T) (gossh.PublicKey, gossh.Signer) {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := gossh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer.PublicKey(), signer
+}
+
+// testKRL returns a KRL with a certificate section for the given authority,
+// holding the given certificate subsections.
+func testKRL(ca gossh.PublicKey, subsections ...[]byte) []byte {
+	b := []byte(_krlMagic)
+	b = binary.BigEndian.AppendUint32(b, 1)
+	b = binary.BigEndian.AppendUint64(b, 1) // krl_version
+	b = binary.BigEndian.AppendUint64(b, 0) // generated_date
+	b = binary.BigEndian.AppendUint64(b, 0) // flags
+	b = append(b, testString(nil)...)       // reserved
+	b = append(b, testString([]byte("test"))...)
+
+	certs := append(testString(ca.Marshal()), testString(nil)...)
+	for _, subsection := range subsections {
+		certs = append(certs, subsection...)
+	}
+	return append(b, testKRLSection(_krlSectionCertificates, certs)...)
+}
+
+func testKRLSection(sectionType byte, data []byte) []byte {
+	return append([]byte{sectionType}, testString(data)...)
+}
+
+func krlSerials(serials ...uint64) []byte {
+	var data []byte
+	for _, serial := range serials {
+		data = binary.BigEndian.AppendUint64(data, serial)
+	}
+	return testKRLSection(_krlCertSerialList, data)
+}
+
+func krlRange(lo, hi uint64) []byte {
+	data := binary.BigEndian.AppendUint64(nil, lo)
+	return testKRLSection(_krlCertSerialRange, binary.BigEndian.AppendUint64(data, hi))
+}
+
+func krlKeyIDs(keyIDs ...string) []byte {
+	var data []byte
+	for _, keyID := range keyIDs {
+		data = append(data, testString([]byte(keyID))...)
+	}
+	return testKRLSection(_krlCertKeyID, data)
+}
+
+func testString(b []byte) []byte {
+	return append(binary.BigEndian.AppendUint32(nil, uint32(len(b))), b...)
+}
+
+func TestRoster(t *testing.T) {
+	ca, caSigner := newTestKey(t)
+	shared, _ := newTestKey(t)
+	stranger, _ := newTestKey(t)
+	trust := &keyTrust{authorities: []gossh.PublicKey{ca}, clock: realClock{}}
+	userKey, _ := newTestKey(t)
+	cert := &gossh.Certificate{
+		Key:             userKey,
+		CertType:        gossh.UserCert,
+		KeyId:           "bob@example.com",
+		ValidPrincipals: []string{"bob", "family"},
+		ValidBefore:     gossh.CertTimeInfinity,
+	}
+	if err := cert.SignCert(rand.Reader, caSigner); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "roster.json")
+	write := func(data string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(`{"players": [
+		{"fingerprint": "` + gossh.FingerprintSHA256(shared) + `", "name": "Mum", "role": "admin"},
+		{"principal": "family", "name": "Bob"}
+	]}`)
+	r, err := loadRoster(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if entry, ok := r.lookup(shared, trust); !ok || entry.Name != "Mum" || !r.admin(shared, trust) {
+		t.Errorf("unexpected entry for the shared key: %+v", entry)
+	}
+	if entry, ok := r.lookup(cert, trust); !ok || entry.Name != "Bob" || entry.Role != _rolePlayer || r.admin(cert, trust) {
+		t.Errorf("unexpected entry for the certificate: %+v", entry)
+	}
+	if _, ok := r.lookup(stranger, trust); ok {
+		t.Error("expected no entry for an unknown key")
+	}
+	// Principals of untrusted certificates aren't matched.
+	if _, ok := r.lookup(cert, nil); ok {
+		t.Error("expected no entry for an untrusted certificate")
+	}
+
+	// An invalid roster is rejected on reload, and the previous one is kept.
+	write(`{"players": [{"fingerprint": "SHA256:x", "role": "owner"}]}`)
+	if err := r.reload(); err == nil {
+		t.Error("expected an error for an unknown role")
+	}
+	if !r.admin(shared, trust) {
+		t.Error("expected the previous roster to be kept")
+	}
+	write(`{"players": [{"principal": "family", "name": "Bobby"}]}`)
+	if err := r.reload(); err != nil {
+		t.Fatal(err)
+	}
+	if entry, _ := r.lookup(cert, trust); entry.Name != "Bobby" || r.admin(shared, trust) {
+		t.Errorf("expected the roster to be reloaded, got %+v", entry)
+	}
+}
+
+func TestGuest(t *testing.T) {
+	answer := func(nickname, token string) gossh.KeyboardInteractiveChallenge {
+		return func(string, string, []string, []bool) ([]string, error) {
+			return []string{nickname, token}, nil
+		}
+	}
+
+	// A new guest gets a token, which they can enter next time to play as
+	// the same player, however they write it.
+	first, err := askGuest("clidle", answer("  Ada\x1b[31m ", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.name != "Ada[31m" || !first.fresh || !isGuest(first.player()) {
+		t.Fatalf("unexpected guest: %+v", first)
+	}
+	messy := strings.ToLower(strings.ReplaceAll(first.token, "-", " "))
+	second, err := askGuest("clidle", answer("", messy))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.player() != first.player() || second.fresh || second.name != "clidle" {
+		t.Errorf("expected the same guest with the default name, got %+v", second)
+	}
+	if _, err := askGuest("clidle", answer("Ada", "not-a-token")); err == nil {
+		t.Error("expected an error for an invalid token")
+	}
+
+	// Guests are labeled on leaderboards, within the width of the name.
+	if got := leaderboardName("Ada Lovelace", first.player(), 16); got != "Ada Love (guest)" {
+		t.Errorf("leaderboardName() = %q", got)
+	}
+	if got := leaderboardName("", "SHA256:abc", 16); got != "anonymous" {
+		t.Errorf("leaderboardName() = %q", got)
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+
+	"github.com/ajeetdsouza/clidle/pkg/game"
+	"github.com/ajeetdsouza/clidle/store"
+	"github.com/pkg/errors"
+)
+
+// checkGuesses verifies that a sequence of guesses could have been produced by
+// a legitimate game with the given answer. A finished game needs at least one
+// guess, but need not have been won or run out of guesses: a duel round ends
+// early for the player who loses it.
+func checkGuesses(dictionary Dictionary, answer string, guesses []string, finished bool) error {
+	if !dictionary.IsWord(answer) {
+		return errors.Errorf("invalid answer %q", answer)
+	}
+
+	var answerWord game.Word
+	copy(answerWord[:], answer)
+	g := game.New(answerWord, dictionary)
+	for idx, guess := range guesses {
+		if _, err := g.Guess(guess); errors.Is(err, game.ErrGameOver) {
+			return errors.Errorf("guess %d was made after the game was over", idx+1)
+		} else if err != nil {
+			return errors.Wrapf(err, "guess %d is invalid (%q)", idx+1, guess)
+		}
+	}
+
+	if finished && len(guesses) == 0 {
+		return errors.New("game finished without any guesses")
+	}
+	return nil
+}
+
+// validateGame checks a stored game for consistency, and flags it if it fails.
+// Flagged games are excluded from leaderboards.
+func validateGame(ctx context.Context, queries *store.Queries, dictionary Dictionary, gameID int64) error {
+	game, err := queries.GetGame(ctx, gameID)
+	if err != nil {
+		return err
+	}
+	guessRows, err := queries.ListGuesses(ctx, sql.NullInt64{Int64: gameID, Valid: true})
+	if err != nil {
+		return err
+	}
+	guesses := make([]string, len(guessRows))
+	for i, guess := range guessRows {
+		guesses[i] = guess.Guess.String
+	}
+
+	reason := checkGuesses(dictionary, game.Answer.String, guesses, game.FinishedAt.Valid)
+	if reason == nil {
+		return nil
+	}
+	slog.Warn("flagging inconsistent game",
+		slog.Int64("game", gameID),
+		slog.String("player", game.Player.String),
+		slog.String("reason", reason.Error()),
+	)
+	return store.Retry(ctx, func() error { return queries.FlagGame(ctx, gameID) })
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"math/rand"
+	"strings"
+
+	"github.com/ajeetdsouza/clidle/pkg/game"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+)
+
+// computeWalkthrough returns the guesses an entropy-based solver would have
+// made to find the answer of a lost game, starting with the player's first
+// guess. It is empty unless the walkthrough is enabled.
+func (m *model) computeWalkthrough() []game.Word {
+	if !m.options.walkthrough || m.game.State() != game.StateLost {
+		return nil
+	}
+	candidates := wordsToBytes(m.dictionary.commonWords)
+	rng := rand.New(rand.NewSource(0))
+	return solvePath(candidates, strategyEntropy, m.game.Guesses()[0], m.game.Answer(), rng)
+}
+
+// viewWalkthrough renders the solver's guesses as rows of colored letters,
+// including a border, in at most the given number of lines. Without colors,
+// each row is followed by its pattern, as in CPAAA. If not even one row fits,
+// it returns an empty string.
+func (m *model) viewWalkthrough(maxHeight int) string {
+	// The border and the title take up three lines.
+	numRows := min(len(m.walkthrough), maxHeight-3)
+	if numRows <= 0 {
+		return ""
+	}
+
+	title := "How the solver would have found it"
+	if m.walkthrough[len(m.walkthrough)-1] != m.game.Answer() {
+		title = "The solver couldn't find it either"
+	}
+	rows := []string{m.styles.text.Render(title)}
+	answer := m.game.Answer()
+	for _, word := range m.walkthrough[:numRows] {
+		feedback := game.Evaluate(word, answer)
+		letters := make([]string, _numChars)
+		for i := range word {
+			letter := string(word[i])
+			if m.options.lowercase {
+				letter = strings.ToLower(letter)
+			}
+			letters[i] = m.styles.letters[keyState(feedback[i])].Render(letter)
+		}
+		row := strings.Join(letters, " ")
+		if m.renderer.ColorProfile() == termenv.Ascii {
+			row += "  " + m.styles.subtext.Render(feedback.Pattern())
+		}
+		rows = append(rows, row)
+	}
+	return m.styles.box.Render(lipgloss.JoinVertical(lipgloss.Center, rows...))
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ajeetdsouza/clidle/store"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/pkg/errors"
+)
+
+// _webCacheTTL is how long the web leaderboard is cached for, so that busy
+// pages don't query the database on every request.
+const _webCacheTTL = 30 * time.Second
+
+// webLeaderboard serves a read-only page with today's daily leaderboard and the
+// all-time top scores, so that standings can be checked without connecting.
+// Players who have opted out are left out.
+type webLeaderboard struct {
+	store   *store.Queries
+	options options
+	clock   clock
+
+	mu        sync.Mutex
+	page      *webPage
+	fetchedAt time.Time
+}
+
+func newWebLeaderboard(store *store.Queries, options options, clock clock) *webLeaderboard {
+	return &webLeaderboard{store: store, options: options, clock: clock}
+}
+
+// webPage is the content of the web leaderboard.
+type webPage struct {
+	Daily       string          `json:"daily"`
+	DailyNumber int             `json:"daily_number"`
+	Leaderboard []webDailyEntry `json:"leaderboard"`
+	TopScores   []webTopScore   `json:"top_scores"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+}
+
+// webDailyEntry is an entry of the daily leaderboard.
+type webDailyEntry struct {
+	Name      string  `json:"name"`
+	Guest     bool    `json:"guest"`
+	Guesses   int     `json:"guesses"`
+	SolveTime float64 `json:"solve_time_seconds"`
+}
+
+// webTopScore is an entry of the all-time top scores.
+type webTopScore struct {
+	Name   string `json:"name"`
+	Guest  bool   `json:"guest"`
+	Points int    `json:"points"`
+	Wins   int    `json:"wins"`
+}
+
+// handler returns the handler serving the web leaderboard.
+func (l *webLeaderboard) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /leaderboard", l.handleLeaderboard)
+	return mux
+}
+
+// handleLeaderboard serves the leaderboard as HTML, or as JSON to clients that
+// ask for it with ?format=json or an Accept header.
+func (l *webLeaderboard) handleLeaderboard(w http.ResponseWriter, r *http.Request) {
+	page, err := l.load(r.Context())
+	if err != nil {
+		slog.Error("error loading web leaderboard", slog.Any("error", err))
+		http.Error(w, "could not load the leaderboard", http.StatusInternalServerError)
+		return
+	}
+
+	if r.URL.Query().Get("format") == "json" || strings.Contains(r.Header.Get("Accept"), "application/json") {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(page)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := _webTemplate.Execute(w, page); err != nil {
+		slog.Error("error rendering web leaderboard", slog.Any("error", err))
+	}
+}
+
+// load returns the leaderboard, querying the database at most once every
+// _webCacheTTL.
+func (l *webLeaderboard) load(ctx context.Context) (*webPage, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.clock.Now()
+	if l.page != nil && now.Sub(l.fetchedAt) < _webCacheTTL {
+		return l.page, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	today := dailyDate(now, l.options.dailyLocation)
+	page := &webPage{
+		Daily:       today.Format(time.DateOnly),
+		DailyNumber: dailyNumber(today, l.options.dailyEpoch),
+		Leaderboard: []webDailyEntry{},
+		TopScores:   []webTopScore{},
+		UpdatedAt:   now,
+	}
+	daily, err := l.store.GetWebDailyLeaderboard(ctx, store.GetWebDailyLeaderboardParams{
+		Daily: sql.NullString{String: page.Daily, Valid: true},
+		Limit: _leaderboardSize,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "could not fetch daily leaderboard")
+	}
+	for _, entry := range daily {
+		name := leaderboardName(entry.PlayerName.String, "", 0)
+		solveTime := entry.FinishedAt.Time.Sub(entry.StartedAt.Time).Round(time.Second)
+		page.Leaderboard = append(page.Leaderboard, webDailyEntry{
+			Name:      name,
+			Guest:     isGuest(entry.Player.String),
+			Guesses:   int(entry.NumGuesses),
+			SolveTime: solveTime.Seconds(),
+		})
+	}
+
+	top, err := l.store.GetTopScores(ctx, _leaderboardSize)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not fetch top scores")
+	}
+	for _, entry := range top {
+		page.TopScores = append(page.TopScores, webTopScore{
+			Name:   leaderboardName(entry.PlayerName, "", 0),
+			Guest:  isGuest(entry.Player.String),
+			Points: int(entry.Points),
+			Wins:   int(entry.Wins),
+		})
+	}
+
+	l.page, l.fetchedAt = page, now
+	return page, nil
+}
+
+// _webTemplate renders the web leaderboard as HTML.
+var _webTemplate = template.Must(template.New("leaderboard").Funcs(template.FuncMap{
+	"inc":      func(i int) int { return i + 1 },
+	"thousand": formatThousands,
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>clidle leaderboard</title>
+<style>
+body { background: #121213; color: #d7dadc; font-family: monospace; max-width: 40em; margin: 2em auto; padding: 0 1em; }
+h1, h2 { font-weight: normal; }
+table { border-collapse: collapse; width: 100%; }
+td, th { padding: 0.2em 0.5em; text-align: left; }
+.num { text-align: right; }
+.muted { color: #626262; }
+</style>
+</head>
+<body>
+<h1>clidle</h1>
+<h2>Daily #{{.DailyNumber}} ({{.Daily}})</h2>
+{{if .Leaderboard}}<table>
+{{range $i, $e := .Leaderboard}}<tr><td class="num">{{inc $i}}.</td><td>{{$e.Name}}{{if $e.Guest}} <span class="muted">(guest)</span>{{end}}</td><td class="num">{{$e.Guesses}}/6</td><td class="num">{{$e.SolveTime}}s</td></tr>
+{{end}}</table>{{else}}<p class="muted">No one has solved it yet.</p>{{end}}
+<h2>Top scores</h2>
+{{if .TopScores}}<table>
+{{range $i, $e := .TopScores}}<tr><td class="num">{{inc $i}}.</td><td>{{$e.Name}}{{if $e.Guest}} <span class="muted">(guest)</span>{{end}}</td><td class="num">{{thousand $e.Points}} points</td><td class="num">{{$e.Wins}} wins</td></tr>
+{{end}}</table>{{else}}<p class="muted">No scores yet.</p>{{end}}
+<p class="muted">Updated {{.UpdatedAt.UTC.Format "2006-01-02 15:04:05 MST"}}</p>
+</body>
+</html>
+`))
+
+// newWebServer creates the server of the web leaderboard, which runs alongside
+// the SSH server.
+func newWebServer(options options) (*http.Server, error) {
+	openStore := getStore
+	if options.noPersist {
+		openStore = getMemoryStore
+	}
+	queries, err := openStore()
+	if err != nil {
+		return nil, err
+	}
+	return &http.Server{
+		Handler:           newWebLeaderboard(queries, options, realClock{}).handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}, nil
+}
+
+// loadHideFromWeb loads whether the player prefers to be left out of the web
+// leaderboard. The preference is only kept for players with a key.
+func (m *model) loadHideFromWeb() {
+	if m.player == "" {
+		return
+	}
+	ctx, cancel := context.WithTimeout(m.ctx, 5*time.Second)
+	defer cancel()
+
+	hide, err := m.store.GetHideFromWeb(ctx, m.player)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		slog.Error("error fetching web leaderboard preference", slog.Any("error", err))
+	}
+	m.hideFromWeb = hide
+}
+
+// doSaveHideFromWeb queues saving whether the player prefers to be left out of
+// the web leaderboard.
+func (m *model) doSaveHideFromWeb() tea.Cmd {
+	if m.player == "" {
+		return nil
+	}
+	params := store.SetHideFromWebParams{Player: m.player, HideFromWeb: m.hideFromWeb}
+	return m.writes.enqueue(func() tea.Msg {
+		ctx, cancel := context.WithTimeout(m.writeCtx, 5*time.Second)
+		defer cancel()
+		err := store.Retry(ctx, func() error { return m.store.SetHideFromWeb(ctx, params) })
+		return msgSaved{err: err}
+	})
+}
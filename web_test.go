@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ajeetdsouza/clidle/store"
+)
+
+func TestWebLeaderboard(t *testing.T) {
+	db, err := sql.Open("sqlite", "file::memory:?_time_format=sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	if err := migrate(db); err != nil {
+		t.Fatal(err)
+	}
+	queries := store.New(db)
+	ctx := context.Background()
+
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	clock := newFakeClock(now)
+	options := options{dailyLocation: time.UTC, dailyEpoch: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+
+	// solve saves a daily game won by the player in the given guesses.
+	solve := func(player, name string, guesses ...string) {
+		t.Helper()
+		row, err := queries.CreateGame(ctx, store.CreateGameParams{
+			Answer:     sql.NullString{String: "TRACE", Valid: true},
+			Player:     sql.NullString{String: player, Valid: true},
+			PlayerName: sql.NullString{String: name, Valid: true},
+			Daily:      sql.NullString{String: "2024-03-01", Valid: true},
+			StartedAt:  sql.NullTime{Time: now.Add(-time.Minute), Valid: true},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, guess := range guesses {
+			params := store.CreateGuessParams{GameID: sql.NullInt64{Int64: row.ID, Valid: true}, Guess: sql.NullString{String: guess, Valid: true}}
+			if _, err := queries.CreateGuess(ctx, params); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := queries.FinishGame(ctx, store.FinishGameParams{FinishedAt: sql.NullTime{Time: now, Valid: true}, ID: row.ID}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	solve("SHA256:alice", "alice", "CRANE", "TRACE")
+	solve("SHA256:bob", "bob", "TRACE")
+
+	server := httptest.NewServer(newWebLeaderboard(queries, options, clock).handler())
+	defer server.Close()
+	getPage := func() webPage {
+		t.Helper()
+		var page webPage
+		if status := doAPI(t, "GET", server.URL+"/leaderboard?format=json", "", &page); status != http.StatusOK {
+			t.Fatalf("GET /leaderboard = %d, want %d", status, http.StatusOK)
+		}
+		return page
+	}
+
+	page := getPage()
+	if page.DailyNumber != 61 || len(page.Leaderboard) != 2 || page.Leaderboard[0].Name != "bob" || page.Leaderboard[1].Guesses != 2 {
+		t.Fatalf("unexpected leaderboard: %+v", page)
+	}
+	if len(page.TopScores) != 2 || page.TopScores[0].Name != "bob" || page.TopScores[0].Points != 100 || page.TopScores[0].Wins != 1 {
+		t.Fatalf("unexpected top scores: %+v", page.TopScores)
+	}
+
+	// Opting out only shows once the cached page expires.
+	if err := queries.SetHideFromWeb(ctx, store.SetHideFromWebParams{Player: "SHA256:bob", HideFromWeb: true}); err != nil {
+		t.Fatal(err)
+	}
+	if page := getPage(); len(page.Leaderboard) != 2 {
+		t.Errorf("expected the cached leaderboard, got %+v", page.Leaderboard)
+	}
+	clock.Advance(_webCacheTTL)
+	page = getPage()
+	if len(page.Leaderboard) != 1 || page.Leaderboard[0].Name != "alice" || len(page.TopScores) != 1 || page.TopScores[0].Name != "alice" {
+		t.Errorf("expected bob to be left out, got %+v", page)
+	}
+
+	resp, err := http.Get(server.URL + "/leaderboard")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") || !strings.Contains(string(body), "alice") || strings.Contains(string(body), "bob") {
+		t.Errorf("unexpected HTML leaderboard: %s", body)
+	}
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ajeetdsouza/clidle/pkg/game"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/pkg/errors"
+)
+
+const (
+	// _webhookStreakLength is the number of wins in a row after which a
+	// streak is announced, and again at every multiple of it.
+	_webhookStreakLength = 7
+	// _webhookQueueSize is the number of events waiting to be sent, beyond
+	// which new events are dropped.
+	_webhookQueueSize = 64
+	// _webhookMaxAttempts is the number of times an event is sent before
+	// giving up on it.
+	_webhookMaxAttempts = 5
+	// _webhookBackoff is the delay before the first retry, which doubles on
+	// every attempt.
+	_webhookBackoff = time.Second
+	// _webhookSignatureHeader is the header holding the signature of the body.
+	_webhookSignatureHeader = "X-Clidle-Signature"
+)
+
+// webhookEvent is the type of an event sent to the webhook.
+type webhookEvent string
+
+const (
+	// _webhookFirstSolve is sent when a player is the first to solve the
+	// daily puzzle.
+	_webhookFirstSolve webhookEvent = "daily_first_solve"
+	// _webhookHighScore is sent when a player takes the lead of the all-time
+	// top scores.
+	_webhookHighScore webhookEvent = "high_score"
+	// _webhookStreak is sent when a player wins _webhookStreakLength games
+	// in a row.
+	_webhookStreak webhookEvent = "streak"
+)
+
+// webhookPayload is the JSON body sent to the webhook. Fields that don't apply
+// to the event are left out.
+type webhookPayload struct {
+	Event       webhookEvent `json:"event"`
+	Player      string       `json:"player"`
+	Time        time.Time    `json:"time"`
+	Daily       string       `json:"daily,omitempty"`
+	DailyNumber int          `json:"daily_number,omitempty"`
+	Guesses     int          `json:"guesses,omitempty"`
+	Points      int          `json:"points,omitempty"`
+	Streak      int          `json:"streak,omitempty"`
+}
+
+// webhooks sends notable events on the server to a URL set by the operator.
+// Events are queued and sent by a goroutine of their own, so that games never
+// wait on a slow endpoint.
+type webhooks struct {
+	url     string
+	secret  string
+	enabled map[webhookEvent]bool
+	client  *http.Client
+	clock   clock
+	backoff time.Duration
+
+	// mu guards the queue against events notified after it is closed.
+	mu     sync.Mutex
+	closed bool
+	events chan webhookPayload
+	done   chan struct{}
+}
+
+// newWebhooks starts sending the enabled events to the URL. Bodies are signed
+// with the secret, unless it is empty.
+func newWebhooks(url, secret string, enabled map[webhookEvent]bool, clock clock) *webhooks {
+	w := &webhooks{
+		url:     url,
+		secret:  secret,
+		enabled: enabled,
+		client:  &http.Client{Timeout: 10 * time.Second},
+		clock:   clock,
+		backoff: _webhookBackoff,
+		events:  make(chan webhookPayload, _webhookQueueSize),
+		done:    make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+// notify queues an event, unless its type is disabled. If the queue is full or
+// closed, the event is dropped rather than waiting.
+func (w *webhooks) notify(payload webhookPayload) {
+	if !w.enabled[payload.Event] {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		slog.Warn("dropped webhook event, queue is closed", slog.String("event", string(payload.Event)))
+		return
+	}
+	select {
+	case w.events <- payload:
+	default:
+		slog.Warn("dropped webhook event, queue is full", slog.String("event", string(payload.Event)))
+	}
+}
+
+// close stops queuing events, and waits for the queued ones to be sent until
+// the context is done.
+func (w *webhooks) close(ctx context.Context) {
+	w.mu.Lock()
+	w.closed = true
+	close(w.events)
+	w.mu.Unlock()
+	select {
+	case <-w.done:
+	case <-ctx.Done():
+		slog.Warn("gave up sending webhook events", slog.Int("pending", len(w.events)))
+	}
+}
+
+func (w *webhooks) run() {
+	defer close(w.done)
+	for payload := range w.events {
+		if err := w.send(payload); err != nil {
+			slog.Error("error sending webhook", slog.String("event", string(payload.Event)), slog.Any("error", err))
+		}
+	}
+}
+
+// send posts an event to the webhook, retrying with exponential backoff on
+// network errors, server errors and rate limiting.
+func (w *webhooks) send(payload webhookPayload) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return errors.Wrap(err, "could not encode payload")
+	}
+
+	backoff := w.backoff
+	for attempt := 1; ; attempt++ {
+		retry, err := w.post(body)
+		if err == nil || !retry || attempt == _webhookMaxAttempts {
+			return err
+		}
+		<-w.clock.After(backoff)
+		backoff *= 2
+	}
+}
+
+// post makes a single attempt at sending a body to the webhook, and reports
+// whether a failed attempt is worth retrying.
+func (w *webhooks) post(body []byte) (retry bool, err error) {
+	req, err := http.NewRequest(http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return false, errors.Wrap(err, "could not create request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if w.secret != "" {
+		req.Header.Set(_webhookSignatureHeader, signWebhook(w.secret, body))
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return true, errors.Wrap(err, "could not reach webhook")
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+		return retry, errors.Errorf("webhook returned %s", resp.Status)
+	}
+	return false, nil
+}
+
+// signWebhook returns the signature of a body, as sent in
+// _webhookSignatureHeader: the hex-encoded HMAC-SHA256 of the body keyed with
+// the secret, prefixed with "sha256=".
+func signWebhook(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// notifyWebhooks queues a check, once the game is saved, for the events that
+// finishing it caused. Only won games can cause any, and never those of
+// players who prefer to be left out of the web leaderboard.
+func (m *model) notifyWebhooks() tea.Cmd {
+	if m.webhooks == nil || m.hideFromWeb || m.game.State() != game.StateWon {
+		return nil
+	}
+	base := webhookPayload{
+		Player:  m.playerName,
+		Time:    m.clock.Now(),
+		Guesses: len(m.game.Guesses()),
+	}
+	player, daily, dailyNumber, earned := m.player, m.daily, m.dailyNumber, m.earned()
+	return m.writes.enqueue(func() tea.Msg {
+		ctx, cancel := context.WithTimeout(m.writeCtx, 5*time.Second)
+		defer cancel()
+
+		if daily != "" {
+			solves, err := m.store.CountDailySolves(ctx, sql.NullString{String: daily, Valid: true})
+			if err != nil {
+				slog.Error("error counting daily solves", slog.Any("error", err))
+			} else if solves == 1 {
+				payload := base
+				payload.Event, payload.Daily, payload.DailyNumber = _webhookFirstSolve, daily, dailyNumber
+				m.webhooks.notify(payload)
+			}
+		}
+
+		// Scores and streaks are only tracked for players with a key.
+		if player == "" {
+			return nil
+		}
+		top, err := m.store.GetTopScores(ctx, 2)
+		if err != nil {
+			slog.Error("error fetching top scores", slog.Any("error", err))
+		} else if len(top) > 0 && top[0].Player.String == player && earned > 0 &&
+			(len(top) == 1 || int(top[0].Points)-earned <= int(top[1].Points)) {
+			payload := base
+			payload.Event, payload.Points = _webhookHighScore, int(top[0].Points)
+			m.webhooks.notify(payload)
+		}
+
+		results, err := listPlayerResults(ctx, m.store, player)
+		if err != nil {
+			slog.Error("error fetching game results", slog.Any("error", err))
+		} else if streak := computeStats(results).CurrentStreak; streak > 0 && streak%_webhookStreakLength == 0 {
+			payload := base
+			payload.Event, payload.Streak = _webhookStreak, streak
+			m.webhooks.notify(payload)
+		}
+		return nil
+	})
+}
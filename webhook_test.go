@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ajeetdsouza/clidle/pkg/game"
+)
+
+func TestWebhooks(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		attempts int
+		events   []webhookPayload
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		body, _ := io.ReadAll(r.Body)
+		if got, want := r.Header.Get(_webhookSignatureHeader), signWebhook("secret", body); got != want {
+			t.Errorf("signature = %q, want %q", got, want)
+		}
+		// The first attempt fails, so that the event has to be retried.
+		if attempts++; attempts == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var payload webhookPayload
+		if err := json.Unmarshal(body, &payload); err != nil {
+			t.Errorf("could not decode payload: %v", err)
+		}
+		events = append(events, payload)
+	}))
+	defer server.Close()
+
+	enabled := map[webhookEvent]bool{_webhookFirstSolve: true, _webhookHighScore: true, _webhookStreak: false}
+	webhooks := newWebhooks(server.URL, "secret", enabled, realClock{})
+	webhooks.backoff = time.Millisecond
+
+	m := newTestModel(t, "TRACE", 80, 40)
+	m.webhooks = webhooks
+	m.daily, m.dailyNumber = "2024-03-01", 61
+	m.player, m.playerName = "SHA256:alice", "alice"
+	m.startGame(m.game.Answer())
+	typeKeys(m, "trace\n")
+	if m.game.State() != game.StateWon {
+		t.Fatalf("expected the game to be won, got %v", m.game.State())
+	}
+	m.writes.flush()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	webhooks.close(ctx)
+
+	// Events notified once the queue is closed are dropped.
+	webhooks.notify(webhookPayload{Event: _webhookFirstSolve})
+
+	// Players who are left out of the web leaderboard are never announced.
+	m.hideFromWeb = true
+	if cmd := m.notifyWebhooks(); cmd != nil {
+		t.Error("expected no events for a hidden player")
+	}
+
+	// The first solve of the day is also the lead of the top scores, and the
+	// streak is both too short and disabled.
+	mu.Lock()
+	defer mu.Unlock()
+	if len(events) != 2 || attempts != 3 {
+		t.Fatalf("expected 2 events after 3 attempts, got %+v after %d", events, attempts)
+	}
+	if e := events[0]; e.Event != _webhookFirstSolve || e.Player != "alice" || e.DailyNumber != 61 || e.Guesses != 1 {
+		t.Errorf("unexpected first solve event: %+v", e)
+	}
+	if e := events[1]; e.Event != _webhookHighScore || e.Points != 100 {
+		t.Errorf("unexpected high score event: %+v", e)
+	}
+}
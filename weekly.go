@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/ajeetdsouza/clidle/pkg/game"
+	"github.com/ajeetdsouza/clidle/store"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// _weeklyDays is the number of days summarized by the weekly recap.
+const _weeklyDays = 7
+
+// weekly summarizes the daily puzzles of the last week.
+type weekly struct {
+	Days           [_weeklyDays]weeklyDay `json:"days"`
+	Completed      int                    `json:"completed"`
+	AverageGuesses float64                `json:"average_guesses"`
+	BestSolveTime  float64                `json:"best_solve_time_seconds"`
+	Points         int                    `json:"points"`
+}
+
+// weeklyDay is the result of the daily puzzle on a single day of the weekly
+// recap.
+type weeklyDay struct {
+	Date       string `json:"date"`
+	Played     bool   `json:"played"`
+	Won        bool   `json:"won"`
+	NumGuesses int    `json:"guesses,omitempty"`
+}
+
+// computeWeekly summarizes the daily puzzles of the week up to and including
+// the given date, from a list of games ordered from oldest to newest. Games are
+// grouped by the date of their puzzle rather than the time they were played,
+// and only the first completed attempt at each puzzle counts.
+func computeWeekly(results []store.ListGameResultsRow, today time.Time) weekly {
+	var w weekly
+	days := make(map[string]int, _weeklyDays)
+	for i := range w.Days {
+		date := today.AddDate(0, 0, i-_weeklyDays+1).Format(time.DateOnly)
+		w.Days[i].Date = date
+		days[date] = i
+	}
+
+	var numGuesses, numWon int
+	for _, result := range results {
+		idx, ok := days[result.Daily.String]
+		if !ok || !result.Daily.Valid || w.Days[idx].Played {
+			continue
+		}
+		if !isCompleted(result) {
+			continue
+		}
+
+		day := &w.Days[idx]
+		day.Played = true
+		day.Won = result.Won
+		w.Completed++
+		if !result.Won {
+			w.Points -= int(result.LossPenalty)
+			continue
+		}
+		day.NumGuesses = int(result.NumGuesses)
+		numGuesses += day.NumGuesses
+		numWon++
+		w.Points += max(0, game.Score(day.NumGuesses, true)-int(result.Penalty))
+		if result.StartedAt.Valid && result.FinishedAt.Valid {
+			duration := result.FinishedAt.Time.Sub(result.StartedAt.Time).Seconds()
+			if w.BestSolveTime == 0 || duration < w.BestSolveTime {
+				w.BestSolveTime = duration
+			}
+		}
+	}
+	if numWon > 0 {
+		w.AverageGuesses = float64(numGuesses) / float64(numWon)
+	}
+	return w
+}
+
+// weekdayInitial returns the first letter of the weekday of a date in the
+// weekly recap.
+func weekdayInitial(date string) string {
+	t, err := time.Parse(time.DateOnly, date)
+	if err != nil {
+		return "?"
+	}
+	return t.Weekday().String()[:1]
+}
+
+// writeWeeklyText prints the weekly recap, with a calendar strip in which
+// every day is shown whether or not it was played.
+func writeWeeklyText(w io.Writer, week weekly) error {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Week of %s to %s\n\n", week.Days[0].Date, week.Days[_weeklyDays-1].Date)
+	for _, day := range week.Days {
+		fmt.Fprintf(&sb, " %s", weekdayInitial(day.Date))
+	}
+	sb.WriteString("\n")
+	for _, day := range week.Days {
+		switch {
+		case day.Won:
+			sb.WriteString(" ■")
+		case day.Played:
+			sb.WriteString(" ×")
+		default:
+			sb.WriteString(" □")
+		}
+	}
+	sb.WriteString("\n\n")
+	fmt.Fprintf(&sb, "Dailies:         %d/%d\n", week.Completed, _weeklyDays)
+	if week.AverageGuesses > 0 {
+		fmt.Fprintf(&sb, "Average guesses: %.1f\n", week.AverageGuesses)
+	}
+	if week.BestSolveTime > 0 {
+		fmt.Fprintf(&sb, "Best solve:      %s\n", formatDuration(secondsToDuration(week.BestSolveTime)))
+	}
+	fmt.Fprintf(&sb, "Score earned:    %d\n", week.Points)
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// doShowWeekly shows the weekly recap, or hides it if it is already shown.
+func (m *model) doShowWeekly() tea.Cmd {
+	if !m.options.weeklyRecap {
+		return nil
+	}
+	if m.screen == screenWeekly {
+		m.closeScreen()
+		m.weekly = nil
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(m.ctx, 5*time.Second)
+	defer cancel()
+
+	results, err := m.store.ListGameResults(ctx)
+	if err != nil {
+		slog.Error("error fetching game results", slog.Any("error", err))
+		return m.setStatus("Couldn't load your week.", 2*time.Second)
+	}
+	week := computeWeekly(results, dailyDate(m.clock.Now(), m.options.dailyLocation))
+	m.weekly = &week
+	m.openScreen(screenWeekly)
+	return nil
+}
+
+// updateWeekly handles key presses while the weekly recap is shown. Any key
+// other than quitting closes it.
+func (m *model) updateWeekly(msg tea.KeyMsg) tea.Cmd {
+	if msg.Type == tea.KeyCtrlC {
+		return m.doExit()
+	}
+	m.closeScreen()
+	m.weekly = nil
+	return nil
+}
+
+// viewWeekly renders the weekly recap, including a border.
+func (m *model) viewWeekly() string {
+	week := m.weekly
+	initials := make([]string, _weeklyDays)
+	cells := make([]string, _weeklyDays)
+	for i, day := range week.Days {
+		initials[i] = m.styles.subtext.Render(weekdayInitial(day.Date))
+		switch {
+		case day.Won:
+			cells[i] = m.styles.opponentTiles[_keyStateCorrect]
+		case day.Played:
+			cells[i] = m.styles.opponentTiles[_keyStateWarning]
+		default:
+			cells[i] = m.styles.subtext.Render("□")
+		}
+	}
+
+	rows := []string{
+		m.styles.text.Render("This week"),
+		"",
+		strings.Join(initials, " "),
+		strings.Join(cells, " "),
+		"",
+		m.styles.subtext.Render(fmt.Sprintf("%-10s%d/%d", "Dailies", week.Completed, _weeklyDays)),
+	}
+	if week.AverageGuesses > 0 {
+		rows = append(rows, m.styles.subtext.Render(fmt.Sprintf("%-10s%.1f guesses", "Average", week.AverageGuesses)))
+	}
+	if week.BestSolveTime > 0 {
+		rows = append(rows, m.styles.subtext.Render(fmt.Sprintf("%-10s%s", "Best", formatDuration(secondsToDuration(week.BestSolveTime)))))
+	}
+	rows = append(rows, m.styles.subtext.Render(fmt.Sprintf("%-10s%d", "Score", week.Points)))
+	rows = m.appendPlaytime(rows)
+	return m.styles.box.Render(lipgloss.JoinVertical(lipgloss.Left, rows...))
+}
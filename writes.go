@@ -0,0 +1,42 @@
+package main
+
+import (
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// writeQueue runs database writes in the background, one at a time and in the
+// order in which they were queued, so that the UI never blocks on the
+// database.
+type writeQueue struct {
+	// last is closed once the most recently queued write has finished.
+	last chan struct{}
+}
+
+// enqueue starts the write once every previously queued write has finished,
+// and returns a tea.Cmd that delivers its result. It must only be called from
+// the UI goroutine.
+func (q *writeQueue) enqueue(write func() tea.Msg) tea.Cmd {
+	prev := q.last
+	done := make(chan struct{})
+	q.last = done
+
+	var msg tea.Msg
+	go func() {
+		defer close(done)
+		if prev != nil {
+			<-prev
+		}
+		msg = write()
+	}()
+	return func() tea.Msg {
+		<-done
+		return msg
+	}
+}
+
+// flush blocks until every queued write has finished.
+func (q *writeQueue) flush() {
+	if q.last != nil {
+		<-q.last
+	}
+}